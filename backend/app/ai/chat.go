@@ -139,7 +139,7 @@ func (a *API) ChatComplete(ctx context.Context, completion models.ChatCompletion
 		return "", err
 	}
 	usage.Usage = response.Usage
-	go payments.Bill(ctx, usage)
+	payments.BillAsync(ctx, usage)
 	return response.Choices[0].Message.Content, nil
 }
 
@@ -203,7 +203,7 @@ func (a *API) ChatCompleteStreaming(ctx context.Context, completion models.ChatM
 			cancelContext()
 
 			usage.Usage.TotalTokens = usage.Usage.PromptTokens + usage.Usage.CompletionTokens
-			go payments.Bill(ctx, usage)
+			payments.BillAsync(ctx, usage)
 			config.CONFIG.DataDogClient.Timing("openai.chat_complete_streaming.latency", time.Since(timeNow), []string{"model:" + completion.Model}, 1)
 			config.CONFIG.DataDogClient.Timing("openai.chat_complete_streaming.latency_per_token", time.Since(timeNow), []string{"model:" + completion.Model}, float64(usage.Usage.CompletionTokens))
 		}()
@@ -420,7 +420,7 @@ func (a *API) chatCompleteClaude(ctx context.Context, completion models.ChatComp
 	usage.Usage.PromptTokens = response.Usage.InputTokens
 	usage.Usage.CompletionTokens = response.Usage.OutputTokens
 
-	go payments.Bill(ctx, usage)
+	payments.BillAsync(ctx, usage)
 	return *response.Content[0].Text, nil
 }
 
@@ -479,7 +479,7 @@ func chatCompleteStreamingClaude(ctx context.Context, completion models.ChatMult
 			cancelContext()
 
 			usage.Usage.TotalTokens = usage.Usage.PromptTokens + usage.Usage.CompletionTokens
-			go payments.Bill(ctx, usage)
+			payments.BillAsync(ctx, usage)
 			config.CONFIG.DataDogClient.Timing("ai.chat_complete_streaming.latency", time.Since(timeNow), []string{"model:" + completion.Model}, 1)
 			config.CONFIG.DataDogClient.Timing("ai.chat_complete_streaming.latency_per_token", time.Since(timeNow), []string{"model:" + completion.Model}, float64(usage.Usage.CompletionTokens))
 		}()
@@ -1,6 +1,149 @@
 package fireworks
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/models"
+	"time"
+)
+
+// https://fireworks.ai/pricing
 const (
 	STABLEDIFFUSION3 float64 = 0.065
 	PLAYGROUND25     float64 = 0.01
 )
+
+const (
+	IMAGE_TIMEOUT = 90 * time.Second
+
+	// MAX_IMAGE_BYTES guards against unexpectedly large responses, a 1024px jpeg is well below 1MB
+	MAX_IMAGE_BYTES = 10 << 20
+)
+
+var HTTP_CLIENT = &http.Client{
+	Timeout: IMAGE_TIMEOUT,
+}
+
+// ImageGenerator generates images with a Fireworks hosted model, Stable Diffusion 3 or Playground 2.5
+type ImageGenerator struct {
+	model models.Engine
+	url   string
+}
+
+func NewImageGenerator(model models.Engine) *ImageGenerator {
+	return &ImageGenerator{model: model, url: "https://api.fireworks.ai/inference/v1/image_generation/" + string(model)}
+}
+
+func (g *ImageGenerator) Engine() models.Engine {
+	return g.model
+}
+
+func (g *ImageGenerator) PricePerImage(request models.ImageRequest) float64 {
+	if g.model == models.Playground25 {
+		return PLAYGROUND25
+	}
+	return STABLEDIFFUSION3
+}
+
+// sd3AspectRatios maps ratios SD3 lacks to the closest it has
+var sd3AspectRatios = map[string]string{"4:3": "5:4", "3:4": "4:5"}
+
+// playgroundSizes are about a megapixel, Playground is trained on these
+var playgroundSizes = map[string][2]int{
+	"1:1":  {1024, 1024},
+	"16:9": {1344, 768},
+	"9:16": {768, 1344},
+	"4:3":  {1152, 896},
+	"3:4":  {896, 1152},
+	"3:2":  {1216, 832},
+	"2:3":  {832, 1216},
+}
+
+// requestBody differs per model, SD3 takes an aspect ratio and Playground takes the size in pixels,
+// neither has an HD quality or styles
+func (g *ImageGenerator) requestBody(request models.ImageRequest) any {
+	aspectRatio := request.AspectRatio
+	if aspectRatio == "" {
+		aspectRatio = "1:1"
+	}
+	if g.model == models.Playground25 {
+		size, ok := playgroundSizes[aspectRatio]
+		if !ok {
+			size = playgroundSizes["1:1"]
+		}
+		return struct {
+			Prompt      string `json:"prompt"`
+			Height      int    `json:"height"`
+			Width       int    `json:"width"`
+			CfgScale    int    `json:"cfg_scale"`
+			Steps       int    `json:"steps"`
+			Samples     int    `json:"samples"`
+			Seed        int64  `json:"seed"`
+			SafetyCheck bool   `json:"safety_check"`
+		}{
+			Prompt:      request.Prompt,
+			Height:      size[1],
+			Width:       size[0],
+			CfgScale:    3,
+			Steps:       30,
+			Samples:     1,
+			Seed:        request.Seed,
+			SafetyCheck: true,
+		}
+	}
+	if closest, ok := sd3AspectRatios[aspectRatio]; ok {
+		aspectRatio = closest
+	}
+	return struct {
+		Prompt      string `json:"prompt"`
+		AspectRatio string `json:"aspect_ratio"`
+		Seed        int64  `json:"seed"`
+	}{
+		Prompt:      request.Prompt,
+		AspectRatio: aspectRatio,
+		Seed:        request.Seed,
+	}
+}
+
+func (g *ImageGenerator) Generate(ctx context.Context, request models.ImageRequest) ([]models.GeneratedImage, error) {
+	requestBodyJSON, err := json.Marshal(g.requestBody(request))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", g.url, bytes.NewBuffer(requestBodyJSON))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "image/jpeg")
+	req.Header.Set("Authorization", "Bearer "+config.CONFIG.FireworksAPIKey)
+
+	resp, err := HTTP_CLIENT.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(io.LimitReader(resp.Body, MAX_IMAGE_BYTES))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, responseBody)
+	}
+	// filtered images come back blank with a successful status
+	if resp.Header.Get("Finish-Reason") == "CONTENT_FILTERED" {
+		return nil, models.ErrImageContentPolicy
+	}
+	if len(responseBody) == 0 {
+		return nil, fmt.Errorf("empty image from %s", g.model)
+	}
+
+	return []models.GeneratedImage{{Data: responseBody}}, nil
+}
@@ -0,0 +1,42 @@
+package fireworks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageGeneratorGenerate(t *testing.T) {
+	config.CONFIG = &config.Config{FireworksAPIKey: "key"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		assert.Equal(t, "image/jpeg", r.Header.Get("Accept"))
+		body := map[string]any{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["prompt"] == "forbidden" {
+			w.Header().Set("Finish-Reason", "CONTENT_FILTERED")
+		}
+		w.Write([]byte("jpeg"))
+	}))
+	defer server.Close()
+
+	generator := NewImageGenerator(models.StableDiffusion3)
+	generator.url = server.URL
+	images, err := generator.Generate(context.Background(), models.ImageRequest{Prompt: "a cat"})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.GeneratedImage{{Data: []byte("jpeg")}}, images)
+	assert.Equal(t, STABLEDIFFUSION3, generator.PricePerImage(models.ImageRequest{}))
+
+	_, err = generator.Generate(context.Background(), models.ImageRequest{Prompt: "forbidden"})
+	assert.ErrorIs(t, err, models.ErrImageContentPolicy)
+
+	playground := NewImageGenerator(models.Playground25)
+	assert.Equal(t, "https://api.fireworks.ai/inference/v1/image_generation/accounts/fireworks/models/playground-v2-5-1024px-aesthetic", playground.url)
+	assert.Equal(t, PLAYGROUND25, playground.PricePerImage(models.ImageRequest{}))
+}
@@ -0,0 +1,61 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"talk2robots/m/v2/app/models"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DOWNLOAD_TIMEOUT = 60 * time.Second
+
+	// MAX_DOWNLOAD_BYTES is above the largest images engines return
+	MAX_DOWNLOAD_BYTES = 30 << 20
+)
+
+var DOWNLOAD_CLIENT = &http.Client{Timeout: DOWNLOAD_TIMEOUT}
+
+// Download fetches images engines return by URL, DALL-E URLs expire in an hour,
+// images that fail to download keep their URL
+func Download(ctx context.Context, generated []models.GeneratedImage) []models.GeneratedImage {
+	for i := range generated {
+		if generated[i].URL == "" || len(generated[i].Data) > 0 {
+			continue
+		}
+		data, err := download(ctx, generated[i].URL)
+		if err != nil {
+			log.Warnf("Failed to download generated image: %v", err)
+			continue
+		}
+		generated[i].Data = data
+	}
+	return generated
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := DOWNLOAD_CLIENT.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download image: %s", response.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, MAX_DOWNLOAD_BYTES+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MAX_DOWNLOAD_BYTES {
+		return nil, fmt.Errorf("image is larger than %d bytes", MAX_DOWNLOAD_BYTES)
+	}
+	return data, nil
+}
@@ -0,0 +1,149 @@
+// package to generate images with the engine selected by a user
+package images
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"talk2robots/m/v2/app/ai/fireworks"
+	"talk2robots/m/v2/app/ai/midjourney"
+	"talk2robots/m/v2/app/ai/openai"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/payments"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ImageProvider interface {
+	Engine() models.Engine
+	PricePerImage(request models.ImageRequest) float64
+	Generate(ctx context.Context, request models.ImageRequest) ([]models.GeneratedImage, error)
+}
+
+// ImageEditor redraws an image by the prompt, an optional mask limits the redrawn area
+type ImageEditor interface {
+	Engine() models.Engine
+	PricePerImage(request models.ImageRequest) float64
+	Edit(ctx context.Context, request models.ImageRequest) ([]models.GeneratedImage, error)
+}
+
+// ProviderFor returns the provider of an image engine, DALL-E is used for unknown engines
+// and for Midjourney when no proxy is configured
+func ProviderFor(engine models.Engine) ImageProvider {
+	switch engine {
+	case models.StableDiffusion3, models.Playground25:
+		return fireworks.NewImageGenerator(engine)
+	case models.Midjourney6:
+		if midjourney.IsConfigured(config.CONFIG) {
+			return midjourney.NewProxy(config.CONFIG)
+		}
+		log.Warnf("Midjourney proxy is not configured, using %s", models.DallE3)
+	}
+	return openai.NewDallE()
+}
+
+// EditorFor returns the editor of an image engine, engines that can't edit fall back to gpt-image-1
+func EditorFor(engine models.Engine) ImageEditor {
+	if editor, ok := ProviderFor(engine).(ImageEditor); ok {
+		return editor
+	}
+	return openai.NewImageEditor()
+}
+
+// EngineName is the name shown to users
+func EngineName(engine models.Engine) string {
+	switch engine {
+	case models.DallE3:
+		return "DALL-E 3"
+	case models.GptImage1:
+		return "GPT Image 1"
+	case models.Midjourney6:
+		return "Midjourney 6"
+	case models.StableDiffusion3:
+		return "Stable Diffusion 3"
+	case models.Playground25:
+		return "Playground 2.5"
+	}
+	return string(engine)
+}
+
+// Generate creates request.N images with the provider in parallel and bills them at the provider's price
+func Generate(ctx context.Context, provider ImageProvider, request models.ImageRequest) ([]models.GeneratedImage, error) {
+	started := time.Now()
+	images, err := each(ctx, request, provider.Generate)
+	return billImages(ctx, "image.latency", started, provider.Engine(), provider.PricePerImage(request), images, err)
+}
+
+// Edit redraws the request image request.N times with the editor and bills the results at the editor's price
+func Edit(ctx context.Context, editor ImageEditor, request models.ImageRequest) ([]models.GeneratedImage, error) {
+	started := time.Now()
+	images, err := each(ctx, request, editor.Edit)
+	return billImages(ctx, "image.edit.latency", started, editor.Engine(), editor.PricePerImage(request), images, err)
+}
+
+// each requests images one by one, engines differ in how many images they draw at once,
+// a seed is incremented for every image so they differ and can be reproduced.
+// Images that were drawn are returned even if others failed
+func each(ctx context.Context, request models.ImageRequest, draw func(context.Context, models.ImageRequest) ([]models.GeneratedImage, error)) ([]models.GeneratedImage, error) {
+	n := max(request.N, 1)
+	results := make([][]models.GeneratedImage, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			single := request
+			single.N = 1
+			if single.Seed > 0 {
+				single.Seed += int64(i)
+			}
+			results[i], errs[i] = draw(ctx, single)
+		}(i)
+	}
+	wg.Wait()
+
+	images := []models.GeneratedImage{}
+	for _, result := range results {
+		images = append(images, result...)
+	}
+	if len(images) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		if err != nil {
+			log.Warnf("Failed to draw one of %d images: %v", n, err)
+		}
+	}
+	return images, nil
+}
+
+// billImages bills generated images and reports the latency by outcome
+func billImages(ctx context.Context, metric string, started time.Time, engine models.Engine, price float64, images []models.GeneratedImage, err error) ([]models.GeneratedImage, error) {
+	status := "status:ok"
+	defer func() {
+		config.CONFIG.DataDogClient.Timing(metric, time.Since(started), []string{status, "model:" + string(engine)}, 1)
+	}()
+	if err != nil {
+		status = "status:error"
+		if errors.Is(err, models.ErrImageContentPolicy) {
+			status = "status:content_policy"
+		}
+		return nil, err
+	}
+	if len(images) == 0 {
+		status = "status:empty"
+		return nil, errors.New("no images generated")
+	}
+
+	payments.BillAsync(ctx, models.CostAndUsage{
+		Engine:     engine,
+		ImagePrice: price,
+		Usage: models.Usage{
+			ImagesCount: len(images),
+		},
+	})
+	return images, nil
+}
@@ -0,0 +1,68 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEach(t *testing.T) {
+	var mutex sync.Mutex
+	seeds := []int64{}
+	draw := func(ctx context.Context, request models.ImageRequest) ([]models.GeneratedImage, error) {
+		assert.Equal(t, 1, request.N)
+		mutex.Lock()
+		defer mutex.Unlock()
+		seeds = append(seeds, request.Seed)
+		if request.Seed == 43 {
+			return nil, errors.New("timeout")
+		}
+		return []models.GeneratedImage{{URL: fmt.Sprint(request.Seed)}}, nil
+	}
+
+	// every image has its own seed, failed ones are skipped
+	images, err := each(context.Background(), models.ImageRequest{Prompt: "a fox", ImageOptions: models.ImageOptions{N: 3, Seed: 42}}, draw)
+	assert.NoError(t, err)
+	assert.Len(t, images, 2)
+	sort.Slice(seeds, func(i, j int) bool { return seeds[i] < seeds[j] })
+	assert.Equal(t, []int64{42, 43, 44}, seeds)
+
+	_, err = each(context.Background(), models.ImageRequest{Prompt: "a fox"}, func(ctx context.Context, request models.ImageRequest) ([]models.GeneratedImage, error) {
+		return nil, models.ErrImageContentPolicy
+	})
+	assert.True(t, errors.Is(err, models.ErrImageContentPolicy))
+}
+
+func TestEditorFor(t *testing.T) {
+	assert.Equal(t, models.GptImage1, EditorFor(models.DallE3).Engine())
+	assert.Equal(t, models.GptImage1, EditorFor(models.StableDiffusion3).Engine())
+}
+
+func TestDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/expired.png" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("png"))
+	}))
+	defer server.Close()
+
+	generated := Download(context.Background(), []models.GeneratedImage{
+		{URL: server.URL + "/image.png"},
+		{URL: server.URL + "/expired.png"},
+		{Data: []byte("jpg")},
+	})
+	assert.Equal(t, []byte("png"), generated[0].Data)
+	assert.Empty(t, generated[1].Data)
+	assert.Equal(t, server.URL+"/expired.png", generated[1].URL)
+	assert.Equal(t, []byte("jpg"), generated[2].Data)
+}
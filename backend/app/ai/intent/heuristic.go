@@ -0,0 +1,222 @@
+package intent
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// HEURISTIC_WORDS are looked at, requests come first: "draw a cat", "нарисуй кота", "צייר חתול"
+	HEURISTIC_WORDS = 6
+
+	// LONG_TEXT is a text worth summarizing rather than a question about summaries
+	LONG_TEXT = 280
+)
+
+// lexicon lists words of an intent in supported languages, exact words or stems of inflected ones
+type lexicon struct {
+	words    []string
+	prefixes []string
+}
+
+func (l lexicon) matches(word string) bool {
+	for _, w := range l.words {
+		if word == w {
+			return true
+		}
+	}
+	for _, prefix := range l.prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageVerbs ask to draw: en, ru, uk, es, pt, fr, de, it, pl, tr, he, ar
+var imageVerbs = lexicon{
+	words: []string{
+		"draw", "paint", "sketch", "illustrate", "render",
+		"dibuja", "dibújame", "dibujame", "pinta", "ilustra", "desenhe", "desenha", "pinte", "dessine", "dessinez", "peins",
+		"zeichne", "zeichnen", "disegna", "dipingi", "narysuj", "çiz", "ciz",
+		"צייר", "ציירי", "תצייר", "תציירי", "ציירו", "ارسم", "ارسمي",
+	},
+	prefixes: []string{"нарису", "нарисов", "намалю", "изобрази", "зобрази"},
+}
+
+// imageSoftVerbs often ask to draw, but not always: "imagine a city on Mars" vs "imagine you are a pirate"
+var imageSoftVerbs = lexicon{
+	words:    []string{"imagine", "visualize", "imagina", "imaginez", "stell", "immagina", "דמיין", "תדמיין"},
+	prefixes: []string{"представь", "уяви", "визуализ"},
+}
+
+// imageNouns are images themselves, they ask to draw along with a request word only
+var imageNouns = lexicon{
+	words: []string{
+		"image", "picture", "drawing", "painting", "illustration", "art", "artwork", "logo", "wallpaper",
+		"imagen", "dibujo", "imagem", "desenho", "dessin", "bild", "zeichnung", "immagine", "disegno", "obrazek", "resim", "görsel",
+		"תמונה", "ציור", "איור", "صورة", "رسمة",
+	},
+	prefixes: []string{"картин", "изображени", "рисун", "малюн", "зображен"},
+}
+
+// imageRequests make a noun a request: "can you create an image", "хочу картинку", "quiero una imagen"
+var imageRequests = lexicon{
+	words: []string{
+		"create", "make", "generate", "give", "show", "want", "like", "need", "can", "could", "would", "you", "please",
+		"хочу", "можешь", "покажи", "дай", "crea", "genera", "hazme", "quiero", "puedes", "crie", "gere", "quero", "crée", "génère",
+		"erstelle", "generiere", "צור", "תיצור", "תעשה", "אפשר", "רוצה",
+	},
+	prefixes: []string{"созда", "сдела", "сгенери", "генери", "створ", "зроб"},
+}
+
+// textStops are about texts and other media, not images: "image of my homework", "write an essay about this picture"
+var textStops = lexicon{
+	words: []string{
+		"write", "article", "dont", "work", "working", "job", "jobs", "task", "tasks", "assignment", "assignments", "homework", "homeworks",
+		"essay", "essays", "report", "reports", "paper", "papers", "document", "documents", "text", "message", "letter", "email",
+		"conversation", "speak", "speech", "video",
+		"напиши", "статью", "эссе", "текст", "письмо", "видео",
+		"escribe", "texto", "artículo", "tarea", "כתוב", "תכתוב", "מאמר", "שיעורי",
+	},
+	prefixes: []string{"домашн", "напис"},
+}
+
+// questionStops ask about an image rather than for one: "what do you think about this picture"
+var questionStops = lexicon{
+	words: []string{
+		"think", "talk", "describe", "explain", "what", "whats", "why", "how", "who", "where",
+		"опиши", "объясни", "что", "почему", "как", "где", "кто",
+		"explica", "qué", "cómo", "תאר", "הסבר", "מה", "למה", "איך",
+	},
+}
+
+// demonstratives before a noun point at an image that exists: "this image", "эту картинку"
+var demonstratives = lexicon{
+	words: []string{"this", "that", "these", "эту", "это", "эта", "этой", "esta", "este", "esa", "ese", "cette", "dieses", "diese", "questa", "הזו", "הזאת", "הזה"},
+}
+
+var searchVerbs = lexicon{
+	words: []string{
+		"search", "google", "lookup", "busca", "buscar", "búscame", "pesquise", "pesquisa", "procure", "cherche", "recherche",
+		"suche", "szukaj", "חפש", "תחפש", "חפשי", "ابحث",
+	},
+	prefixes: []string{"поищ", "загугл", "погугл", "пошук", "знайди"},
+}
+
+var searchNouns = lexicon{
+	words:    []string{"news", "latest", "today", "noticias", "notícias", "actualités", "nachrichten", "notizie", "חדשות", "أخبار"},
+	prefixes: []string{"новост", "сегодня", "свіж"},
+}
+
+var translateVerbs = lexicon{
+	words: []string{
+		"translate", "translation", "traduce", "traducir", "tradúceme", "traduceme", "traduza", "traduz", "traduis", "traduire",
+		"übersetze", "übersetzen", "traduci", "przetłumacz", "çevir", "תרגם", "תתרגם", "תרגמי", "ترجم",
+	},
+	prefixes: []string{"переве", "перекла"},
+}
+
+var summarizeVerbs = lexicon{
+	words: []string{
+		"summarize", "summarise", "summary", "tldr", "resume", "resumen", "resumir", "resúmeme", "resuma", "résume", "résumer",
+		"zusammenfassen", "zusammenfassung", "riassumi", "podsumuj", "özetle", "סכם", "תסכם", "סכמי", "תקציר", "لخص",
+	},
+	prefixes: []string{"переска", "резюмир", "суммир", "кратк", "підсум"},
+}
+
+// Heuristic classifies by words of supported languages, a confidence below HIGH_CONFIDENCE is worth asking a model
+func Heuristic(text string) Result {
+	words := normalizedWords(text, HEURISTIC_WORDS)
+	if len(words) == 0 {
+		return Result{Intent: Chat, Confidence: 1, Source: SourceHeuristic}
+	}
+
+	best := Result{Intent: Chat, Confidence: 0, Source: SourceHeuristic}
+	consider := func(intent Intent, confidence float64) {
+		if confidence > best.Confidence {
+			best = Result{Intent: intent, Confidence: confidence, Source: SourceHeuristic}
+		}
+	}
+
+	consider(Image, imageConfidence(words))
+	for i, word := range words {
+		if i < 2 && searchVerbs.matches(word) {
+			consider(Search, 0.9)
+		}
+		if searchNouns.matches(word) {
+			consider(Search, 0.6)
+		}
+		if i < 3 && translateVerbs.matches(word) {
+			consider(Translate, 0.9)
+		}
+		if i < 3 && summarizeVerbs.matches(word) {
+			confidence := 0.7
+			if len([]rune(text)) > LONG_TEXT || strings.Contains(strings.TrimSpace(text), "\n") {
+				confidence = 0.9
+			}
+			consider(Summarize, confidence)
+		}
+	}
+	return best
+}
+
+// imageConfidence trusts verbs, nouns need a request and no sign the image already exists
+func imageConfidence(words []string) float64 {
+	verb, soft, noun, request, question, existing := false, false, false, false, false, false
+	for i, word := range words {
+		if textStops.matches(word) {
+			return 0
+		}
+		verb = verb || imageVerbs.matches(word)
+		soft = soft || imageSoftVerbs.matches(word)
+		request = request || imageRequests.matches(word)
+		question = question || questionStops.matches(word)
+		if imageNouns.matches(word) {
+			noun = true
+			existing = existing || (i > 0 && demonstratives.matches(words[i-1]))
+		}
+	}
+	switch {
+	case verb:
+		return 0.9
+	case soft && !question:
+		return 0.75
+	case noun && (question || existing):
+		return 0
+	case noun && request:
+		return 0.85
+	case noun:
+		return 0.5
+	}
+	return 0
+}
+
+// normalizedWords lowercases the text and drops punctuation, keeping up to max words
+func normalizedWords(text string, max int) []string {
+	clean := strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return -1
+		}
+		return r
+	}, strings.ToLower(text))
+	words := strings.Fields(clean)
+	if len(words) > max {
+		words = words[:max]
+	}
+	return words
+}
+
+// isCovered tells if words lists know the language of the text: Latin, Cyrillic, Hebrew and Arabic scripts,
+// texts in other scripts are classified by a model
+func isCovered(text string) bool {
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if !unicode.In(r, unicode.Latin, unicode.Cyrillic, unicode.Hebrew, unicode.Arabic) {
+			return false
+		}
+	}
+	return true
+}
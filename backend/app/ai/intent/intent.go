@@ -0,0 +1,190 @@
+// package to route messages in any language to image generation, web search, translation, summaries or a chat
+package intent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/models"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Intent string
+
+const (
+	Chat      Intent = "chat"
+	Image     Intent = "image"
+	Search    Intent = "search"
+	Translate Intent = "translate"
+	Summarize Intent = "summarize"
+)
+
+type Source string
+
+const (
+	SourceHeuristic Source = "heuristic"
+	SourceModel     Source = "model"
+	SourceCache     Source = "cache"
+)
+
+const (
+	MODEL = models.ChatGpt4oMini
+
+	// HIGH_CONFIDENCE heuristic results are trusted, less confident ones are checked by a model
+	HIGH_CONFIDENCE = 0.85
+
+	// LOW_CONFIDENCE heuristic results are chats, unless the heuristic doesn't know the script of the text
+	LOW_CONFIDENCE = 0.4
+
+	MAX_CLASSIFIED_TEXT = 500
+	MAX_TOKENS          = 40
+	CACHE_TTL           = 24 * time.Hour
+)
+
+// Thresholds are confidences messages are routed from, a wrong search or translation costs the user an answer
+var Thresholds = map[Intent]float64{
+	Image:     0.7,
+	Search:    0.75,
+	Translate: 0.8,
+	Summarize: 0.8,
+}
+
+const INSTRUCTIONS = `Classify the intent of a message to an AI assistant, the message may be in any language.
+Intents:
+image - asks to draw, paint or generate a picture
+search - needs fresh information from the web: news, prices, weather, schedules, recent events
+translate - asks to translate a text
+summarize - asks to summarize or shorten a text
+chat - anything else, including questions about existing images, texts or words
+Reply with JSON only: {"intent": "<intent>", "confidence": <0 to 1>, "language": "<ISO 639-1 code of the target language of a translation, empty otherwise>"}`
+
+var ErrUnknownIntent = errors.New("unknown intent")
+
+type Result struct {
+	Intent     Intent  `json:"intent"`
+	Confidence float64 `json:"confidence"`
+
+	// Language is the target language code of a translation, e.g. "es"
+	Language string `json:"language,omitempty"`
+	Source   Source `json:"-"`
+}
+
+// Routed tells if a message is confident enough to leave the chat
+func (r Result) Routed() bool {
+	threshold, ok := Thresholds[r.Intent]
+	return ok && r.Confidence >= threshold
+}
+
+type CompleteFunc func(ctx context.Context, completion models.ChatCompletion) (string, error)
+
+type Router struct {
+	complete CompleteFunc
+}
+
+func NewRouter(complete CompleteFunc) *Router {
+	return &Router{complete: complete}
+}
+
+// Route classifies a message with the heuristic, ambiguous messages and messages in scripts
+// the heuristic doesn't know are classified by a model, model answers are cached
+func (r *Router) Route(ctx context.Context, text string) Result {
+	result := Heuristic(text)
+	if result.Confidence >= HIGH_CONFIDENCE || (result.Confidence < LOW_CONFIDENCE && isCovered(text)) {
+		return record(result)
+	}
+	classified, err := r.classify(ctx, text)
+	if err != nil {
+		log.Warnf("Failed to classify intent, using %s %.2f: %v", result.Intent, result.Confidence, err)
+		config.CONFIG.DataDogClient.Incr("intent.model_failed", nil, 1)
+		return record(result)
+	}
+	return record(classified)
+}
+
+func (r *Router) classify(ctx context.Context, text string) (Result, error) {
+	key := cacheKey(text)
+	if cached, err := redis.RedisClient.Get(ctx, key).Result(); err == nil && cached != "" {
+		result, err := parseResult(cached)
+		if err == nil {
+			result.Source = SourceCache
+			return result, nil
+		}
+	}
+
+	started := time.Now()
+	reply, err := r.complete(ctx, models.ChatCompletion{
+		Model: string(MODEL),
+		Messages: []models.Message{
+			{Role: "system", Content: INSTRUCTIONS},
+			{Role: "user", Content: truncate(text)},
+		},
+		MaxTokens: MAX_TOKENS,
+	})
+	config.CONFIG.DataDogClient.Timing("intent.model.latency", time.Since(started), nil, 1)
+	if err != nil {
+		return Result{}, err
+	}
+	result, err := parseResult(reply)
+	if err != nil {
+		return Result{}, err
+	}
+	result.Source = SourceModel
+
+	cached, _ := json.Marshal(result)
+	err = redis.RedisClient.Set(ctx, key, string(cached), CACHE_TTL).Err()
+	if err != nil {
+		log.Warnf("Failed to cache intent: %v", err)
+	}
+	return result, nil
+}
+
+// parseResult reads the model's JSON, possibly wrapped in a code block
+func parseResult(reply string) (Result, error) {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return Result{}, fmt.Errorf("no JSON in %q", reply)
+	}
+	var result Result
+	err := json.Unmarshal([]byte(reply[start:end+1]), &result)
+	if err != nil {
+		return Result{}, err
+	}
+	result.Intent = Intent(strings.ToLower(strings.TrimSpace(string(result.Intent))))
+	if _, ok := Thresholds[result.Intent]; !ok && result.Intent != Chat {
+		return Result{}, fmt.Errorf("%w: %s", ErrUnknownIntent, result.Intent)
+	}
+	result.Confidence = min(max(result.Confidence, 0), 1)
+	result.Language = strings.ToLower(strings.TrimSpace(result.Language))
+	return result, nil
+}
+
+func record(result Result) Result {
+	config.CONFIG.DataDogClient.Incr("intent.classified", []string{
+		"intent:" + string(result.Intent),
+		"source:" + string(result.Source),
+		fmt.Sprintf("routed:%t", result.Routed()),
+	}, 1)
+	return result
+}
+
+func cacheKey(text string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(truncate(text))))
+	return "intent:" + hex.EncodeToString(hash[:16])
+}
+
+func truncate(text string) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) > MAX_CLASSIFIED_TEXT {
+		return string(runes[:MAX_CLASSIFIED_TEXT])
+	}
+	return string(runes)
+}
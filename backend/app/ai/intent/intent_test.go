@@ -0,0 +1,156 @@
+package intent
+
+import (
+	"context"
+	"errors"
+	"log"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	testClient, err := statsd.New("127.0.0.1:8125", statsd.WithNamespace("tests."))
+	if err != nil {
+		log.Fatalf("error creating test DataDog client: %v", err)
+	}
+	config.CONFIG = &config.Config{
+		DataDogClient: testClient,
+	}
+}
+
+func TestHeuristicImage(t *testing.T) {
+	prompts := []string{
+		"Can you create a drawing of a sunset?",
+		"Can you create an image of a sunset?",
+		"Draw, please, an image of a cat",
+		"I'd like a picture! Of a mountain",
+		"Imagine this image: a futuristic city",
+		"Cam you creete an image of a sunset?",
+		"Sketch a picture of a cat",
+		"нарисуй кота в сапогах",
+		"Сделай картинку с закатом",
+		"намалюй кота",
+		"dibuja un gato que vuela",
+		"quiero una imagen de un perro",
+		"dessine un chat",
+		"zeichne eine Katze",
+		"צייר חתול",
+		"ارسم قطة",
+	}
+
+	for _, prompt := range prompts {
+		result := Heuristic(prompt)
+		assert.Equal(t, Image, result.Intent, prompt)
+		assert.True(t, result.Routed(), prompt)
+	}
+}
+
+func TestHeuristicNotImage(t *testing.T) {
+	prompts := []string{
+		"Imagene, please, an article about a cat",
+		"I'd like a video! Of a mountain",
+		"Don't you think this image is beautiful?",
+		"Can we talk about what you think about this image?",
+		"image of my homework",
+		"write an essay about this picture",
+		"что ты думаешь об этой картинке?",
+		"напиши письмо про картину",
+		"imagine you are a pirate, what would you do?",
+		`🗣:
+		Перечисли источники, из которых ты взял информацию.
+		`,
+	}
+
+	for _, prompt := range prompts {
+		result := Heuristic(prompt)
+		assert.False(t, result.Intent == Image && result.Routed(), prompt)
+	}
+}
+
+func TestHeuristicOtherIntents(t *testing.T) {
+	tests := []struct {
+		text   string
+		intent Intent
+	}{
+		{"search for flights to Lisbon", Search},
+		{"поищи рецепт борща", Search},
+		{"translate to Spanish: good morning", Translate},
+		{"переведи на английский: доброе утро", Translate},
+		{"תרגם לאנגלית: בוקר טוב", Translate},
+		{"summarize:\nThe meeting covered the budget and the roadmap.", Summarize},
+		{"how are you?", Chat},
+		{"", Chat},
+	}
+
+	for _, test := range tests {
+		result := Heuristic(test.text)
+		assert.Equal(t, test.intent, result.Intent, test.text)
+		if test.intent != Chat {
+			assert.True(t, result.Routed(), test.text)
+		}
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	result, err := parseResult("```json\n{\"intent\": \"Translate\", \"confidence\": 1.4, \"language\": \"ES\"}\n```")
+	assert.NoError(t, err)
+	assert.Equal(t, Translate, result.Intent)
+	assert.Equal(t, 1.0, result.Confidence)
+	assert.Equal(t, "es", result.Language)
+
+	_, err = parseResult(`{"intent": "weather", "confidence": 0.9}`)
+	assert.ErrorIs(t, err, ErrUnknownIntent)
+
+	_, err = parseResult("image")
+	assert.Error(t, err)
+}
+
+func TestRouteTrustsConfidentHeuristic(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	calls := 0
+	router := NewRouter(func(ctx context.Context, completion models.ChatCompletion) (string, error) {
+		calls++
+		return `{"intent": "chat", "confidence": 1}`, nil
+	})
+
+	assert.Equal(t, Image, router.Route(context.Background(), "draw a cat").Intent)
+	assert.Equal(t, Chat, router.Route(context.Background(), "how are you?").Intent)
+	assert.Equal(t, 0, calls)
+}
+
+func TestRouteClassifiesAndCaches(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	calls := 0
+	router := NewRouter(func(ctx context.Context, completion models.ChatCompletion) (string, error) {
+		calls++
+		assert.Equal(t, string(MODEL), completion.Model)
+		return `{"intent": "image", "confidence": 0.95}`, nil
+	})
+
+	result := router.Route(context.Background(), "猫の絵を描いて")
+	assert.Equal(t, Image, result.Intent)
+	assert.Equal(t, SourceModel, result.Source)
+	assert.True(t, result.Routed())
+
+	result = router.Route(context.Background(), "猫の絵を描いて")
+	assert.Equal(t, Image, result.Intent)
+	assert.Equal(t, SourceCache, result.Source)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRouteFallsBackToHeuristic(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	router := NewRouter(func(ctx context.Context, completion models.ChatCompletion) (string, error) {
+		return "", errors.New("timeout")
+	})
+
+	result := router.Route(context.Background(), "a picture of the sea")
+	assert.Equal(t, Image, result.Intent)
+	assert.Equal(t, SourceHeuristic, result.Source)
+	assert.False(t, result.Routed())
+}
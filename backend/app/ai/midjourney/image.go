@@ -1,5 +1,184 @@
 package midjourney
 
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/models"
+	"time"
+)
+
 const (
 	MIDJOURNEY6 float64 = 0.02
 )
+
+const (
+	// POLL_INTERVAL between task status checks, a fast mode imagine takes 30-60 seconds
+	POLL_INTERVAL = 3 * time.Second
+	TASK_TIMEOUT  = 3 * time.Minute
+)
+
+var ErrNotConfigured = errors.New("midjourney proxy is not configured")
+
+var HTTP_CLIENT = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// Proxy generates images through a midjourney-proxy compatible API (https://github.com/novicezk/midjourney-proxy),
+// Midjourney has no public API, the proxy drives a Discord account
+type Proxy struct {
+	url          string
+	secret       string
+	pollInterval time.Duration
+}
+
+func NewProxy(cfg *config.Config) *Proxy {
+	return &Proxy{
+		url:          strings.TrimSuffix(cfg.MidjourneyProxyURL, "/"),
+		secret:       cfg.MidjourneyProxySecret,
+		pollInterval: POLL_INTERVAL,
+	}
+}
+
+// IsConfigured is false without a proxy URL, Midjourney isn't offered then
+func IsConfigured(cfg *config.Config) bool {
+	return cfg != nil && cfg.MidjourneyProxyURL != ""
+}
+
+func (p *Proxy) Engine() models.Engine {
+	return models.Midjourney6
+}
+
+func (p *Proxy) PricePerImage(request models.ImageRequest) float64 {
+	return MIDJOURNEY6
+}
+
+type task struct {
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+	ImageURL   string `json:"imageUrl"`
+	FailReason string `json:"failReason"`
+	Progress   string `json:"progress"`
+}
+
+func (p *Proxy) Generate(ctx context.Context, request models.ImageRequest) ([]models.GeneratedImage, error) {
+	return p.imagine(ctx, imagineRequest{Prompt: imaginePrompt(request)})
+}
+
+// imaginePrompt puts options as Midjourney parameters after the prompt, a natural style is the raw style
+func imaginePrompt(request models.ImageRequest) string {
+	prompt := request.Prompt
+	if request.AspectRatio != "" && request.AspectRatio != "1:1" {
+		prompt += " --ar " + request.AspectRatio
+	}
+	if request.HD {
+		prompt += " --q 2"
+	}
+	if request.Style == "natural" {
+		prompt += " --style raw"
+	}
+	if request.Seed > 0 {
+		prompt += fmt.Sprintf(" --seed %d", request.Seed)
+	}
+	return prompt
+}
+
+// Edit uses the image as an image prompt, Midjourney redraws it in the style of the prompt,
+// the proxy can't vary a region so the mask is ignored
+func (p *Proxy) Edit(ctx context.Context, request models.ImageRequest) ([]models.GeneratedImage, error) {
+	image := "data:" + http.DetectContentType(request.Image) + ";base64," + base64.StdEncoding.EncodeToString(request.Image)
+	return p.imagine(ctx, imagineRequest{Prompt: imaginePrompt(request), Base64Array: []string{image}})
+}
+
+type imagineRequest struct {
+	Prompt      string   `json:"prompt"`
+	Base64Array []string `json:"base64Array,omitempty"`
+}
+
+func (p *Proxy) imagine(ctx context.Context, request imagineRequest) ([]models.GeneratedImage, error) {
+	if p.url == "" {
+		return nil, ErrNotConfigured
+	}
+	ctx, cancel := context.WithTimeout(ctx, TASK_TIMEOUT)
+	defer cancel()
+
+	submitted := struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+		Result      string `json:"result"`
+	}{}
+	err := p.do(ctx, "POST", "/mj/submit/imagine", request, &submitted)
+	if err != nil {
+		return nil, err
+	}
+	// 1 is submitted, 22 is queued
+	if submitted.Code != 1 && submitted.Code != 22 {
+		if strings.Contains(strings.ToLower(submitted.Description), "banned") {
+			return nil, models.ErrImageContentPolicy
+		}
+		return nil, fmt.Errorf("midjourney task not submitted: %d %s", submitted.Code, submitted.Description)
+	}
+
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("midjourney task %s: %w", submitted.Result, ctx.Err())
+		case <-ticker.C:
+		}
+		var status task
+		if err := p.do(ctx, "GET", "/mj/task/"+submitted.Result+"/fetch", nil, &status); err != nil {
+			return nil, err
+		}
+		switch status.Status {
+		case "SUCCESS":
+			return []models.GeneratedImage{{URL: status.ImageURL}}, nil
+		case "FAILURE":
+			if strings.Contains(strings.ToLower(status.FailReason), "banned") {
+				return nil, models.ErrImageContentPolicy
+			}
+			return nil, fmt.Errorf("midjourney task %s failed: %s", submitted.Result, status.FailReason)
+		}
+	}
+}
+
+func (p *Proxy) do(ctx context.Context, method string, path string, body any, response any) error {
+	var reader io.Reader
+	if body != nil {
+		requestBodyJSON, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewBuffer(requestBodyJSON)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.secret != "" {
+		req.Header.Set("mj-api-secret", p.secret)
+	}
+
+	resp, err := HTTP_CLIENT.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, responseBody)
+	}
+	return json.Unmarshal(responseBody, response)
+}
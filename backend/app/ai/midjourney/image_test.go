@@ -0,0 +1,63 @@
+package midjourney
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyGenerate(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("mj-api-secret"))
+		switch r.URL.Path {
+		case "/mj/submit/imagine":
+			body := imagineRequest{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Prompt == "as watercolor" {
+				assert.Equal(t, []string{"data:image/png;base64,iVBORw0KGgo="}, body.Base64Array)
+			}
+			if body.Prompt == "forbidden" {
+				w.Write([]byte(`{"code":24,"description":"banned prompt"}`))
+				return
+			}
+			w.Write([]byte(`{"code":1,"description":"Submitted","result":"42"}`))
+		case "/mj/task/42/fetch":
+			fetches++
+			if fetches < 2 {
+				w.Write([]byte(`{"id":"42","status":"IN_PROGRESS","progress":"50%"}`))
+				return
+			}
+			w.Write([]byte(`{"id":"42","status":"SUCCESS","imageUrl":"https://cdn.example.com/42.png"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	proxy := NewProxy(&config.Config{MidjourneyProxyURL: server.URL + "/", MidjourneyProxySecret: "secret"})
+	proxy.pollInterval = 10 * time.Millisecond
+
+	images, err := proxy.Generate(context.Background(), models.ImageRequest{Prompt: "a cat on a bicycle"})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.GeneratedImage{{URL: "https://cdn.example.com/42.png"}}, images)
+	assert.Equal(t, 2, fetches)
+
+	fetches = 0
+	images, err = proxy.Edit(context.Background(), models.ImageRequest{Prompt: "as watercolor", Image: []byte("\x89PNG\r\n\x1a\n")})
+	assert.NoError(t, err)
+	assert.Len(t, images, 1)
+
+	_, err = proxy.Generate(context.Background(), models.ImageRequest{Prompt: "forbidden"})
+	assert.ErrorIs(t, err, models.ErrImageContentPolicy)
+
+	_, err = NewProxy(&config.Config{}).Generate(context.Background(), models.ImageRequest{Prompt: "a cat"})
+	assert.Equal(t, ErrNotConfigured, err)
+}
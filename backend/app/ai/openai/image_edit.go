@@ -0,0 +1,152 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/models"
+)
+
+// https://openai.com/api/pricing, per output image, input image tokens are small in comparison
+const (
+	GPTIMAGE1_M      float64 = 0.042
+	GPTIMAGE1_M_WIDE float64 = 0.063
+	GPTIMAGE1_H      float64 = 0.167
+	GPTIMAGE1_H_WIDE float64 = 0.25
+)
+
+// ImageEditor edits images with gpt-image-1, DALL-E 3 can't edit
+type ImageEditor struct {
+	url string
+}
+
+func NewImageEditor() *ImageEditor {
+	return &ImageEditor{url: "https://api.openai.com/v1/images/edits"}
+}
+
+func (e *ImageEditor) Engine() models.Engine {
+	return models.GptImage1
+}
+
+func (e *ImageEditor) PricePerImage(request models.ImageRequest) float64 {
+	wide := gptImageSize(request.ImageOptions) != "1024x1024"
+	switch {
+	case request.HD && wide:
+		return GPTIMAGE1_H_WIDE
+	case request.HD:
+		return GPTIMAGE1_H
+	case wide:
+		return GPTIMAGE1_M_WIDE
+	}
+	return GPTIMAGE1_M
+}
+
+func gptImageSize(options models.ImageOptions) string {
+	width, height := options.Size()
+	switch {
+	case width > height:
+		return "1536x1024"
+	case width < height:
+		return "1024x1536"
+	}
+	return "1024x1024"
+}
+
+func (e *ImageEditor) Edit(ctx context.Context, request models.ImageRequest) ([]models.GeneratedImage, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	fields := map[string]string{
+		"model":   string(models.GptImage1),
+		"prompt":  request.Prompt,
+		"quality": "medium",
+		"size":    gptImageSize(request.ImageOptions),
+		"n":       "1",
+	}
+	if request.HD {
+		fields["quality"] = "high"
+	}
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeImagePart(writer, "image", request.Image); err != nil {
+		return nil, err
+	}
+	if len(request.Mask) > 0 {
+		if err := writeImagePart(writer, "mask", request.Mask); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+config.CONFIG.OpenAIAPIKey)
+
+	resp, err := HTTP_CLIENT.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if strings.Contains(string(responseBody), "moderation_blocked") || strings.Contains(string(responseBody), "content_policy_violation") {
+			return nil, models.ErrImageContentPolicy
+		}
+		return nil, fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, responseBody)
+	}
+
+	responseJson := struct {
+		Data []struct {
+			B64JSON string `json:"b64_json"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(responseBody, &responseJson); err != nil {
+		return nil, err
+	}
+
+	images := []models.GeneratedImage{}
+	for _, image := range responseJson.Data {
+		data, err := base64.StdEncoding.DecodeString(image.B64JSON)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, models.GeneratedImage{Data: data})
+	}
+	return images, nil
+}
+
+// writeImagePart names the file after the detected format, the API rejects parts without an image type
+func writeImagePart(writer *multipart.Writer, field string, data []byte) error {
+	contentType := http.DetectContentType(data)
+	extension := map[string]string{"image/png": ".png", "image/jpeg": ".jpg", "image/webp": ".webp"}[contentType]
+	if extension == "" {
+		return fmt.Errorf("unsupported %s type %s", field, contentType)
+	}
+	part, err := writer.CreatePart(map[string][]string{
+		"Content-Disposition": {fmt.Sprintf(`form-data; name="%s"; filename="%s%s"`, field, field, extension)},
+		"Content-Type":        {contentType},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(data)
+	return err
+}
@@ -141,6 +141,54 @@ func CreateRun(ctx context.Context, assistantId string, threadId string) (*model
 	return &threadRunResponse, nil
 }
 
+// create a thread with messages, without running it.
+func CreateThread(ctx context.Context, thread *models.Thread) (*models.ThreadResponse, error) {
+	body, err := json.Marshal(thread)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.openai.com/v1/threads", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+config.CONFIG.OpenAIAPIKey)
+	req.Header.Set("OpenAI-Beta", "assistants=v2")
+
+	timeNow := time.Now()
+	status := fmt.Sprintf("status:%d", 0)
+	api_name := "api:create_thread"
+	defer func() {
+		config.CONFIG.DataDogClient.Timing("openai.threads.latency", time.Since(timeNow), []string{status, api_name}, 1)
+	}()
+
+	resp, err := HTTP_CLIENT.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	status = fmt.Sprintf("status:%d", resp.StatusCode)
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+		return nil, err
+	}
+
+	var threadResponse models.ThreadResponse
+	err = json.Unmarshal(body, &threadResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return &threadResponse, nil
+}
+
 // get a thread.
 func GetThread(ctx context.Context, threadId string) (*models.ThreadResponse, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.openai.com/v1/threads/"+threadId, nil)
@@ -563,7 +611,9 @@ func ListThreadMessagesForARun(ctx context.Context, threadId string, runId strin
 	return messages, nil
 }
 
-func CreateThreadAndRunStreaming(ctx context.Context, assistantId string, model models.Engine, thread *models.Thread, cancelContext context.CancelFunc) (chan string, error) {
+// CreateThreadAndRunStreaming starts a thread with a run, there are no additional instructions for a new thread,
+// so they are added to the assistant instructions of the run
+func CreateThreadAndRunStreaming(ctx context.Context, assistantId string, model models.Engine, thread *models.Thread, additionalInstructions string, cancelContext context.CancelFunc) (chan string, error) {
 	if assistantId == "" {
 		return nil, fmt.Errorf("assistantId is required")
 	}
@@ -572,11 +622,16 @@ func CreateThreadAndRunStreaming(ctx context.Context, assistantId string, model
 		return nil, fmt.Errorf("thread is required")
 	}
 
+	instructions := ""
+	if additionalInstructions != "" {
+		instructions = config.AI_INSTRUCTIONS + "\n\n" + additionalInstructions
+	}
 	body, err := json.Marshal(&models.ThreadRunRequest{
-		AssistantID: assistantId,
-		Thread:      thread,
-		Stream:      true,
-		Model:       string(model),
+		AssistantID:  assistantId,
+		Thread:       thread,
+		Stream:       true,
+		Model:        string(model),
+		Instructions: instructions,
 	})
 	if err != nil {
 		return nil, err
@@ -605,7 +660,7 @@ func CreateThreadAndRunStreaming(ctx context.Context, assistantId string, model
 	return messages, nil
 }
 
-func CreateRunStreaming(ctx context.Context, assistantId string, model models.Engine, threadId string, cancelContext context.CancelFunc) (chan string, error) {
+func CreateRunStreaming(ctx context.Context, assistantId string, model models.Engine, threadId string, additionalInstructions string, cancelContext context.CancelFunc) (chan string, error) {
 	if assistantId == "" {
 		return nil, fmt.Errorf("assistantId is required")
 	}
@@ -615,13 +670,15 @@ func CreateRunStreaming(ctx context.Context, assistantId string, model models.En
 	}
 
 	requestBody := struct {
-		AssistantId string `json:"assistant_id"`
-		Stream      bool   `json:"stream"`
-		Model       string `json:"model,omitempty"`
+		AssistantId            string `json:"assistant_id"`
+		Stream                 bool   `json:"stream"`
+		Model                  string `json:"model,omitempty"`
+		AdditionalInstructions string `json:"additional_instructions,omitempty"`
 	}{
-		AssistantId: assistantId,
-		Stream:      true,
-		Model:       string(model),
+		AssistantId:            assistantId,
+		Stream:                 true,
+		Model:                  string(model),
+		AdditionalInstructions: additionalInstructions,
 	}
 
 	requestBodyJSON, err := json.Marshal(requestBody)
@@ -670,7 +727,7 @@ func subscribeAndProcess(
 		cancelContext()
 
 		go payments.HugePromptAlarm(ctx, usage)
-		go payments.Bill(ctx, usage)
+		payments.BillAsync(ctx, usage)
 		config.CONFIG.DataDogClient.Timing("openai.threads.latency", time.Since(timeNow), []string{status, "api:" + apiName}, 1)
 	}()
 
@@ -30,6 +30,19 @@ func CreateSpeech(ctx context.Context, tts *models.TTSRequest) (io.ReadCloser, e
 		tts.Model = "gpt-4o-mini-tts"
 	}
 
+	if tts.Speed == 0 {
+		tts.Speed = 1.00
+	}
+
+	if tts.Format == "" {
+		tts.Format = models.TTSFormatOpus
+	}
+
+	// only gpt-4o-mini-tts follows style instructions
+	if tts.Model != models.TTS {
+		tts.Instructions = ""
+	}
+
 	if len(tts.Input) > 4096 {
 		log.Warnf("trimming input for tts")
 		tts.Input = tts.Input[:4096]
@@ -42,14 +55,16 @@ func CreateSpeech(ctx context.Context, tts *models.TTSRequest) (io.ReadCloser, e
 		Model          string  `json:"model"`
 		Input          string  `json:"input"`
 		Voice          string  `json:"voice"`
+		Instructions   string  `json:"instructions,omitempty"`
 		ResponseFormat string  `json:"response_format,omitempty"`
 		Speed          float64 `json:"speed,omitempty"`
 	}{
 		Model:          string(tts.Model),
 		Input:          tts.Input,
 		Voice:          tts.Voice,
-		ResponseFormat: "opus",
-		Speed:          1.00,
+		Instructions:   tts.Instructions,
+		ResponseFormat: string(tts.Format),
+		Speed:          tts.Speed,
 	}
 
 	// Convert the request body to JSON
@@ -99,7 +114,7 @@ func CreateSpeech(ctx context.Context, tts *models.TTSRequest) (io.ReadCloser, e
 		return nil, fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, responseBody)
 	}
 
-	go payments.Bill(ctx, usage)
+	payments.BillAsync(ctx, usage)
 
 	return resp.Body, nil
 }
@@ -15,6 +15,7 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"strings"
 	"talk2robots/m/v2/app/config"
 	"talk2robots/m/v2/app/models"
 	"talk2robots/m/v2/app/payments"
@@ -26,6 +27,9 @@ import (
 
 const (
 	WHISPER_PRICE_PER_MINUTE = 0.006
+
+	// WHISPER_TIMESTAMPS_FORMAT returns segment timings along with the text
+	WHISPER_TIMESTAMPS_FORMAT = "verbose_json"
 )
 
 type WhisperConfig struct {
@@ -45,8 +49,9 @@ type WhisperTimeout struct {
 }
 
 type WhisperTranscript struct {
-	Blob []byte
-	Text string
+	Blob     []byte
+	Text     string
+	Segments []models.TranscriptSegment
 }
 
 type WhisperHook interface {
@@ -138,62 +143,22 @@ func (uw *whisper) onTranscribing() {
 	uw.transcript.Text = text
 }
 
-func (uw *whisper) onWhispered(reader io.Reader, fileName string) (string, error) {
-	timeNow := time.Now()
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	part, err := writer.CreateFormFile("file", fileName)
-	if err != nil {
-		logrus.Debug("Whisper: could not create form file: ", err)
-		return "", err
-	}
-	io.Copy(part, reader)
-
-	writer.WriteField("model", "gpt-4o-mini-transcribe")
-
-	// if uw.mode == "transcriptions" {
-	// 	language := uw.WhisperConfig.Language
-	// 	if language == "" {
-	// 		language = "en"
-	// 	}
-	// 	writer.WriteField("language", language)
-	// }
-	params := uw.ctx.Value(models.ParamsContext{}).(string)
-	if params != "" {
-		writer.WriteField("language", params)
-	}
-
-	if uw.WhisperConfig.Prompt != "" {
-		writer.WriteField("prompt", uw.WhisperConfig.Prompt)
-	}
-
-	if uw.WhisperConfig.ResponseFormat != "" {
-		writer.WriteField("response_format", uw.WhisperConfig.ResponseFormat)
-	}
-
-	if uw.WhisperConfig.Temperature != 0 {
-		writer.WriteField("temperature", fmt.Sprintf("%f", uw.WhisperConfig.Temperature))
-	}
-
-	err = writer.Close()
-	if err != nil {
-		logrus.Debug("Whisper: could not close writer: ", err)
-		return "", err
+// timed transcripts are only supported by whisper-1, which is priced the same
+func (uw *whisper) model() models.Engine {
+	if uw.WhisperConfig.ResponseFormat == WHISPER_TIMESTAMPS_FORMAT {
+		return models.WhisperTimestamps
 	}
+	return models.Whisper
+}
 
-	req, err := http.NewRequest("POST", uw.WhisperConfig.WhisperAPIEndpoint+uw.mode, body)
+func (uw *whisper) onWhispered(reader io.Reader, fileName string) (string, error) {
+	timeNow := time.Now()
+	uw.usage.Engine = uw.model()
+	req, err := NewTranscriptionRequest(uw.ctx, uw.WhisperConfig, uw.usage.Engine, reader, fileName)
 	if err != nil {
-		logrus.Debug("Whisper: could not create request: ", err)
 		return "", err
 	}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	if uw.WhisperConfig.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+uw.WhisperConfig.APIKey)
-	}
-
 	client := &http.Client{
 		Timeout: TIMEOUT * 2,
 	}
@@ -221,7 +186,7 @@ func (uw *whisper) onWhispered(reader io.Reader, fileName string) (string, error
 	uw.usage.Usage = models.Usage{
 		AudioDuration: duration.Minutes(),
 	}
-	go payments.Bill(uw.ctx, uw.usage)
+	payments.BillAsync(uw.ctx, uw.usage)
 
 	config.CONFIG.DataDogClient.Timing("openai.whisper.latency", time.Since(timeNow), []string{"model:" + string(uw.usage.Engine)}, 1)
 	logrus.Debugf("Whisper response: %+v", jsonResponse)
@@ -230,5 +195,93 @@ func (uw *whisper) onWhispered(reader io.Reader, fileName string) (string, error
 		logrus.Debug("Could not find 'text' key or it's not a string in the jsonResponse")
 		return "", fmt.Errorf("Whisper: unexpected JSON response: %v", jsonResponse)
 	}
+	uw.transcript.Segments = parseWhisperSegments(jsonResponse)
 	return textValue, nil
 }
+
+// NewTranscriptionRequest builds a multipart transcription request, the language is taken from params in ctx,
+// without it whisper detects the language
+func NewTranscriptionRequest(ctx context.Context, config WhisperConfig, model models.Engine, reader io.Reader, fileName string) (*http.Request, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		logrus.Debug("Whisper: could not create form file: ", err)
+		return nil, err
+	}
+	io.Copy(part, reader)
+
+	writer.WriteField("model", string(model))
+
+	params, _ := ctx.Value(models.ParamsContext{}).(string)
+	if params != "" {
+		writer.WriteField("language", params)
+	}
+
+	if config.Prompt != "" {
+		writer.WriteField("prompt", config.Prompt)
+	}
+
+	if config.ResponseFormat != "" {
+		writer.WriteField("response_format", config.ResponseFormat)
+	}
+	if config.ResponseFormat == WHISPER_TIMESTAMPS_FORMAT {
+		writer.WriteField("timestamp_granularities[]", "segment")
+	}
+
+	if config.Temperature != 0 {
+		writer.WriteField("temperature", fmt.Sprintf("%f", config.Temperature))
+	}
+
+	err = writer.Close()
+	if err != nil {
+		logrus.Debug("Whisper: could not close writer: ", err)
+		return nil, err
+	}
+
+	mode := config.Mode
+	if mode == "" {
+		mode = "transcriptions"
+	}
+	req, err := http.NewRequest("POST", config.WhisperAPIEndpoint+mode, body)
+	if err != nil {
+		logrus.Debug("Whisper: could not create request: ", err)
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	if config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	}
+	return req, nil
+}
+
+// parseWhisperSegments reads "segments": [{"start": 0.0, "end": 3.2, "text": " Hello"}] of verbose_json responses
+func parseWhisperSegments(jsonResponse map[string]interface{}) []models.TranscriptSegment {
+	rawSegments, ok := jsonResponse["segments"].([]interface{})
+	if !ok {
+		return nil
+	}
+	segments := []models.TranscriptSegment{}
+	for _, rawSegment := range rawSegments {
+		segment, ok := rawSegment.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		start, _ := segment["start"].(float64)
+		end, _ := segment["end"].(float64)
+		text, _ := segment["text"].(string)
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		segments = append(segments, models.TranscriptSegment{
+			Start: time.Duration(start * float64(time.Second)),
+			End:   time.Duration(end * float64(time.Second)),
+			Text:  text,
+		})
+	}
+	return segments
+}
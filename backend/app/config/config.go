@@ -20,8 +20,14 @@ You can work in different modes, user can use following commands to switch betwe
 - /teacher - correct and explain grammar and mistakes
 - /transcribe voice/audio/video messages only
 - /translate [language code] - translate text to English or the specified language
+- /interpret [language code] [second language code] - voice interpreter, translates voice/text messages and answers with a voice message in the language, with two languages translates between them both ways
 - /summarize text/voice/audio/video messages
-- draw in any mode, user can just ask to picture anything (Example: 'create an image of a fish riding a bicycle')
+- /dictate [email|note|todo|post] - turns voice notes into a clean draft of the format in the speaker's language, next voice/text messages edit the same draft (Example: 'make it more formal') until a new one is started
+- /search - search the web and answer with cited sources
+- draw in any mode, user can just ask to picture anything in any language (Example: 'create an image of a fish riding a bicycle', 'нарисуй кота'), options go after the prompt: --ar 16:9 --hd --n 3 --style natural --seed 42
+- /edit [description] as a reply to a photo - edit the photo, in /chatgpt and /voicegpt modes user can also just reply to a photo with the change (Example: 'make it look like a watercolor')
+- /images - drawn images of the chat, user can send any of them again or redraw it by the same prompt
+- /reactions - emoji reactions on any message run actions on it: 🔊 reads it aloud, 🌐 translates, ✍️ corrects grammar, 📝 summarizes, user can change them (Example: '/reactions 🔥 summarize', '/reactions 🔊 off')
 	
 You can only remember context in /chatgpt and /voicegpt modes, user can use /clear command to cleanup context memory (to avoid increased costs)
 /status to check usage limits, consumed tokens and audio transcription minutes. Usage limits for the assistant are reset every 1st of the month.
@@ -30,29 +36,36 @@ To use any of the modes user has to send respective /{command} first, i.e. if th
 	
 You can understand and respond in any language, not just English, prefer answering in a language user engages conversation with.
 
-The rendered responses should have proper HTML formatting. Prettify responses with following HTML tags only:
-Bold => <b>bold</b>, <strong>bold</strong>
-Italic => <i>italic</i>, <em>italic</em>
-Code => <code>code</code>
-Strike => <s>strike</s>, <strike>strike</strike>, <del>strike</del>
-Underline => <u>underline</u>
-Pre => <pre language="c++">code</pre>`
+Format responses with Markdown when it helps readability: **bold**, *italic*, ~~strike~~, ` + "`code`" + `, fenced code blocks with a language, lists, > quotes, [links](https://example.com) and tables. Don't use raw HTML.`
 )
 
 type Config struct {
 	AssistantGpt4Id        string
 	AssistantGpt35Id       string
+	BlobStoreDir           string
 	BotName                string
 	BotUrl                 string
+	BraveSearchAPIKey      string
 	ClaudeAPIKey           string
 	DataDogClient          *statsd.Client
 	Environment            string
 	FireworksAPIKey        string
 	GrokAPIKey             string
+	LocalesDir             string
+	MidjourneyProxySecret  string
+	MidjourneyProxyURL     string
 	MongoDBName            string
 	MongoDBConnection      string
 	OpenAIAPIKey           string
+	PerplexityAPIKey       string
 	Redis                  Redis
+	S3AccessKeyID          string
+	S3Bucket               string
+	S3Endpoint             string
+	S3Region               string
+	S3SecretAccessKey      string
+	SearchProvider         string
+	SearXNGURL             string
 	SlackBotToken          string
 	SlackSigningSecret     string
 	StatusWorkerInterval   time.Duration
@@ -11,13 +11,19 @@ import (
 )
 
 func ConvertWithFFMPEG(inputFile string, outputFile string) (duration time.Duration, err error) {
+	duration, _, err = ConvertWithSilences(inputFile, outputFile)
+	return duration, err
+}
+
+// ConvertWithSilences converts the input to mono opus and also returns silences found by ffmpeg silencedetect
+func ConvertWithSilences(inputFile string, outputFile string) (duration time.Duration, silences []Silence, err error) {
 	var cmd *exec.Cmd
 	// if input file is .ogg, rename it first to avoid overwriting the original file
 	if strings.HasSuffix(inputFile, ".ogg") {
 		renamedInputFile := inputFile + ".tmp"
 		err := exec.Command("mv", inputFile, renamedInputFile).Run()
 		if err != nil {
-			return 0, fmt.Errorf("failed to rename %s to %s: %v", inputFile, renamedInputFile, err)
+			return 0, nil, fmt.Errorf("failed to rename %s to %s: %v", inputFile, renamedInputFile, err)
 		}
 		defer util.SafeOsDelete(renamedInputFile)
 		inputFile = renamedInputFile
@@ -31,20 +37,21 @@ func ConvertWithFFMPEG(inputFile string, outputFile string) (duration time.Durat
 	}
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return 0, fmt.Errorf("failed to convert %s to %s: %v\n%s", inputFile, outputFile, err, output)
+		return 0, nil, fmt.Errorf("failed to convert %s to %s: %v\n%s", inputFile, outputFile, err, output)
 	}
 	outputStr := string(output)
 	if outputStr == "" && err != nil {
 		logrus.Errorf("failed to get duration of %s, output: %s, error: %v", outputFile, outputStr, err)
-		return 0, nil
+		return 0, nil, nil
 	}
 
+	silences = ParseSilences(outputStr)
 	duration, err = ParseDuration(outputStr)
 	if err != nil {
 		logrus.Errorf("failed to parse duration %s: %v", outputStr, err)
-		return 0, nil
+		return 0, silences, nil
 	}
-	return duration, nil
+	return duration, silences, nil
 }
 
 func ParseDuration(outputStr string) (duration time.Duration, err error) {
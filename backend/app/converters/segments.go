@@ -0,0 +1,180 @@
+package converters
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// MAX_SEGMENT_DURATION keeps every upload well below the transcription API duration limit
+	MAX_SEGMENT_DURATION = 10 * time.Minute
+
+	// MAX_SEGMENT_SIZE keeps every upload below the transcription API 25 MB limit
+	MAX_SEGMENT_SIZE = 24 * 1024 * 1024
+
+	// SEGMENT_OVERLAP is transcribed twice so words cut at a boundary survive, duplicates are removed when stitching
+	SEGMENT_OVERLAP = 3 * time.Second
+
+	// MAX_STITCH_WORDS is the longest overlap looked for when stitching transcripts
+	MAX_STITCH_WORDS = 40
+
+	// MAX_STITCH_SKIP words at the edges of a segment may be garbled by the cut and are ignored when matching
+	MAX_STITCH_SKIP = 2
+
+	// MIN_STITCH_WORDS avoids dropping a legitimately repeated word, e.g. "very very"
+	MIN_STITCH_WORDS = 2
+)
+
+type Silence struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+type Segment struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+func (s Segment) Duration() time.Duration {
+	return s.End - s.Start
+}
+
+var silenceRegex = regexp.MustCompile(`silence_(start|end): (-?[0-9.]+)`)
+
+// ParseSilences extracts silences from ffmpeg silencedetect output:
+// [silencedetect @ 0x7f8c] silence_start: 12.345
+// [silencedetect @ 0x7f8c] silence_end: 14.01 | silence_duration: 1.665
+func ParseSilences(outputStr string) []Silence {
+	silences := []Silence{}
+	var current *Silence
+	for _, match := range silenceRegex.FindAllStringSubmatch(outputStr, -1) {
+		seconds, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			continue
+		}
+		position := time.Duration(seconds * float64(time.Second))
+		if position < 0 {
+			position = 0
+		}
+		switch match[1] {
+		case "start":
+			current = &Silence{Start: position, End: position}
+		case "end":
+			if current == nil {
+				continue
+			}
+			current.End = position
+			silences = append(silences, *current)
+			current = nil
+		}
+	}
+	// silence till the end of the file is never closed, it doesn't matter for cutting
+	return silences
+}
+
+// MaxSegmentDuration is the longest segment of a converted file that fits both upload limits
+func MaxSegmentDuration(duration time.Duration, size int64) time.Duration {
+	maxSegment := MAX_SEGMENT_DURATION
+	if size > 0 && duration > 0 {
+		// 10% headroom for uneven bitrate
+		bySize := time.Duration(float64(duration) * float64(MAX_SEGMENT_SIZE) / float64(size) * 0.9)
+		if bySize < maxSegment {
+			maxSegment = bySize
+		}
+	}
+	return maxSegment
+}
+
+// PlanSegments splits the duration into segments no longer than maxSegment, overlapping by overlap.
+// Segments are cut in the middle of the latest silence in the second half of the window, or at the window end if there is none.
+func PlanSegments(duration time.Duration, silences []Silence, maxSegment time.Duration, overlap time.Duration) []Segment {
+	if maxSegment <= 0 || duration <= maxSegment {
+		return []Segment{{Start: 0, End: duration}}
+	}
+	if overlap >= maxSegment/2 {
+		overlap = 0
+	}
+
+	segments := []Segment{}
+	start := time.Duration(0)
+	for duration-start > maxSegment {
+		limit := start + maxSegment
+		cut := limit
+		for _, silence := range silences {
+			middle := (silence.Start + silence.End) / 2
+			if middle > start+maxSegment/2 && middle <= limit {
+				cut = middle
+			}
+		}
+		segments = append(segments, Segment{Start: start, End: cut})
+		start = cut - overlap
+	}
+	return append(segments, Segment{Start: start, End: duration})
+}
+
+// CutSegment copies a segment of an already converted file without re-encoding
+func CutSegment(inputFile string, segment Segment, outputFile string) error {
+	cmd := exec.Command("ffmpeg", "-y", "-ss", formatSeconds(segment.Start), "-i", inputFile, "-t", formatSeconds(segment.Duration()), "-map", "a", "-c", "copy", outputFile)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to cut %s-%s of %s to %s: %v\n%s", segment.Start, segment.End, inputFile, outputFile, err, output)
+	}
+	return nil
+}
+
+func formatSeconds(duration time.Duration) string {
+	return strconv.FormatFloat(duration.Seconds(), 'f', 3, 64)
+}
+
+// StitchTranscripts joins transcripts of overlapping segments, dropping the words transcribed twice
+func StitchTranscripts(texts []string) string {
+	result := []string{}
+	for _, text := range texts {
+		words := strings.Fields(text)
+		if len(words) == 0 {
+			continue
+		}
+		// the first half of the overlap is taken from the previous transcript and the second half from the next one,
+		// each is transcribed with more context around it there
+		drop, skip, length := findOverlap(result, words)
+		half := length / 2
+		result = append(result[:len(result)-drop-length+half], words[skip+half:]...)
+	}
+	return strings.Join(result, " ")
+}
+
+// findOverlap finds the longest run of words ending the previous transcript (but the last drop words)
+// that starts the next one (but the first skip words)
+func findOverlap(previous []string, next []string) (drop int, skip int, length int) {
+	maxLength := min(MAX_STITCH_WORDS, len(previous), len(next))
+	for length = maxLength; length >= MIN_STITCH_WORDS; length-- {
+		for drop = 0; drop <= MAX_STITCH_SKIP && drop+length <= len(previous); drop++ {
+			for skip = 0; skip <= MAX_STITCH_SKIP && skip+length <= len(next); skip++ {
+				if wordsEqual(previous[len(previous)-drop-length:len(previous)-drop], next[skip:skip+length]) {
+					return drop, skip, length
+				}
+			}
+		}
+	}
+	return 0, 0, 0
+}
+
+func wordsEqual(a []string, b []string) bool {
+	for i := range a {
+		if normalizeWord(a[i]) != normalizeWord(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}))
+}
@@ -0,0 +1,89 @@
+package converters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSilences(t *testing.T) {
+	output := `Input #0, ogg, from '/data/819adad0.oga':
+  Duration: 00:21:02.06, start: 0.000000, bitrate: 32 kb/s
+[silencedetect @ 0x7ff66a672100] silence_start: -0.0125
+[silencedetect @ 0x7ff66a672100] silence_end: 1.5 | silence_duration: 1.5125
+size=     100kB time=00:05:00.00 bitrate=  12.0kbits/s speed=90x
+[silencedetect @ 0x7ff66a672100] silence_start: 412.25
+[silencedetect @ 0x7ff66a672100] silence_end: 414.75 | silence_duration: 2.5
+[silencedetect @ 0x7ff66a672100] silence_start: 1260.1
+size=     300kB time=00:21:02.06 bitrate=  12.0kbits/s speed=90x
+`
+	silences := ParseSilences(output)
+	assert.Equal(t, []Silence{
+		{Start: 0, End: 1500 * time.Millisecond},
+		{Start: 412250 * time.Millisecond, End: 414750 * time.Millisecond},
+	}, silences)
+
+	assert.Empty(t, ParseSilences("size=      13kB time=00:00:01.63 bitrate=  67.5kbits/s speed=6.97x"))
+}
+
+func TestMaxSegmentDuration(t *testing.T) {
+	assert.Equal(t, MAX_SEGMENT_DURATION, MaxSegmentDuration(time.Hour, 5*1024*1024))
+	assert.Equal(t, MAX_SEGMENT_DURATION, MaxSegmentDuration(0, 0))
+	// 48 MB for 10 minutes fits 5 minutes into 24 MB, 10% less with headroom
+	assert.Equal(t, 4*time.Minute+30*time.Second, MaxSegmentDuration(10*time.Minute, 2*MAX_SEGMENT_SIZE))
+}
+
+func TestPlanSegments(t *testing.T) {
+	minute := time.Minute
+
+	// short recordings are never split
+	assert.Equal(t, []Segment{{Start: 0, End: 9 * minute}}, PlanSegments(9*minute, nil, 10*minute, SEGMENT_OVERLAP))
+
+	// no silences, cut at the window end
+	segments := PlanSegments(25*minute, nil, 10*minute, 3*time.Second)
+	assert.Equal(t, []Segment{
+		{Start: 0, End: 10 * minute},
+		{Start: 10*minute - 3*time.Second, End: 20*minute - 3*time.Second},
+		{Start: 20*minute - 6*time.Second, End: 25 * minute},
+	}, segments)
+
+	// cut in the middle of the latest silence in the second half of the window, silences in the first half are ignored
+	silences := []Silence{
+		{Start: 3 * minute, End: 3*minute + 2*time.Second},
+		{Start: 7 * minute, End: 7*minute + 2*time.Second},
+		{Start: 8 * minute, End: 8*minute + 2*time.Second},
+		{Start: 11 * minute, End: 11*minute + 4*time.Second},
+	}
+	segments = PlanSegments(15*minute, silences, 10*minute, 3*time.Second)
+	assert.Equal(t, []Segment{
+		{Start: 0, End: 8*minute + time.Second},
+		{Start: 8*minute - 2*time.Second, End: 15 * minute},
+	}, segments)
+
+	for _, segment := range PlanSegments(3*time.Hour, silences, 7*minute, SEGMENT_OVERLAP) {
+		assert.LessOrEqual(t, segment.Duration(), 7*minute)
+		assert.Greater(t, segment.Duration(), time.Duration(0))
+	}
+}
+
+func TestStitchTranscripts(t *testing.T) {
+	assert.Equal(t, "", StitchTranscripts(nil))
+	assert.Equal(t, "one two", StitchTranscripts([]string{"", "one two", "  "}))
+
+	// exact overlap
+	assert.Equal(t,
+		"We went to the market and bought some apples for the pie.",
+		StitchTranscripts([]string{"We went to the market and bought some", "and bought some apples for the pie."}))
+
+	// punctuation and case differ, garbled words at the cut are dropped
+	assert.Equal(t,
+		"Hello everyone, today we talk about Go. Channels are great.",
+		StitchTranscripts([]string{"Hello everyone, today we talk about go ch", "-out Today we talk about Go. Channels are great."}))
+
+	// no overlap found, texts are just joined
+	assert.Equal(t, "first part. second part.", StitchTranscripts([]string{"first part.", "second part."}))
+
+	// a single repeated word isn't treated as overlap
+	assert.Equal(t, "it was very very good", StitchTranscripts([]string{"it was very", "very good"}))
+}
@@ -0,0 +1,89 @@
+package converters
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+	"talk2robots/m/v2/app/models"
+	"time"
+)
+
+// RenderSRT renders timed transcript segments as SubRip subtitles
+func RenderSRT(segments []models.TranscriptSegment) string {
+	var result strings.Builder
+	for i, segment := range segments {
+		fmt.Fprintf(&result, "%d\n%s --> %s\n%s\n\n", i+1, formatSubtitleTime(segment.Start, ","), formatSubtitleTime(segment.End, ","), segment.Text)
+	}
+	return result.String()
+}
+
+// RenderVTT renders timed transcript segments as WebVTT subtitles
+func RenderVTT(segments []models.TranscriptSegment) string {
+	var result strings.Builder
+	result.WriteString("WEBVTT\n\n")
+	for _, segment := range segments {
+		fmt.Fprintf(&result, "%s --> %s\n%s\n\n", formatSubtitleTime(segment.Start, "."), formatSubtitleTime(segment.End, "."), segment.Text)
+	}
+	return result.String()
+}
+
+// RenderTimestamps renders timed transcript segments as plain text lines prefixed with [hh:mm:ss]
+func RenderTimestamps(segments []models.TranscriptSegment) string {
+	var result strings.Builder
+	for _, segment := range segments {
+		fmt.Fprintf(&result, "[%s] %s\n", formatSubtitleTime(segment.Start, "")[:8], segment.Text)
+	}
+	return result.String()
+}
+
+// formatSubtitleTime formats hh:mm:ss with milliseconds after the separator, or without them if the separator is empty
+func formatSubtitleTime(position time.Duration, separator string) string {
+	if position < 0 {
+		position = 0
+	}
+	milliseconds := position.Milliseconds()
+	clock := fmt.Sprintf("%02d:%02d:%02d", milliseconds/3600000, milliseconds/60000%60, milliseconds/1000%60)
+	if separator == "" {
+		return clock
+	}
+	return fmt.Sprintf("%s%s%03d", clock, separator, milliseconds%1000)
+}
+
+// MergeTimedSegments shifts timed transcripts of overlapping audio segments to the recording time
+// and keeps every transcript segment once, in the audio segment that owns its middle.
+// Audio segments own the time up to the middle of their overlaps with the neighbours.
+func MergeTimedSegments(segments []Segment, timed [][]models.TranscriptSegment) []models.TranscriptSegment {
+	merged := []models.TranscriptSegment{}
+	for i, segment := range segments {
+		if i >= len(timed) {
+			break
+		}
+		lower, upper := time.Duration(0), time.Duration(math.MaxInt64)
+		if i > 0 {
+			lower = (segments[i-1].End + segment.Start) / 2
+		}
+		if i < len(segments)-1 {
+			upper = (segment.End + segments[i+1].Start) / 2
+		}
+		for _, transcriptSegment := range timed[i] {
+			transcriptSegment.Start += segment.Start
+			transcriptSegment.End += segment.Start
+			middle := (transcriptSegment.Start + transcriptSegment.End) / 2
+			if middle >= lower && middle < upper {
+				merged = append(merged, transcriptSegment)
+			}
+		}
+	}
+	return merged
+}
+
+// BurnSubtitles renders subtitles into the video, audio is copied as is
+func BurnSubtitles(videoFile string, subtitlesFile string, outputFile string) error {
+	cmd := exec.Command("ffmpeg", "-y", "-i", videoFile, "-vf", "subtitles="+subtitlesFile, "-c:v", "libx264", "-preset", "veryfast", "-c:a", "copy", "-movflags", "+faststart", outputFile)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to burn subtitles %s into %s: %v\n%s", subtitlesFile, videoFile, err, output)
+	}
+	return nil
+}
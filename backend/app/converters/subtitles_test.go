@@ -0,0 +1,69 @@
+package converters
+
+import (
+	"talk2robots/m/v2/app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testTranscriptSegments = []models.TranscriptSegment{
+	{Start: 0, End: 2500 * time.Millisecond, Text: "Hello there."},
+	{Start: time.Hour + 2*time.Minute + 3*time.Second + 45*time.Millisecond, End: time.Hour + 2*time.Minute + 5*time.Second, Text: "General Kenobi!"},
+}
+
+func TestRenderSRT(t *testing.T) {
+	assert.Equal(t, `1
+00:00:00,000 --> 00:00:02,500
+Hello there.
+
+2
+01:02:03,045 --> 01:02:05,000
+General Kenobi!
+
+`, RenderSRT(testTranscriptSegments))
+}
+
+func TestRenderVTT(t *testing.T) {
+	assert.Equal(t, `WEBVTT
+
+00:00:00.000 --> 00:00:02.500
+Hello there.
+
+01:02:03.045 --> 01:02:05.000
+General Kenobi!
+
+`, RenderVTT(testTranscriptSegments))
+}
+
+func TestRenderTimestamps(t *testing.T) {
+	assert.Equal(t, "[00:00:00] Hello there.\n[01:02:03] General Kenobi!\n", RenderTimestamps(testTranscriptSegments))
+}
+
+func TestMergeTimedSegments(t *testing.T) {
+	segments := []Segment{
+		{Start: 0, End: 60 * time.Second},
+		{Start: 56 * time.Second, End: 120 * time.Second},
+	}
+	timed := [][]models.TranscriptSegment{
+		{
+			{Start: 50 * time.Second, End: 55 * time.Second, Text: "first"},
+			// overlap, the middle at 58s belongs to the first segment
+			{Start: 57 * time.Second, End: 59 * time.Second, Text: "both"},
+			// cut at the end of the segment, the middle at 59.5s belongs to the second one
+			{Start: 59 * time.Second, End: 60 * time.Second, Text: "cut"},
+		},
+		{
+			{Start: 1 * time.Second, End: 3 * time.Second, Text: "both"},
+			{Start: 3 * time.Second, End: 4 * time.Second, Text: "cut off"},
+			{Start: 10 * time.Second, End: 12 * time.Second, Text: "second"},
+		},
+	}
+	assert.Equal(t, []models.TranscriptSegment{
+		{Start: 50 * time.Second, End: 55 * time.Second, Text: "first"},
+		{Start: 57 * time.Second, End: 59 * time.Second, Text: "both"},
+		{Start: 59 * time.Second, End: 60 * time.Second, Text: "cut off"},
+		{Start: 66 * time.Second, End: 68 * time.Second, Text: "second"},
+	}, MergeTimedSegments(segments, timed))
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"talk2robots/m/v2/app/models"
+	"time"
 )
 
 // MockMongoDBClient is a mock for the MongoDB client in the mongo package.
 type MockMongoDBClient struct {
 	MongoClient
-	User models.MongoUser
+	User        models.MongoUser
+	Images      []models.MongoImage
+	UsageEvents []models.MongoUsageEvent
 }
 
 func NewMockMongoDBClient(user models.MongoUser) *MockMongoDBClient {
@@ -26,9 +29,87 @@ func (m *MockMongoDBClient) UpdateUserSubscription(ctx context.Context, subscrip
 	return nil
 }
 
+func (m *MockMongoDBClient) UpdateUserLanguage(ctx context.Context, language string) error {
+	m.User.Language = language
+	return nil
+}
+
 func (m *MockMongoDBClient) GetUser(ctx context.Context) (*models.MongoUser, error) {
 	if m.User.ID == "" {
 		return &models.MongoUser{}, errors.New("user not found")
 	}
 	return &m.User, nil
 }
+
+func (m *MockMongoDBClient) SaveImage(ctx context.Context, image *models.MongoImage) error {
+	if image.UserId == "" {
+		image.UserId = ctx.Value(models.UserContext{}).(string)
+	}
+	m.Images = append(m.Images, *image)
+	return nil
+}
+
+// GetImages returns saved images the newest first
+func (m *MockMongoDBClient) GetImages(ctx context.Context, page int, pageSize int) ([]models.MongoImage, int64, error) {
+	images := []models.MongoImage{}
+	for i := len(m.Images) - 1 - page*pageSize; i >= 0 && len(images) < pageSize; i-- {
+		images = append(images, m.Images[i])
+	}
+	return images, int64(len(m.Images)), nil
+}
+
+func (m *MockMongoDBClient) GetImage(ctx context.Context, id string) (*models.MongoImage, error) {
+	for _, image := range m.Images {
+		if image.ID == id {
+			return &image, nil
+		}
+	}
+	return nil, errors.New("image not found")
+}
+
+// SaveUsageEvents keeps events once per id, like the unique _id in mongo
+func (m *MockMongoDBClient) SaveUsageEvents(ctx context.Context, events []models.MongoUsageEvent) error {
+	for _, event := range events {
+		duplicate := false
+		for _, saved := range m.UsageEvents {
+			duplicate = duplicate || saved.ID == event.ID
+		}
+		if !duplicate {
+			m.UsageEvents = append(m.UsageEvents, event)
+		}
+	}
+	return nil
+}
+
+func (m *MockMongoDBClient) GetFirstUsageEventTime(ctx context.Context) (time.Time, error) {
+	first := time.Time{}
+	for _, event := range m.UsageEvents {
+		if first.IsZero() || event.CreatedAt.Before(first) {
+			first = event.CreatedAt
+		}
+	}
+	return first, nil
+}
+
+func (m *MockMongoDBClient) GetUsageTotals(ctx context.Context, since time.Time, userId string) ([]models.MongoUsageTotals, error) {
+	totals := []models.MongoUsageTotals{}
+	index := map[string]int{}
+	for _, event := range m.UsageEvents {
+		if event.CreatedAt.Before(since) || (userId != "" && event.UserId != userId) {
+			continue
+		}
+		key := event.UserId + ":" + event.MemberId
+		i, ok := index[key]
+		if !ok {
+			i = len(totals)
+			index[key] = i
+			totals = append(totals, models.MongoUsageTotals{UserId: event.UserId, MemberId: event.MemberId})
+		}
+		totals[i].Cost += event.Cost
+		totals[i].TotalTokens += int64(event.TotalTokens)
+		totals[i].AudioMinutes += event.AudioMinutes
+		totals[i].ImagesCount += int64(event.ImagesCount)
+		totals[i].SearchQueries += int64(event.SearchQueries)
+	}
+	return totals, nil
+}
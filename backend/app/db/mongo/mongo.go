@@ -3,6 +3,7 @@ package mongo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -28,6 +29,18 @@ const (
 
 	// MongoUserThreadCollection is the name of the collection that stores user thread data
 	MongoUserThreadCollection = "user_threads"
+
+	// MongoFeedbackCollection is the name of the collection that stores likes, dislikes and reactions
+	MongoFeedbackCollection = "feedback"
+
+	// MongoReminderCollection is the name of the collection that stores reminders and scheduled prompts
+	MongoReminderCollection = "reminders"
+
+	// MongoImageCollection is the name of the collection that stores generated images metadata
+	MongoImageCollection = "images"
+
+	// MongoUsageEventCollection is the name of the collection that stores the ledger of billed usage events
+	MongoUsageEventCollection = "usage_events"
 )
 
 type MongoClient interface {
@@ -41,7 +54,9 @@ type MongoClient interface {
 	MigrateUsersToSubscription(ctx context.Context, from, to string) error
 	Ping(ctx context.Context, rp *readpref.ReadPref) error
 	UpdateUserContacts(ctx context.Context, name, phone, email string) error
+	UpdateUserLanguage(ctx context.Context, language string) error
 	UpdateUserSubscription(ctx context.Context, subscription models.MongoSubscription) error
+	UpdateUserTimeZone(ctx context.Context, timeZone string) error
 	UpdateUserUsage(ctx context.Context, userTotalCost float64) error
 	UpdateUserStripeCustomerId(ctx context.Context, stripeCustomerId string) error
 	UpdateUsersNotified(ctx context.Context, userIds []string) error
@@ -53,6 +68,28 @@ type MongoClient interface {
 	UpdateUserThread(ctx context.Context, thread *models.MongoUserThread) error
 
 	UpdateUserSourceModeLanguage(ctx context.Context, source string, mode string, language string) error
+
+	// feedback
+	GetFeedback(ctx context.Context, filter models.MongoFeedbackFilter) ([]models.MongoFeedback, error)
+	SaveFeedback(ctx context.Context, feedback *models.MongoFeedback) error
+	UpdateFeedbackComment(ctx context.Context, messageId int, comment string) error
+
+	// reminders
+	ClaimDueReminder(ctx context.Context, now time.Time, lease time.Duration) (*models.MongoReminder, error)
+	DeleteReminder(ctx context.Context, id string) error
+	GetReminders(ctx context.Context) ([]models.MongoReminder, error)
+	RescheduleReminder(ctx context.Context, id string, next time.Time, lastRun time.Time) error
+	SaveReminder(ctx context.Context, reminder *models.MongoReminder) error
+
+	// images
+	GetImage(ctx context.Context, id string) (*models.MongoImage, error)
+	GetImages(ctx context.Context, page int, pageSize int) ([]models.MongoImage, int64, error)
+	SaveImage(ctx context.Context, image *models.MongoImage) error
+
+	// usage ledger
+	GetFirstUsageEventTime(ctx context.Context) (time.Time, error)
+	GetUsageTotals(ctx context.Context, since time.Time, userId string) ([]models.MongoUsageTotals, error)
+	SaveUsageEvents(ctx context.Context, events []models.MongoUsageEvent) error
 }
 
 var MongoDBClient MongoClient
@@ -133,6 +170,39 @@ func (c *Client) UpdateUserSubscription(ctx context.Context, subscription models
 	return err
 }
 
+func (c *Client) UpdateUserLanguage(ctx context.Context, language string) error {
+	userId := ctx.Value(models.UserContext{}).(string)
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoUserCollection)
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{
+		"$set": bson.M{
+			"language": sanitize(language),
+		},
+	}
+
+	options := options.Update().SetUpsert(true)
+	_, err := collection.UpdateOne(ctx, filter, update, options)
+	return err
+}
+
+// UpdateUserTimeZone stores a time zone already validated with lib.ParseTimeZone
+func (c *Client) UpdateUserTimeZone(ctx context.Context, timeZone string) error {
+	userId := ctx.Value(models.UserContext{}).(string)
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoUserCollection)
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{
+		"$set": bson.M{
+			"time_zone": timeZone,
+		},
+	}
+
+	options := options.Update().SetUpsert(true)
+	_, err := collection.UpdateOne(ctx, filter, update, options)
+	return err
+}
+
 func (c *Client) UpdateUserUsage(ctx context.Context, newUsage float64) error {
 	userId := ctx.Value(models.UserContext{}).(string)
 	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoUserCollection)
@@ -447,3 +517,326 @@ func sanitize(s string) string {
 	// use regex to keep only english letters and digits
 	return regexp.MustCompile("[^a-zA-Z0-9]+").ReplaceAllString(s, "")
 }
+
+func (c *Client) SaveFeedback(ctx context.Context, feedback *models.MongoFeedback) error {
+	if feedback == nil {
+		return fmt.Errorf("SaveFeedback: feedback is required")
+	}
+	if feedback.UserId == "" {
+		feedback.UserId = ctx.Value(models.UserContext{}).(string)
+	}
+	if feedback.CreatedAt == "" {
+		feedback.CreatedAt = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	}
+
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoFeedbackCollection)
+	_, err := collection.InsertOne(ctx, feedback)
+	if err != nil {
+		return fmt.Errorf("SaveFeedback: failed to insert feedback: %w", err)
+	}
+	return nil
+}
+
+// UpdateFeedbackComment attaches a free text comment to the latest dislike of a message
+func (c *Client) UpdateFeedbackComment(ctx context.Context, messageId int, comment string) error {
+	userId := ctx.Value(models.UserContext{}).(string)
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoFeedbackCollection)
+
+	filter := bson.M{"user_id": userId, "message_id": messageId, "kind": "dislike"}
+	update := bson.M{
+		"$set": bson.M{
+			"comment": comment,
+		},
+	}
+
+	options := options.FindOneAndUpdate().SetSort(bson.M{"created_at": -1})
+	err := collection.FindOneAndUpdate(ctx, filter, update, options).Err()
+	if err != nil {
+		return fmt.Errorf("UpdateFeedbackComment: failed to update feedback: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) GetFeedback(ctx context.Context, filter models.MongoFeedbackFilter) ([]models.MongoFeedback, error) {
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoFeedbackCollection)
+	query := bson.M{}
+	if !filter.Since.IsZero() {
+		query["created_at"] = bson.M{"$gte": filter.Since.UTC().Format("2006-01-02T15:04:05.000Z")}
+	}
+	if filter.Kind != "" {
+		query["kind"] = filter.Kind
+	}
+	if filter.Mood != "" {
+		query["mood"] = filter.Mood
+	}
+	if filter.Engine != "" {
+		query["engine"] = filter.Engine
+	}
+
+	findOptions := options.Find().SetSort(bson.M{"created_at": 1})
+	if filter.Limit > 0 {
+		findOptions.SetLimit(int64(filter.Limit))
+	}
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("GetFeedback: failed to find feedback: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	feedback := []models.MongoFeedback{}
+	for cursor.Next(ctx) {
+		var item models.MongoFeedback
+		err := cursor.Decode(&item)
+		if err != nil {
+			return nil, fmt.Errorf("GetFeedback: failed to decode feedback: %w", err)
+		}
+		feedback = append(feedback, item)
+	}
+	return feedback, nil
+}
+
+func (c *Client) SaveReminder(ctx context.Context, reminder *models.MongoReminder) error {
+	if reminder == nil {
+		return fmt.Errorf("SaveReminder: reminder is required")
+	}
+	if reminder.UserId == "" {
+		reminder.UserId = ctx.Value(models.UserContext{}).(string)
+	}
+	if reminder.CreatedAt.IsZero() {
+		reminder.CreatedAt = time.Now().UTC()
+	}
+
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoReminderCollection)
+	_, err := collection.InsertOne(ctx, reminder)
+	if err != nil {
+		return fmt.Errorf("SaveReminder: failed to insert reminder: %w", err)
+	}
+	return nil
+}
+
+// GetReminders returns reminders of the current chat, the soonest first
+func (c *Client) GetReminders(ctx context.Context) ([]models.MongoReminder, error) {
+	userId := ctx.Value(models.UserContext{}).(string)
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoReminderCollection)
+
+	findOptions := options.Find().SetSort(bson.M{"next_run_at": 1})
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userId}, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("GetReminders: failed to find reminders: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	reminders := []models.MongoReminder{}
+	err = cursor.All(ctx, &reminders)
+	if err != nil {
+		return nil, fmt.Errorf("GetReminders: failed to decode reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+// DeleteReminder deletes a reminder of the current chat
+func (c *Client) DeleteReminder(ctx context.Context, id string) error {
+	userId := ctx.Value(models.UserContext{}).(string)
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoReminderCollection)
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id, "user_id": userId})
+	if err != nil {
+		return fmt.Errorf("DeleteReminder: failed to delete reminder: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("DeleteReminder: reminder %s not found", id)
+	}
+	return nil
+}
+
+// ClaimDueReminder atomically takes the most overdue reminder and moves it forward by the lease,
+// so other pods skip it and it's retried if the claiming pod dies before rescheduling it,
+// returns nil when nothing is due
+func (c *Client) ClaimDueReminder(ctx context.Context, now time.Time, lease time.Duration) (*models.MongoReminder, error) {
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoReminderCollection)
+
+	filter := bson.M{"next_run_at": bson.M{"$lte": now.UTC()}}
+	update := bson.M{
+		"$set": bson.M{
+			"next_run_at": now.Add(lease).UTC(),
+		},
+	}
+
+	options := options.FindOneAndUpdate().SetSort(bson.M{"next_run_at": 1}).SetReturnDocument(options.Before)
+	var reminder models.MongoReminder
+	err := collection.FindOneAndUpdate(ctx, filter, update, options).Decode(&reminder)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ClaimDueReminder: failed to claim reminder: %w", err)
+	}
+	return &reminder, nil
+}
+
+// RescheduleReminder sets the next run of a recurring reminder after it was fired
+func (c *Client) RescheduleReminder(ctx context.Context, id string, next time.Time, lastRun time.Time) error {
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoReminderCollection)
+
+	update := bson.M{
+		"$set": bson.M{
+			"next_run_at": next.UTC(),
+			"last_run_at": lastRun.UTC(),
+		},
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return fmt.Errorf("RescheduleReminder: failed to update reminder: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) SaveImage(ctx context.Context, image *models.MongoImage) error {
+	if image == nil {
+		return fmt.Errorf("SaveImage: image is required")
+	}
+	if image.UserId == "" {
+		image.UserId = ctx.Value(models.UserContext{}).(string)
+	}
+	if image.CreatedAt.IsZero() {
+		image.CreatedAt = time.Now().UTC()
+	}
+
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoImageCollection)
+	_, err := collection.InsertOne(ctx, image)
+	if err != nil {
+		return fmt.Errorf("SaveImage: failed to insert image: %w", err)
+	}
+	return nil
+}
+
+// GetImages returns a page of images of the current chat, the newest first, and the total count
+func (c *Client) GetImages(ctx context.Context, page int, pageSize int) ([]models.MongoImage, int64, error) {
+	userId := ctx.Value(models.UserContext{}).(string)
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoImageCollection)
+
+	filter := bson.M{"user_id": userId}
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetImages: failed to count images: %w", err)
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(page * pageSize)).
+		SetLimit(int64(pageSize))
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetImages: failed to find images: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	images := []models.MongoImage{}
+	err = cursor.All(ctx, &images)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetImages: failed to decode images: %w", err)
+	}
+	return images, total, nil
+}
+
+// GetImage returns an image of the current chat
+func (c *Client) GetImage(ctx context.Context, id string) (*models.MongoImage, error) {
+	userId := ctx.Value(models.UserContext{}).(string)
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoImageCollection)
+
+	var image models.MongoImage
+	err := collection.FindOne(ctx, bson.M{"_id": id, "user_id": userId}).Decode(&image)
+	if err != nil {
+		return nil, fmt.Errorf("GetImage: failed to find image %s: %w", id, err)
+	}
+	return &image, nil
+}
+
+// SaveUsageEvents adds billed events to the usage ledger, events already there are skipped, so the outbox can be replayed
+func (c *Client) SaveUsageEvents(ctx context.Context, events []models.MongoUsageEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, 0, len(events))
+	for _, event := range events {
+		documents = append(documents, event)
+	}
+
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoUsageEventCollection)
+	_, err := collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if err != nil && !isOnlyDuplicateKeyError(err) {
+		return fmt.Errorf("SaveUsageEvents: failed to insert %d usage events: %w", len(events), err)
+	}
+	return nil
+}
+
+// GetFirstUsageEventTime tells since when the ledger is kept, zero time for an empty ledger
+func (c *Client) GetFirstUsageEventTime(ctx context.Context) (time.Time, error) {
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoUsageEventCollection)
+	var event models.MongoUsageEvent
+	err := collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"created_at": 1})).Decode(&event)
+	if err == mongo.ErrNoDocuments {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("GetFirstUsageEventTime: failed to find the first usage event: %w", err)
+	}
+	return event.CreatedAt, nil
+}
+
+// GetUsageTotals sums the ledger since the given time per user and group member, for all users or the given one
+func (c *Client) GetUsageTotals(ctx context.Context, since time.Time, userId string) ([]models.MongoUsageTotals, error) {
+	match := bson.M{"created_at": bson.M{"$gte": since}}
+	if userId != "" {
+		match["user_id"] = userId
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            bson.M{"user_id": "$user_id", "member_id": "$member_id"},
+			"cost":           bson.M{"$sum": "$cost"},
+			"total_tokens":   bson.M{"$sum": "$total_tokens"},
+			"audio_minutes":  bson.M{"$sum": "$audio_minutes"},
+			"images_count":   bson.M{"$sum": "$images_count"},
+			"search_queries": bson.M{"$sum": "$search_queries"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":            0,
+			"user_id":        "$_id.user_id",
+			"member_id":      "$_id.member_id",
+			"cost":           1,
+			"total_tokens":   1,
+			"audio_minutes":  1,
+			"images_count":   1,
+			"search_queries": 1,
+		}}},
+	}
+
+	collection := c.Database(config.CONFIG.MongoDBName).Collection(MongoUsageEventCollection)
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("GetUsageTotals: failed to aggregate usage events: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	totals := []models.MongoUsageTotals{}
+	err = cursor.All(ctx, &totals)
+	if err != nil {
+		return nil, fmt.Errorf("GetUsageTotals: failed to decode usage totals: %w", err)
+	}
+	return totals, nil
+}
+
+// isOnlyDuplicateKeyError tells if an unordered insert failed on documents that already exist only
+func isOnlyDuplicateKeyError(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return false
+		}
+	}
+	return true
+}
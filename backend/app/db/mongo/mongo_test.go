@@ -225,3 +225,120 @@ func TestAddToUserThread_ExistingThreadWOPointer(t *testing.T) {
 		t.Fatalf("expected user thread to not contain user info, got %s", userThread.ThreadJson)
 	}
 }
+
+func TestClaimDueReminder(t *testing.T) {
+	uri := MockMongoServer.URIWithRandomDB()
+
+	// parse db name from uri
+	dbName := uri[strings.LastIndex(uri, "/")+1:]
+	config.CONFIG = &config.Config{
+		MongoDBName: dbName,
+	}
+	MockMongoDBClient := NewClient(uri)
+
+	// setup
+	ctx := context.WithValue(context.Background(), models.UserContext{}, "19291")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for _, reminder := range []*models.MongoReminder{
+		{ID: "due", Text: "due", NextRunAt: now.Add(-time.Minute)},
+		{ID: "later", Text: "later", NextRunAt: now.Add(time.Hour)},
+	} {
+		err := MockMongoDBClient.SaveReminder(ctx, reminder)
+		if err != nil {
+			t.Fatalf("error saving reminder: %v", err)
+		}
+	}
+
+	// test
+	reminder, err := MockMongoDBClient.ClaimDueReminder(context.Background(), now, time.Minute*10)
+	if err != nil {
+		t.Fatalf("error claiming reminder: %v", err)
+	}
+	if reminder == nil || reminder.ID != "due" || reminder.UserId != "19291" {
+		t.Fatalf("expected due reminder of user 19291 to be claimed, got %+v", reminder)
+	}
+
+	// verify claimed reminder is leased
+	reminder, err = MockMongoDBClient.ClaimDueReminder(context.Background(), now, time.Minute*10)
+	if err != nil {
+		t.Fatalf("error claiming reminder: %v", err)
+	}
+	if reminder != nil {
+		t.Fatalf("expected no due reminders, got %+v", reminder)
+	}
+
+	err = MockMongoDBClient.RescheduleReminder(ctx, "due", now.Add(time.Minute*30), now)
+	if err != nil {
+		t.Fatalf("error rescheduling reminder: %v", err)
+	}
+	reminders, err := MockMongoDBClient.GetReminders(ctx)
+	if err != nil {
+		t.Fatalf("error getting reminders: %v", err)
+	}
+	if len(reminders) != 2 || reminders[0].ID != "due" || !reminders[0].NextRunAt.Equal(now.Add(time.Minute*30)) {
+		t.Fatalf("expected rescheduled reminder to be first, got %+v", reminders)
+	}
+
+	err = MockMongoDBClient.DeleteReminder(context.WithValue(context.Background(), models.UserContext{}, "other"), "due")
+	if err == nil {
+		t.Fatalf("expected other chats not to delete the reminder")
+	}
+	err = MockMongoDBClient.DeleteReminder(ctx, "due")
+	if err != nil {
+		t.Fatalf("error deleting reminder: %v", err)
+	}
+}
+
+func TestGetImages(t *testing.T) {
+	uri := MockMongoServer.URIWithRandomDB()
+
+	// parse db name from uri
+	dbName := uri[strings.LastIndex(uri, "/")+1:]
+	config.CONFIG = &config.Config{
+		MongoDBName: dbName,
+	}
+	MockMongoDBClient := NewClient(uri)
+
+	// setup
+	ctx := context.WithValue(context.Background(), models.UserContext{}, "19291")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for i, id := range []string{"first", "second", "third"} {
+		err := MockMongoDBClient.SaveImage(ctx, &models.MongoImage{
+			ID:        id,
+			Prompt:    id,
+			Options:   models.ImageOptions{AspectRatio: "16:9", Seed: 42},
+			CreatedAt: now.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("error saving image: %v", err)
+		}
+	}
+
+	// test
+	images, total, err := MockMongoDBClient.GetImages(ctx, 0, 2)
+	if err != nil {
+		t.Fatalf("error getting images: %v", err)
+	}
+	if total != 3 || len(images) != 2 || images[0].ID != "third" || images[1].ID != "second" {
+		t.Fatalf("expected the newest 2 of 3 images, got %d %+v", total, images)
+	}
+	images, _, err = MockMongoDBClient.GetImages(ctx, 1, 2)
+	if err != nil {
+		t.Fatalf("error getting images: %v", err)
+	}
+	if len(images) != 1 || images[0].ID != "first" {
+		t.Fatalf("expected the oldest image on the second page, got %+v", images)
+	}
+
+	image, err := MockMongoDBClient.GetImage(ctx, "first")
+	if err != nil {
+		t.Fatalf("error getting image: %v", err)
+	}
+	if image.UserId != "19291" || image.Options.AspectRatio != "16:9" || image.Options.Seed != 42 {
+		t.Fatalf("expected image with options of user 19291, got %+v", image)
+	}
+	_, err = MockMongoDBClient.GetImage(context.WithValue(context.Background(), models.UserContext{}, "other"), "first")
+	if err == nil {
+		t.Fatalf("expected other chats not to get the image")
+	}
+}
@@ -12,16 +12,30 @@ import (
 // Client is a redis client
 type Client interface {
 	Del(ctx context.Context, keys ...string) *r.IntCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *r.BoolCmd
 	Get(ctx context.Context, key string) *r.StringCmd
 	IncrBy(ctx context.Context, key string, value int64) *r.IntCmd
 	IncrByFloat(ctx context.Context, key string, value float64) *r.FloatCmd
 	Keys(ctx context.Context, pattern string) *r.StringSliceCmd
+	LLen(ctx context.Context, key string) *r.IntCmd
+	LPush(ctx context.Context, key string, values ...interface{}) *r.IntCmd
+	LRange(ctx context.Context, key string, start, stop int64) *r.StringSliceCmd
+	LRem(ctx context.Context, key string, count int64, value interface{}) *r.IntCmd
+	LTrim(ctx context.Context, key string, start, stop int64) *r.StatusCmd
 	Ping(ctx context.Context) *r.StatusCmd
+	RPopLPush(ctx context.Context, source string, destination string) *r.StringCmd
+	RPush(ctx context.Context, key string, values ...interface{}) *r.IntCmd
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *r.ScanCmd
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *r.StatusCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *r.BoolCmd
+	TxPipelined(ctx context.Context, fn func(r.Pipeliner) error) ([]r.Cmder, error)
 }
 
 var RedisClient Client
 
+// SCAN_COUNT is how many keys a single SCAN call looks at
+const SCAN_COUNT = 1000
+
 // NewClient creates a new redis client
 func NewClient(cfg config.Redis) Client {
 	client := r.NewClient(&r.Options{
@@ -34,6 +48,30 @@ func NewClient(cfg config.Redis) Client {
 	return client
 }
 
+// ScanKeys lists keys matching the pattern, unlike KEYS it doesn't block redis while going through all keys,
+// SCAN may return a key more than once, so keys are deduplicated
+func ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	keys := []string{}
+	seen := map[string]bool{}
+	var cursor uint64
+	for {
+		page, next, err := RedisClient.Scan(ctx, cursor, pattern, SCAN_COUNT).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range page {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		if next == 0 {
+			return keys, nil
+		}
+		cursor = next
+	}
+}
+
 // Define a function to wrap another function in Redis cache.
 func WrapInCache(c Client, key string, duration time.Duration, fn func() (string, error)) func() (string, error) {
 	return func() (string, error) {
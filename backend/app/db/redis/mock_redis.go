@@ -2,17 +2,19 @@ package redis
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"path"
+	"sync"
 	"time"
 
 	r "github.com/go-redis/redis/v8"
 )
 
-// MockRedisClient is a mock for the Redis client in the redis package.
+// MockRedisClient is a mock for the Redis client in the redis package, safe for concurrent use like the real one.
 type MockRedisClient struct {
 	Client
-	data map[string]interface{}
+	mutex sync.Mutex
+	data  map[string]interface{}
 }
 
 func NewMockRedisClient() *MockRedisClient {
@@ -22,6 +24,8 @@ func NewMockRedisClient() *MockRedisClient {
 }
 
 func (m *MockRedisClient) IncrByFloat(ctx context.Context, key string, value float64) *r.FloatCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	if v, ok := m.data[key]; ok {
 		if f, ok := v.(float64); ok {
 			m.data[key] = f + value
@@ -37,6 +41,8 @@ func (m *MockRedisClient) IncrByFloat(ctx context.Context, key string, value flo
 }
 
 func (m *MockRedisClient) IncrBy(ctx context.Context, key string, value int64) *r.IntCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	if v, ok := m.data[key]; ok {
 		if i, ok := v.(int64); ok {
 			m.data[key] = i + value
@@ -52,18 +58,231 @@ func (m *MockRedisClient) IncrBy(ctx context.Context, key string, value int64) *
 }
 
 func (m *MockRedisClient) Get(ctx context.Context, key string) *r.StringCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	cmd := r.NewStringCmd(ctx)
 	if value, ok := m.data[key]; ok {
 		strValue := fmt.Sprintf("%v", value) // Convert the value to a string
 		cmd.SetVal(strValue)
 	} else {
 		cmd.SetVal("")
-		cmd.SetErr(errors.New("key not found"))
+		cmd.SetErr(r.Nil)
 	}
 	return cmd
 }
 
 func (m *MockRedisClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *r.StatusCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	m.data[key] = value
 	return r.NewStatusCmd(ctx)
 }
+
+func (m *MockRedisClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *r.BoolCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	cmd := r.NewBoolCmd(ctx)
+	if _, ok := m.data[key]; ok {
+		cmd.SetVal(false)
+		return cmd
+	}
+	m.data[key] = value
+	cmd.SetVal(true)
+	return cmd
+}
+
+func (m *MockRedisClient) Del(ctx context.Context, keys ...string) *r.IntCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	deleted := int64(0)
+	for _, key := range keys {
+		if _, ok := m.data[key]; ok {
+			delete(m.data, key)
+			deleted++
+		}
+	}
+	cmd := r.NewIntCmd(ctx)
+	cmd.SetVal(deleted)
+	return cmd
+}
+
+// Expire keeps the key, the mock doesn't track expirations
+func (m *MockRedisClient) Expire(ctx context.Context, key string, expiration time.Duration) *r.BoolCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, ok := m.data[key]
+	cmd := r.NewBoolCmd(ctx)
+	cmd.SetVal(ok)
+	return cmd
+}
+
+// lists are kept as string slices, the head first
+
+func (m *MockRedisClient) list(key string) []string {
+	values, _ := m.data[key].([]string)
+	return values
+}
+
+func (m *MockRedisClient) LPush(ctx context.Context, key string, values ...interface{}) *r.IntCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	list := m.list(key)
+	for _, value := range values {
+		list = append([]string{fmt.Sprintf("%v", value)}, list...)
+	}
+	m.data[key] = list
+	cmd := r.NewIntCmd(ctx)
+	cmd.SetVal(int64(len(list)))
+	return cmd
+}
+
+func (m *MockRedisClient) RPush(ctx context.Context, key string, values ...interface{}) *r.IntCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	list := m.list(key)
+	for _, value := range values {
+		list = append(list, fmt.Sprintf("%v", value))
+	}
+	m.data[key] = list
+	cmd := r.NewIntCmd(ctx)
+	cmd.SetVal(int64(len(list)))
+	return cmd
+}
+
+func (m *MockRedisClient) LLen(ctx context.Context, key string) *r.IntCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	cmd := r.NewIntCmd(ctx)
+	cmd.SetVal(int64(len(m.list(key))))
+	return cmd
+}
+
+func (m *MockRedisClient) LRem(ctx context.Context, key string, count int64, value interface{}) *r.IntCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	list := m.list(key)
+	kept := []string{}
+	removed := int64(0)
+	for _, item := range list {
+		if item == fmt.Sprintf("%v", value) && (count == 0 || removed < count) {
+			removed++
+			continue
+		}
+		kept = append(kept, item)
+	}
+	m.data[key] = kept
+	cmd := r.NewIntCmd(ctx)
+	cmd.SetVal(removed)
+	return cmd
+}
+
+// listRange resolves redis start/stop indexes, negative ones count from the tail
+func listRange(length int, start, stop int64) (int, int) {
+	if start < 0 {
+		start += int64(length)
+	}
+	if stop < 0 {
+		stop += int64(length)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= int64(length) {
+		stop = int64(length) - 1
+	}
+	if start > stop {
+		return 0, 0
+	}
+	return int(start), int(stop) + 1
+}
+
+func (m *MockRedisClient) LRange(ctx context.Context, key string, start, stop int64) *r.StringSliceCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	list := m.list(key)
+	from, to := listRange(len(list), start, stop)
+	cmd := r.NewStringSliceCmd(ctx)
+	cmd.SetVal(append([]string{}, list[from:to]...))
+	return cmd
+}
+
+func (m *MockRedisClient) LTrim(ctx context.Context, key string, start, stop int64) *r.StatusCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	list := m.list(key)
+	from, to := listRange(len(list), start, stop)
+	m.data[key] = append([]string{}, list[from:to]...)
+	return r.NewStatusCmd(ctx)
+}
+
+func (m *MockRedisClient) RPopLPush(ctx context.Context, source string, destination string) *r.StringCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	cmd := r.NewStringCmd(ctx)
+	list := m.list(source)
+	if len(list) == 0 {
+		cmd.SetErr(r.Nil)
+		return cmd
+	}
+	value := list[len(list)-1]
+	m.data[source] = list[:len(list)-1]
+	m.data[destination] = append([]string{value}, m.list(destination)...)
+	cmd.SetVal(value)
+	return cmd
+}
+
+func (m *MockRedisClient) Keys(ctx context.Context, pattern string) *r.StringSliceCmd {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	keys := []string{}
+	for key := range m.data {
+		if matched, _ := path.Match(pattern, key); matched {
+			keys = append(keys, key)
+		}
+	}
+	cmd := r.NewStringSliceCmd(ctx)
+	cmd.SetVal(keys)
+	return cmd
+}
+
+// Scan returns all matching keys at once
+func (m *MockRedisClient) Scan(ctx context.Context, cursor uint64, match string, count int64) *r.ScanCmd {
+	keys := m.Keys(ctx, match).Val()
+	cmd := r.NewScanCmd(ctx, nil)
+	cmd.SetVal(keys, 0)
+	return cmd
+}
+
+// mockPipeliner queues writes of a transaction, only the commands the code uses in transactions are implemented
+type mockPipeliner struct {
+	r.Pipeliner
+	writes []func(data map[string]interface{})
+}
+
+func (p *mockPipeliner) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *r.StatusCmd {
+	p.writes = append(p.writes, func(data map[string]interface{}) { data[key] = value })
+	return r.NewStatusCmd(ctx)
+}
+
+func (p *mockPipeliner) Del(ctx context.Context, keys ...string) *r.IntCmd {
+	p.writes = append(p.writes, func(data map[string]interface{}) {
+		for _, key := range keys {
+			delete(data, key)
+		}
+	})
+	return r.NewIntCmd(ctx)
+}
+
+// TxPipelined applies queued writes at once, like MULTI/EXEC
+func (m *MockRedisClient) TxPipelined(ctx context.Context, fn func(r.Pipeliner) error) ([]r.Cmder, error) {
+	pipe := &mockPipeliner{}
+	if err := fn(pipe); err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, write := range pipe.writes {
+		write(m.data)
+	}
+	return nil, nil
+}
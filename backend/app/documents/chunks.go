@@ -0,0 +1,114 @@
+package documents
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// CHUNK_SIZE in characters, about 2k tokens
+	CHUNK_SIZE = 8000
+
+	// MAX_DOCUMENT_SIZE in characters, about 30k tokens, the document stays in the thread and is sent with every follow-up
+	MAX_DOCUMENT_SIZE = 120000
+)
+
+// Chunk is a part of a document, pages are 0 if unknown
+type Chunk struct {
+	Text      string
+	FirstPage int
+	LastPage  int
+}
+
+// Chunks splits the document into chunks of up to chunkSize characters at paragraph boundaries,
+// each page start is marked with [p. N] for citations. Text after maxSize characters is dropped and truncated is true.
+func Chunks(document Document, chunkSize int, maxSize int) (chunks []Chunk, truncated bool) {
+	current := Chunk{}
+	var text strings.Builder
+	size := 0
+	flush := func() {
+		if strings.TrimSpace(text.String()) == "" {
+			return
+		}
+		current.Text = strings.TrimSpace(text.String())
+		chunks = append(chunks, current)
+		current = Chunk{}
+		text.Reset()
+	}
+	add := func(paragraph string, page int) {
+		if text.Len() > 0 && text.Len()+len(paragraph)+1 > chunkSize {
+			flush()
+		}
+		if current.FirstPage == 0 {
+			current.FirstPage = page
+		}
+		current.LastPage = page
+		text.WriteString(paragraph)
+		text.WriteString("\n")
+	}
+
+	for _, page := range document.Pages {
+		paragraphs := strings.Split(page.Text, "\n")
+		// the marker sticks to the first paragraph, so it's never left alone at the end of a chunk
+		if page.Number > 0 {
+			paragraphs[0] = fmt.Sprintf("[p. %d]\n%s", page.Number, paragraphs[0])
+		}
+		for _, paragraph := range paragraphs {
+			for _, piece := range splitRunes(paragraph, chunkSize-1) {
+				if size+len(piece) > maxSize {
+					flush()
+					return chunks, true
+				}
+				size += len(piece) + 1
+				add(piece, page.Number)
+			}
+		}
+	}
+	flush()
+	return chunks, false
+}
+
+// splitRunes splits a long paragraph without breaking UTF-8 characters
+func splitRunes(text string, size int) []string {
+	if len(text) <= size {
+		return []string{text}
+	}
+	pieces := []string{}
+	for len(text) > size {
+		cut := size
+		for cut > 0 && !isRuneStart(text[cut]) {
+			cut--
+		}
+		if space := strings.LastIndexByte(text[:cut], ' '); space > size/2 {
+			cut = space + 1
+		}
+		pieces = append(pieces, text[:cut])
+		text = text[cut:]
+	}
+	return append(pieces, text)
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// Render formats chunks as a context block for the AI, with citations instructions
+func Render(name string, chunks []Chunk, truncated bool) string {
+	var result strings.Builder
+	fmt.Fprintf(&result, "<document name=%q parts=\"%d\">\n", name, len(chunks))
+	for i, chunk := range chunks {
+		pages := ""
+		if chunk.FirstPage > 0 {
+			pages = fmt.Sprintf(" pages=\"%d-%d\"", chunk.FirstPage, chunk.LastPage)
+			if chunk.FirstPage == chunk.LastPage {
+				pages = fmt.Sprintf(" page=\"%d\"", chunk.FirstPage)
+			}
+		}
+		fmt.Fprintf(&result, "<part n=\"%d\"%s>\n%s\n</part>\n", i+1, pages, chunk.Text)
+	}
+	if truncated {
+		result.WriteString("<truncated/>\n")
+	}
+	result.WriteString("</document>")
+	return result.String()
+}
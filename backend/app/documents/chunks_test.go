@@ -0,0 +1,70 @@
+package documents
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunks(t *testing.T) {
+	document := Document{Name: "paper.pdf", Kind: KindPDF, Pages: []Page{
+		{Number: 1, Text: "Intro paragraph.\nSecond paragraph."},
+		{Number: 2, Text: "Methods."},
+		{Number: 3, Text: "Results."},
+	}}
+	chunks, truncated := Chunks(document, 1000, 10000)
+	assert.False(t, truncated)
+	assert.Equal(t, []Chunk{{
+		Text:      "[p. 1]\nIntro paragraph.\nSecond paragraph.\n[p. 2]\nMethods.\n[p. 3]\nResults.",
+		FirstPage: 1,
+		LastPage:  3,
+	}}, chunks)
+
+	// page markers stay with the page text
+	chunks, _ = Chunks(document, 40, 10000)
+	assert.Equal(t, []Chunk{
+		{Text: "[p. 1]\nIntro paragraph.", FirstPage: 1, LastPage: 1},
+		{Text: "Second paragraph.\n[p. 2]\nMethods.", FirstPage: 1, LastPage: 2},
+		{Text: "[p. 3]\nResults.", FirstPage: 3, LastPage: 3},
+	}, chunks)
+
+	chunks, truncated = Chunks(document, 1000, 45)
+	assert.True(t, truncated)
+	assert.Equal(t, []Chunk{{Text: "[p. 1]\nIntro paragraph.\nSecond paragraph.", FirstPage: 1, LastPage: 1}}, chunks)
+}
+
+func TestChunksLongParagraph(t *testing.T) {
+	text := strings.Repeat("слово ", 100)
+	chunks, truncated := Chunks(Document{Pages: []Page{{Text: text}}}, 100, 10000)
+	assert.False(t, truncated)
+	assert.Greater(t, len(chunks), 1)
+	joined := ""
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk.Text), 100)
+		assert.Equal(t, 0, chunk.FirstPage)
+		assert.True(t, strings.HasPrefix(chunk.Text, "слово"), chunk.Text)
+		joined += chunk.Text + " "
+	}
+	assert.Equal(t, strings.Fields(text), strings.Fields(joined))
+}
+
+func TestRender(t *testing.T) {
+	rendered := Render("paper.pdf", []Chunk{
+		{Text: "[p. 1]\nIntro", FirstPage: 1, LastPage: 2},
+		{Text: "[p. 3]\nEnd", FirstPage: 3, LastPage: 3},
+	}, true)
+	assert.Equal(t, `<document name="paper.pdf" parts="2">
+<part n="1" pages="1-2">
+[p. 1]
+Intro
+</part>
+<part n="2" page="3">
+[p. 3]
+End
+</part>
+<truncated/>
+</document>`, rendered)
+
+	assert.Equal(t, "<document name=\"notes.txt\" parts=\"1\">\n<part n=\"1\">\nhi\n</part>\n</document>", Render("notes.txt", []Chunk{{Text: "hi"}}, false))
+}
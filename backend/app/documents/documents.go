@@ -0,0 +1,252 @@
+// Package documents detects document types and extracts their text for AI turns
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type Kind string
+
+const (
+	KindUnsupported Kind = ""
+	KindMedia       Kind = "media"
+	KindPDF         Kind = "pdf"
+	KindDOCX        Kind = "docx"
+	KindText        Kind = "text"
+
+	// EXTRACT_TIMEOUT bounds pdftotext on huge or malformed files
+	EXTRACT_TIMEOUT = time.Minute
+
+	// MAX_DOCX_XML_SIZE guards against zip bombs
+	MAX_DOCX_XML_SIZE = 50 * 1024 * 1024
+)
+
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true, ".rst": true, ".csv": true, ".tsv": true, ".log": true, ".tex": true,
+	".json": true, ".yaml": true, ".yml": true, ".toml": true, ".ini": true, ".xml": true, ".html": true, ".htm": true, ".css": true, ".scss": true,
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".java": true, ".kt": true, ".scala": true, ".c": true, ".h": true,
+	".cpp": true, ".hpp": true, ".cc": true, ".cs": true, ".rb": true, ".php": true, ".rs": true, ".swift": true, ".sh": true, ".sql": true,
+	".lua": true, ".r": true, ".dart": true, ".vue": true, ".svelte": true, ".proto": true, ".gradle": true, ".dockerfile": true,
+}
+
+var mediaExtensions = map[string]bool{
+	".mp3": true, ".m4a": true, ".wav": true, ".ogg": true, ".oga": true, ".opus": true, ".flac": true, ".aac": true, ".mpga": true,
+	".webm": true, ".mp4": true, ".mov": true, ".mkv": true, ".avi": true, ".mpeg": true, ".m4v": true,
+}
+
+var textMimeTypes = map[string]bool{
+	"application/json": true, "application/xml": true, "application/x-yaml": true, "application/yaml": true, "application/javascript": true,
+	"application/x-sh": true, "application/sql": true, "application/x-httpd-php": true, "application/toml": true,
+}
+
+// DetectKind routes a file by its MIME type, falling back to the extension for generic types like application/octet-stream
+func DetectKind(mimeType string, fileName string) Kind {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	switch {
+	case strings.HasPrefix(mimeType, "audio/"), strings.HasPrefix(mimeType, "video/"):
+		return KindMedia
+	case mimeType == "application/pdf":
+		return KindPDF
+	case mimeType == "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return KindDOCX
+	case strings.HasPrefix(mimeType, "text/"), textMimeTypes[mimeType]:
+		return KindText
+	}
+
+	extension := strings.ToLower(filepath.Ext(fileName))
+	switch {
+	case mediaExtensions[extension]:
+		return KindMedia
+	case extension == ".pdf":
+		return KindPDF
+	case extension == ".docx":
+		return KindDOCX
+	case textExtensions[extension]:
+		return KindText
+	}
+	return KindUnsupported
+}
+
+// Page is the text of a single page, Number is 0 if pages are unknown, e.g. for plain text
+type Page struct {
+	Number int
+	Text   string
+}
+
+type Document struct {
+	Name  string
+	Kind  Kind
+	Pages []Page
+}
+
+// Text is the whole document text without page markers
+func (d Document) Text() string {
+	texts := make([]string, 0, len(d.Pages))
+	for _, page := range d.Pages {
+		texts = append(texts, page.Text)
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n\n"))
+}
+
+// Extract reads the text of a PDF, DOCX or text file
+func Extract(fileName string, name string, kind Kind) (Document, error) {
+	document := Document{Name: name, Kind: kind}
+	var err error
+	switch kind {
+	case KindPDF:
+		document.Pages, err = extractPDF(fileName)
+	case KindDOCX:
+		document.Pages, err = extractDOCX(fileName)
+	case KindText:
+		document.Pages, err = extractText(fileName)
+	default:
+		return document, fmt.Errorf("unsupported document kind %q", kind)
+	}
+	if err != nil {
+		return document, err
+	}
+	if document.Text() == "" {
+		return document, fmt.Errorf("no text found in %s", name)
+	}
+	return document, nil
+}
+
+// extractPDF uses pdftotext (poppler-utils), which separates pages with form feeds
+func extractPDF(fileName string) ([]Page, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), EXTRACT_TIMEOUT)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "pdftotext", "-enc", "UTF-8", fileName, "-")
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract text from %s: %v\n%s", fileName, err, stderr.String())
+	}
+	return SplitPages(string(output)), nil
+}
+
+// SplitPages splits pdftotext output into numbered pages, empty pages keep their numbers
+func SplitPages(text string) []Page {
+	pages := []Page{}
+	for i, pageText := range strings.Split(text, "\f") {
+		pageText = strings.TrimSpace(pageText)
+		if pageText == "" {
+			continue
+		}
+		pages = append(pages, Page{Number: i + 1, Text: pageText})
+	}
+	return pages
+}
+
+// extractDOCX reads paragraphs of word/document.xml, page numbers come from page breaks Word saves when rendering
+func extractDOCX(fileName string) ([]Page, error) {
+	archive, err := zip.OpenReader(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", fileName, err)
+	}
+	defer archive.Close()
+	for _, file := range archive.File {
+		if file.Name != "word/document.xml" {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open document.xml of %s: %w", fileName, err)
+		}
+		defer reader.Close()
+		return ParseDOCXDocument(io.LimitReader(reader, MAX_DOCX_XML_SIZE))
+	}
+	return nil, fmt.Errorf("no word/document.xml in %s", fileName)
+}
+
+// ParseDOCXDocument extracts text of word/document.xml, pages are numbered only if the document has page breaks
+func ParseDOCXDocument(reader io.Reader) ([]Page, error) {
+	decoder := xml.NewDecoder(reader)
+	pages := []Page{}
+	var page strings.Builder
+	inText := false
+	// Word saves both an explicit page break and a rendered one after it, so empty pages are skipped
+	newPage := func() {
+		text := strings.TrimSpace(page.String())
+		if text == "" {
+			return
+		}
+		pages = append(pages, Page{Number: len(pages) + 1, Text: text})
+		page.Reset()
+	}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse document.xml: %w", err)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				page.WriteString("\t")
+			case "br":
+				if getXMLAttribute(element, "type") == "page" {
+					newPage()
+				} else {
+					page.WriteString("\n")
+				}
+			case "lastRenderedPageBreak":
+				newPage()
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				page.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				page.Write(element)
+			}
+		}
+	}
+	newPage()
+
+	if len(pages) == 1 {
+		pages[0].Number = 0
+	}
+	return pages, nil
+}
+
+func getXMLAttribute(element xml.StartElement, name string) string {
+	for _, attribute := range element.Attr {
+		if attribute.Name.Local == name {
+			return attribute.Value
+		}
+	}
+	return ""
+}
+
+func extractText(fileName string) ([]Page, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", fileName, err)
+	}
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return nil, fmt.Errorf("%s is not a UTF-8 text file", fileName)
+	}
+	return []Page{{Text: strings.TrimSpace(string(content))}}, nil
+}
@@ -0,0 +1,76 @@
+package documents
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectKind(t *testing.T) {
+	assert.Equal(t, KindMedia, DetectKind("audio/mpeg", "song.mp3"))
+	assert.Equal(t, KindMedia, DetectKind("video/mp4", ""))
+	assert.Equal(t, KindMedia, DetectKind("application/octet-stream", "Meeting.M4A"))
+	assert.Equal(t, KindPDF, DetectKind("application/pdf", "paper.pdf"))
+	assert.Equal(t, KindPDF, DetectKind("application/octet-stream", "paper.pdf"))
+	assert.Equal(t, KindDOCX, DetectKind("application/vnd.openxmlformats-officedocument.wordprocessingml.document", "cv.docx"))
+	assert.Equal(t, KindText, DetectKind("text/csv; charset=utf-8", "data.csv"))
+	assert.Equal(t, KindText, DetectKind("application/json", "package.json"))
+	assert.Equal(t, KindText, DetectKind("", "main.go"))
+	assert.Equal(t, KindText, DetectKind("application/octet-stream", "README.md"))
+	assert.Equal(t, KindUnsupported, DetectKind("application/zip", "archive.zip"))
+	assert.Equal(t, KindUnsupported, DetectKind("application/msword", "old.doc"))
+}
+
+func TestSplitPages(t *testing.T) {
+	pages := SplitPages("First page\n\fSecond page\n\f\n\fFourth page\n\f")
+	assert.Equal(t, []Page{
+		{Number: 1, Text: "First page"},
+		{Number: 2, Text: "Second page"},
+		{Number: 4, Text: "Fourth page"},
+	}, pages)
+}
+
+func TestParseDOCXDocument(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Title</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Hello, </w:t></w:r><w:r><w:t>world</w:t><w:tab/><w:t>!</w:t></w:r></w:p>
+<w:p><w:r><w:br w:type="page"/></w:r><w:r><w:lastRenderedPageBreak/><w:t>Second page</w:t></w:r></w:p>
+</w:body></w:document>`
+	pages, err := ParseDOCXDocument(strings.NewReader(xml))
+	assert.NoError(t, err)
+	assert.Equal(t, []Page{
+		{Number: 1, Text: "Title\nHello, world\t!"},
+		{Number: 2, Text: "Second page"},
+	}, pages)
+
+	// no page breaks, no page numbers
+	pages, err = ParseDOCXDocument(strings.NewReader(`<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>Only</w:t></w:r></w:p></w:body></w:document>`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Page{{Number: 0, Text: "Only"}}, pages)
+
+	_, err = ParseDOCXDocument(strings.NewReader(`<w:document><w:body>`))
+	assert.Error(t, err)
+}
+
+func TestExtractText(t *testing.T) {
+	directory := t.TempDir()
+	textFile := filepath.Join(directory, "notes.md")
+	assert.NoError(t, os.WriteFile(textFile, []byte("\xef\xbb\xbf# Notes\n\n- one\n"), 0644))
+	document, err := Extract(textFile, "notes.md", KindText)
+	assert.NoError(t, err)
+	assert.Equal(t, "# Notes\n\n- one", document.Text())
+
+	binaryFile := filepath.Join(directory, "data.txt")
+	assert.NoError(t, os.WriteFile(binaryFile, []byte{0x00, 0xff, 0x10}, 0644))
+	_, err = Extract(binaryFile, "data.txt", KindText)
+	assert.Error(t, err)
+
+	emptyFile := filepath.Join(directory, "empty.txt")
+	assert.NoError(t, os.WriteFile(emptyFile, []byte(" \n"), 0644))
+	_, err = Extract(emptyFile, "empty.txt", KindText)
+	assert.Error(t, err)
+}
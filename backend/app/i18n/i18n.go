@@ -0,0 +1,293 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"talk2robots/m/v2/app/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DEFAULT_LANGUAGE is used when the user language is unknown or there is no translation for a message
+const DEFAULT_LANGUAGE = "en"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// Args are named message arguments, i.e. {name} placeholders and {count, plural, ...} selectors
+type Args map[string]interface{}
+
+var (
+	catalog     = map[string]map[string]string{}
+	catalogLock sync.RWMutex
+)
+
+func init() {
+	entries, err := locales.ReadDir("locales")
+	if err != nil {
+		log.Fatalf("i18n: failed to read embedded locales: %v", err)
+	}
+	for _, entry := range entries {
+		data, err := locales.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			log.Fatalf("i18n: failed to read embedded locale %s: %v", entry.Name(), err)
+		}
+		err = Load(strings.TrimSuffix(entry.Name(), ".json"), data)
+		if err != nil {
+			log.Fatalf("i18n: %v", err)
+		}
+	}
+}
+
+// Load adds translations from a flat JSON object of message ids to messages, overriding existing ones
+func Load(language string, data []byte) error {
+	messages := map[string]string{}
+	err := json.Unmarshal(data, &messages)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s translations: %w", language, err)
+	}
+
+	catalogLock.Lock()
+	defer catalogLock.Unlock()
+	language = strings.ToLower(language)
+	if catalog[language] == nil {
+		catalog[language] = map[string]string{}
+	}
+	for id, message := range messages {
+		catalog[language][id] = message
+	}
+	return nil
+}
+
+// LoadDir loads <language>.json translation files from a directory on top of the embedded ones
+func LoadDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("LoadDir: failed to list %s: %w", dir, err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("LoadDir: failed to read %s: %w", file, err)
+		}
+		err = Load(strings.TrimSuffix(filepath.Base(file), ".json"), data)
+		if err != nil {
+			return fmt.Errorf("LoadDir: %w", err)
+		}
+	}
+	log.Infof("Loaded %d translation files from %s", len(files), dir)
+	return nil
+}
+
+// Languages returns all languages with translations
+func Languages() []string {
+	catalogLock.RLock()
+	defer catalogLock.RUnlock()
+	languages := make([]string, 0, len(catalog))
+	for language := range catalog {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+func IsSupported(language string) bool {
+	catalogLock.RLock()
+	defer catalogLock.RUnlock()
+	_, ok := catalog[language]
+	return ok
+}
+
+// Language normalizes an IETF language tag like "pt-BR" to a base language code, empty if it's not a language code
+func Language(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if index := strings.IndexAny(code, "-_"); index >= 0 {
+		code = code[:index]
+	}
+	if len(code) < 2 || len(code) > 3 {
+		return ""
+	}
+	for _, r := range code {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return code
+}
+
+// T translates a message to the language stored in the context
+func T(ctx context.Context, id string, args ...Args) string {
+	language, _ := ctx.Value(models.LanguageContext{}).(string)
+	return Translate(language, id, args...)
+}
+
+// Translate formats a message in the given language, falling back to English and then to the id itself,
+// so literal texts pass through untouched
+func Translate(language string, id string, args ...Args) string {
+	language = Language(language)
+	catalogLock.RLock()
+	messages, supported := catalog[language]
+	message, ok := messages[id]
+	if !ok {
+		message, ok = catalog[DEFAULT_LANGUAGE][id]
+		if ok || !supported {
+			language = DEFAULT_LANGUAGE
+		}
+	}
+	catalogLock.RUnlock()
+	if !ok {
+		message = id
+	}
+
+	arguments := Args{}
+	for _, a := range args {
+		for key, value := range a {
+			arguments[key] = value
+		}
+	}
+	return format(language, message, arguments, "")
+}
+
+// format renders {name} placeholders and {name, plural, =0 {...} one {...} other {...}} selectors,
+// # inside a plural branch is replaced with the number
+func format(language string, message string, args Args, number string) string {
+	var result strings.Builder
+	for i := 0; i < len(message); i++ {
+		switch message[i] {
+		case '#':
+			if number != "" {
+				result.WriteString(number)
+				continue
+			}
+		case '{':
+			end := matchingBrace(message, i)
+			if end < 0 {
+				break
+			}
+			result.WriteString(formatArgument(language, message[i+1:end], args))
+			i = end
+			continue
+		}
+		result.WriteByte(message[i])
+	}
+	return result.String()
+}
+
+func formatArgument(language string, argument string, args Args) string {
+	parts := strings.SplitN(argument, ",", 3)
+	name := strings.TrimSpace(parts[0])
+	value, ok := args[name]
+	if len(parts) < 3 || strings.TrimSpace(parts[1]) != "plural" {
+		if !ok {
+			return "{" + argument + "}"
+		}
+		return fmt.Sprint(value)
+	}
+
+	count, isNumber := toFloat(value)
+	if !isNumber {
+		return "{" + argument + "}"
+	}
+	options := parsePluralOptions(parts[2])
+	branch, ok := options[fmt.Sprintf("=%v", count)]
+	if !ok {
+		branch, ok = options[pluralCategory(language, count)]
+	}
+	if !ok {
+		branch = options["other"]
+	}
+	return format(language, branch, args, fmt.Sprint(value))
+}
+
+// parsePluralOptions parses "one {# day} other {# days}" into selectors and their messages
+func parsePluralOptions(options string) map[string]string {
+	result := map[string]string{}
+	for {
+		start := strings.IndexByte(options, '{')
+		if start < 0 {
+			return result
+		}
+		end := matchingBrace(options, start)
+		if end < 0 {
+			return result
+		}
+		result[strings.TrimSpace(options[:start])] = options[start+1 : end]
+		options = options[end+1:]
+	}
+}
+
+func matchingBrace(message string, start int) int {
+	depth := 0
+	for i := start; i < len(message); i++ {
+		switch message[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// pluralCategory implements CLDR cardinal plural rules for supported languages
+func pluralCategory(language string, count float64) string {
+	isInteger := count == float64(int64(count))
+	n := int64(count)
+	if n < 0 {
+		n = -n
+	}
+	switch language {
+	case "ru", "uk", "be":
+		if !isInteger {
+			return "other"
+		}
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "he":
+		switch {
+		case isInteger && n == 1:
+			return "one"
+		case isInteger && n == 2:
+			return "two"
+		default:
+			return "other"
+		}
+	default:
+		if isInteger && n == 1 {
+			return "one"
+		}
+		return "other"
+	}
+}
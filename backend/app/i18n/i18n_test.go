@@ -0,0 +1,76 @@
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslatePlaceholders(t *testing.T) {
+	assert.Equal(t, "Switched to GPT-4o engine!", Translate("en", "engine.switched", Args{"engine": "GPT-4o"}))
+	assert.Equal(t, "Hello {name}", Translate("en", "Hello {name}"))
+	assert.Equal(t, "Hello Ann", Translate("en", "Hello {name}", Args{"name": "Ann"}))
+	// argument values are not parsed as messages
+	assert.Equal(t, "Hello {x}", Translate("en", "Hello {name}", Args{"name": "{x}"}))
+}
+
+func TestTranslatePlurals(t *testing.T) {
+	message := "{count, plural, =0 {no days} one {# day} other {# days}}"
+	assert.Equal(t, "no days", Translate("en", message, Args{"count": 0}))
+	assert.Equal(t, "1 day", Translate("en", message, Args{"count": 1}))
+	assert.Equal(t, "5 days", Translate("en", message, Args{"count": 5}))
+
+	message = "{count, plural, one {# день} few {# дня} many {# дней} other {# дня}}"
+	assert.Equal(t, "1 день", Translate("ru", message, Args{"count": 1}))
+	assert.Equal(t, "21 день", Translate("ru", message, Args{"count": 21}))
+	assert.Equal(t, "3 дня", Translate("ru", message, Args{"count": 3}))
+	assert.Equal(t, "11 дней", Translate("ru", message, Args{"count": 11}))
+	assert.Equal(t, "25 дней", Translate("ru", message, Args{"count": 25}))
+	assert.Equal(t, "1.5 дня", Translate("ru", message, Args{"count": 1.5}))
+}
+
+func TestTranslateFallbacks(t *testing.T) {
+	assert.NoError(t, Load("xx", []byte(`{"only.xx": "xx message"}`)))
+	assert.Equal(t, "xx message", Translate("xx", "only.xx"))
+	assert.Equal(t, Translate("en", "oopsie"), Translate("xx", "oopsie"))
+	assert.Equal(t, Translate("en", "oopsie"), Translate("unknown", "oopsie"))
+	assert.Equal(t, "no such message", Translate("ru", "no such message"))
+
+	ctx := context.WithValue(context.Background(), models.LanguageContext{}, "ru")
+	assert.Equal(t, Translate("ru", "oopsie"), T(ctx, "oopsie"))
+	assert.Equal(t, Translate("en", "oopsie"), T(context.Background(), "oopsie"))
+}
+
+func TestLanguage(t *testing.T) {
+	assert.Equal(t, "pt", Language("pt-BR"))
+	assert.Equal(t, "en", Language("EN_us"))
+	assert.Equal(t, "es", Language(" es "))
+	assert.Equal(t, "", Language(""))
+	assert.Equal(t, "", Language("english"))
+	assert.Equal(t, "", Language("e1"))
+}
+
+func TestLocalesHaveSameMessages(t *testing.T) {
+	english := map[string]string{}
+	data, err := locales.ReadFile("locales/en.json")
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &english))
+
+	entries, err := locales.ReadDir("locales")
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		messages := map[string]string{}
+		data, err := locales.ReadFile("locales/" + entry.Name())
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(data, &messages))
+		for id := range english {
+			assert.Contains(t, messages, id, "%s is missing %s", entry.Name(), id)
+		}
+		for id := range messages {
+			assert.Contains(t, english, id, "%s has unknown %s", entry.Name(), id)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package lib
+
+// UserDictationDraftKey keeps the /dictate draft follow-up voice messages edit
+func UserDictationDraftKey(user string, topic string) string {
+	if topic != "" && topic != "0" {
+		return user + ":" + topic + ":dictation-draft"
+	}
+	return user + ":dictation-draft"
+}
@@ -0,0 +1,20 @@
+package lib
+
+import "fmt"
+
+func UserLastInteractionKey(user string, topic string) string {
+	if topic != "" && topic != "0" {
+		return user + ":" + topic + ":last-interaction"
+	}
+	return user + ":last-interaction"
+}
+
+// AnswerInteractionKey points from an answer message to the prompt it answered, message ids are unique per chat
+func AnswerInteractionKey(user string, answerMessageId int) string {
+	return fmt.Sprintf("%s:answer-interaction:%d", user, answerMessageId)
+}
+
+// FeedbackCommentKey points from the "what was wrong?" question to the disliked message
+func FeedbackCommentKey(user string, questionMessageId int) string {
+	return fmt.Sprintf("%s:feedback-comment:%d", user, questionMessageId)
+}
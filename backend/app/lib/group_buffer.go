@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/models"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// GROUP_BUFFER_TTL is how long group messages are kept for digests
+	GROUP_BUFFER_TTL = 48 * time.Hour
+
+	// GROUP_BUFFER_SIZE is the maximum amount of messages kept per group
+	GROUP_BUFFER_SIZE = 1000
+)
+
+var bufferRangeRegex = regexp.MustCompile(`^(\d+)([mhd]?)$`)
+
+func GroupBufferKey(chatID string) string {
+	return chatID + ":group-buffer"
+}
+
+// GroupBufferTranscriptKey keeps a transcript of a buffered voice message, so it's transcribed once
+func GroupBufferTranscriptKey(chatID string, fileID string) string {
+	return chatID + ":group-buffer-transcript:" + fileID
+}
+
+func GroupBufferEnabledKey(chatID string) string {
+	return chatID + ":group-buffer-enabled"
+}
+
+func IsGroupBufferEnabled(chatID string) bool {
+	enabled, err := redis.RedisClient.Get(context.Background(), GroupBufferEnabledKey(chatID)).Result()
+	if err != nil {
+		return false
+	}
+	return enabled == "true"
+}
+
+func SetGroupBufferEnabled(chatID string, enabled bool) error {
+	if !enabled {
+		return redis.RedisClient.Del(context.Background(), GroupBufferEnabledKey(chatID)).Err()
+	}
+	return redis.RedisClient.Set(context.Background(), GroupBufferEnabledKey(chatID), "true", 0).Err()
+}
+
+func PurgeGroupBuffer(chatID string) error {
+	return redis.RedisClient.Del(context.Background(), GroupBufferKey(chatID)).Err()
+}
+
+// AppendToGroupBuffer adds a message to the group rolling buffer, capped by size and TTL
+func AppendToGroupBuffer(chatID string, message models.GroupBufferMessage) {
+	if message.Text == "" && message.Transcript == "" && message.MediaFileID == "" {
+		return
+	}
+	if message.Timestamp == 0 {
+		message.Timestamp = time.Now().Unix()
+	}
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Errorf("AppendToGroupBuffer: failed to marshal message in chat %s: %v", chatID, err)
+		return
+	}
+
+	ctx := context.Background()
+	key := GroupBufferKey(chatID)
+	err = redis.RedisClient.LPush(ctx, key, string(messageBytes)).Err()
+	if err != nil {
+		log.Errorf("AppendToGroupBuffer: failed to push message in chat %s: %v", chatID, err)
+		return
+	}
+	redis.RedisClient.LTrim(ctx, key, 0, GROUP_BUFFER_SIZE-1)
+	redis.RedisClient.Expire(ctx, key, GROUP_BUFFER_TTL)
+}
+
+func SaveGroupBufferTranscript(chatID string, fileID string, transcript string) {
+	if fileID == "" || transcript == "" {
+		return
+	}
+	err := redis.RedisClient.Set(context.Background(), GroupBufferTranscriptKey(chatID, fileID), transcript, GROUP_BUFFER_TTL).Err()
+	if err != nil {
+		log.Errorf("SaveGroupBufferTranscript: failed to save transcript in chat %s: %v", chatID, err)
+	}
+}
+
+func GetGroupBufferTranscript(chatID string, fileID string) string {
+	transcript, err := redis.RedisClient.Get(context.Background(), GroupBufferTranscriptKey(chatID, fileID)).Result()
+	if err != nil {
+		return ""
+	}
+	return transcript
+}
+
+// GetGroupBuffer returns buffered messages in chronological order, either last `count` or the ones newer than `since`
+func GetGroupBuffer(chatID string, count int, since time.Duration) ([]models.GroupBufferMessage, error) {
+	stop := int64(GROUP_BUFFER_SIZE - 1)
+	if count > 0 && count < GROUP_BUFFER_SIZE {
+		stop = int64(count - 1)
+	}
+	rawMessages, err := redis.RedisClient.LRange(context.Background(), GroupBufferKey(chatID), 0, stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("GetGroupBuffer: failed to read buffer: %w", err)
+	}
+
+	oldest := time.Now().Add(-GROUP_BUFFER_TTL)
+	if since > 0 && since < GROUP_BUFFER_TTL {
+		oldest = time.Now().Add(-since)
+	}
+
+	messages := make([]models.GroupBufferMessage, 0, len(rawMessages))
+	// the list is newest first, walk it backwards
+	for i := len(rawMessages) - 1; i >= 0; i-- {
+		var message models.GroupBufferMessage
+		err := json.Unmarshal([]byte(rawMessages[i]), &message)
+		if err != nil {
+			log.Warnf("GetGroupBuffer: failed to unmarshal message in chat %s: %v", chatID, err)
+			continue
+		}
+		if time.Unix(message.Timestamp, 0).Before(oldest) {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// ParseBufferRange parses "200" as a messages count or "6h"/"30m"/"2d" as a time range
+func ParseBufferRange(param string) (count int, since time.Duration, ok bool) {
+	matches := bufferRangeRegex.FindStringSubmatch(param)
+	if matches == nil {
+		return 0, 0, false
+	}
+	value, err := strconv.Atoi(matches[1])
+	if err != nil || value <= 0 {
+		return 0, 0, false
+	}
+	switch matches[2] {
+	case "":
+		return value, 0, true
+	case "m":
+		return 0, time.Duration(value) * time.Minute, true
+	case "h":
+		return 0, time.Duration(value) * time.Hour, true
+	case "d":
+		return 0, time.Duration(value) * 24 * time.Hour, true
+	}
+	return 0, 0, false
+}
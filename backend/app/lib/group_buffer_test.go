@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBufferRange(t *testing.T) {
+	count, since, ok := ParseBufferRange("200")
+	assert.True(t, ok)
+	assert.Equal(t, 200, count)
+	assert.Equal(t, time.Duration(0), since)
+
+	count, since, ok = ParseBufferRange("6h")
+	assert.True(t, ok)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, 6*time.Hour, since)
+
+	_, since, ok = ParseBufferRange("30m")
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Minute, since)
+
+	_, since, ok = ParseBufferRange("2d")
+	assert.True(t, ok)
+	assert.Equal(t, 48*time.Hour, since)
+
+	_, _, ok = ParseBufferRange("es")
+	assert.False(t, ok)
+
+	_, _, ok = ParseBufferRange("0")
+	assert.False(t, ok)
+}
@@ -0,0 +1,254 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GroupEngineAliases are short engine names admins can use in /groupsettings
+var GroupEngineAliases = map[string]models.Engine{
+	"gpt-4o-mini": models.ChatGpt4oMini,
+	"gpt-4o":      models.ChatGpt4o,
+	"haiku":       models.Haiku,
+	"sonnet":      models.Sonnet,
+	"opus":        models.Opus,
+	"grok":        models.Grok,
+	"llama-8b":    models.LlamaV3_8b,
+	"llama-70b":   models.LlamaV3_70b,
+}
+
+// paidEngines need a paid subscription, same as switching to them with /models
+var paidEngines = map[models.Engine]bool{
+	models.ChatGpt4:            true,
+	models.ChatGpt4Turbo:       true,
+	models.ChatGpt4TurboVision: true,
+	models.ChatGpt4o:           true,
+	models.Haiku:               true,
+	models.Sonnet:              true,
+	models.Opus:                true,
+}
+
+var groupModes = []ModeName{ChatGPT, VoiceGPT, Grammar, Teacher, Transcribe, Summarize, Translate, Interpret, Dictate, Search}
+
+func GroupSettingsKey(chatID string) string {
+	return chatID + ":group-settings"
+}
+
+func DefaultGroupSettings() models.GroupSettings {
+	return models.GroupSettings{
+		ImagesAllowed: true,
+		VoiceAllowed:  true,
+		Triggers:      []models.GroupTrigger{models.GroupTriggerMention},
+	}
+}
+
+func GetGroupSettings(chatID string) models.GroupSettings {
+	settingsString, err := redis.RedisClient.Get(context.Background(), GroupSettingsKey(chatID)).Result()
+	if err != nil || settingsString == "" {
+		return DefaultGroupSettings()
+	}
+	settings := DefaultGroupSettings()
+	err = json.Unmarshal([]byte(settingsString), &settings)
+	if err != nil {
+		log.Errorf("GetGroupSettings: failed to unmarshal settings for chat %s: %v", chatID, err)
+		return DefaultGroupSettings()
+	}
+	return settings
+}
+
+func SaveGroupSettings(chatID string, settings models.GroupSettings) error {
+	settingsBytes, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("SaveGroupSettings: failed to marshal settings: %w", err)
+	}
+	return redis.RedisClient.Set(context.Background(), GroupSettingsKey(chatID), string(settingsBytes), 0).Err()
+}
+
+func ResetGroupSettings(chatID string) error {
+	return redis.RedisClient.Del(context.Background(), GroupSettingsKey(chatID)).Err()
+}
+
+func IsGroupModeAllowed(settings models.GroupSettings, mode ModeName) bool {
+	return len(settings.AllowedModes) == 0 || containsString(settings.AllowedModes, string(mode))
+}
+
+func IsGroupEngineAllowed(settings models.GroupSettings, engine models.Engine) bool {
+	return len(settings.AllowedEngines) == 0 || containsString(settings.AllowedEngines, string(engine))
+}
+
+// IsEngineInSubscription checks if the subscription can use the engine, free plans only get free engines
+func IsEngineInSubscription(subscription models.MongoSubscriptionName, engine models.Engine) bool {
+	return !paidEngines[engine] || (subscription != models.FreeSubscriptionName && subscription != models.FreePlusSubscriptionName)
+}
+
+// GetGroupEngine returns the chat engine if admins allow it, otherwise the first allowed engine the subscription can use,
+// falling back to the default engine
+func GetGroupEngine(settings models.GroupSettings, subscription models.MongoSubscriptionName, engine models.Engine) models.Engine {
+	if IsGroupEngineAllowed(settings, engine) {
+		return engine
+	}
+	for _, allowedEngine := range settings.AllowedEngines {
+		if IsEngineInSubscription(subscription, models.Engine(allowedEngine)) {
+			return models.Engine(allowedEngine)
+		}
+	}
+	return models.ChatGpt4oMini
+}
+
+func HasGroupTrigger(settings models.GroupSettings, trigger models.GroupTrigger) bool {
+	for _, groupTrigger := range settings.Triggers {
+		if groupTrigger == trigger {
+			return true
+		}
+	}
+	return false
+}
+
+// HasGroupKeyword checks if the text contains any of the group keywords as a whole word
+func HasGroupKeyword(settings models.GroupSettings, text string) bool {
+	for _, keyword := range settings.Keywords {
+		re, err := regexp.Compile(`(?i)(^|\W)` + regexp.QuoteMeta(keyword) + `($|\W)`)
+		if err == nil && re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyGroupSetting updates a single setting from /groupsettings <setting> <value>, engines are checked against the group subscription
+func ApplyGroupSetting(settings *models.GroupSettings, setting string, value string, subscription models.MongoSubscriptionName) error {
+	values := splitSettingValues(value)
+	switch strings.ToLower(setting) {
+	case "modes":
+		modes := []string{}
+		for _, value := range values {
+			if value == "all" {
+				modes = []string{}
+				break
+			}
+			if !containsMode(groupModes, ModeName(value)) {
+				return fmt.Errorf("unknown mode %s", value)
+			}
+			modes = append(modes, value)
+		}
+		settings.AllowedModes = modes
+	case "engines":
+		engines := []string{}
+		for _, value := range values {
+			if value == "all" {
+				engines = []string{}
+				break
+			}
+			engine, ok := GroupEngineAliases[value]
+			if !ok {
+				return fmt.Errorf("unknown engine %s", value)
+			}
+			if !IsEngineInSubscription(subscription, engine) {
+				return fmt.Errorf("engine %s needs a paid plan, see /upgrade", value)
+			}
+			engines = append(engines, string(engine))
+		}
+		settings.AllowedEngines = engines
+	case "images", "voice":
+		allowed, err := parseOnOff(value)
+		if err != nil {
+			return err
+		}
+		if strings.ToLower(setting) == "images" {
+			settings.ImagesAllowed = allowed
+		} else {
+			settings.VoiceAllowed = allowed
+		}
+	case "cap":
+		capValue, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(value), "$"), 64)
+		if err != nil || capValue < 0 {
+			return fmt.Errorf("cap should be a non-negative amount in dollars, 0 means no cap")
+		}
+		settings.MemberMonthlyCap = capValue
+	case "triggers":
+		triggers := []models.GroupTrigger{}
+		for _, value := range values {
+			trigger := models.GroupTrigger(value)
+			if trigger != models.GroupTriggerMention && trigger != models.GroupTriggerReply && trigger != models.GroupTriggerKeyword {
+				return fmt.Errorf("unknown trigger %s", value)
+			}
+			triggers = append(triggers, trigger)
+		}
+		if len(triggers) == 0 {
+			return fmt.Errorf("at least one trigger is required")
+		}
+		settings.Triggers = triggers
+	case "keywords":
+		settings.Keywords = values
+	case "memory":
+		enabled, err := parseOnOff(value)
+		if err != nil {
+			return err
+		}
+		settings.MemberMemory = enabled
+	default:
+		return fmt.Errorf("unknown setting %s", setting)
+	}
+	return nil
+}
+
+func splitSettingValues(value string) []string {
+	values := []string{}
+	for _, field := range strings.FieldsFunc(strings.ToLower(value), func(r rune) bool { return r == ',' || r == ' ' }) {
+		values = append(values, strings.TrimSpace(field))
+	}
+	return values
+}
+
+func parseOnOff(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected on or off, got %s", value)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMode(modes []ModeName, mode ModeName) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateGroupMemberUsage checks a group member spend against the per-member cap set by admins
+func ValidateGroupMemberUsage(ctx context.Context, settings models.GroupSettings) bool {
+	if settings.MemberMonthlyCap <= 0 {
+		return true
+	}
+	member, ok := ctx.Value(models.MemberContext{}).(string)
+	if !ok || member == "" {
+		return true
+	}
+	userId := ctx.Value(models.UserContext{}).(string)
+	memberTotalCost, err := redis.RedisClient.Get(context.Background(), MemberTotalCostKey(userId, member)).Float64()
+	if err != nil && err.Error() != "redis: nil" {
+		log.Errorf("Error getting member %s total cost in chat %s: %v", member, userId, err)
+	}
+	return memberTotalCost < settings.MemberMonthlyCap
+}
@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyGroupSetting(t *testing.T) {
+	settings := DefaultGroupSettings()
+
+	assert.NoError(t, ApplyGroupSetting(&settings, "modes", "chatgpt, grammar", models.BasicSubscriptionName))
+	assert.Equal(t, []string{"chatgpt", "grammar"}, settings.AllowedModes)
+	assert.True(t, IsGroupModeAllowed(settings, ChatGPT))
+	assert.False(t, IsGroupModeAllowed(settings, VoiceGPT))
+
+	assert.NoError(t, ApplyGroupSetting(&settings, "modes", "all", models.BasicSubscriptionName))
+	assert.True(t, IsGroupModeAllowed(settings, VoiceGPT))
+
+	assert.NoError(t, ApplyGroupSetting(&settings, "engines", "gpt-4o-mini,haiku", models.BasicSubscriptionName))
+	assert.True(t, IsGroupEngineAllowed(settings, models.Haiku))
+	assert.False(t, IsGroupEngineAllowed(settings, models.Opus))
+
+	assert.NoError(t, ApplyGroupSetting(&settings, "images", "off", models.BasicSubscriptionName))
+	assert.False(t, settings.ImagesAllowed)
+	assert.NoError(t, ApplyGroupSetting(&settings, "voice", "off", models.BasicSubscriptionName))
+	assert.False(t, settings.VoiceAllowed)
+
+	assert.NoError(t, ApplyGroupSetting(&settings, "cap", "$1.5", models.BasicSubscriptionName))
+	assert.Equal(t, 1.5, settings.MemberMonthlyCap)
+
+	assert.NoError(t, ApplyGroupSetting(&settings, "triggers", "mention,keyword", models.BasicSubscriptionName))
+	assert.NoError(t, ApplyGroupSetting(&settings, "keywords", "robot", models.BasicSubscriptionName))
+	assert.True(t, HasGroupTrigger(settings, models.GroupTriggerKeyword))
+	assert.False(t, HasGroupTrigger(settings, models.GroupTriggerReply))
+	assert.True(t, HasGroupKeyword(settings, "hey Robot, what's up?"))
+	assert.False(t, HasGroupKeyword(settings, "robots are cool"))
+
+	assert.False(t, settings.MemberMemory)
+	assert.NoError(t, ApplyGroupSetting(&settings, "memory", "on", models.BasicSubscriptionName))
+	assert.True(t, settings.MemberMemory)
+
+	assert.Error(t, ApplyGroupSetting(&settings, "modes", "image", models.BasicSubscriptionName))
+	assert.Error(t, ApplyGroupSetting(&settings, "engines", "gpt-2", models.BasicSubscriptionName))
+	assert.Error(t, ApplyGroupSetting(&settings, "voice", "maybe", models.BasicSubscriptionName))
+	assert.Error(t, ApplyGroupSetting(&settings, "cap", "-1", models.BasicSubscriptionName))
+	assert.Error(t, ApplyGroupSetting(&settings, "triggers", "", models.BasicSubscriptionName))
+	assert.Error(t, ApplyGroupSetting(&settings, "colors", "blue", models.BasicSubscriptionName))
+}
+
+func TestGroupEnginesFollowSubscription(t *testing.T) {
+	settings := DefaultGroupSettings()
+	assert.Error(t, ApplyGroupSetting(&settings, "engines", "gpt-4o-mini,opus", models.FreeSubscriptionName), "free groups can't allow paid engines")
+	assert.Empty(t, settings.AllowedEngines)
+	assert.NoError(t, ApplyGroupSetting(&settings, "engines", "grok,gpt-4o-mini", models.FreePlusSubscriptionName))
+
+	// settings saved on a paid plan are kept after a downgrade, but answers don't use paid engines
+	settings.AllowedEngines = []string{string(models.Sonnet), string(models.Grok)}
+	assert.Equal(t, models.Grok, GetGroupEngine(settings, models.FreeSubscriptionName, models.ChatGpt4oMini))
+	assert.Equal(t, models.Sonnet, GetGroupEngine(settings, models.BasicSubscriptionName, models.ChatGpt4oMini))
+	assert.Equal(t, models.Grok, GetGroupEngine(settings, models.FreeSubscriptionName, models.Grok), "the chat engine is kept when allowed")
+
+	settings.AllowedEngines = []string{string(models.Opus)}
+	assert.Equal(t, models.ChatGpt4oMini, GetGroupEngine(settings, models.FreeSubscriptionName, models.Grok), "none qualify, use the default")
+}
@@ -0,0 +1,162 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DEFAULT_ASPECT_RATIO = "1:1"
+	MAX_IMAGE_SEED       = 1<<32 - 1
+)
+
+// AspectRatios are supported by all image engines, close ratios are used where an engine lacks one
+var AspectRatios = []string{"1:1", "16:9", "9:16", "4:3", "3:4", "3:2", "2:3"}
+
+// ImageStyles are DALL-E 3 styles, other engines draw natural with a raw style
+var ImageStyles = []string{"vivid", "natural"}
+
+// ErrImageOptionNotInPlan is returned for options the user's plan doesn't include
+var ErrImageOptionNotInPlan = errors.New("image option is not included in the plan")
+
+func ImageOptionsKey(chatID string) string {
+	return chatID + ":image-options"
+}
+
+func DefaultImageOptions() models.ImageOptions {
+	return models.ImageOptions{
+		AspectRatio: DEFAULT_ASPECT_RATIO,
+		N:           1,
+	}
+}
+
+func GetImageOptions(chatID string) models.ImageOptions {
+	optionsString, err := redis.RedisClient.Get(context.Background(), ImageOptionsKey(chatID)).Result()
+	if err != nil || optionsString == "" {
+		return DefaultImageOptions()
+	}
+	options := DefaultImageOptions()
+	err = json.Unmarshal([]byte(optionsString), &options)
+	if err != nil {
+		log.Errorf("GetImageOptions: failed to unmarshal options for chat %s: %v", chatID, err)
+		return DefaultImageOptions()
+	}
+	return options
+}
+
+func SaveImageOptions(chatID string, options models.ImageOptions) error {
+	optionsBytes, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("SaveImageOptions: failed to marshal options: %w", err)
+	}
+	return redis.RedisClient.Set(context.Background(), ImageOptionsKey(chatID), string(optionsBytes), 0).Err()
+}
+
+// MaxImagesPerRequest by plan, every image is billed
+func MaxImagesPerRequest(ctx context.Context) int {
+	switch {
+	case IsUserBasic(ctx):
+		return 4
+	case IsUserFreePlus(ctx):
+		return 2
+	}
+	return 1
+}
+
+// IsImageHDAllowed only on basic, HD images cost twice as much
+func IsImageHDAllowed(ctx context.Context) bool {
+	return IsUserBasic(ctx)
+}
+
+// ValidateImageOptions checks options against the user's plan
+func ValidateImageOptions(ctx context.Context, options models.ImageOptions) error {
+	if options.HD && !IsImageHDAllowed(ctx) {
+		return fmt.Errorf("%w: hd", ErrImageOptionNotInPlan)
+	}
+	if options.N > MaxImagesPerRequest(ctx) {
+		return fmt.Errorf("%w: n", ErrImageOptionNotInPlan)
+	}
+	return nil
+}
+
+// ApplyImageOption validates and applies a single option: ar, hd, n, style or seed
+func ApplyImageOption(options *models.ImageOptions, name string, value string) error {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch strings.ToLower(name) {
+	case "ar", "aspect":
+		// keyboard callbacks can't have colons
+		ratio := strings.Replace(value, "x", ":", 1)
+		if !containsString(AspectRatios, ratio) {
+			return fmt.Errorf("unknown aspect ratio %s, available: %s", value, strings.Join(AspectRatios, ", "))
+		}
+		options.AspectRatio = ratio
+	case "hd":
+		switch value {
+		case "", "on", "true", "yes":
+			options.HD = true
+		case "off", "false", "no":
+			options.HD = false
+		default:
+			return fmt.Errorf("hd should be on or off")
+		}
+	case "n":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 4 {
+			return fmt.Errorf("n should be a number from 1 to 4")
+		}
+		options.N = n
+	case "style":
+		if !containsString(ImageStyles, value) {
+			return fmt.Errorf("unknown style %s, available: %s", value, strings.Join(ImageStyles, ", "))
+		}
+		options.Style = value
+	case "seed":
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seed < 0 || seed > MAX_IMAGE_SEED {
+			return fmt.Errorf("seed should be a number from 0 to %d", int64(MAX_IMAGE_SEED))
+		}
+		options.Seed = seed
+	default:
+		return fmt.Errorf("unknown option --%s, use --ar, --hd, --n, --style or --seed", name)
+	}
+	return nil
+}
+
+// ParseImageOptions takes --name value options out of the prompt, --hd needs no value
+func ParseImageOptions(text string, options models.ImageOptions) (string, models.ImageOptions, error) {
+	// keep line breaks of prompts without options
+	if !strings.Contains(text, "--") {
+		return strings.TrimSpace(text), options, nil
+	}
+	words := strings.Fields(text)
+	prompt := []string{}
+	for i := 0; i < len(words); i++ {
+		name, isOption := strings.CutPrefix(words[i], "--")
+		if !isOption || name == "" {
+			prompt = append(prompt, words[i])
+			continue
+		}
+		value := ""
+		if i+1 < len(words) && !strings.HasPrefix(words[i+1], "--") && (name != "hd" || isSwitchValue(words[i+1])) {
+			value = words[i+1]
+			i++
+		}
+		if err := ApplyImageOption(&options, name, value); err != nil {
+			return text, options, err
+		}
+	}
+	return strings.Join(prompt, " "), options, nil
+}
+
+// isSwitchValue tells "--hd off" from "--hd a cat"
+func isSwitchValue(word string) bool {
+	return containsString([]string{"on", "off", "true", "false", "yes", "no"}, strings.ToLower(word))
+}
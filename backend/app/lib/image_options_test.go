@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseImageOptions(t *testing.T) {
+	prompt, options, err := ParseImageOptions("a fox in the snow --ar 16:9 --hd --n 3 --style natural --seed 42", DefaultImageOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, "a fox in the snow", prompt)
+	assert.Equal(t, models.ImageOptions{AspectRatio: "16:9", HD: true, N: 3, Style: "natural", Seed: 42}, options)
+
+	// --hd needs no value, chat defaults are kept unless overridden
+	prompt, options, err = ParseImageOptions("--hd a cat\non a bicycle --ar 9x16", models.ImageOptions{AspectRatio: "1:1", N: 2, Style: "vivid"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a cat on a bicycle", prompt)
+	assert.Equal(t, models.ImageOptions{AspectRatio: "9:16", HD: true, N: 2, Style: "vivid"}, options)
+
+	prompt, _, err = ParseImageOptions(" a cat\non a bicycle ", DefaultImageOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, "a cat\non a bicycle", prompt)
+
+	for _, text := range []string{"a cat --ar 5:1", "a cat --n 9", "a cat --style anime", "a cat --seed -1", "a cat --quality high"} {
+		_, _, err = ParseImageOptions(text, DefaultImageOptions())
+		assert.Error(t, err, text)
+	}
+}
+
+func TestValidateImageOptions(t *testing.T) {
+	free := context.WithValue(context.Background(), models.SubscriptionContext{}, models.FreeSubscriptionName)
+	freePlus := context.WithValue(context.Background(), models.SubscriptionContext{}, models.FreePlusSubscriptionName)
+	basic := context.WithValue(context.Background(), models.SubscriptionContext{}, models.BasicSubscriptionName)
+
+	assert.NoError(t, ValidateImageOptions(free, DefaultImageOptions()))
+	assert.True(t, errors.Is(ValidateImageOptions(free, models.ImageOptions{N: 2}), ErrImageOptionNotInPlan))
+	assert.NoError(t, ValidateImageOptions(freePlus, models.ImageOptions{N: 2}))
+	assert.Error(t, ValidateImageOptions(freePlus, models.ImageOptions{N: 1, HD: true}))
+	assert.NoError(t, ValidateImageOptions(basic, models.ImageOptions{N: 4, HD: true}))
+}
+
+func TestImageOptionsSize(t *testing.T) {
+	width, height := models.ImageOptions{AspectRatio: "16:9"}.Size()
+	assert.Equal(t, []int{16, 9}, []int{width, height})
+	width, height = models.ImageOptions{}.Size()
+	assert.Equal(t, []int{1, 1}, []int{width, height})
+}
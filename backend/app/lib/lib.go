@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 	"github.com/valyala/fasthttp"
 
@@ -39,6 +40,7 @@ func SetupUserAndContext(userId string, client ClientName, channelId string, top
 	currentContext = context.WithValue(currentContext, models.ClientContext{}, string(client))
 	currentContext = context.WithValue(currentContext, models.ChannelContext{}, channelId)
 	currentContext = context.WithValue(currentContext, models.TopicContext{}, topicId)
+	currentContext = context.WithValue(currentContext, models.RequestContext{}, uuid.New().String())
 	currentContext, cancelContext = context.WithTimeout(currentContext, TIMEOUT)
 
 	log.Infof("Fetching subscription from DB for user: %s", userId)
@@ -65,6 +67,9 @@ func SetupUserAndContext(userId string, client ClientName, channelId string, top
 		currentSubscriptionName = user.SubscriptionType.Name
 	}
 	currentContext = context.WithValue(currentContext, models.SubscriptionContext{}, currentSubscriptionName)
+	if user != nil {
+		currentContext = context.WithValue(currentContext, models.LanguageContext{}, user.Language)
+	}
 	log.Infof("User %s subscription: %s", userId, currentSubscriptionName)
 	return user, currentContext, cancelContext, err
 }
@@ -123,7 +128,9 @@ func AddBotSuffixToGroupCommands(ctx context.Context, message string) string {
 		commands := []string{
 			"/chatgpt", "/voicegpt", "/clear", "/downgrade", "/grammar",
 			"/start", "/status", "/summarize", "/support", "/teacher",
-			"/terms", "/transcribe", "/upgrade", "/translate", "/billing",
+			"/terms", "/transcribe", "/upgrade", "/translate", "/interpret", "/dictate", "/billing",
+			"/groupbuffer", "/groupsettings", "/mymemory", "/language",
+			"/remind", "/schedule", "/reminders", "/voice", "/links", "/reactions", "/search", "/edit", "/images",
 		}
 
 		for _, command := range commands {
@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/web"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LINK_CACHE_TTL keeps fetched pages, so the same link shared in many chats is downloaded once
+const LINK_CACHE_TTL = 6 * time.Hour
+
+func LinksDisabledKey(chatID string) string {
+	return chatID + ":links-disabled"
+}
+
+func LinkCacheKey(url string) string {
+	hash := sha256.Sum256([]byte(url))
+	return "link-cache:" + hex.EncodeToString(hash[:])
+}
+
+// IsLinksEnabled is true unless links reading was turned off in the chat with /links off
+func IsLinksEnabled(chatID string) bool {
+	disabled, err := redis.RedisClient.Get(context.Background(), LinksDisabledKey(chatID)).Result()
+	if err != nil {
+		return true
+	}
+	return disabled != "true"
+}
+
+func SetLinksEnabled(chatID string, enabled bool) error {
+	if enabled {
+		return redis.RedisClient.Del(context.Background(), LinksDisabledKey(chatID)).Err()
+	}
+	return redis.RedisClient.Set(context.Background(), LinksDisabledKey(chatID), "true", 0).Err()
+}
+
+func GetCachedPage(url string) (*web.Page, bool) {
+	pageString, err := redis.RedisClient.Get(context.Background(), LinkCacheKey(url)).Result()
+	if err != nil || pageString == "" {
+		return nil, false
+	}
+	page := &web.Page{}
+	err = json.Unmarshal([]byte(pageString), page)
+	if err != nil {
+		log.Errorf("GetCachedPage: failed to unmarshal page %s: %v", url, err)
+		return nil, false
+	}
+	return page, true
+}
+
+func CachePage(url string, page *web.Page) {
+	pageBytes, err := json.Marshal(page)
+	if err != nil {
+		log.Errorf("CachePage: failed to marshal page %s: %v", url, err)
+		return
+	}
+	err = redis.RedisClient.Set(context.Background(), LinkCacheKey(url), string(pageBytes), LINK_CACHE_TTL).Err()
+	if err != nil {
+		log.Errorf("CachePage: failed to cache page %s: %v", url, err)
+	}
+}
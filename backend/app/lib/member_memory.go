@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"talk2robots/m/v2/app/db/redis"
+)
+
+// MEMBER_MEMORY_SIZE is the maximum amount of private notes kept per group member
+const MEMBER_MEMORY_SIZE = 20
+
+func MemberMemoryKey(chatID string, member string) string {
+	return chatID + ":member:" + member + ":memory"
+}
+
+// AddMemberMemory keeps a private note of a group member, separate from the shared group thread
+func AddMemberMemory(chatID string, member string, note string) error {
+	ctx := context.Background()
+	key := MemberMemoryKey(chatID, member)
+	err := redis.RedisClient.LPush(ctx, key, note).Err()
+	if err != nil {
+		return fmt.Errorf("AddMemberMemory: failed to push note: %w", err)
+	}
+	return redis.RedisClient.LTrim(ctx, key, 0, MEMBER_MEMORY_SIZE-1).Err()
+}
+
+// GetMemberMemory returns private notes of a group member, oldest first
+func GetMemberMemory(chatID string, member string) ([]string, error) {
+	notes, err := redis.RedisClient.LRange(context.Background(), MemberMemoryKey(chatID, member), 0, MEMBER_MEMORY_SIZE-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("GetMemberMemory: failed to read notes: %w", err)
+	}
+	for i, j := 0, len(notes)-1; i < j; i, j = i+1, j-1 {
+		notes[i], notes[j] = notes[j], notes[i]
+	}
+	return notes, nil
+}
+
+func ClearMemberMemory(chatID string, member string) error {
+	return redis.RedisClient.Del(context.Background(), MemberMemoryKey(chatID, member)).Err()
+}
+
+// MemberMemoryGroupKey keeps the group a member manages private notes of in the private chat with the bot
+func MemberMemoryGroupKey(member string) string {
+	return member + ":member-memory-group"
+}
+
+func SetMemberMemoryGroup(member string, chatID string) error {
+	return redis.RedisClient.Set(context.Background(), MemberMemoryGroupKey(member), chatID, 0).Err()
+}
+
+func GetMemberMemoryGroup(member string) string {
+	chatID, err := redis.RedisClient.Get(context.Background(), MemberMemoryGroupKey(member)).Result()
+	if err != nil {
+		return ""
+	}
+	return chatID
+}
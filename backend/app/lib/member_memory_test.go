@@ -0,0 +1,31 @@
+package lib
+
+import (
+	"talk2robots/m/v2/app/db/redis"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemberMemory(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+
+	assert.Equal(t, "", GetMemberMemoryGroup("42"))
+	assert.NoError(t, SetMemberMemoryGroup("42", "-100123"))
+	assert.Equal(t, "-100123", GetMemberMemoryGroup("42"))
+
+	assert.NoError(t, AddMemberMemory("-100123", "42", "call me Sam"))
+	assert.NoError(t, AddMemberMemory("-100123", "42", "I prefer metric units"))
+	notes, err := GetMemberMemory("-100123", "42")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"call me Sam", "I prefer metric units"}, notes)
+
+	notes, err = GetMemberMemory("-100456", "42")
+	assert.NoError(t, err)
+	assert.Empty(t, notes, "notes are kept per group")
+
+	assert.NoError(t, ClearMemberMemory("-100123", "42"))
+	notes, err = GetMemberMemory("-100123", "42")
+	assert.NoError(t, err)
+	assert.Empty(t, notes)
+}
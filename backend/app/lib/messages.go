@@ -0,0 +1,33 @@
+package lib
+
+import "fmt"
+
+// MessageTextKey keeps recent message texts, since telegram doesn't pass them along with reactions
+func MessageTextKey(user string, messageId int) string {
+	return fmt.Sprintf("%s:message-text:%d", user, messageId)
+}
+
+func InlineQueryCacheKey(user string, engine string, queryHash string) string {
+	return user + ":inline:" + engine + ":" + queryHash
+}
+
+func InlineQueryPendingKey(user string, queryHash string) string {
+	return user + ":inline-pending:" + queryHash
+}
+
+func InlineQueryLatestKey(user string) string {
+	return user + ":inline-latest"
+}
+
+// AlbumKey keeps parts of a media group until the album is complete, any replica can receive a part
+func AlbumKey(chatID string, mediaGroupID string) string {
+	return chatID + ":album:" + mediaGroupID
+}
+
+func AlbumLatestKey(chatID string, mediaGroupID string) string {
+	return chatID + ":album-latest:" + mediaGroupID
+}
+
+func AlbumFlushKey(chatID string, mediaGroupID string) string {
+	return chatID + ":album-flush:" + mediaGroupID
+}
@@ -21,7 +21,10 @@ const (
 	Transcribe ModeName = "transcribe"
 	Summarize  ModeName = "summarize"
 	Translate  ModeName = "translate"
+	Interpret  ModeName = "interpret"
+	Dictate    ModeName = "dictate"
 	Image      ModeName = "image"
+	Search     ModeName = "search"
 )
 
 func SaveMode(chatID string, topicID string, mode ModeName, params string) {
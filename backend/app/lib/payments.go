@@ -16,6 +16,10 @@ func UserTotalImagesKey(user string) string {
 	return user + ":total_images"
 }
 
+func UserTotalSearchesKey(user string) string {
+	return user + ":total_searches"
+}
+
 func UserCurrentThreadPromptKey(user string, topic string) string {
 	if topic != "" && topic != "0" {
 		return user + ":" + topic + ":current-thread-prompt-tokens"
@@ -29,3 +33,25 @@ func UserCurrentThreadKey(user string, topic string) string {
 	}
 	return user + ":current-thread"
 }
+
+// RequestCostKey sums the cost of all calls billed for a request, so feedback is attributed to the whole answer
+func RequestCostKey(request string) string {
+	return "request:" + request + ":cost"
+}
+
+const (
+	// UsageOutboxKey lists billed usage events waiting for the usage ledger in mongo, the newest first
+	UsageOutboxKey = "usage_outbox"
+
+	// UsageOutboxProcessingKey lists events taken from the outbox, they are removed once saved in the ledger
+	UsageOutboxProcessingKey = "usage_outbox:processing"
+
+	// UsagePeriodStartKey is when monthly usage counters were last cleared
+	UsagePeriodStartKey = "usage_period_start"
+)
+
+// MemberTotalCostKey tracks a group member spend within the group budget,
+// matches UserTotalCostKey("*") wildcard, so it's cleared monthly as well
+func MemberTotalCostKey(user string, member string) string {
+	return user + ":member:" + member + ":total_cost"
+}
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"talk2robots/m/v2/app/db/redis"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReactionActionNames are actions an emoji reaction can run on a message
+var ReactionActionNames = []string{"read_aloud", "translate", "grammar", "summarize"}
+
+// DefaultReactionActions are used until the chat sets its own with /reactions
+var DefaultReactionActions = map[string]string{
+	"🔊": "read_aloud",
+	"🌐": "translate",
+	"✍": "grammar",
+	"📝": "summarize",
+}
+
+func ReactionActionsKey(chatID string) string {
+	return chatID + ":reaction-actions"
+}
+
+// NormalizeReactionEmoji drops emoji variation selectors, so ✍️ and ✍ are the same reaction
+func NormalizeReactionEmoji(emoji string) string {
+	return strings.ReplaceAll(strings.TrimSpace(emoji), "\uFE0F", "")
+}
+
+// GetReactionActions returns emoji to action map of the chat
+func GetReactionActions(chatID string) map[string]string {
+	actionsString, err := redis.RedisClient.Get(context.Background(), ReactionActionsKey(chatID)).Result()
+	if err != nil || actionsString == "" {
+		return DefaultReactionActions
+	}
+	actions := map[string]string{}
+	err = json.Unmarshal([]byte(actionsString), &actions)
+	if err != nil {
+		log.Errorf("GetReactionActions: failed to unmarshal actions for chat %s: %v", chatID, err)
+		return DefaultReactionActions
+	}
+	return actions
+}
+
+// SetReactionAction maps the emoji to an action, "off" removes the emoji
+func SetReactionAction(chatID string, emoji string, action string) error {
+	emoji = NormalizeReactionEmoji(emoji)
+	if emoji == "" || strings.IndexFunc(emoji, func(r rune) bool { return r < 0x80 || unicode.IsLetter(r) }) >= 0 {
+		return fmt.Errorf("%s is not an emoji", emoji)
+	}
+	action = strings.ToLower(action)
+	if action != "off" && !slices.Contains(ReactionActionNames, action) {
+		return fmt.Errorf("unknown action %s", action)
+	}
+
+	actions := map[string]string{}
+	for existingEmoji, existingAction := range GetReactionActions(chatID) {
+		actions[existingEmoji] = existingAction
+	}
+	if action == "off" {
+		delete(actions, emoji)
+	} else {
+		actions[emoji] = action
+	}
+	actionsBytes, err := json.Marshal(actions)
+	if err != nil {
+		return fmt.Errorf("SetReactionAction: failed to marshal actions: %w", err)
+	}
+	return redis.RedisClient.Set(context.Background(), ReactionActionsKey(chatID), string(actionsBytes), 0).Err()
+}
+
+func ResetReactionActions(chatID string) error {
+	return redis.RedisClient.Del(context.Background(), ReactionActionsKey(chatID)).Err()
+}
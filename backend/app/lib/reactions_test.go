@@ -0,0 +1,30 @@
+package lib
+
+import (
+	"talk2robots/m/v2/app/db/redis"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReactionActions(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+
+	assert.Equal(t, DefaultReactionActions, GetReactionActions("123"))
+
+	assert.NoError(t, SetReactionAction("123", "🔥", "summarize"))
+	assert.NoError(t, SetReactionAction("123", "✍️", "off"))
+	actions := GetReactionActions("123")
+	assert.Equal(t, "summarize", actions["🔥"])
+	assert.Equal(t, "summarize", actions["📝"])
+	_, ok := actions["✍"]
+	assert.False(t, ok)
+	assert.Equal(t, "read_aloud", DefaultReactionActions["🔊"], "defaults are kept intact")
+	assert.Equal(t, "grammar", DefaultReactionActions["✍"], "defaults are kept intact")
+
+	assert.Error(t, SetReactionAction("123", "🔥", "dance"))
+	assert.Error(t, SetReactionAction("123", "fire", "summarize"))
+
+	assert.NoError(t, ResetReactionActions("123"))
+	assert.Equal(t, DefaultReactionActions, GetReactionActions("123"))
+}
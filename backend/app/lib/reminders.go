@@ -0,0 +1,442 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones for reminders, the container image has no zoneinfo
+)
+
+const (
+	// MAX_REMINDERS_PER_CHAT limits active reminders and scheduled prompts per chat/group
+	MAX_REMINDERS_PER_CHAT = 25
+
+	// MIN_REMINDER_INTERVAL is the shortest allowed interval between runs of a recurring reminder
+	MIN_REMINDER_INTERVAL = 15 * time.Minute
+
+	// DEFAULT_REMINDER_HOUR is used when a day is given without time, e.g. "tomorrow"
+	DEFAULT_REMINDER_HOUR = 9
+)
+
+// ReminderSchedule is either a one-off time or a cron expression evaluated in the user's time zone
+type ReminderSchedule struct {
+	At   time.Time
+	Cron string
+}
+
+// Next returns the next run after the given time, zero if there is none
+func (s ReminderSchedule) Next(after time.Time) time.Time {
+	if s.Cron == "" {
+		if s.At.After(after) {
+			return s.At
+		}
+		return time.Time{}
+	}
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+	return cron.Next(after)
+}
+
+var (
+	fixedZoneRegex     = regexp.MustCompile(`^(?:utc|gmt)?\s*([+-])(\d{1,2})(?::?(\d{2}))?$`)
+	clockRegex         = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+	durationRegex      = regexp.MustCompile(`^(\d+)\s*(m|min|mins|minute|minutes|h|hr|hrs|hour|hours|d|day|days|w|week|weeks)$`)
+	everyIntervalRegex = regexp.MustCompile(`^(\d+)\s*(m|min|mins|minute|minutes|h|hr|hrs|hour|hours)$`)
+	weekdays           = map[string]time.Weekday{
+		"sunday": time.Sunday, "sun": time.Sunday,
+		"monday": time.Monday, "mon": time.Monday,
+		"tuesday": time.Tuesday, "tue": time.Tuesday,
+		"wednesday": time.Wednesday, "wed": time.Wednesday,
+		"thursday": time.Thursday, "thu": time.Thursday,
+		"friday": time.Friday, "fri": time.Friday,
+		"saturday": time.Saturday, "sat": time.Saturday,
+	}
+)
+
+// ParseTimeZone accepts IANA names (Europe/Berlin) and UTC offsets (+3, UTC-05:30, GMT+2)
+// and returns the location with its canonical name to store
+func ParseTimeZone(name string) (*time.Location, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.UTC, "UTC", nil
+	}
+	if match := fixedZoneRegex.FindStringSubmatch(strings.ToLower(name)); match != nil {
+		hours, _ := strconv.Atoi(match[2])
+		minutes, _ := strconv.Atoi(match[3])
+		if hours > 14 || minutes > 59 {
+			return nil, "", fmt.Errorf("invalid UTC offset %s", name)
+		}
+		canonical := fmt.Sprintf("UTC%s%02d:%02d", match[1], hours, minutes)
+		offset := hours*3600 + minutes*60
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(canonical, offset), canonical, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, "", fmt.Errorf("unknown time zone %s", name)
+	}
+	return location, location.String(), nil
+}
+
+// ParseReminder parses a schedule at the start of a text and returns the rest of it, e.g.
+// "in 10 minutes stretch", "tomorrow at 9 call mom", "on friday 18:00 ...", "2024-12-31 23:00 ...",
+// "every monday at 9 ...", "every weekday 8:30 ...", "every 2 hours ..." or "cron 0 9 * * 1 ...".
+// now must be in the user's time zone
+func ParseReminder(text string, now time.Time) (ReminderSchedule, string, error) {
+	words := strings.Fields(text)
+	if len(words) > 0 && strings.EqualFold(words[0], "me") {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return ReminderSchedule{}, "", fmt.Errorf("empty reminder")
+	}
+
+	var schedule ReminderSchedule
+	var used int
+	var err error
+	switch strings.ToLower(words[0]) {
+	case "in":
+		schedule, used, err = parseIn(words[1:], now)
+		used++
+	case "every":
+		schedule, used, err = parseEvery(words[1:], now)
+		used++
+	case "cron":
+		if len(words) < 6 {
+			return ReminderSchedule{}, "", fmt.Errorf("cron needs 5 fields: minute hour day month weekday")
+		}
+		schedule.Cron = strings.Join(words[1:6], " ")
+		_, err = ParseCron(schedule.Cron)
+		used = 6
+	default:
+		schedule, used, err = parseDay(words, now)
+	}
+	if err != nil {
+		return ReminderSchedule{}, "", err
+	}
+
+	if schedule.Cron != "" {
+		run := schedule.Next(now)
+		if run.IsZero() {
+			return ReminderSchedule{}, "", fmt.Errorf("%s never runs", schedule.Cron)
+		}
+		// uneven steps like */50 fire at :00 and :50, so check a day worth of runs
+		for i := 0; i < 96; i++ {
+			next := schedule.Next(run)
+			if next.IsZero() {
+				break
+			}
+			if next.Sub(run) < MIN_REMINDER_INTERVAL {
+				return ReminderSchedule{}, "", fmt.Errorf("reminders can't repeat more often than every %d minutes", int(MIN_REMINDER_INTERVAL.Minutes()))
+			}
+			run = next
+		}
+	} else if !schedule.At.After(now) {
+		return ReminderSchedule{}, "", fmt.Errorf("%s is in the past", schedule.At.Format("2006-01-02 15:04"))
+	}
+
+	rest := words[used:]
+	if len(rest) > 0 && (strings.EqualFold(rest[0], "to") || strings.EqualFold(rest[0], "that")) {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return ReminderSchedule{}, "", fmt.Errorf("what should I remind about?")
+	}
+	return schedule, strings.Join(rest, " "), nil
+}
+
+// parseIn parses "10m", "10 minutes", "2h", "3 days"
+func parseIn(words []string, now time.Time) (ReminderSchedule, int, error) {
+	for used := 2; used >= 1; used-- {
+		if len(words) < used {
+			continue
+		}
+		match := durationRegex.FindStringSubmatch(strings.ToLower(strings.Join(words[:used], " ")))
+		if match == nil {
+			continue
+		}
+		count, _ := strconv.Atoi(match[1])
+		switch match[2][0] {
+		case 'm':
+			return ReminderSchedule{At: now.Add(time.Duration(count) * time.Minute)}, used, nil
+		case 'h':
+			return ReminderSchedule{At: now.Add(time.Duration(count) * time.Hour)}, used, nil
+		case 'd':
+			return ReminderSchedule{At: now.AddDate(0, 0, count)}, used, nil
+		case 'w':
+			return ReminderSchedule{At: now.AddDate(0, 0, 7*count)}, used, nil
+		}
+	}
+	return ReminderSchedule{}, 0, fmt.Errorf("expected a duration like 'in 10 minutes' or 'in 2h'")
+}
+
+// parseEvery parses "day", "weekday", "monday", "hour", "2 hours", "30 minutes" with an optional time of day
+func parseEvery(words []string, now time.Time) (ReminderSchedule, int, error) {
+	if len(words) == 0 {
+		return ReminderSchedule{}, 0, fmt.Errorf("expected 'every day', 'every monday' or 'every 2 hours'")
+	}
+
+	unit := strings.ToLower(words[0])
+	used := 1
+	if match := everyIntervalRegex.FindStringSubmatch(unit); match == nil && len(words) > 1 {
+		if everyIntervalRegex.MatchString(unit + " " + strings.ToLower(words[1])) {
+			unit = unit + " " + strings.ToLower(words[1])
+			used = 2
+		}
+	}
+	if match := everyIntervalRegex.FindStringSubmatch(unit); match != nil {
+		count, _ := strconv.Atoi(match[1])
+		if match[2][0] == 'h' {
+			if count < 1 || count > 23 {
+				return ReminderSchedule{}, 0, fmt.Errorf("every N hours needs N between 1 and 23")
+			}
+			return ReminderSchedule{Cron: fmt.Sprintf("%d */%d * * *", now.Minute(), count)}, used, nil
+		}
+		if count < 1 || count > 59 {
+			return ReminderSchedule{}, 0, fmt.Errorf("every N minutes needs N between 1 and 59")
+		}
+		return ReminderSchedule{Cron: fmt.Sprintf("*/%d * * * *", count)}, used, nil
+	}
+	if unit == "hour" {
+		return ReminderSchedule{Cron: fmt.Sprintf("%d * * * *", now.Minute())}, used, nil
+	}
+
+	dayField, weekdayField := "*", "*"
+	switch unit {
+	case "day":
+	case "weekday":
+		weekdayField = "1-5"
+	case "weekend":
+		weekdayField = "0,6"
+	case "month":
+		dayField = "1"
+	default:
+		weekday, ok := weekdays[strings.TrimSuffix(unit, "s")]
+		if !ok {
+			weekday, ok = weekdays[unit]
+		}
+		if !ok {
+			return ReminderSchedule{}, 0, fmt.Errorf("unknown period %s", words[0])
+		}
+		weekdayField = strconv.Itoa(int(weekday))
+	}
+
+	hour, minute, clockWords, err := parseOptionalClock(words[used:], true)
+	if err != nil {
+		return ReminderSchedule{}, 0, err
+	}
+	return ReminderSchedule{Cron: fmt.Sprintf("%d %d %s * %s", minute, hour, dayField, weekdayField)}, used + clockWords, nil
+}
+
+// parseDay parses "tomorrow [at] 9", "today 18:30", "at 7pm", "on friday [at] 10", "2024-12-31 [at] 23:00"
+func parseDay(words []string, now time.Time) (ReminderSchedule, int, error) {
+	used := 0
+	if strings.EqualFold(words[0], "on") {
+		used = 1
+	}
+	if used >= len(words) {
+		return ReminderSchedule{}, 0, fmt.Errorf("expected a day")
+	}
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	dayGiven := true
+	word := strings.ToLower(words[used])
+	if weekday, ok := weekdays[word]; ok {
+		days := (int(weekday) - int(now.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		day = day.AddDate(0, 0, days)
+		used++
+	} else if word == "today" {
+		used++
+	} else if word == "tomorrow" {
+		day = day.AddDate(0, 0, 1)
+		used++
+	} else if date, err := time.ParseInLocation("2006-01-02", word, now.Location()); err == nil {
+		day = date
+		used++
+	} else {
+		dayGiven = false
+	}
+
+	hour, minute, clockWords, err := parseOptionalClock(words[used:], dayGiven)
+	if err != nil {
+		return ReminderSchedule{}, 0, err
+	}
+	if !dayGiven && clockWords == 0 {
+		return ReminderSchedule{}, 0, fmt.Errorf("expected a time like 'in 10 minutes', 'tomorrow at 9' or 'every monday at 9'")
+	}
+
+	at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location())
+	if !dayGiven && !at.After(now) {
+		// "at 7pm" when it's already later means tomorrow
+		at = at.AddDate(0, 0, 1)
+	}
+	return ReminderSchedule{At: at}, used + clockWords, nil
+}
+
+// parseOptionalClock parses "[at] 9:30", "[at] 7pm", "[at] 7 pm" or a bare "9" if allowed, defaulting to DEFAULT_REMINDER_HOUR
+func parseOptionalClock(words []string, allowBareHour bool) (hour int, minute int, used int, err error) {
+	at := len(words) > 0 && strings.EqualFold(words[0], "at")
+	if at {
+		used = 1
+	}
+	if used >= len(words) {
+		if at {
+			return 0, 0, 0, fmt.Errorf("expected time after 'at'")
+		}
+		return DEFAULT_REMINDER_HOUR, 0, 0, nil
+	}
+
+	clock := strings.ToLower(words[used])
+	clockWords := 1
+	if used+1 < len(words) && (strings.EqualFold(words[used+1], "am") || strings.EqualFold(words[used+1], "pm")) {
+		clock += strings.ToLower(words[used+1])
+		clockWords = 2
+	}
+	match := clockRegex.FindStringSubmatch(clock)
+	// a bare number is part of the reminder text unless it follows "at" or a day
+	if match == nil || (match[2] == "" && match[3] == "" && !at && !allowBareHour) {
+		if at {
+			return 0, 0, 0, fmt.Errorf("invalid time %s", words[used])
+		}
+		return DEFAULT_REMINDER_HOUR, 0, 0, nil
+	}
+	hour, _ = strconv.Atoi(match[1])
+	minute, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		if hour < 1 || hour > 12 {
+			return 0, 0, 0, fmt.Errorf("invalid time %s", clock)
+		}
+		hour %= 12
+		if match[3] == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, 0, fmt.Errorf("invalid time %s", clock)
+	}
+	return hour, minute, used + clockWords, nil
+}
+
+// CronSchedule is a parsed 5 field cron expression: minute hour day-of-month month day-of-week
+type CronSchedule struct {
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool
+}
+
+// ParseCron parses a standard 5 field cron expression with lists, ranges and steps
+func ParseCron(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron needs 5 fields, got %d", len(fields))
+	}
+	var cron CronSchedule
+	var err error
+	if cron.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if cron.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if cron.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if cron.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if cron.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	// both 0 and 7 are Sunday
+	if cron.weekdays&(1<<7) != 0 {
+		cron.weekdays |= 1
+	}
+	cron.anyDay = fields[2] == "*"
+	cron.anyWeekday = fields[4] == "*"
+	return &cron, nil
+}
+
+func parseCronField(field string, min int, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid cron step in %s", field)
+			}
+			part = part[:index]
+		}
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid cron value %s", field)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("invalid cron range %s", field)
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("cron value %s out of range %d-%d", field, min, max)
+		}
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first matching minute after the given time in its location, zero if none in 5 years
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	location := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+			continue
+		}
+		if c.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location)
+			continue
+		}
+		if c.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchesDay follows cron semantics: if both day of month and weekday are restricted, either may match
+func (c *CronSchedule) matchesDay(t time.Time) bool {
+	day := c.days&(1<<uint(t.Day())) != 0
+	weekday := c.weekdays&(1<<uint(t.Weekday())) != 0
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
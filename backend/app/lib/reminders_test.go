@@ -0,0 +1,159 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Wednesday
+var reminderNow = time.Date(2024, 5, 15, 14, 20, 30, 0, time.UTC)
+
+func TestParseReminderOneOff(t *testing.T) {
+	schedule, text, err := ParseReminder("in 10 minutes stretch", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, reminderNow.Add(10*time.Minute), schedule.At)
+	assert.Equal(t, "stretch", text)
+
+	schedule, text, err = ParseReminder("me in 2h to check the oven", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, reminderNow.Add(2*time.Hour), schedule.At)
+	assert.Equal(t, "check the oven", text)
+
+	schedule, text, err = ParseReminder("tomorrow at 9 call mom", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 16, 9, 0, 0, 0, time.UTC), schedule.At)
+	assert.Equal(t, "call mom", text)
+
+	schedule, text, err = ParseReminder("tomorrow 7:30pm 2 pills", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 16, 19, 30, 0, 0, time.UTC), schedule.At)
+	assert.Equal(t, "2 pills", text)
+
+	schedule, _, err = ParseReminder("tomorrow water plants", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 16, DEFAULT_REMINDER_HOUR, 0, 0, 0, time.UTC), schedule.At)
+
+	// time that already passed today is tomorrow
+	schedule, _, err = ParseReminder("at 9am standup", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 16, 9, 0, 0, 0, time.UTC), schedule.At)
+
+	schedule, _, err = ParseReminder("at 18:00 gym", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 15, 18, 0, 0, 0, time.UTC), schedule.At)
+
+	schedule, _, err = ParseReminder("on friday at 12 pm lunch", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC), schedule.At)
+
+	schedule, _, err = ParseReminder("wednesday 10 retro", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 22, 10, 0, 0, 0, time.UTC), schedule.At)
+
+	schedule, _, err = ParseReminder("2024-12-31 23:00 happy new year", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC), schedule.At)
+}
+
+func TestParseReminderRecurring(t *testing.T) {
+	schedule, text, err := ParseReminder("every monday at 9 news-style summary", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, "0 9 * * 1", schedule.Cron)
+	assert.Equal(t, "news-style summary", text)
+	assert.Equal(t, time.Date(2024, 5, 20, 9, 0, 0, 0, time.UTC), schedule.Next(reminderNow))
+
+	schedule, _, err = ParseReminder("every weekday 8:30 standup", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, "30 8 * * 1-5", schedule.Cron)
+
+	schedule, _, err = ParseReminder("every day drink water", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, "0 9 * * *", schedule.Cron)
+
+	schedule, _, err = ParseReminder("every 2 hours stretch", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, "20 */2 * * *", schedule.Cron)
+
+	schedule, _, err = ParseReminder("cron 0 9 1 * * pay rent", reminderNow)
+	assert.NoError(t, err)
+	assert.Equal(t, "0 9 1 * *", schedule.Cron)
+	assert.Equal(t, time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC), schedule.Next(reminderNow))
+}
+
+func TestParseReminderErrors(t *testing.T) {
+	_, _, err := ParseReminder("call mom", reminderNow)
+	assert.Error(t, err)
+	_, _, err = ParseReminder("tomorrow at 9", reminderNow)
+	assert.Error(t, err)
+	_, _, err = ParseReminder("2020-01-01 10:00 too late", reminderNow)
+	assert.Error(t, err)
+	_, _, err = ParseReminder("every 5 minutes spam", reminderNow)
+	assert.Error(t, err)
+	_, _, err = ParseReminder("cron */50 * * * * uneven", reminderNow)
+	assert.Error(t, err)
+	_, _, err = ParseReminder("cron 0 25 * * * invalid", reminderNow)
+	assert.Error(t, err)
+	_, _, err = ParseReminder("at 25:00 invalid", reminderNow)
+	assert.Error(t, err)
+}
+
+func TestCronNext(t *testing.T) {
+	cron, err := ParseCron("*/15 9-17 * * 1-5")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 15, 14, 30, 0, 0, time.UTC), cron.Next(reminderNow))
+	// friday evening to monday morning
+	assert.Equal(t, time.Date(2024, 5, 20, 9, 0, 0, 0, time.UTC), cron.Next(time.Date(2024, 5, 17, 17, 45, 0, 0, time.UTC)))
+
+	// both day of month and weekday restricted match either
+	cron, err = ParseCron("0 0 13 * 5")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC), cron.Next(reminderNow))
+
+	// 7 is sunday too
+	cron, err = ParseCron("0 10 * * 7")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 19, 10, 0, 0, 0, time.UTC), cron.Next(reminderNow))
+
+	cron, err = ParseCron("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, cron.Next(reminderNow).IsZero())
+
+	berlin, _, err := ParseTimeZone("Europe/Berlin")
+	assert.NoError(t, err)
+	cron, err = ParseCron("0 9 * * *")
+	assert.NoError(t, err)
+	next := cron.Next(reminderNow.In(berlin))
+	assert.Equal(t, time.Date(2024, 5, 16, 7, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestParseTimeZone(t *testing.T) {
+	location, name, err := ParseTimeZone("Europe/Berlin")
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", name)
+	assert.Equal(t, "Europe/Berlin", location.String())
+
+	location, name, err = ParseTimeZone("+3")
+	assert.NoError(t, err)
+	assert.Equal(t, "UTC+03:00", name)
+	_, offset := time.Date(2024, 1, 1, 0, 0, 0, 0, location).Zone()
+	assert.Equal(t, 3*3600, offset)
+
+	location, name, err = ParseTimeZone("UTC-05:30")
+	assert.NoError(t, err)
+	assert.Equal(t, "UTC-05:30", name)
+	_, offset = time.Date(2024, 1, 1, 0, 0, 0, 0, location).Zone()
+	assert.Equal(t, -(5*3600 + 30*60), offset)
+
+	_, name, err = ParseTimeZone(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "UTC-05:30", name)
+
+	_, _, err = ParseTimeZone("Mars/Olympus")
+	assert.Error(t, err)
+	_, _, err = ParseTimeZone("Local")
+	assert.Error(t, err)
+	_, _, err = ParseTimeZone("+15")
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"talk2robots/m/v2/app/db/redis"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type TranscriptFormat string
+
+const (
+	TranscriptFormatText       TranscriptFormat = ""
+	TranscriptFormatSRT        TranscriptFormat = "srt"
+	TranscriptFormatVTT        TranscriptFormat = "vtt"
+	TranscriptFormatTimestamps TranscriptFormat = "timestamps"
+)
+
+// TranscriptOptions are set with /transcribe srt|vtt|timestamps [burn] per chat and topic, next to the transcription language
+type TranscriptOptions struct {
+	Format TranscriptFormat `json:"format"`
+	// BurnSubtitles sends videos back with the subtitles rendered into them
+	BurnSubtitles bool `json:"burn_subtitles,omitempty"`
+	// Prompt hints the transcription, e.g. with the languages spoken, it's set per message and not saved
+	Prompt string `json:"-"`
+}
+
+// Timed is true when the transcript needs segment timings
+func (o TranscriptOptions) Timed() bool {
+	return o.Format != TranscriptFormatText
+}
+
+func TranscriptOptionsKey(chatID string, topicID string) string {
+	if topicID != "" && topicID != "0" {
+		return chatID + ":" + topicID + ":transcript-options"
+	}
+	return chatID + ":transcript-options"
+}
+
+func GetTranscriptOptions(chatID string, topicID string) TranscriptOptions {
+	options := TranscriptOptions{}
+	optionsString, err := redis.RedisClient.Get(context.Background(), TranscriptOptionsKey(chatID, topicID)).Result()
+	if err != nil || optionsString == "" {
+		return options
+	}
+	err = json.Unmarshal([]byte(optionsString), &options)
+	if err != nil {
+		log.Errorf("GetTranscriptOptions: failed to unmarshal options for chat %s: %v", chatID, err)
+		return TranscriptOptions{}
+	}
+	return options
+}
+
+func SaveTranscriptOptions(chatID string, topicID string, options TranscriptOptions) error {
+	if !options.Timed() && !options.BurnSubtitles {
+		return redis.RedisClient.Del(context.Background(), TranscriptOptionsKey(chatID, topicID)).Err()
+	}
+	optionsBytes, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("SaveTranscriptOptions: failed to marshal options: %w", err)
+	}
+	return redis.RedisClient.Set(context.Background(), TranscriptOptionsKey(chatID, topicID), string(optionsBytes), 0).Err()
+}
+
+// ParseTranscriptOptions picks output options out of /transcribe arguments and returns the rest, e.g. the language.
+// Burning subtitles without a format uses SRT.
+func ParseTranscriptOptions(args []string) (options TranscriptOptions, rest []string) {
+	for _, arg := range args {
+		switch strings.ToLower(arg) {
+		case "srt", "subtitles":
+			options.Format = TranscriptFormatSRT
+		case "vtt", "webvtt":
+			options.Format = TranscriptFormatVTT
+		case "timestamps", "ts":
+			options.Format = TranscriptFormatTimestamps
+		case "burn":
+			options.BurnSubtitles = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if options.BurnSubtitles && options.Format == TranscriptFormatText {
+		options.Format = TranscriptFormatSRT
+	}
+	return options, rest
+}
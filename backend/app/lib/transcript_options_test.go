@@ -0,0 +1,33 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTranscriptOptions(t *testing.T) {
+	options, rest := ParseTranscriptOptions([]string{"SRT", "spanish"})
+	assert.Equal(t, TranscriptOptions{Format: TranscriptFormatSRT}, options)
+	assert.Equal(t, []string{"spanish"}, rest)
+
+	options, rest = ParseTranscriptOptions([]string{"he", "vtt", "burn"})
+	assert.Equal(t, TranscriptOptions{Format: TranscriptFormatVTT, BurnSubtitles: true}, options)
+	assert.Equal(t, []string{"he"}, rest)
+
+	options, _ = ParseTranscriptOptions([]string{"timestamps"})
+	assert.Equal(t, TranscriptFormatTimestamps, options.Format)
+	assert.True(t, options.Timed())
+
+	// burning needs subtitles
+	options, _ = ParseTranscriptOptions([]string{"burn"})
+	assert.Equal(t, TranscriptOptions{Format: TranscriptFormatSRT, BurnSubtitles: true}, options)
+
+	options, rest = ParseTranscriptOptions([]string{"english"})
+	assert.False(t, options.Timed())
+	assert.Equal(t, []string{"english"}, rest)
+
+	options, rest = ParseTranscriptOptions(nil)
+	assert.False(t, options.Timed())
+	assert.Empty(t, rest)
+}
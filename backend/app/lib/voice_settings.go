@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DEFAULT_VOICE          = "shimmer"
+	MAX_VOICE_INSTRUCTIONS = 500
+	MIN_VOICE_SPEED        = 0.25
+	MAX_VOICE_SPEED        = 4.0
+)
+
+// Voices are all voices supported by OpenAI TTS
+var Voices = []string{"alloy", "ash", "ballad", "coral", "echo", "fable", "nova", "onyx", "sage", "shimmer", "verse"}
+
+// VoiceSpeeds are offered in the voice settings keyboard, any speed within limits can be set with /voice speed
+var VoiceSpeeds = []float64{0.75, 1, 1.25, 1.5}
+
+// VoiceStyles are style instruction presets offered in the voice settings keyboard
+var VoiceStyles = map[string]string{
+	"calm":     "Speak in a calm, warm and soothing tone, at a relaxed pace.",
+	"cheerful": "Speak in a cheerful, upbeat and energetic tone.",
+	"story":    "Speak like an expressive audiobook narrator, with natural pauses and emphasis.",
+	"news":     "Speak like a clear and neutral news anchor.",
+}
+
+func VoiceSettingsKey(chatID string) string {
+	return chatID + ":voice-settings"
+}
+
+func DefaultVoiceSettings() models.VoiceSettings {
+	return models.VoiceSettings{
+		Voice:  DEFAULT_VOICE,
+		Speed:  1,
+		Format: models.TTSFormatOpus,
+	}
+}
+
+func GetVoiceSettings(chatID string) models.VoiceSettings {
+	settingsString, err := redis.RedisClient.Get(context.Background(), VoiceSettingsKey(chatID)).Result()
+	if err != nil || settingsString == "" {
+		return DefaultVoiceSettings()
+	}
+	settings := DefaultVoiceSettings()
+	err = json.Unmarshal([]byte(settingsString), &settings)
+	if err != nil {
+		log.Errorf("GetVoiceSettings: failed to unmarshal settings for chat %s: %v", chatID, err)
+		return DefaultVoiceSettings()
+	}
+	return settings
+}
+
+func SaveVoiceSettings(chatID string, settings models.VoiceSettings) error {
+	settingsBytes, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("SaveVoiceSettings: failed to marshal settings: %w", err)
+	}
+	return redis.RedisClient.Set(context.Background(), VoiceSettingsKey(chatID), string(settingsBytes), 0).Err()
+}
+
+// ApplyVoiceSetting validates and applies a single setting: voice, speed, style or format
+func ApplyVoiceSetting(settings *models.VoiceSettings, name string, value string) error {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(name) {
+	case "voice":
+		voice := strings.ToLower(value)
+		if !containsString(Voices, voice) {
+			return fmt.Errorf("unknown voice %s, available voices: %s", value, strings.Join(Voices, ", "))
+		}
+		settings.Voice = voice
+	case "speed":
+		speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(value), "x"), 64)
+		if err != nil || speed < MIN_VOICE_SPEED || speed > MAX_VOICE_SPEED {
+			return fmt.Errorf("speed should be a number from %.2f to %.1f", MIN_VOICE_SPEED, MAX_VOICE_SPEED)
+		}
+		settings.Speed = speed
+	case "style", "instructions":
+		if preset, ok := VoiceStyles[strings.ToLower(value)]; ok {
+			value = preset
+		}
+		if strings.EqualFold(value, "none") || strings.EqualFold(value, "off") || strings.EqualFold(value, "clear") {
+			value = ""
+		}
+		if len(value) > MAX_VOICE_INSTRUCTIONS {
+			return fmt.Errorf("style instructions should be up to %d characters", MAX_VOICE_INSTRUCTIONS)
+		}
+		settings.Instructions = value
+	case "format":
+		switch strings.ToLower(value) {
+		case "voice", string(models.TTSFormatOpus):
+			settings.Format = models.TTSFormatOpus
+		case "audio", string(models.TTSFormatMp3):
+			settings.Format = models.TTSFormatMp3
+		default:
+			return fmt.Errorf("format should be voice or mp3")
+		}
+	default:
+		return fmt.Errorf("unknown setting %s, use voice, speed, style or format", name)
+	}
+	return nil
+}
+
+// TTSRequestForChat builds a TTS request with the chat's voice settings
+func TTSRequestForChat(chatID string, input string) *models.TTSRequest {
+	settings := GetVoiceSettings(chatID)
+	return &models.TTSRequest{
+		Model:        models.TTS,
+		Input:        input,
+		Voice:        settings.Voice,
+		Speed:        settings.Speed,
+		Instructions: settings.Instructions,
+		Format:       settings.Format,
+	}
+}
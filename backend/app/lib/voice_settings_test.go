@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"strings"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyVoiceSetting(t *testing.T) {
+	settings := DefaultVoiceSettings()
+
+	assert.NoError(t, ApplyVoiceSetting(&settings, "voice", "Nova"))
+	assert.Equal(t, "nova", settings.Voice)
+	assert.Error(t, ApplyVoiceSetting(&settings, "voice", "robot"))
+	assert.Equal(t, "nova", settings.Voice)
+
+	assert.NoError(t, ApplyVoiceSetting(&settings, "speed", "1.25x"))
+	assert.Equal(t, 1.25, settings.Speed)
+	assert.Error(t, ApplyVoiceSetting(&settings, "speed", "5"))
+	assert.Error(t, ApplyVoiceSetting(&settings, "speed", "fast"))
+
+	assert.NoError(t, ApplyVoiceSetting(&settings, "style", "calm"))
+	assert.Equal(t, VoiceStyles["calm"], settings.Instructions)
+	assert.NoError(t, ApplyVoiceSetting(&settings, "style", "speak like a pirate"))
+	assert.Equal(t, "speak like a pirate", settings.Instructions)
+	assert.Error(t, ApplyVoiceSetting(&settings, "style", strings.Repeat("a", MAX_VOICE_INSTRUCTIONS+1)))
+	assert.NoError(t, ApplyVoiceSetting(&settings, "style", "none"))
+	assert.Equal(t, "", settings.Instructions)
+
+	assert.NoError(t, ApplyVoiceSetting(&settings, "format", "mp3"))
+	assert.Equal(t, models.TTSFormatMp3, settings.Format)
+	assert.NoError(t, ApplyVoiceSetting(&settings, "format", "voice"))
+	assert.Equal(t, models.TTSFormatOpus, settings.Format)
+	assert.Error(t, ApplyVoiceSetting(&settings, "format", "wav"))
+
+	assert.Error(t, ApplyVoiceSetting(&settings, "pitch", "high"))
+}
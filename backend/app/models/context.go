@@ -7,3 +7,8 @@ type ChannelContext struct{}
 type TopicContext struct{}
 type WhisperDurationContext struct{}
 type ParamsContext struct{}
+type MemberContext struct{}
+type LanguageContext struct{}
+
+// RequestContext identifies an incoming message or callback, usage events billed for it share the id
+type RequestContext struct{}
@@ -1,8 +1,53 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+)
+
 const (
 	DallE3           Engine = "dall-e-3"
+	GptImage1        Engine = "gpt-image-1"
 	Midjourney6      Engine = "midjourney-6"
 	Playground25     Engine = "accounts/fireworks/models/playground-v2-5-1024px-aesthetic"
 	StableDiffusion3 Engine = "accounts/stability/models/sd3"
 )
+
+// ErrImageContentPolicy is returned by image providers when the prompt or the result is filtered
+var ErrImageContentPolicy = errors.New("content_policy_violation")
+
+// ImageOptions are set with --ar 16:9 --hd --n 3 --style natural --seed 42, or per chat in the image options keyboard
+type ImageOptions struct {
+	AspectRatio string `bson:"ar,omitempty" json:"ar,omitempty"`
+	HD          bool   `bson:"hd,omitempty" json:"hd,omitempty"`
+	N           int    `bson:"n,omitempty" json:"n,omitempty"`
+	Style       string `bson:"style,omitempty" json:"style,omitempty"`
+
+	// Seed 0 is random, a seed isn't kept in chat options, only with saved images
+	Seed int64 `bson:"seed,omitempty" json:"-"`
+}
+
+// Size is width and height of the aspect ratio, 1:1 when not set
+func (o ImageOptions) Size() (int, int) {
+	var width, height int
+	if _, err := fmt.Sscanf(o.AspectRatio, "%d:%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return 1, 1
+	}
+	return width, height
+}
+
+type ImageRequest struct {
+	Prompt string
+	ImageOptions
+
+	// Image to edit and an optional Mask, transparent areas of the mask are redrawn
+	Image []byte
+	Mask  []byte
+}
+
+// GeneratedImage is either hosted by the provider (URL) or returned inline (Data)
+type GeneratedImage struct {
+	URL           string
+	Data          []byte
+	RevisedPrompt string
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type MongoUser struct {
 	Email            string            `bson:"email"`
 	ID               string            `bson:"_id"`
@@ -16,6 +18,8 @@ type MongoUser struct {
 	Source   string `bson:"source"`
 	Mode     string `bson:"mode"`
 	Language string `bson:"language"`
+
+	TimeZone string `bson:"time_zone"`
 }
 
 type MongoSubscription struct {
@@ -42,3 +46,97 @@ type MongoUserThread struct {
 	UpdateAt   string `bson:"updated_at"`
 	UserId     string `bson:"user_id"`
 }
+
+type MongoFeedback struct {
+	UserId    string  `bson:"user_id" json:"user_id"`
+	FromId    int64   `bson:"from_id" json:"from_id"`
+	Client    string  `bson:"client" json:"client"`
+	ChatType  string  `bson:"chat_type" json:"chat_type"`
+	TopicId   string  `bson:"topic_id" json:"topic_id"`
+	MessageId int     `bson:"message_id" json:"message_id"`
+	Kind      string  `bson:"kind" json:"kind"`         // like, dislike or reaction
+	Reaction  string  `bson:"reaction" json:"reaction"` // emoji name for reactions
+	Mood      string  `bson:"mood" json:"mood"`         // positive, negative or neutral
+	Prompt    string  `bson:"prompt" json:"prompt"`
+	Answer    string  `bson:"answer" json:"answer"`
+	Mode      string  `bson:"mode" json:"mode"`
+	Engine    string  `bson:"engine" json:"engine"`
+	Cost      float64 `bson:"cost" json:"cost"`
+	Comment   string  `bson:"comment" json:"comment"`
+	CreatedAt string  `bson:"created_at" json:"created_at"`
+}
+
+// MongoReminder is a one-off or recurring (cron) reminder, prompt reminders are run through the AI when due
+type MongoReminder struct {
+	ID        string    `bson:"_id" json:"id"`
+	UserId    string    `bson:"user_id" json:"user_id"`
+	Client    string    `bson:"client" json:"client"`
+	TopicId   string    `bson:"topic_id" json:"topic_id"`
+	Text      string    `bson:"text" json:"text"`
+	Prompt    bool      `bson:"prompt" json:"prompt"`
+	Cron      string    `bson:"cron" json:"cron"`
+	TimeZone  string    `bson:"time_zone" json:"time_zone"`
+	NextRunAt time.Time `bson:"next_run_at" json:"next_run_at"`
+	LastRunAt time.Time `bson:"last_run_at" json:"last_run_at"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// MongoImage is a generated or edited image, the image itself is kept in the blob store under BlobKey
+type MongoImage struct {
+	ID            string       `bson:"_id" json:"id"`
+	UserId        string       `bson:"user_id" json:"user_id"`
+	Client        string       `bson:"client" json:"client"`
+	TopicId       string       `bson:"topic_id" json:"topic_id"`
+	Prompt        string       `bson:"prompt" json:"prompt"`
+	RevisedPrompt string       `bson:"revised_prompt,omitempty" json:"revised_prompt,omitempty"`
+	Engine        string       `bson:"engine" json:"engine"`
+	Options       ImageOptions `bson:"options" json:"options"`
+	Edit          bool         `bson:"edit" json:"edit"`
+	Cost          float64      `bson:"cost" json:"cost"`
+	BlobKey       string       `bson:"blob_key" json:"blob_key"`
+	ContentType   string       `bson:"content_type" json:"content_type"`
+	CreatedAt     time.Time    `bson:"created_at" json:"created_at"`
+}
+
+// MongoUsageEvent is a billed event in the usage ledger: units used, their prices and the resulting cost
+type MongoUsageEvent struct {
+	ID                 string    `bson:"_id" json:"id"`
+	UserId             string    `bson:"user_id" json:"user_id"`
+	Client             string    `bson:"client" json:"client"`
+	ChannelId          string    `bson:"channel_id" json:"channel_id"`
+	TopicId            string    `bson:"topic_id,omitempty" json:"topic_id,omitempty"`
+	MemberId           string    `bson:"member_id,omitempty" json:"member_id,omitempty"`
+	Engine             string    `bson:"engine" json:"engine"`
+	PromptTokens       int       `bson:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens   int       `bson:"completion_tokens" json:"completion_tokens"`
+	TotalTokens        int       `bson:"total_tokens" json:"total_tokens"`
+	AudioMinutes       float64   `bson:"audio_minutes" json:"audio_minutes"`
+	ImagesCount        int       `bson:"images_count" json:"images_count"`
+	SearchQueries      int       `bson:"search_queries" json:"search_queries"`
+	PricePerInputUnit  float64   `bson:"price_per_input_unit" json:"price_per_input_unit"`
+	PricePerOutputUnit float64   `bson:"price_per_output_unit" json:"price_per_output_unit"`
+	ImagePrice         float64   `bson:"image_price,omitempty" json:"image_price,omitempty"`
+	SearchPrice        float64   `bson:"search_price,omitempty" json:"search_price,omitempty"`
+	Cost               float64   `bson:"cost" json:"cost"`
+	RequestId          string    `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	CreatedAt          time.Time `bson:"created_at" json:"created_at"`
+}
+
+// MongoUsageTotals sums usage events of a user, or of a group member when MemberId is set
+type MongoUsageTotals struct {
+	UserId        string  `bson:"user_id"`
+	MemberId      string  `bson:"member_id,omitempty"`
+	Cost          float64 `bson:"cost"`
+	TotalTokens   int64   `bson:"total_tokens"`
+	AudioMinutes  float64 `bson:"audio_minutes"`
+	ImagesCount   int64   `bson:"images_count"`
+	SearchQueries int64   `bson:"search_queries"`
+}
+
+type MongoFeedbackFilter struct {
+	Since  time.Time
+	Kind   string
+	Mood   string
+	Engine string
+	Limit  int
+}
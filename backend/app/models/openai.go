@@ -1,6 +1,9 @@
 package models
 
-import "talk2robots/m/v2/app/config"
+import (
+	"talk2robots/m/v2/app/config"
+	"time"
+)
 
 // Engine is a type for OpenAI API engine
 type Engine string
@@ -21,6 +24,7 @@ const (
 	ChatGpt4Turbo       Engine = "gpt-4-turbo-preview"
 	ChatGpt4o           Engine = "gpt-4o"
 	Whisper             Engine = "gpt-4o-mini-transcribe"
+	WhisperTimestamps   Engine = "whisper-1" // the only transcription model returning segment timings
 	TTS                 Engine = "gpt-4o-mini-tts"
 
 	// used as Redis keys
@@ -55,6 +59,7 @@ type CostAndUsage struct {
 	PricePerInputUnit  float64 `json:"price_per_input_unit"`
 	PricePerOutputUnit float64 `json:"price_per_output_unit"`
 	ImagePrice         float64 `json:"image_price,omitempty"`
+	SearchPrice        float64 `json:"search_price,omitempty"`
 	Cost               float64 `json:"cost"`
 	Usage              Usage   `json:"usage"`
 	User               string  `json:"user"`
@@ -72,10 +77,35 @@ type Completion struct {
 	Stop             []string
 }
 
+// TranscriptSegment is a piece of a transcript with its timing in the recording
+type TranscriptSegment struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
 type TTSRequest struct {
-	Model Engine
-	Input string
-	Voice string
+	Model        Engine
+	Input        string
+	Voice        string
+	Speed        float64
+	Instructions string
+	Format       TTSFormat
+}
+
+type TTSFormat string
+
+const (
+	TTSFormatOpus TTSFormat = "opus"
+	TTSFormatMp3  TTSFormat = "mp3"
+)
+
+// VoiceSettings are TTS preferences of a chat, used for VoiceGPT replies and read aloud
+type VoiceSettings struct {
+	Voice        string    `json:"voice"`
+	Speed        float64   `json:"speed"`
+	Instructions string    `json:"instructions,omitempty"`
+	Format       TTSFormat `json:"format"`
 }
 
 // Response is a type for OpenAI API response
@@ -135,6 +165,8 @@ type Message struct {
 type MultimodalMessage struct {
 	Role    string              `json:"role"`
 	Content []MultimodalContent `json:"content"`
+	// Name is the author of a user turn in group threads, it's moved into the content before sending to a model
+	Name string `json:"name,omitempty"`
 }
 
 type MultimodalContent struct {
@@ -175,13 +207,15 @@ type Usage struct {
 	TotalTokens      int     `json:"total_tokens"`
 	AudioDuration    float64 `json:"audio_duration"` // only for Whisper API
 	ImagesCount      int     `json:"images_count,omitempty"`
+	SearchQueries    int     `json:"search_queries,omitempty"`
 }
 
 type ThreadRunRequest struct {
-	AssistantID string  `json:"assistant_id"`
-	Thread      *Thread `json:"thread"`
-	Model       string  `json:"model,omitempty"`
-	Metadata    struct {
+	AssistantID  string  `json:"assistant_id"`
+	Thread       *Thread `json:"thread"`
+	Model        string  `json:"model,omitempty"`
+	Instructions string  `json:"instructions,omitempty"`
+	Metadata     struct {
 	} `json:"metadata,omitempty"`
 	Stream bool `json:"stream,omitempty"`
 }
@@ -7,3 +7,35 @@ type RoundTripperFunc func(req *http.Request) (*http.Response, error)
 func (fn RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 	return fn(req)
 }
+
+// GroupBufferMessage is a group chat message kept in the rolling buffer for digests
+type GroupBufferMessage struct {
+	From       string `json:"from"`
+	Text       string `json:"text,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+	Timestamp  int64  `json:"ts"`
+
+	// voice/audio/video messages are kept as file references, they are transcribed once a digest is asked for
+	MediaFileID string `json:"media_file_id,omitempty"`
+	MediaType   string `json:"media_type,omitempty"`
+}
+
+type GroupTrigger string
+
+const (
+	GroupTriggerMention GroupTrigger = "mention"
+	GroupTriggerReply   GroupTrigger = "reply"
+	GroupTriggerKeyword GroupTrigger = "keyword"
+)
+
+// GroupSettings is a group policy set by admins via /groupsettings, empty lists mean everything is allowed
+type GroupSettings struct {
+	AllowedModes     []string       `json:"allowed_modes,omitempty"`
+	AllowedEngines   []string       `json:"allowed_engines,omitempty"`
+	ImagesAllowed    bool           `json:"images_allowed"`
+	VoiceAllowed     bool           `json:"voice_allowed"`
+	MemberMonthlyCap float64        `json:"member_monthly_cap,omitempty"`
+	Triggers         []GroupTrigger `json:"triggers,omitempty"`
+	Keywords         []string       `json:"keywords,omitempty"`
+	MemberMemory     bool           `json:"member_memory"`
+}
@@ -9,8 +9,10 @@ import (
 	"talk2robots/m/v2/app/config"
 	"talk2robots/m/v2/app/db/mongo"
 	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/models"
+	"time"
 
 	"github.com/mymmrac/telego"
 	tu "github.com/mymmrac/telego/telegoutil"
@@ -19,19 +21,22 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// REQUEST_COST_TTL matches how long feedback can be attributed to an answer
+const REQUEST_COST_TTL = 24 * time.Hour
+
 var (
 	PaymentsBot         *telego.Bot
 	PaymentsSlackClient *slack.Client
 )
 
 // At 50%, 80% and 100% of the maximum usage, the user will receive a notification
-// that they are approaching their maximum usage.
+// that they are approaching their maximum usage, messages are i18n catalog ids.
 var UsageThresholds = map[models.MongoSubscriptionName]models.UsageThresholds{
 	models.FreeSubscriptionName: {
 		Thresholds: []models.UsageThreshold{
 			{
 				Percentage: 1.0,
-				Message:    "Check available options to /upgrade and continue using me.",
+				Message:    "usage.threshold.upgrade",
 			},
 		},
 	},
@@ -39,7 +44,7 @@ var UsageThresholds = map[models.MongoSubscriptionName]models.UsageThresholds{
 		Thresholds: []models.UsageThreshold{
 			{
 				Percentage: 1.0,
-				Message:    "Check available options to /upgrade and continue using me.",
+				Message:    "usage.threshold.upgrade",
 			},
 		},
 	},
@@ -47,15 +52,15 @@ var UsageThresholds = map[models.MongoSubscriptionName]models.UsageThresholds{
 		Thresholds: []models.UsageThreshold{
 			{
 				Percentage: 0.5,
-				Message:    "⚠️ Thanks for using the bot! You are halfway through your paid monthly usage. Use /status to track your current usage. You can /clear long conversations to avoid overly consuming your usage.",
+				Message:    "usage.threshold.half",
 			},
 			{
 				Percentage: 0.8,
-				Message:    "⚠️ You are 80% through your paid monthly usage. Use /status to track your current usage. You can /clear long conversations to avoid overly consuming your usage.",
+				Message:    "usage.threshold.most",
 			},
 			{
 				Percentage: 1.0,
-				Message:    "🚫 You have reached your paid monthly usage limit. Further requests may not be served until the 1st of next month. Use /status to track your current usage.",
+				Message:    "usage.threshold.reached",
 			},
 		},
 	},
@@ -74,35 +79,73 @@ func HugePromptAlarm(ctx context.Context, usage models.CostAndUsage) {
 	MAX_TOKENS_ALARM := 10 * 1024
 	if usage.Usage.PromptTokens > MAX_TOKENS_ALARM {
 		log.Warnf("Prompt tokens for chat %s exceeded max tokens alarm: %d", userId, usage.Usage.PromptTokens)
-		PaymentsBot.SendMessage(context.Background(), tu.Message(chatID, fmt.Sprintf("⚠️ Your prompt (including previous conversation) is very long. This may lead to increased costs and the bot timeouts.\nConsider /clear the memory to start a new thread and/or use shorter messages.\n\nRequest tokens - %d.\nProjected cost of the request - $%.3f", usage.Usage.PromptTokens, usage.PricePerInputUnit*float64(usage.Usage.PromptTokens))).WithMessageThreadID(topicId))
+		PaymentsBot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "usage.huge_prompt", i18n.Args{"tokens": usage.Usage.PromptTokens, "cost": fmt.Sprintf("%.3f", usage.PricePerInputUnit*float64(usage.Usage.PromptTokens))})).WithMessageThreadID(topicId))
 	}
 }
 
+// Bill prices the usage, records it in the usage outbox for the ledger and updates usage counters
 func Bill(originalContext context.Context, usage models.CostAndUsage) models.CostAndUsage {
+	ctx := billingContext(originalContext)
+	usage = priceUsage(ctx, usage)
+	enqueueUsageEvent(ctx, usage)
+	updateUsageCounters(ctx, usage)
+	return usage
+}
+
+// BillAsync records the usage in the outbox before returning, so a crash doesn't lose the charge,
+// counters are updated in background
+func BillAsync(originalContext context.Context, usage models.CostAndUsage) {
+	ctx := billingContext(originalContext)
+	usage = priceUsage(ctx, usage)
+	enqueueUsageEvent(ctx, usage)
+	go updateUsageCounters(ctx, usage)
+}
+
+// billingContext keeps values of the request context, billing outlives the request
+func billingContext(originalContext context.Context) context.Context {
 	ctx := context.WithValue(context.Background(), models.UserContext{}, originalContext.Value(models.UserContext{}).(string))
 	ctx = context.WithValue(ctx, models.SubscriptionContext{}, originalContext.Value(models.SubscriptionContext{}).(models.MongoSubscriptionName))
 	ctx = context.WithValue(ctx, models.ClientContext{}, originalContext.Value(models.ClientContext{}).(string))
 	ctx = context.WithValue(ctx, models.ChannelContext{}, originalContext.Value(models.ChannelContext{}).(string))
 	ctx = context.WithValue(ctx, models.TopicContext{}, originalContext.Value(models.TopicContext{}))
 	ctx = context.WithValue(ctx, models.ParamsContext{}, originalContext.Value(models.ParamsContext{}))
-	defer ctx.Done()
+	ctx = context.WithValue(ctx, models.MemberContext{}, originalContext.Value(models.MemberContext{}))
+	ctx = context.WithValue(ctx, models.RequestContext{}, originalContext.Value(models.RequestContext{}))
+	return ctx
+}
+
+func priceUsage(ctx context.Context, usage models.CostAndUsage) models.CostAndUsage {
 	usage.Cost =
 		float64(usage.Usage.PromptTokens)*usage.PricePerInputUnit +
 			float64(usage.Usage.CompletionTokens)*usage.PricePerOutputUnit +
 			usage.Usage.AudioDuration*usage.PricePerInputUnit +
-			float64(usage.Usage.ImagesCount)*usage.ImagePrice
+			float64(usage.Usage.ImagesCount)*usage.ImagePrice +
+			float64(usage.Usage.SearchQueries)*usage.SearchPrice
+	usage.User = ctx.Value(models.UserContext{}).(string)
+	return usage
+}
+
+func updateUsageCounters(ctx context.Context, usage models.CostAndUsage) {
 	_, err := redis.RedisClient.IncrByFloat(ctx, "system_totals:cost", usage.Cost).Result()
 	if err != nil {
 		log.Errorf("[billing] error incrementing system cost: %v", err)
 	}
 
-	usage.User = ctx.Value(models.UserContext{}).(string)
 	client := ctx.Value(models.ClientContext{}).(string)
 
 	userType := "system"
 	if usage.User != "SYSTEM:STATUS" {
 		userType = "user"
 		CheckThresholdsAndNotify(ctx, usage.Cost)
+
+		// keep the request cost around, so feedback can be attributed to it
+		if request, ok := ctx.Value(models.RequestContext{}).(string); ok && request != "" {
+			_, err = redis.RedisClient.IncrByFloat(context.Background(), lib.RequestCostKey(request), usage.Cost).Result()
+			if err != nil {
+				log.Errorf("[billing] error incrementing request cost: %v", err)
+			}
+			redis.RedisClient.Expire(context.Background(), lib.RequestCostKey(request), REQUEST_COST_TTL)
+		}
 	}
 
 	config.CONFIG.DataDogClient.Distribution("billing.cost", usage.Cost, []string{"engine:" + string(usage.Engine), "user_type:" + userType, "client:" + client}, 1)
@@ -146,6 +189,26 @@ func Bill(originalContext context.Context, usage models.CostAndUsage) models.Cos
 		config.CONFIG.DataDogClient.Distribution("billing.images", float64(usage.Usage.ImagesCount), []string{"engine:" + string(usage.Engine), "user_type:" + userType}, 1)
 	}
 
+	if usage.Usage.SearchQueries > 0 {
+		_, err = redis.RedisClient.IncrBy(context.Background(), lib.UserTotalSearchesKey(usage.User), int64(usage.Usage.SearchQueries)).Result()
+		if err != nil {
+			log.Errorf("[billing] error incrementing user total searches: %v", err)
+		}
+		_, err = redis.RedisClient.IncrBy(context.Background(), "system_totals:searches", int64(usage.Usage.SearchQueries)).Result()
+		if err != nil {
+			log.Errorf("[billing] error incrementing system total searches: %v", err)
+		}
+		config.CONFIG.DataDogClient.Distribution("billing.searches", float64(usage.Usage.SearchQueries), []string{"engine:" + string(usage.Engine), "user_type:" + userType}, 1)
+	}
+
+	// group members spend is tracked separately, so admins can cap it within the group budget
+	if member, ok := ctx.Value(models.MemberContext{}).(string); ok && member != "" {
+		_, err = redis.RedisClient.IncrByFloat(context.Background(), lib.MemberTotalCostKey(usage.User, member), usage.Cost).Result()
+		if err != nil {
+			log.Errorf("[billing] error incrementing member %s total cost: %v", member, err)
+		}
+	}
+
 	userTotalCost, err := redis.RedisClient.IncrByFloat(context.Background(), lib.UserTotalCostKey(usage.User), usage.Cost).Result()
 	if err != nil {
 		log.Errorf("[billing] error getting user total cost: %s", err)
@@ -155,7 +218,6 @@ func Bill(originalContext context.Context, usage models.CostAndUsage) models.Cos
 			log.Errorf("[billing] error updating user usage: %s", err)
 		}
 	}
-	return usage
 }
 
 func CheckThresholdsAndNotify(ctx context.Context, incomingCost float64) {
@@ -192,7 +254,7 @@ func CheckThresholdsAndNotify(ctx context.Context, incomingCost float64) {
 			}, 1)
 			log.Infof("CheckThresholdsAndNotify: user %s has reached %.1f%% of their maximum usage for subscription %s. Sending notification..", user, threshold.Percentage*100, mongoUser.SubscriptionType.Name)
 
-			notification := lib.AddBotSuffixToGroupCommands(ctx, threshold.Message)
+			notification := lib.AddBotSuffixToGroupCommands(ctx, i18n.Translate(mongoUser.Language, threshold.Message))
 			SendNotification(ctx, notification)
 		}
 	}
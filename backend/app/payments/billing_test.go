@@ -175,3 +175,24 @@ func TestCheckThresholdsAndNotifyGroup(t *testing.T) {
 	expectedNotifications := []string{"Check available options to /upgrade@testbot and continue using me."}
 	assert.Equal(t, expectedNotifications, notifications, "Unexpected notifications sent")
 }
+
+func TestBillSumsRequestCost(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	mongo.MongoDBClient = mongo.NewMockMongoDBClient(models.MongoUser{ID: "123"})
+
+	ctx := context.WithValue(context.Background(), models.UserContext{}, "123")
+	ctx = context.WithValue(ctx, models.ClientContext{}, "telegram")
+	ctx = context.WithValue(ctx, models.ChannelContext{}, "123")
+	ctx = context.WithValue(ctx, models.TopicContext{}, "")
+	ctx = context.WithValue(ctx, models.SubscriptionContext{}, models.FreeSubscriptionName)
+
+	// the answer and a sub-call of the same request are summed, another request is kept apart
+	Bill(context.WithValue(ctx, models.RequestContext{}, "answer"), models.CostAndUsage{Usage: models.Usage{PromptTokens: 100}, PricePerInputUnit: 0.001})
+	Bill(context.WithValue(ctx, models.RequestContext{}, "answer"), models.CostAndUsage{Usage: models.Usage{PromptTokens: 50}, PricePerInputUnit: 0.001})
+	Bill(context.WithValue(ctx, models.RequestContext{}, "voice-button"), models.CostAndUsage{Usage: models.Usage{AudioDuration: 1}, PricePerInputUnit: 0.015})
+
+	cost, _ := redis.RedisClient.Get(ctx, lib.RequestCostKey("answer")).Float64()
+	assert.InDelta(t, 0.15, cost, 1e-9)
+	cost, _ = redis.RedisClient.Get(ctx, lib.RequestCostKey("voice-button")).Float64()
+	assert.InDelta(t, 0.015, cost, 1e-9)
+}
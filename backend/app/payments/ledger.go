@@ -0,0 +1,312 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"time"
+
+	r "github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// USAGE_OUTBOX_BATCH events are saved to the ledger at once
+	USAGE_OUTBOX_BATCH = 100
+
+	// MAX_USAGE_OUTBOX_BATCHES caps a single flush, the rest is picked up on the next run
+	MAX_USAGE_OUTBOX_BATCHES = 50
+
+	// USAGE_PERIOD_START_DAY is the day the clearusage worker clears monthly counters, same as workers.DAY_FOR_MONTHLY_RUNS
+	USAGE_PERIOD_START_DAY = 2
+
+	// SYSTEM_TOTALS_TOLERANCE is spend in dollars, that may be billed into system totals while they are rebuilt
+	SYSTEM_TOTALS_TOLERANCE = 0.01
+)
+
+func newUsageEvent(ctx context.Context, usage models.CostAndUsage) models.MongoUsageEvent {
+	event := models.MongoUsageEvent{
+		ID:                 uuid.New().String(),
+		UserId:             usage.User,
+		Engine:             string(usage.Engine),
+		PromptTokens:       usage.Usage.PromptTokens,
+		CompletionTokens:   usage.Usage.CompletionTokens,
+		TotalTokens:        usage.Usage.TotalTokens,
+		AudioMinutes:       usage.Usage.AudioDuration,
+		ImagesCount:        usage.Usage.ImagesCount,
+		SearchQueries:      usage.Usage.SearchQueries,
+		PricePerInputUnit:  usage.PricePerInputUnit,
+		PricePerOutputUnit: usage.PricePerOutputUnit,
+		ImagePrice:         usage.ImagePrice,
+		SearchPrice:        usage.SearchPrice,
+		Cost:               usage.Cost,
+		CreatedAt:          time.Now().UTC(),
+	}
+	event.Client, _ = ctx.Value(models.ClientContext{}).(string)
+	event.ChannelId, _ = ctx.Value(models.ChannelContext{}).(string)
+	event.TopicId, _ = ctx.Value(models.TopicContext{}).(string)
+	event.MemberId, _ = ctx.Value(models.MemberContext{}).(string)
+	event.RequestId, _ = ctx.Value(models.RequestContext{}).(string)
+	return event
+}
+
+// enqueueUsageEvent puts the event into the outbox, which outlives restarts of the backend,
+// when redis fails the event is saved to the ledger right away
+func enqueueUsageEvent(ctx context.Context, usage models.CostAndUsage) {
+	event := newUsageEvent(ctx, usage)
+	eventBytes, _ := json.Marshal(event)
+	err := redis.RedisClient.LPush(context.Background(), lib.UsageOutboxKey, string(eventBytes)).Err()
+	if err == nil {
+		return
+	}
+	log.Errorf("[billing] error adding usage event to outbox, saving to ledger: %v", err)
+	config.CONFIG.DataDogClient.Incr("billing.outbox_failed", nil, 1)
+	err = mongo.MongoDBClient.SaveUsageEvents(context.Background(), []models.MongoUsageEvent{event})
+	if err != nil {
+		log.Errorf("[billing] lost usage event %s: %v", string(eventBytes), err)
+		config.CONFIG.DataDogClient.Incr("billing.usage_event_lost", nil, 1)
+	}
+}
+
+// FlushUsageOutbox moves events from the outbox to the ledger in batches. Events are removed from processing
+// once saved, events of a failed batch or left in processing by a crashed run go back to the outbox,
+// the ledger skips events it already has, so replays don't double charge
+func FlushUsageOutbox(ctx context.Context) (int, error) {
+	requeueUsageOutbox(ctx)
+	flushed := 0
+	for batch := 0; batch < MAX_USAGE_OUTBOX_BATCHES; batch++ {
+		items := []string{}
+		events := []models.MongoUsageEvent{}
+		for len(items) < USAGE_OUTBOX_BATCH {
+			item, err := redis.RedisClient.RPopLPush(ctx, lib.UsageOutboxKey, lib.UsageOutboxProcessingKey).Result()
+			if err != nil {
+				if err.Error() != "redis: nil" {
+					log.Errorf("[ledger] error taking usage event from outbox: %v", err)
+				}
+				break
+			}
+			var event models.MongoUsageEvent
+			err = json.Unmarshal([]byte(item), &event)
+			if err != nil || event.ID == "" {
+				log.Errorf("[ledger] dropping malformed usage event %s: %v", item, err)
+				redis.RedisClient.LRem(ctx, lib.UsageOutboxProcessingKey, 1, item)
+				continue
+			}
+			items = append(items, item)
+			events = append(events, event)
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		err := mongo.MongoDBClient.SaveUsageEvents(ctx, events)
+		if err != nil {
+			requeueUsageOutbox(ctx)
+			return flushed, err
+		}
+		for _, item := range items {
+			redis.RedisClient.LRem(ctx, lib.UsageOutboxProcessingKey, 1, item)
+		}
+		flushed += len(events)
+	}
+	return flushed, nil
+}
+
+func requeueUsageOutbox(ctx context.Context) {
+	requeued := 0
+	for {
+		_, err := redis.RedisClient.RPopLPush(ctx, lib.UsageOutboxProcessingKey, lib.UsageOutboxKey).Result()
+		if err != nil {
+			break
+		}
+		requeued++
+	}
+	if requeued > 0 {
+		log.Warnf("[ledger] requeued %d usage events left in processing", requeued)
+	}
+}
+
+// UsagePeriodStart is when monthly counters were cleared last, by the clearusage worker or by the calendar
+func UsagePeriodStart(ctx context.Context, now time.Time) time.Time {
+	cleared, err := redis.RedisClient.Get(ctx, lib.UsagePeriodStartKey).Result()
+	if err == nil {
+		start, err := time.Parse(time.RFC3339, cleared)
+		if err == nil && !start.After(now) {
+			return start
+		}
+	}
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), USAGE_PERIOD_START_DAY, 0, 0, 0, 0, time.UTC)
+	if start.After(now) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start
+}
+
+// RebuildUsageCounters sets monthly usage counters of all users, or of the given one, to sums of the ledger since
+// the period start, the outbox is flushed first, returns the number of users rebuilt. It refuses to run when the ledger
+// started after the period start, as spend billed before it would be lost. Counters of a user are set in one transaction
+// instead of being cleared first, so increments billed meanwhile aren't dropped in between
+func RebuildUsageCounters(ctx context.Context, userId string) (int, error) {
+	_, err := FlushUsageOutbox(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to flush usage outbox: %w", err)
+	}
+	periodStart := UsagePeriodStart(ctx, time.Now())
+	ledgerStart, err := mongo.MongoDBClient.GetFirstUsageEventTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if ledgerStart.IsZero() || ledgerStart.After(periodStart) {
+		return 0, fmt.Errorf("usage ledger starts at %s, after the period start %s, counters billed before it would be lost", ledgerStart.Format(time.RFC3339), periodStart.Format(time.RFC3339))
+	}
+	totals, err := mongo.MongoDBClient.GetUsageTotals(ctx, periodStart, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	users := map[string]*models.MongoUsageTotals{}
+	members := map[string]map[string]float64{}
+	addUser := func(id string) *models.MongoUsageTotals {
+		user, ok := users[id]
+		if !ok {
+			user = &models.MongoUsageTotals{UserId: id}
+			users[id] = user
+			members[id] = map[string]float64{}
+		}
+		return user
+	}
+	for _, total := range totals {
+		user := addUser(total.UserId)
+		user.Cost += total.Cost
+		user.TotalTokens += total.TotalTokens
+		user.AudioMinutes += total.AudioMinutes
+		user.ImagesCount += total.ImagesCount
+		user.SearchQueries += total.SearchQueries
+		if total.MemberId != "" {
+			members[total.UserId][total.MemberId] += total.Cost
+		}
+	}
+	// the ledger covers the period, so users without events in it have spent nothing
+	if userId != "" {
+		addUser(userId)
+	} else {
+		for _, id := range usersWithCounters(ctx) {
+			addUser(id)
+		}
+	}
+
+	for _, user := range users {
+		err = setUsageCounters(ctx, user, members[user.UserId])
+		if err != nil {
+			log.Errorf("[ledger] error setting user %s usage counters: %v", user.UserId, err)
+			continue
+		}
+		err = mongo.MongoDBClient.UpdateUserUsage(context.WithValue(ctx, models.UserContext{}, user.UserId), user.Cost)
+		if err != nil {
+			log.Errorf("[ledger] error updating user %s usage: %v", user.UserId, err)
+		}
+	}
+	config.CONFIG.DataDogClient.Count("ledger.counters_rebuilt", int64(len(users)), nil, 1)
+	return len(users), nil
+}
+
+// setUsageCounters sets monthly counters of a user and its group members in one MULTI, member counters
+// missing from the ledger are removed in the same transaction
+func setUsageCounters(ctx context.Context, user *models.MongoUsageTotals, members map[string]float64) error {
+	stale := []string{}
+	memberKeys, err := redis.ScanKeys(ctx, lib.MemberTotalCostKey(user.UserId, "*"))
+	if err != nil {
+		return err
+	}
+	for _, key := range memberKeys {
+		member := strings.TrimSuffix(strings.TrimPrefix(key, user.UserId+":member:"), ":total_cost")
+		if _, ok := members[member]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	_, err = redis.RedisClient.TxPipelined(ctx, func(pipe r.Pipeliner) error {
+		if len(stale) > 0 {
+			pipe.Del(ctx, stale...)
+		}
+		pipe.Set(ctx, lib.UserTotalCostKey(user.UserId), user.Cost, 0)
+		pipe.Set(ctx, lib.UserTotalTokensKey(user.UserId), user.TotalTokens, 0)
+		pipe.Set(ctx, lib.UserTotalAudioMinutesKey(user.UserId), user.AudioMinutes, 0)
+		pipe.Set(ctx, lib.UserTotalImagesKey(user.UserId), user.ImagesCount, 0)
+		pipe.Set(ctx, lib.UserTotalSearchesKey(user.UserId), user.SearchQueries, 0)
+		for member, cost := range members {
+			pipe.Set(ctx, lib.MemberTotalCostKey(user.UserId, member), cost, 0)
+		}
+		return nil
+	})
+	return err
+}
+
+// usersWithCounters lists users with a monthly cost counter, member counters match the pattern too and are skipped
+func usersWithCounters(ctx context.Context) []string {
+	keys, err := redis.ScanKeys(ctx, lib.UserTotalCostKey("*"))
+	if err != nil {
+		log.Errorf("[ledger] error listing usage counters: %v", err)
+		return nil
+	}
+	users := []string{}
+	for _, key := range keys {
+		if strings.Contains(key, ":member:") {
+			continue
+		}
+		users = append(users, strings.TrimSuffix(key, ":total_cost"))
+	}
+	return users
+}
+
+// RebuildSystemTotals sets system_totals counters to sums of the whole ledger in one MULTI. System totals are never
+// cleared, so it refuses to run when the ledger has less spend than the counters, spend billed before the ledger
+// was kept would be lost
+func RebuildSystemTotals(ctx context.Context) error {
+	_, err := FlushUsageOutbox(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to flush usage outbox: %w", err)
+	}
+	ledgerStart, err := mongo.MongoDBClient.GetFirstUsageEventTime(ctx)
+	if err != nil {
+		return err
+	}
+	if ledgerStart.IsZero() {
+		return fmt.Errorf("usage ledger is empty, system totals billed before it would be lost")
+	}
+	countedCost, err := redis.RedisClient.Get(ctx, "system_totals:cost").Float64()
+	if err != nil && err.Error() != "redis: nil" {
+		return fmt.Errorf("failed to get system cost: %w", err)
+	}
+	totals, err := mongo.MongoDBClient.GetUsageTotals(ctx, time.Time{}, "")
+	if err != nil {
+		return err
+	}
+	system := models.MongoUsageTotals{}
+	for _, total := range totals {
+		system.Cost += total.Cost
+		system.TotalTokens += total.TotalTokens
+		system.AudioMinutes += total.AudioMinutes
+		system.ImagesCount += total.ImagesCount
+		system.SearchQueries += total.SearchQueries
+	}
+	if system.Cost+SYSTEM_TOTALS_TOLERANCE < countedCost {
+		return fmt.Errorf("usage ledger since %s has $%.4f, less than $%.4f counted, system totals billed before it would be lost", ledgerStart.Format(time.RFC3339), system.Cost, countedCost)
+	}
+	_, err = redis.RedisClient.TxPipelined(ctx, func(pipe r.Pipeliner) error {
+		pipe.Set(ctx, "system_totals:cost", system.Cost, 0)
+		pipe.Set(ctx, "system_totals:tokens", system.TotalTokens, 0)
+		pipe.Set(ctx, "system_totals:audio_minutes", system.AudioMinutes, 0)
+		pipe.Set(ctx, "system_totals:images", system.ImagesCount, 0)
+		pipe.Set(ctx, "system_totals:searches", system.SearchQueries, 0)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,198 @@
+package payments
+
+import (
+	"context"
+	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBillEnqueuesUsageEvent(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	mongoClient := mongo.NewMockMongoDBClient(models.MongoUser{ID: "123"})
+	mongo.MongoDBClient = mongoClient
+
+	ctx := context.WithValue(context.Background(), models.UserContext{}, "123")
+	ctx = context.WithValue(ctx, models.ClientContext{}, "telegram")
+	ctx = context.WithValue(ctx, models.ChannelContext{}, "123")
+	ctx = context.WithValue(ctx, models.TopicContext{}, "7")
+	ctx = context.WithValue(ctx, models.RequestContext{}, "request-1")
+	ctx = context.WithValue(ctx, models.SubscriptionContext{}, models.FreeSubscriptionName)
+
+	Bill(ctx, models.CostAndUsage{
+		Engine:             models.ChatGpt4oMini,
+		Usage:              models.Usage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150},
+		PricePerInputUnit:  0.001,
+		PricePerOutputUnit: 0.002,
+	})
+
+	pending, _ := redis.RedisClient.LLen(ctx, lib.UsageOutboxKey).Result()
+	assert.Equal(t, int64(1), pending)
+
+	flushed, err := FlushUsageOutbox(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, flushed)
+	assert.Len(t, mongoClient.UsageEvents, 1)
+	event := mongoClient.UsageEvents[0]
+	assert.Equal(t, "123", event.UserId)
+	assert.Equal(t, "telegram", event.Client)
+	assert.Equal(t, "7", event.TopicId)
+	assert.Equal(t, "request-1", event.RequestId)
+	assert.Equal(t, string(models.ChatGpt4oMini), event.Engine)
+	assert.Equal(t, 150, event.TotalTokens)
+	assert.InDelta(t, 0.2, event.Cost, 1e-9)
+}
+
+func TestFlushUsageOutboxReplay(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	mongoClient := mongo.NewMockMongoDBClient(models.MongoUser{ID: "123"})
+	mongo.MongoDBClient = mongoClient
+	ctx := context.WithValue(context.Background(), models.UserContext{}, "123")
+
+	enqueueUsageEvent(ctx, models.CostAndUsage{User: "123", Cost: 0.1})
+	enqueueUsageEvent(ctx, models.CostAndUsage{User: "123", Cost: 0.2})
+
+	// a crashed run left an event in processing, that was saved already
+	item, _ := redis.RedisClient.RPopLPush(ctx, lib.UsageOutboxKey, lib.UsageOutboxProcessingKey).Result()
+	redis.RedisClient.LPush(ctx, lib.UsageOutboxProcessingKey, "not json")
+	redis.RedisClient.LPush(ctx, lib.UsageOutboxKey, item)
+
+	flushed, err := FlushUsageOutbox(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, flushed)
+	assert.Len(t, mongoClient.UsageEvents, 2)
+
+	pending, _ := redis.RedisClient.LLen(ctx, lib.UsageOutboxKey).Result()
+	assert.Equal(t, int64(0), pending)
+	processing, _ := redis.RedisClient.LLen(ctx, lib.UsageOutboxProcessingKey).Result()
+	assert.Equal(t, int64(0), processing)
+}
+
+func TestRebuildUsageCounters(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	mongoClient := mongo.NewMockMongoDBClient(models.MongoUser{ID: "-100"})
+	mongo.MongoDBClient = mongoClient
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	mongoClient.UsageEvents = []models.MongoUsageEvent{
+		{ID: "1", UserId: "-100", MemberId: "1", Cost: 0.1, TotalTokens: 100, CreatedAt: now},
+		{ID: "2", UserId: "-100", MemberId: "2", Cost: 0.2, ImagesCount: 1, CreatedAt: now},
+		{ID: "3", UserId: "-100", MemberId: "2", Cost: 5, CreatedAt: now.AddDate(0, -2, 0)},
+	}
+	redis.RedisClient.Set(ctx, lib.UserTotalCostKey("-100"), 10, 0)
+	redis.RedisClient.Set(ctx, lib.MemberTotalCostKey("-100", "3"), 10, 0)
+	enqueueUsageEvent(context.WithValue(ctx, models.MemberContext{}, "1"), models.CostAndUsage{User: "-100", Cost: 0.3, Usage: models.Usage{SearchQueries: 1}})
+
+	rebuilt, err := RebuildUsageCounters(ctx, "-100")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rebuilt)
+
+	cost, _ := redis.RedisClient.Get(ctx, lib.UserTotalCostKey("-100")).Float64()
+	assert.InDelta(t, 0.6, cost, 1e-9)
+	tokens, _ := redis.RedisClient.Get(ctx, lib.UserTotalTokensKey("-100")).Int64()
+	assert.Equal(t, int64(100), tokens)
+	images, _ := redis.RedisClient.Get(ctx, lib.UserTotalImagesKey("-100")).Int64()
+	assert.Equal(t, int64(1), images)
+	searches, _ := redis.RedisClient.Get(ctx, lib.UserTotalSearchesKey("-100")).Int64()
+	assert.Equal(t, int64(1), searches)
+	memberCost, _ := redis.RedisClient.Get(ctx, lib.MemberTotalCostKey("-100", "1")).Float64()
+	assert.InDelta(t, 0.4, memberCost, 1e-9)
+	_, err = redis.RedisClient.Get(ctx, lib.MemberTotalCostKey("-100", "3")).Result()
+	assert.Error(t, err)
+}
+
+func TestRebuildUsageCountersAllUsers(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	mongoClient := mongo.NewMockMongoDBClient(models.MongoUser{ID: "123"})
+	mongo.MongoDBClient = mongoClient
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	mongoClient.UsageEvents = []models.MongoUsageEvent{
+		{ID: "1", UserId: "123", Cost: 0.5, CreatedAt: now},
+		{ID: "2", UserId: "123", Cost: 1, CreatedAt: now.AddDate(0, -2, 0)},
+	}
+	redis.RedisClient.Set(ctx, lib.UserTotalCostKey("123"), 2, 0)
+	redis.RedisClient.Set(ctx, lib.UserTotalCostKey("456"), 3, 0)
+	redis.RedisClient.Set(ctx, lib.MemberTotalCostKey("-100", "1"), 1, 0)
+
+	rebuilt, err := RebuildUsageCounters(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rebuilt)
+
+	cost, _ := redis.RedisClient.Get(ctx, lib.UserTotalCostKey("123")).Float64()
+	assert.InDelta(t, 0.5, cost, 1e-9)
+	// the ledger covers the period, so users without events in it have spent nothing
+	cost, _ = redis.RedisClient.Get(ctx, lib.UserTotalCostKey("456")).Float64()
+	assert.Zero(t, cost)
+}
+
+func TestRebuildUsageCountersRefusesPartialLedger(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	mongoClient := mongo.NewMockMongoDBClient(models.MongoUser{ID: "123"})
+	mongo.MongoDBClient = mongoClient
+	ctx := context.Background()
+
+	// the ledger was started in the middle of the period
+	redis.RedisClient.Set(ctx, lib.UsagePeriodStartKey, time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), 0)
+	mongoClient.UsageEvents = []models.MongoUsageEvent{{ID: "1", UserId: "123", Cost: 0.5, CreatedAt: time.Now().UTC()}}
+	redis.RedisClient.Set(ctx, lib.UserTotalCostKey("123"), 2, 0)
+
+	_, err := RebuildUsageCounters(ctx, "123")
+	assert.Error(t, err)
+	cost, _ := redis.RedisClient.Get(ctx, lib.UserTotalCostKey("123")).Float64()
+	assert.Equal(t, 2.0, cost)
+
+	mongoClient.UsageEvents = nil
+	_, err = RebuildUsageCounters(ctx, "")
+	assert.Error(t, err, "an empty ledger covers nothing")
+}
+
+func TestUsagePeriodStart(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	ctx := context.Background()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC), UsagePeriodStart(ctx, now))
+	now = time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), UsagePeriodStart(ctx, now))
+
+	redis.RedisClient.Set(ctx, lib.UsagePeriodStartKey, "2024-03-02T00:05:00Z", 0)
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 5, 0, 0, time.UTC), UsagePeriodStart(ctx, now))
+}
+
+func TestRebuildSystemTotals(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	mongoClient := mongo.NewMockMongoDBClient(models.MongoUser{ID: "123"})
+	mongo.MongoDBClient = mongoClient
+	ctx := context.Background()
+
+	assert.Error(t, RebuildSystemTotals(ctx), "an empty ledger covers nothing")
+
+	now := time.Now().UTC()
+	mongoClient.UsageEvents = []models.MongoUsageEvent{
+		{ID: "1", UserId: "123", Cost: 0.5, TotalTokens: 100, CreatedAt: now.AddDate(0, -2, 0)},
+		{ID: "2", UserId: "456", Cost: 1, ImagesCount: 2, CreatedAt: now},
+	}
+	// counters have spend billed before the ledger was kept
+	redis.RedisClient.Set(ctx, "system_totals:cost", 10.0, 0)
+	assert.Error(t, RebuildSystemTotals(ctx))
+	cost, _ := redis.RedisClient.Get(ctx, "system_totals:cost").Float64()
+	assert.Equal(t, 10.0, cost)
+
+	// counters missed some spend the ledger has
+	redis.RedisClient.Set(ctx, "system_totals:cost", 1.2, 0)
+	assert.NoError(t, RebuildSystemTotals(ctx))
+	cost, _ = redis.RedisClient.Get(ctx, "system_totals:cost").Float64()
+	assert.InDelta(t, 1.5, cost, 1e-9)
+	tokens, _ := redis.RedisClient.Get(ctx, "system_totals:tokens").Int64()
+	assert.Equal(t, int64(100), tokens)
+	images, _ := redis.RedisClient.Get(ctx, "system_totals:images").Int64()
+	assert.Equal(t, int64(2), images)
+}
@@ -8,6 +8,7 @@ import (
 	"talk2robots/m/v2/app/config"
 	"talk2robots/m/v2/app/db/mongo"
 	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/models"
 	"talk2robots/m/v2/app/util"
@@ -133,7 +134,7 @@ func StripeCreateCheckoutSession(ctx context.Context, bot *telego.Bot, message *
 	params.AddMetadata(AppID, config.CONFIG.BotName)
 	s, err := session.New(params)
 	if err != nil {
-		bot.SendMessage(context.Background(), tu.Message(chatID, "Couldn't reach Stripe. Please try again later."))
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "stripe.unreachable")))
 		log.Errorf("StripeCreateCheckoutSession: %v", err)
 		return nil, err
 	}
@@ -145,7 +146,7 @@ func StripeGetCustomer(ctx context.Context, bot *telego.Bot, message *telego.Mes
 	chatID := util.GetChatID(message)
 	c, err := customer.Get(customerId, nil)
 	if err != nil {
-		bot.SendMessage(context.Background(), tu.Message(chatID, "Couldn't reach Stripe. Please try again later."))
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "stripe.unreachable")))
 		log.Errorf("StripeGetCustomer: %v", err)
 		return nil, err
 	}
@@ -197,8 +198,9 @@ func handleCheckoutSessionCompleted(session stripe.CheckoutSession) {
 		return
 	}
 	chatID := tu.ID(chatIDInt64)
+	ctx = withStoredLanguage(ctx)
 
-	failedNotification := "Failed to upgrade your account to basic paid plan. Please contact /support for help."
+	failedNotification := i18n.T(ctx, "stripe.upgrade_failed")
 	failedNotification = lib.AddBotSuffixToGroupCommands(ctx, failedNotification)
 	if session.PaymentStatus != "paid" {
 		log.Errorf("Checkout session %s payment status is not paid: %s, user_id: %s", session.ID, session.PaymentStatus, chatIDString)
@@ -213,7 +215,7 @@ func handleCheckoutSessionCompleted(session stripe.CheckoutSession) {
 		return
 	}
 
-	PaymentsBot.SendMessage(ctx, tu.Message(chatID, "Your account has been upgraded to basic paid plan! Thanks for your support and enjoy using the bot!"))
+	PaymentsBot.SendMessage(ctx, tu.Message(chatID, i18n.T(ctx, "stripe.upgraded")))
 
 	go func() {
 		// update subscription metadata to include telegram chat id
@@ -269,10 +271,11 @@ func handleCustomerSubscriptionDeleted(subscription stripe.Subscription) {
 	ctx := context.WithValue(context.Background(), models.UserContext{}, chatIDString)
 	ctx = context.WithValue(ctx, models.ClientContext{}, "telegram") //  TODO: fix for slack/other clients
 	chatID := tu.ID(chatIDInt64)
+	ctx = withStoredLanguage(ctx)
 	err = mongo.MongoDBClient.UpdateUserSubscription(ctx, models.Subscriptions[models.FreePlusSubscriptionName])
 	if err != nil {
 		log.Errorf("handleCustomerSubscriptionDeleted: failed to update MongoDB record, user id: %s: %v", chatIDString, err)
-		notification := "Failed to cancel your subscription. Please contact /support for help."
+		notification := i18n.T(ctx, "stripe.cancel_failed")
 		notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
 		PaymentsBot.SendMessage(ctx, tu.Message(chatID, notification))
 		return
@@ -281,6 +284,15 @@ func handleCustomerSubscriptionDeleted(subscription stripe.Subscription) {
 	//  downgrade engine to GPT 4o Mini
 	go redis.SaveModel(chatIDString, models.ChatGpt4oMini)
 
-	PaymentsBot.SendMessage(ctx, tu.Message(chatID, "Your subscription has been canceled and the account downgraded to free+. No further charges will be made. If you were using GPT-4 it was downgraded to GPT-3.5 Turbo."))
+	PaymentsBot.SendMessage(ctx, tu.Message(chatID, i18n.T(ctx, "stripe.canceled")))
 	log.Infof("Successfully deleted customer %s subscription %s, user id: %s", subscription.Customer.ID, subscription.ID, chatIDString)
 }
+
+// withStoredLanguage adds the user language to webhook contexts, which don't come from a chat message
+func withStoredLanguage(ctx context.Context) context.Context {
+	user, err := mongo.MongoDBClient.GetUser(ctx)
+	if err != nil || user == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, models.LanguageContext{}, user.Language)
+}
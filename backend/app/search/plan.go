@@ -0,0 +1,66 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MAX_QUERIES planned for a single question
+const MAX_QUERIES = 3
+
+// PlannerPrompt asks a chat model to turn a question into search engine queries
+func PlannerPrompt(now time.Time) string {
+	return fmt.Sprintf(`You plan web searches. Today is %s.
+Reply with up to %d short search engine queries that together find what's needed to answer the user's question, one query per line, without numbering or explanations.
+Use the language of the question, add an English query if sources are likely in English. Resolve relative dates like "this year" or "yesterday" to actual dates.`, now.Format("Monday, 2 January 2006"), MAX_QUERIES)
+}
+
+var queryPrefix = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s*`)
+
+// ParsePlannedQueries cleans up the planner reply, falling back to the question itself
+func ParsePlannedQueries(reply string, question string, max int) []string {
+	queries := []string{}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(reply, "\n") {
+		query := queryPrefix.ReplaceAllString(line, "")
+		query = strings.Trim(strings.TrimSpace(query), `"'`+"`")
+		key := strings.ToLower(query)
+		if query == "" || seen[key] || len(query) > 300 {
+			continue
+		}
+		seen[key] = true
+		queries = append(queries, query)
+		if len(queries) == max {
+			break
+		}
+	}
+	if len(queries) == 0 {
+		question = strings.TrimSpace(question)
+		if len(question) > 300 {
+			question = question[:300]
+		}
+		return []string{question}
+	}
+	return queries
+}
+
+// Render formats results as a context block for the AI, Content is a page excerpt when the page was read
+func Render(queries []string, results []Result, contents []string) string {
+	var block strings.Builder
+	fmt.Fprintf(&block, "<search_results queries=%q>\n", strings.Join(queries, "; "))
+	for i, result := range results {
+		date := ""
+		if result.Date != "" {
+			date = fmt.Sprintf(" date=%q", result.Date)
+		}
+		text := result.Snippet
+		if i < len(contents) && contents[i] != "" {
+			text = contents[i]
+		}
+		fmt.Fprintf(&block, "<result n=\"%d\" url=%q title=%q%s>\n%s\n</result>\n", i+1, result.URL, result.Title, date, strings.TrimSpace(text))
+	}
+	block.WriteString("</search_results>")
+	return block.String()
+}
@@ -0,0 +1,30 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlannedQueries(t *testing.T) {
+	reply := "1. Go 1.24 release notes\n- \"go 1.24 generic type aliases\"\n\n* Go 1.24 release notes\n4) swiss tables go map"
+	assert.Equal(t, []string{"Go 1.24 release notes", "go 1.24 generic type aliases", "swiss tables go map"}, ParsePlannedQueries(reply, "what's new in go?", 3))
+	assert.Equal(t, []string{"Go 1.24 release notes"}, ParsePlannedQueries(reply, "what's new in go?", 1))
+	assert.Equal(t, []string{"what's new in go?"}, ParsePlannedQueries(" \n", " what's new in go? ", 3))
+}
+
+func TestRender(t *testing.T) {
+	results := []Result{
+		{Title: "Go 1.24", URL: "https://go.dev/blog/go1.24", Snippet: "Go 1.24 is released", Date: "2025-02-11"},
+		{Title: "Swiss tables", URL: "https://go.dev/blog/swisstable", Snippet: "faster maps"},
+	}
+	expected := `<search_results queries="go 1.24; go maps">
+<result n="1" url="https://go.dev/blog/go1.24" title="Go 1.24" date="2025-02-11">
+Go 1.24 is released
+</result>
+<result n="2" url="https://go.dev/blog/swisstable" title="Swiss tables">
+The whole article
+</result>
+</search_results>`
+	assert.Equal(t, expected, Render([]string{"go 1.24", "go maps"}, results, []string{"", "The whole article"}))
+}
@@ -0,0 +1,243 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// https://docs.perplexity.ai/getting-started/pricing
+// https://brave.com/search/api/
+const (
+	PERPLEXITY_SEARCH_PRICE = 5.0 / 1000
+	BRAVE_SEARCH_PRICE      = 5.0 / 1000
+
+	PERPLEXITY_SEARCH_URL = "https://api.perplexity.ai/search"
+	BRAVE_SEARCH_URL      = "https://api.search.brave.com/res/v1/web/search"
+)
+
+// Perplexity uses the Search API, returning ranked results with page excerpts
+type Perplexity struct {
+	client *http.Client
+	apiKey string
+	url    string
+}
+
+func NewPerplexity(client *http.Client, apiKey string) *Perplexity {
+	return &Perplexity{client: client, apiKey: apiKey, url: PERPLEXITY_SEARCH_URL}
+}
+
+func (p *Perplexity) Name() string {
+	return "perplexity"
+}
+
+func (p *Perplexity) PricePerQuery() float64 {
+	return PERPLEXITY_SEARCH_PRICE
+}
+
+func (p *Perplexity) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":       query,
+		"max_results": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	var response struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Snippet string `json:"snippet"`
+			Date    string `json:"date"`
+		} `json:"results"`
+	}
+	err = doJSON(p.client, request, p.Name(), &response)
+	if err != nil {
+		return nil, err
+	}
+	results := []Result{}
+	for _, result := range response.Results {
+		results = append(results, Result{Title: result.Title, URL: result.URL, Snippet: result.Snippet, Date: result.Date})
+	}
+	return limitResults(results, limit), nil
+}
+
+// Brave uses the Web Search API
+type Brave struct {
+	client *http.Client
+	apiKey string
+	url    string
+}
+
+func NewBrave(client *http.Client, apiKey string) *Brave {
+	return &Brave{client: client, apiKey: apiKey, url: BRAVE_SEARCH_URL}
+}
+
+func (b *Brave) Name() string {
+	return "brave"
+}
+
+func (b *Brave) PricePerQuery() float64 {
+	return BRAVE_SEARCH_PRICE
+}
+
+func (b *Brave) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	parameters := url.Values{}
+	parameters.Set("q", query)
+	parameters.Set("count", strconv.Itoa(limit))
+	parameters.Set("extra_snippets", "true")
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+"?"+parameters.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("X-Subscription-Token", b.apiKey)
+
+	var response struct {
+		Web struct {
+			Results []struct {
+				Title         string   `json:"title"`
+				URL           string   `json:"url"`
+				Description   string   `json:"description"`
+				Age           string   `json:"age"`
+				ExtraSnippets []string `json:"extra_snippets"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	err = doJSON(b.client, request, b.Name(), &response)
+	if err != nil {
+		return nil, err
+	}
+	results := []Result{}
+	for _, result := range response.Web.Results {
+		snippet := strings.Join(append([]string{result.Description}, result.ExtraSnippets...), "\n")
+		results = append(results, Result{Title: result.Title, URL: result.URL, Snippet: stripTags(snippet), Date: result.Age})
+	}
+	return limitResults(results, limit), nil
+}
+
+// SearXNG is a self-hosted metasearch engine, the instance must have the json format enabled
+type SearXNG struct {
+	client  *http.Client
+	baseURL string
+}
+
+func NewSearXNG(client *http.Client, baseURL string) *SearXNG {
+	return &SearXNG{client: client, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (s *SearXNG) Name() string {
+	return "searxng"
+}
+
+// PricePerQuery is 0, the instance is self-hosted
+func (s *SearXNG) PricePerQuery() float64 {
+	return 0
+}
+
+func (s *SearXNG) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	parameters := url.Values{}
+	parameters.Set("q", query)
+	parameters.Set("format", "json")
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/search?"+parameters.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	var response struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"publishedDate"`
+		} `json:"results"`
+	}
+	err = doJSON(s.client, request, s.Name(), &response)
+	if err != nil {
+		return nil, err
+	}
+	results := []Result{}
+	for _, result := range response.Results {
+		results = append(results, Result{Title: result.Title, URL: result.URL, Snippet: result.Content, Date: result.PublishedDate})
+	}
+	return limitResults(results, limit), nil
+}
+
+// Stub is used when no provider is configured and in tests, it returns canned results by query
+type Stub struct {
+	Results map[string][]Result
+}
+
+func NewStub() *Stub {
+	return &Stub{Results: map[string][]Result{}}
+}
+
+func (s *Stub) Name() string {
+	return "stub"
+}
+
+func (s *Stub) PricePerQuery() float64 {
+	return 0
+}
+
+func (s *Stub) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	return limitResults(s.Results[query], limit), nil
+}
+
+func doJSON(client *http.Client, request *http.Request, provider string, response interface{}) error {
+	timeNow := time.Now()
+	resp, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("%s search failed: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	log.Debugf("%s search took %s, status: %s", provider, time.Since(timeNow), resp.Status)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s search failed, status: %s", provider, resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(response)
+	if err != nil {
+		return fmt.Errorf("%s search returned invalid json: %w", provider, err)
+	}
+	return nil
+}
+
+func limitResults(results []Result, limit int) []Result {
+	if len(results) > limit {
+		return results[:limit]
+	}
+	return results
+}
+
+// stripTags removes <strong> highlights some engines put into snippets
+func stripTags(text string) string {
+	var builder strings.Builder
+	inTag := false
+	for _, r := range text {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			builder.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(builder.String())
+}
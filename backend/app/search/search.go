@@ -0,0 +1,108 @@
+// Package search runs web searches through a pluggable provider, so any chat engine can answer with fresh sources
+package search
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/models"
+	"time"
+)
+
+const (
+	TIMEOUT = 15 * time.Second
+
+	// MAX_RESULTS per query, providers are asked for this many results
+	MAX_RESULTS = 5
+)
+
+// Result is a single search hit, Snippet is a short excerpt provided by the search engine
+type Result struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+	Date    string `json:"date,omitempty"`
+}
+
+// SearchProvider searches the web, PricePerQuery is billed for every Search call
+type SearchProvider interface {
+	Name() string
+	PricePerQuery() float64
+	Search(ctx context.Context, query string, limit int) ([]Result, error)
+}
+
+// Engine is how search calls are attributed in billing, e.g. search:brave
+func Engine(provider SearchProvider) models.Engine {
+	return models.Engine("search:" + provider.Name())
+}
+
+// NewProvider picks the configured provider, or the first one with credentials,
+// the local stub is used when none is configured, so the mode keeps working without sources
+func NewProvider(cfg *config.Config) SearchProvider {
+	client := &http.Client{Timeout: TIMEOUT}
+	switch strings.ToLower(cfg.SearchProvider) {
+	case "perplexity":
+		return NewPerplexity(client, cfg.PerplexityAPIKey)
+	case "brave":
+		return NewBrave(client, cfg.BraveSearchAPIKey)
+	case "searxng":
+		return NewSearXNG(client, cfg.SearXNGURL)
+	case "stub":
+		return NewStub()
+	}
+
+	switch {
+	case cfg.PerplexityAPIKey != "":
+		return NewPerplexity(client, cfg.PerplexityAPIKey)
+	case cfg.BraveSearchAPIKey != "":
+		return NewBrave(client, cfg.BraveSearchAPIKey)
+	case cfg.SearXNGURL != "":
+		return NewSearXNG(client, cfg.SearXNGURL)
+	}
+	return NewStub()
+}
+
+// MergeResults interleaves results of several queries, skipping duplicate links, up to max results
+func MergeResults(resultSets [][]Result, max int) []Result {
+	merged := []Result{}
+	seen := map[string]bool{}
+	for position := 0; len(merged) < max; position++ {
+		added := false
+		for _, results := range resultSets {
+			if position >= len(results) {
+				continue
+			}
+			added = true
+			result := results[position]
+			key := normalizeURL(result.URL)
+			if result.URL == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, result)
+			if len(merged) == max {
+				break
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return merged
+}
+
+// normalizeURL ignores scheme, www, fragments and trailing slashes when comparing links
+func normalizeURL(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Host), "www.")
+	path := strings.TrimSuffix(parsed.Path, "/")
+	if parsed.RawQuery != "" {
+		path += "?" + parsed.RawQuery
+	}
+	return host + path
+}
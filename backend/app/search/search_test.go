@@ -0,0 +1,74 @@
+package search
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"talk2robots/m/v2/app/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProvider(t *testing.T) {
+	assert.Equal(t, "stub", NewProvider(&config.Config{}).Name())
+	assert.Equal(t, "brave", NewProvider(&config.Config{BraveSearchAPIKey: "key", SearXNGURL: "http://searx"}).Name())
+	assert.Equal(t, "perplexity", NewProvider(&config.Config{PerplexityAPIKey: "key", BraveSearchAPIKey: "key"}).Name())
+	assert.Equal(t, "searxng", NewProvider(&config.Config{SearchProvider: "SearXNG", PerplexityAPIKey: "key", SearXNGURL: "http://searx"}).Name())
+}
+
+func TestProviders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/perplexity":
+			assert.Equal(t, "Bearer perplexity-key", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"results":[{"title":"Go 1.24","url":"https://go.dev/blog/go1.24","snippet":"Go 1.24 is released","date":"2025-02-11"},{"title":"Second","url":"https://example.com","snippet":"more"}]}`))
+		case "/brave":
+			assert.Equal(t, "brave-key", r.Header.Get("X-Subscription-Token"))
+			assert.Equal(t, "go release", r.URL.Query().Get("q"))
+			w.Write([]byte(`{"web":{"results":[{"title":"Go 1.24","url":"https://go.dev/blog/go1.24","description":"<strong>Go</strong> 1.24 is released","age":"February 11, 2025","extra_snippets":["with generic type aliases"]}]}}`))
+		case "/searxng/search":
+			assert.Equal(t, "json", r.URL.Query().Get("format"))
+			w.Write([]byte(`{"results":[{"title":"Go 1.24","url":"https://go.dev/blog/go1.24","content":"Go 1.24 is released"}]}`))
+		default:
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	perplexity := NewPerplexity(server.Client(), "perplexity-key")
+	perplexity.url = server.URL + "/perplexity"
+	results, err := perplexity.Search(context.Background(), "go release", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{{Title: "Go 1.24", URL: "https://go.dev/blog/go1.24", Snippet: "Go 1.24 is released", Date: "2025-02-11"}}, results)
+
+	brave := NewBrave(server.Client(), "brave-key")
+	brave.url = server.URL + "/brave"
+	results, err = brave.Search(context.Background(), "go release", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{{Title: "Go 1.24", URL: "https://go.dev/blog/go1.24", Snippet: "Go 1.24 is released\nwith generic type aliases", Date: "February 11, 2025"}}, results)
+
+	searxng := NewSearXNG(server.Client(), server.URL+"/searxng/")
+	results, err = searxng.Search(context.Background(), "go release", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "Go 1.24 is released", results[0].Snippet)
+
+	brave.url = server.URL + "/missing"
+	_, err = brave.Search(context.Background(), "go release", 5)
+	assert.ErrorContains(t, err, "429")
+}
+
+func TestMergeResults(t *testing.T) {
+	merged := MergeResults([][]Result{
+		{{URL: "https://a.com/1"}, {URL: "https://b.com/"}, {URL: "https://c.com"}},
+		{{URL: "http://www.b.com"}, {URL: "https://a.com/1#intro"}, {URL: "https://d.com"}},
+	}, 4)
+	urls := []string{}
+	for _, result := range merged {
+		urls = append(urls, result.URL)
+	}
+	assert.Equal(t, []string{"https://a.com/1", "http://www.b.com", "https://c.com", "https://d.com"}, urls)
+
+	assert.Empty(t, MergeResults(nil, 3))
+	assert.Len(t, MergeResults([][]Result{{{URL: "https://a.com"}}}, 3), 1)
+}
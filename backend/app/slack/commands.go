@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/models"
 
@@ -18,7 +19,7 @@ func upgradeCommandHandler(ctx context.Context, bot *Bot) {
 	log.Infof("upgradeCommandHandler: userId: %s, channelId: %s", userString, channelId)
 	if lib.IsUserFree(ctx) {
 		_, _, _, err := bot.SendMessage(channelId,
-			slack.MsgOptionText("Upgrading to free+ gives you 5x monthly usage limits, effective immediately 🎉", false),
+			slack.MsgOptionText(i18n.T(ctx, "upgrade.free_plus_benefits"), false),
 			slack.MsgOptionPostEphemeral(userId))
 		if err != nil {
 			log.Errorf("Failed to send upgrade message to user %s, %v", userString, err)
@@ -27,18 +28,18 @@ func upgradeCommandHandler(ctx context.Context, bot *Bot) {
 		if err != nil {
 			log.Errorf("Failed to update user %s subscription: %v", userString, err)
 			bot.SendMessage(channelId,
-				slack.MsgOptionText("Failed to upgrade your account to free+ plan. Please try again later.", false),
+				slack.MsgOptionText(i18n.T(ctx, "upgrade.free_plus_failed"), false),
 				slack.MsgOptionPostEphemeral(userId))
 			return
 		}
 		bot.SendMessage(channelId,
-			slack.MsgOptionText("You are now a free+ user 🥳! Thanks for trying the bot and the wish to support it's development! 🙏", false),
+			slack.MsgOptionText(i18n.T(ctx, "upgrade.free_plus_done"), false),
 			slack.MsgOptionPostEphemeral(userId))
 		return
 	}
 	if lib.IsUserFreePlus(ctx) || lib.IsUserBasic(ctx) {
 		_, _, _, err := bot.SendMessage(channelId,
-			slack.MsgOptionText("You are already a free+ plan user! Premium upgrade plans are not available yet. Stay tuned for updates!", false),
+			slack.MsgOptionText(i18n.T(ctx, "upgrade.already_free_plus"), false),
 			slack.MsgOptionPostEphemeral(userId))
 		if err != nil {
 			log.Errorf("Failed to send already upgraded message to user %s, %v", userString, err)
@@ -0,0 +1,35 @@
+package slack
+
+import (
+	"context"
+	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// withUserLanguage picks up the slack locale of users without a language yet,
+// falls back to the default language to avoid asking slack on every message
+func withUserLanguage(ctx context.Context, user *models.MongoUser, slackUserId string) context.Context {
+	if user != nil && user.Language != "" {
+		return ctx
+	}
+	info, err := BOT.GetUserInfo(slackUserId)
+	if err != nil {
+		log.Errorf("Failed to get slack user %s info: %v", slackUserId, err)
+		return ctx
+	}
+	language := i18n.Language(info.Locale)
+	if language == "" {
+		language = i18n.DEFAULT_LANGUAGE
+	}
+	err = mongo.MongoDBClient.UpdateUserLanguage(ctx, language)
+	if err != nil {
+		log.Errorf("Failed to save language %s for slack user %s: %v", language, slackUserId, err)
+	}
+	if user != nil {
+		user.Language = language
+	}
+	return context.WithValue(ctx, models.LanguageContext{}, language)
+}
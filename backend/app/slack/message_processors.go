@@ -3,6 +3,7 @@ package slack
 import (
 	"context"
 	"strings"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/models"
 	"talk2robots/m/v2/app/util"
@@ -42,7 +43,7 @@ func ProcessStreamingMessage(
 
 	if err != nil {
 		log.Errorf("Failed get streaming response from Open AI: %s", err)
-		_, _, _, err = BOT.SendMessage(channelId, slack.MsgOptionText("Oopsie, it looks like my AI brain isn't working 🧠🔥. Please try again later.", false), slack.MsgOptionPostEphemeral(userId))
+		_, _, _, err = BOT.SendMessage(channelId, slack.MsgOptionText(i18n.T(ctx, "oopsie"), false), slack.MsgOptionPostEphemeral(userId))
 		if err != nil {
 			log.Errorf("Failed to send error message in chat: %s, user: %s, %v", channelId, userId, err)
 		}
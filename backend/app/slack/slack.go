@@ -9,6 +9,7 @@ import (
 	"talk2robots/m/v2/app/ai/openai"
 	"talk2robots/m/v2/app/config"
 	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/models"
 	"time"
@@ -26,7 +27,6 @@ var (
 	GRAMMAR_CHECK_REACTION              = "eyeglasses"
 	SUMMARIZE_REACTION                  = "memo"
 	PROCESSED_REACTION                  = "white_check_mark"
-	HOME_TAB_MESSAGE                    = "I'm @gienji, your intelligent chatbot. Just DM your question or request, and I'll do my best to provide you with the information you need. You can also add me to a channel and mention @gienji. React a message with :eyeglasses: to check grammar, :memo: to summarize threads.\n\n Btw, did I mention that I'm powered by OpenAI's API and completely open source - https://github.com/radiantspace/talk2robots?"
 	TIMEOUT                             = 2 * time.Minute
 	THREAD_MESSAGES_LIMIT_FOR_SUMMARIZE = 100
 )
@@ -162,19 +162,22 @@ func slackCommandsHandler(ctx *fasthttp.RequestCtx) {
 	ctx.Response.SetBodyString("")
 
 	go func() {
+		user, currentContext, cancelFunc, err := lib.SetupUserAndContext(userId, lib.SlackClientName, command.ChannelID, "")
+		if err != nil {
+			log.Errorf("Error setting up user and context: %v", err)
+			return
+		}
+		defer cancelFunc()
+		currentContext = withUserLanguage(currentContext, user, command.UserID)
+
 		switch command.Command {
 		case "/grammar":
 			lib.SaveMode(userId, "", lib.Grammar, "")
-			BOT.SendMessage(command.ChannelID, slack.MsgOptionText("Grammar mode enabled", false), slack.MsgOptionPostEphemeral(command.UserID))
+			BOT.SendMessage(command.ChannelID, slack.MsgOptionText(i18n.T(currentContext, "slack.grammar_enabled"), false), slack.MsgOptionPostEphemeral(command.UserID))
 		case "/chatgpt":
 			lib.SaveMode(userId, "", lib.ChatGPT, "")
-			BOT.SendMessage(command.ChannelID, slack.MsgOptionText("ChatGPT mode enabled", false), slack.MsgOptionPostEphemeral(command.UserID))
+			BOT.SendMessage(command.ChannelID, slack.MsgOptionText(i18n.T(currentContext, "slack.chatgpt_enabled"), false), slack.MsgOptionPostEphemeral(command.UserID))
 		case "/upgrade":
-			_, currentContext, _, err := lib.SetupUserAndContext(userId, lib.SlackClientName, command.ChannelID, "")
-			if err != nil {
-				log.Errorf("Error setting up user and context: %v", err)
-				return
-			}
 			upgradeCommandHandler(currentContext, BOT)
 		}
 	}()
@@ -193,7 +196,7 @@ func handleUrlVerificationEvent(ctx *fasthttp.RequestCtx) {
 
 func handleAppHomeOpenedEvent(ev *slackevents.AppHomeOpenedEvent) {
 	userId := "slack:" + ev.User
-	user, _, cancelFunc, err := lib.SetupUserAndContext(userId, lib.SlackClientName, ev.Channel, "")
+	user, currentContext, cancelFunc, err := lib.SetupUserAndContext(userId, lib.SlackClientName, ev.Channel, "")
 	if err != nil {
 		if err == lib.ErrUserBanned {
 			log.Infof("User %s is banned", userId)
@@ -204,6 +207,7 @@ func handleAppHomeOpenedEvent(ev *slackevents.AppHomeOpenedEvent) {
 		return
 	}
 	defer cancelFunc()
+	currentContext = withUserLanguage(currentContext, user, ev.User)
 
 	usage := user.Usage
 	productName := user.SubscriptionType.Name
@@ -212,12 +216,12 @@ func handleAppHomeOpenedEvent(ev *slackevents.AppHomeOpenedEvent) {
 	// Row 1: Current Plan and Upgrade Button
 	currentPlanSection := slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("✅ *%s*", productName), false, false), nil, nil)
 	if hasFreePlan {
-		currentPlanSection.Accessory = slack.NewAccessory(slack.NewButtonBlockElement("", "upgrade_plan", slack.NewTextBlockObject("plain_text", "Upgrade", false, false)))
+		currentPlanSection.Accessory = slack.NewAccessory(slack.NewButtonBlockElement("", "upgrade_plan", slack.NewTextBlockObject("plain_text", i18n.T(currentContext, "slack.upgrade"), false, false)))
 		currentPlanSection.Accessory.ButtonElement.URL = "https://radiant.space"
 	}
 
 	// Info section
-	infoSection := slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", HOME_TAB_MESSAGE, false, false), nil, nil)
+	infoSection := slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", i18n.T(currentContext, "slack.home"), false, false), nil, nil)
 
 	row1Blocks := []slack.Block{
 		currentPlanSection,
@@ -226,10 +230,10 @@ func handleAppHomeOpenedEvent(ev *slackevents.AppHomeOpenedEvent) {
 		slack.NewDividerBlock(),
 	}
 
-	usageSection := slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Current $ usage: %f", usage), false, false))
+	usageSection := slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", i18n.T(currentContext, "slack.current_usage", i18n.Args{"usage": fmt.Sprintf("%f", usage)}), false, false))
 	row1Blocks = append(row1Blocks, usageSection)
 
-	contactSupportButton := slack.NewButtonBlockElement("", "email_support", slack.NewTextBlockObject("plain_text", "✉️ Contact support", false, false))
+	contactSupportButton := slack.NewButtonBlockElement("", "email_support", slack.NewTextBlockObject("plain_text", i18n.T(currentContext, "slack.contact_support"), false, false))
 	contactSupportButton.URL = "mailto:free+support@radiant.space"
 
 	row1Blocks = append(row1Blocks, slack.NewActionBlock("", contactSupportButton))
@@ -254,7 +258,7 @@ func handleMessageEvent(userId string, channel string, messageTS string, message
 		log.Errorf("Invalid user: %s", userId)
 		return
 	}
-	_, currentContext, cancelFunc, err := lib.SetupUserAndContext(userId, lib.SlackClientName, channel, "")
+	user, currentContext, cancelFunc, err := lib.SetupUserAndContext(userId, lib.SlackClientName, channel, "")
 	if err != nil {
 		if err == lib.ErrUserBanned {
 			log.Infof("User %s is banned", userId)
@@ -265,11 +269,12 @@ func handleMessageEvent(userId string, channel string, messageTS string, message
 		return
 	}
 	defer cancelFunc()
+	currentContext = withUserLanguage(currentContext, user, strings.TrimPrefix(userId, "slack:"))
 
 	// user usage exceeded monthly limit, send message and return
 	ok, _ := lib.ValidateUserUsage(currentContext)
 	if !ok {
-		BOT.SendMessage(channel, slack.MsgOptionText("Your monthly usage limit has been exceeded. Check /upgrade options.", false), slack.MsgOptionPostEphemeral(userId))
+		BOT.SendMessage(channel, slack.MsgOptionText(i18n.T(currentContext, "usage.exceeded"), false), slack.MsgOptionPostEphemeral(userId))
 		config.CONFIG.DataDogClient.Incr("usage_exceeded", []string{"client:slack"}, 1)
 		return
 	}
@@ -295,7 +300,7 @@ func summarizeThread(userId string, messageTS string, channelId string) {
 		log.Errorf("Invalid user: %s", userId)
 		return
 	}
-	_, currentContext, cancelFunc, err := lib.SetupUserAndContext(userId, lib.SlackClientName, channelId, "")
+	user, currentContext, cancelFunc, err := lib.SetupUserAndContext(userId, lib.SlackClientName, channelId, "")
 	if err != nil {
 		if err == lib.ErrUserBanned {
 			log.Infof("User %s is banned", userId)
@@ -306,11 +311,12 @@ func summarizeThread(userId string, messageTS string, channelId string) {
 		return
 	}
 	defer cancelFunc()
+	currentContext = withUserLanguage(currentContext, user, strings.TrimPrefix(userId, "slack:"))
 
 	// user usage exceeded monthly limit, send message and return
 	ok, _ := lib.ValidateUserUsage(currentContext)
 	if !ok {
-		BOT.SendMessage(channelId, slack.MsgOptionText("Your monthly usage limit has been exceeded. Check /upgrade option.", false), slack.MsgOptionPostEphemeral(userId))
+		BOT.SendMessage(channelId, slack.MsgOptionText(i18n.T(currentContext, "usage.exceeded"), false), slack.MsgOptionPostEphemeral(userId))
 		config.CONFIG.DataDogClient.Incr("usage_exceeded", []string{"client:slack"}, 1)
 		return
 	}
@@ -322,7 +328,7 @@ func summarizeThread(userId string, messageTS string, channelId string) {
 	messageText := fetchMessageThread(channelId, messageTS)
 
 	if messageText == "" {
-		BOT.SendMessage(channelId, slack.MsgOptionText("Error fetching message thread", false), slack.MsgOptionPostEphemeral(userId))
+		BOT.SendMessage(channelId, slack.MsgOptionText(i18n.T(currentContext, "slack.thread_failed"), false), slack.MsgOptionPostEphemeral(userId))
 		return
 	}
 
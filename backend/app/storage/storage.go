@@ -0,0 +1,52 @@
+// package to keep files, generated images for now, on a local disk or in an S3 compatible bucket
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"talk2robots/m/v2/app/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+var ErrInvalidKey = errors.New("invalid blob key")
+
+type BlobStore interface {
+	Name() string
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// BlobStoreClient is nil when no durable store is configured, files aren't kept then
+var BlobStoreClient BlobStore
+
+// NewBlobStore keeps blobs in S3 when a bucket is configured, or in BLOB_STORE_DIR when a durable volume is mounted there,
+// returns nil otherwise: pod scratch space is shared with temporary files and lost on restarts
+func NewBlobStore(cfg *config.Config) BlobStore {
+	if cfg.S3Bucket != "" {
+		return NewS3Store(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKeyID, cfg.S3SecretAccessKey)
+	}
+	if cfg.BlobStoreDir != "" {
+		return NewLocalStore(cfg.BlobStoreDir)
+	}
+	return nil
+}
+
+// Enabled tells if there is a durable store to keep files in
+func Enabled() bool {
+	return BlobStoreClient != nil
+}
+
+// validateKey allows keys like images/123/abc.png, they become paths on disk and in buckets
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/lib"
+	"time"
+
+	"github.com/mymmrac/telego"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ALBUM_WINDOW is how long to wait for the next part of an album, Telegram delivers all parts within a second or so
+	ALBUM_WINDOW = 1500 * time.Millisecond
+
+	// ALBUM_TTL keeps album parts around if a replica restarts before flushing them
+	ALBUM_TTL = time.Minute
+
+	// MERGED_ALBUM_PREFIX marks a merged album message, its Photo holds one size per photo instead of sizes of a single photo
+	MERGED_ALBUM_PREFIX = "merged:"
+)
+
+// albumBuffer collects messages of a media group in Redis until no new parts arrive for the window,
+// parts land on different replicas, so only the replica which got the latest part flushes the album
+type albumBuffer struct {
+	window time.Duration
+	flush  func(bot *telego.Bot, messages []telego.Message)
+}
+
+func newAlbumBuffer(window time.Duration, flush func(bot *telego.Bot, messages []telego.Message)) *albumBuffer {
+	return &albumBuffer{window: window, flush: flush}
+}
+
+var albums = newAlbumBuffer(ALBUM_WINDOW, processAlbum)
+
+func (b *albumBuffer) add(bot *telego.Bot, message telego.Message) {
+	ctx := context.Background()
+	chatID := message.Chat.ChatID().String()
+	key := lib.AlbumKey(chatID, message.MediaGroupID)
+	data, err := json.Marshal(message)
+	if err == nil {
+		err = redis.RedisClient.RPush(ctx, key, string(data)).Err()
+	}
+	if err != nil {
+		// answer the part on its own rather than lose it
+		log.Errorf("Failed to buffer album %s part %d in chat %s: %v", message.MediaGroupID, message.MessageID, chatID, err)
+		b.flush(bot, []telego.Message{message})
+		return
+	}
+	redis.RedisClient.Expire(ctx, key, ALBUM_TTL)
+
+	// every part restarts the window
+	part := strconv.Itoa(message.MessageID)
+	redis.RedisClient.Set(ctx, lib.AlbumLatestKey(chatID, message.MediaGroupID), part, ALBUM_TTL)
+	time.AfterFunc(b.window, func() { b.release(bot, chatID, message.MediaGroupID, part) })
+}
+
+// release flushes the album if no part arrived after this one, the flush lock outlives duplicate releases only
+func (b *albumBuffer) release(bot *telego.Bot, chatID string, mediaGroupID string, part string) {
+	ctx := context.Background()
+	latestKey := lib.AlbumLatestKey(chatID, mediaGroupID)
+	latest, err := redis.RedisClient.Get(ctx, latestKey).Result()
+	if err != nil || latest != part {
+		return
+	}
+	locked, err := redis.RedisClient.SetNX(ctx, lib.AlbumFlushKey(chatID, mediaGroupID), part, b.window).Result()
+	if err != nil || !locked {
+		return
+	}
+
+	key := lib.AlbumKey(chatID, mediaGroupID)
+	parts, err := redis.RedisClient.LRange(ctx, key, 0, -1).Result()
+	redis.RedisClient.Del(ctx, key, latestKey)
+	if err != nil {
+		log.Errorf("Failed to get album %s in chat %s: %v", mediaGroupID, chatID, err)
+		return
+	}
+	messages := []telego.Message{}
+	for _, data := range parts {
+		var message telego.Message
+		if err := json.Unmarshal([]byte(data), &message); err != nil {
+			log.Errorf("Failed to decode album %s part in chat %s: %v", mediaGroupID, chatID, err)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) == 0 {
+		return
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].MessageID < messages[j].MessageID
+	})
+	b.flush(bot, messages)
+}
+
+// processAlbum handles all photos of an album as one message, other media (videos, documents) are handled one by one
+func processAlbum(bot *telego.Bot, messages []telego.Message) {
+	merged, others := mergeAlbum(messages)
+	config.CONFIG.DataDogClient.Incr("telegram.album_received", nil, 1)
+	if merged != nil {
+		log.Infof("Merged album %s of %d photos in chat %d", merged.MediaGroupID, len(merged.Photo), merged.Chat.ID)
+		config.CONFIG.DataDogClient.Count("telegram.album_photos", int64(len(merged.Photo)), nil, 1)
+		handleMessageWithBot(bot, *merged)
+	}
+	for _, message := range others {
+		handleMessageWithBot(bot, message)
+	}
+}
+
+// mergeAlbum puts the largest size of every photo into the first photo message,
+// the album caption is usually on one of the parts and moves to the merged message
+func mergeAlbum(messages []telego.Message) (*telego.Message, []telego.Message) {
+	photos := []telego.Message{}
+	others := []telego.Message{}
+	for _, message := range messages {
+		if len(message.Photo) > 0 {
+			photos = append(photos, message)
+		} else {
+			others = append(others, message)
+		}
+	}
+	if len(photos) == 0 {
+		return nil, others
+	}
+
+	merged := photos[0]
+	merged.Photo = []telego.PhotoSize{}
+	merged.Caption, merged.CaptionEntities = "", nil
+	for _, photo := range photos {
+		merged.Photo = append(merged.Photo, photo.Photo[len(photo.Photo)-1])
+		if merged.Caption == "" && photo.Caption != "" {
+			merged.Caption, merged.CaptionEntities = photo.Caption, photo.CaptionEntities
+		}
+	}
+	for i := range others {
+		if merged.Caption == "" && others[i].Caption != "" {
+			merged.Caption, merged.CaptionEntities = others[i].Caption, others[i].CaptionEntities
+			others[i].Caption, others[i].CaptionEntities = "", nil
+		}
+	}
+	merged.MediaGroupID = MERGED_ALBUM_PREFIX + merged.MediaGroupID
+	return &merged, others
+}
+
+// messagePhotos are the photos to send to a vision model: the largest size of a photo, or all photos of a merged album
+func messagePhotos(message *telego.Message) []telego.PhotoSize {
+	if len(message.Photo) == 0 {
+		return nil
+	}
+	if strings.HasPrefix(message.MediaGroupID, MERGED_ALBUM_PREFIX) {
+		return message.Photo
+	}
+	return message.Photo[len(message.Photo)-1:]
+}
@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/lib"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/stretchr/testify/assert"
+)
+
+func albumPhoto(messageID int, fileID string, caption string) telego.Message {
+	return telego.Message{
+		MessageID:    messageID,
+		Chat:         telego.Chat{ID: 42},
+		MediaGroupID: "album",
+		Caption:      caption,
+		Photo: []telego.PhotoSize{
+			{FileID: fileID + "-small", Width: 90},
+			{FileID: fileID + "-large", Width: 1280},
+		},
+	}
+}
+
+func TestMergeAlbum(t *testing.T) {
+	video := telego.Message{MessageID: 4, Chat: telego.Chat{ID: 42}, MediaGroupID: "album", Video: &telego.Video{FileID: "video"}, Caption: "compare these"}
+	merged, others := mergeAlbum([]telego.Message{albumPhoto(1, "a", ""), albumPhoto(2, "b", ""), video, albumPhoto(3, "c", "")})
+
+	assert.Equal(t, 1, merged.MessageID)
+	assert.Equal(t, MERGED_ALBUM_PREFIX+"album", merged.MediaGroupID)
+	assert.Equal(t, "compare these", merged.Caption)
+	fileIDs := []string{}
+	for _, photo := range messagePhotos(merged) {
+		fileIDs = append(fileIDs, photo.FileID)
+	}
+	assert.Equal(t, []string{"a-large", "b-large", "c-large"}, fileIDs)
+
+	// the caption is answered once, with the photos
+	assert.Len(t, others, 1)
+	assert.Equal(t, "video", others[0].Video.FileID)
+	assert.Empty(t, others[0].Caption)
+
+	merged, others = mergeAlbum([]telego.Message{video})
+	assert.Nil(t, merged)
+	assert.Equal(t, "compare these", others[0].Caption)
+}
+
+func TestMessagePhotos(t *testing.T) {
+	message := albumPhoto(1, "a", "")
+	assert.Equal(t, []telego.PhotoSize{{FileID: "a-large", Width: 1280}}, messagePhotos(&message))
+	assert.Nil(t, messagePhotos(&telego.Message{Text: "hi"}))
+}
+
+func TestAlbumBuffer(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	var mutex sync.Mutex
+	flushed := [][]telego.Message{}
+	buffer := newAlbumBuffer(100*time.Millisecond, func(bot *telego.Bot, messages []telego.Message) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		flushed = append(flushed, messages)
+	})
+
+	buffer.add(nil, albumPhoto(2, "b", ""))
+	time.Sleep(60 * time.Millisecond)
+	buffer.add(nil, albumPhoto(1, "a", "caption"))
+	other := albumPhoto(5, "x", "")
+	other.Chat.ID = 43
+	buffer.add(nil, other)
+	time.Sleep(60 * time.Millisecond)
+
+	// the window restarts with every part
+	mutex.Lock()
+	assert.Empty(t, flushed)
+	mutex.Unlock()
+
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(flushed) == 2
+	}, time.Second, 10*time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, messages := range flushed {
+		if messages[0].Chat.ID == 42 {
+			assert.Equal(t, 1, messages[0].MessageID)
+			assert.Equal(t, 2, messages[1].MessageID)
+		} else {
+			assert.Len(t, messages, 1)
+		}
+	}
+
+	// parts are gone from Redis once flushed
+	parts, _ := redis.RedisClient.LLen(context.Background(), lib.AlbumKey("42", "album")).Result()
+	assert.Zero(t, parts)
+}
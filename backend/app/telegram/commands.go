@@ -8,6 +8,7 @@ import (
 	"talk2robots/m/v2/app/config"
 	"talk2robots/m/v2/app/db/mongo"
 	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/models"
 	"talk2robots/m/v2/app/payments"
@@ -24,21 +25,6 @@ type Command string
 
 var EMILY_BIRTHDAY = time.Date(2023, 5, 25, 0, 18, 0, 0, time.FixedZone("UTC+2", 3*60*60))
 var VASILISA_BIRTHDAY = time.Date(2007, 12, 13, 23, 45, 0, 0, time.FixedZone("UTC+3", 3*60*60))
-var ONBOARDING_TEXT = `I'm Gienji, a smart assistant which is available 24/7 to amplify your 🧠 intelligence and 💬 communication skills! I'm here to unlock your full potential!
-
-Here are some of the things I can do:
-- 🧠 /chatgpt - chat or answer any questions, respond with text messages
-- 🎙️ /voicegpt - full conversation experience, respond using voice messages
-- remember context in /chatgpt and /voicegpt modes (use /clear to clear current thread)
-- 🖼️ draw, just ask to picture anything (Example: 'create an image of a fish riding a bicycle')
-- /translate [language code or name] - translate messages to English or other language (Example: /translate es)
-- /grammar - correct grammar mode, will only correct last sent message
-- /teacher - correct and explain grammar and mistakes
-- /transcribe voice/audio/video messages only
-- /summarize text/voice/audio/video messages
-- /status - check usage limits, consumed tokens and audio transcription minutes. Usage limits for the assistant are reset every 1st of the month.
-
-Enjoy and let me know if any /support is needed!`
 
 const (
 	StartCommand              Command = "/start"
@@ -50,12 +36,27 @@ const (
 	TranscribeCommand         Command = "/transcribe"
 	SummarizeCommand          Command = "/summarize"
 	TranslateCommand          Command = "/translate"
+	InterpretCommand          Command = "/interpret"
+	DictateCommand            Command = "/dictate"
 	StatusCommand             Command = "/status"
 	SupportCommand            Command = "/support"
 	TermsCommand              Command = "/terms"
 	UpgradeCommand            Command = "/upgrade"
 	CancelSubscriptionCommand Command = "/downgrade"
 	BillingCommand            Command = "/billing"
+	GroupBufferCommand        Command = "/groupbuffer"
+	GroupSettingsCommand      Command = "/groupsettings"
+	MyMemoryCommand           Command = "/mymemory"
+	LanguageCommand           Command = "/language"
+	RemindCommand             Command = "/remind"
+	ScheduleCommand           Command = "/schedule"
+	RemindersCommand          Command = "/reminders"
+	VoiceCommand              Command = "/voice"
+	LinksCommand              Command = "/links"
+	ReactionsCommand          Command = "/reactions"
+	SearchCommand             Command = "/search"
+	EditCommand               Command = "/edit"
+	ImagesCommand             Command = "/images"
 	VasilisaCommand           Command = "/vasilisa"
 	EmiliCommand              Command = "/emily"
 	EmptyCommand              Command = ""
@@ -68,9 +69,24 @@ voicegpt - 🎙 talk to AI using voice messages (with memory)
 clear - 🧹 clear current conversation memory
 grammar - 👀 grammar checking mode only, no explanations
 teacher - 🧑‍🏫 grammar correction and explanations
-transcribe - 🎙 transcribe voice/audio/video
+transcribe - 🎙 transcribe voice/audio/video, add srt, vtt or timestamps for subtitles
 translate - 🌍 translate text to English or the specified language (Example: /translate es)
-summarize - 📝 summarize text/voice/audio/video
+interpret - 🗣 speak and get a voice translation, two languages translate both ways (Example: /interpret en es)
+dictate - ✍️ turn voice notes into an email, note, todo list or post, edit the draft by voice (Example: /dictate email)
+search - 🔎 search the web and answer with cited sources
+edit - 🖌 edit a photo by description, reply to a photo (Example: /edit make it a watercolor)
+images - 🖼 your drawn images, send them again or redraw
+summarize - 📝 summarize text/voice/audio/video (in groups: /summarize 200 or /summarize 6h to catch up)
+groupbuffer - 📥 keep recent group messages for /summarize (admins only)
+groupsettings - ⚙️ group policy: modes, engines, images, voice, member caps, triggers (admins only)
+mymemory - 🗒 your private notes the bot keeps in mind in groups, managed in the private chat
+language - 🌍 change the bot language (Example: /language es)
+remind - ⏰ set a reminder (Example: /remind tomorrow at 9 call mom)
+schedule - 🗓 run a prompt on schedule (Example: /schedule every monday at 9 news-style summary of AI news)
+voice - 🗣 voice, speed and style of voice replies (Example: /voice nova)
+links - 🔗 read shared links and answer with citations (Example: /links off)
+reactions - 🔊 emoji reactions that read aloud, translate, correct or summarize a message (Example: /reactions 🔥 summarize)
+reminders - 📋 list or cancel reminders, set your time zone (Example: /reminders timezone Europe/Berlin)
 status - 📊 status and settings
 billing - 💳 manage or cancel your subscription
 support - 🤔 contact developer for support
@@ -97,13 +113,27 @@ func setupCommandHandlers() {
 		newCommandHandler(StartCommand, startCommandHandler),
 		newCommandHandler(EmiliCommand, getModeHandlerFunction(lib.Emili, "היי, אעזור עם הטקסטים והודעות בעברית."+"\n\n"+fmt.Sprintf("אגב, אני בת %.f שעות, כלומר %.f ימים, %.f שבועות, %.1f חודשים או %.1f שנים", time.Since(EMILY_BIRTHDAY).Hours(), time.Since(EMILY_BIRTHDAY).Hours()/24, time.Since(EMILY_BIRTHDAY).Hours()/24/7, 12*(time.Since(EMILY_BIRTHDAY).Hours()/24/365), time.Since(EMILY_BIRTHDAY).Hours()/24/365))),
 		newCommandHandler(VasilisaCommand, getModeHandlerFunction(lib.Vasilisa, "Привет, я помогу тебе с текстами и сообщениями на русском языке 😊\n\n"+fmt.Sprintf("Кстати, мне %.f часов, то есть %.f дней или %.1f лет", time.Since(VASILISA_BIRTHDAY).Hours(), time.Since(VASILISA_BIRTHDAY).Hours()/24, time.Since(VASILISA_BIRTHDAY).Hours()/24/365))),
-		newCommandHandler(ChatGPTCommand, getModeHandlerFunction(lib.ChatGPT, "🚀 ChatGPT is now fully unleashed! Just tell me or ask me anything you want. I can now remember the context of our conversation. You can use /clear command anytime to wipe my memory and start a new thread.")),
-		newCommandHandler(GrammarCommand, getModeHandlerFunction(lib.Grammar, "Will only correct your grammar without any explainations. If you want to get explainations, use /teacher command.")),
-		newCommandHandler(TeacherCommand, getModeHandlerFunction(lib.Teacher, "Will correct your grammar and explain any mistakes found.")),
-		newCommandHandler(TranscribeCommand, getModeHandlerFunction(lib.Transcribe, "Will transcribe your voice/audio/video messages only.")),
-		newCommandHandler(SummarizeCommand, getModeHandlerFunction(lib.Summarize, "Will summarize your text/voice/audio/video messages.")),
-		newCommandHandler(VoiceGPTCommand, getModeHandlerFunction(lib.VoiceGPT, "🚀 now I'm like ChatGPT with memory and all, but will respond with voice messages. What do you want to talk about? Use /clear command anytime to wipe my memory and start a new thread.\n\nNote, that this mode is more expensive than regular /chatgpt mode.")),
-		newCommandHandler(TranslateCommand, getModeHandlerFunction(lib.Translate, "Will translate your messages to English.")),
+		newCommandHandler(ChatGPTCommand, getModeHandlerFunction(lib.ChatGPT, "mode.chatgpt")),
+		newCommandHandler(GrammarCommand, getModeHandlerFunction(lib.Grammar, "mode.grammar")),
+		newCommandHandler(TeacherCommand, getModeHandlerFunction(lib.Teacher, "mode.teacher")),
+		newCommandHandler(TranscribeCommand, transcribeCommandHandler),
+		newCommandHandler(SummarizeCommand, summarizeCommandHandler),
+		newCommandHandler(GroupBufferCommand, groupBufferCommandHandler),
+		newCommandHandler(GroupSettingsCommand, groupSettingsCommandHandler),
+		newCommandHandler(MyMemoryCommand, myMemoryCommandHandler),
+		newCommandHandler(LanguageCommand, languageCommandHandler),
+		newCommandHandler(RemindCommand, remindCommandHandler),
+		newCommandHandler(ScheduleCommand, scheduleCommandHandler),
+		newCommandHandler(RemindersCommand, remindersCommandHandler),
+		newCommandHandler(VoiceCommand, voiceCommandHandler),
+		newCommandHandler(LinksCommand, linksCommandHandler),
+		newCommandHandler(ReactionsCommand, reactionsCommandHandler),
+		newCommandHandler(ImagesCommand, galleryCommandHandler),
+		newCommandHandler(VoiceGPTCommand, getModeHandlerFunction(lib.VoiceGPT, "mode.voicegpt")),
+		newCommandHandler(TranslateCommand, getModeHandlerFunction(lib.Translate, "mode.translate")),
+		newCommandHandler(InterpretCommand, interpretCommandHandler),
+		newCommandHandler(DictateCommand, dictateCommandHandler),
+		newCommandHandler(SearchCommand, getModeHandlerFunction(lib.Search, "mode.search")),
 		newCommandHandler(StatusCommand, statusCommandHandler),
 		newCommandHandler(UpgradeCommand, upgradeCommandHandler),
 		newCommandHandler(CancelSubscriptionCommand, cancelSubscriptionCommandHandler),
@@ -145,12 +175,11 @@ func setupCommandHandlers() {
 			}
 
 			if user.StripeCustomerId == "" {
-				bot.SendMessage(context.Background(), tu.Message(chatID, "You don't have billing setup.").WithMessageThreadID(message.MessageThreadID))
+				bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "billing.not_setup")).WithMessageThreadID(message.MessageThreadID))
 				return
 			}
 
-			notification := `Tap 'Continue' button to manage or cancel your subscription, use the email you used for registering. If you don't remember which email you used, check your inboxes for Stripe messages or reach out to /support 🚀`
-			notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
+			notification := lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "billing.manage"))
 			stripePortalLink := "https://billing.stripe.com/p/login/bIYbMG468cuR9a06oo"
 
 			// send link to customer as a button in telegram
@@ -160,7 +189,7 @@ func setupCommandHandlers() {
 						InlineKeyboard: [][]telego.InlineKeyboardButton{
 							{
 								telego.InlineKeyboardButton{
-									Text: "Continue",
+									Text: i18n.T(ctx, "button.continue"),
 									URL:  stripePortalLink,
 								},
 							},
@@ -190,7 +219,7 @@ func (c CommandHandlers) handleCommand(ctx context.Context, bot *Bot, message *t
 		commandHandler.Handler(ctx, bot, message)
 	} else {
 		config.CONFIG.DataDogClient.Incr("unknown_command", nil, 1)
-		bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), "Unknown command \U0001f937").WithMessageThreadID(message.MessageThreadID))
+		bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), i18n.T(ctx, "unknown_command")).WithMessageThreadID(message.MessageThreadID))
 	}
 }
 
@@ -205,16 +234,20 @@ func (c CommandHandlers) getCommandHandler(command Command) *CommandHandler {
 
 func getModeHandlerFunction(mode lib.ModeName, response string) func(context.Context, *Bot, *telego.Message) {
 	return func(ctx context.Context, bot *Bot, message *telego.Message) {
+		if message.Chat.Type != telego.ChatTypePrivate && !lib.IsGroupModeAllowed(lib.GetGroupSettings(util.GetChatIDString(message)), mode) {
+			notification := lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "mode.disabled_in_group", i18n.Args{"mode": mode}))
+			bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), notification).WithMessageThreadID(message.MessageThreadID))
+			return
+		}
 		messageArray := strings.Split(message.Text, " ")
 		params := ""
 		if len(messageArray) > 1 {
 			params = validateParams(mode, messageArray[1])
 		}
-		if mode == lib.Translate {
-			response = strings.ReplaceAll(response, "English", getLanguageName(params))
-		}
-		response = lib.AddBotSuffixToGroupCommands(ctx, response)
-		bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), response).WithMessageThreadID(message.MessageThreadID))
+		// persona modes pass their own literal greetings, which the catalog returns as is
+		notification := i18n.T(ctx, response, i18n.Args{"language": getLanguageDisplayName(ctx, params)})
+		notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
+		bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), notification).WithMessageThreadID(message.MessageThreadID))
 		lib.SaveMode(util.GetChatIDString(message), util.GetTopicID(message), mode, params)
 	}
 }
@@ -255,7 +288,7 @@ func upgradeCommandHandler(ctx context.Context, bot *Bot, message *telego.Messag
 			return
 		}
 
-		notification := "Tap 'Continue' button to keep using me.\n\n" + ONBOARDING_TEXT
+		notification := i18n.T(ctx, "upgrade.continue") + "\n\n" + i18n.T(ctx, "onboarding")
 		notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
 
 		// send link to customer as a button in telegram
@@ -266,7 +299,7 @@ func upgradeCommandHandler(ctx context.Context, bot *Bot, message *telego.Messag
 					InlineKeyboard: [][]telego.InlineKeyboardButton{
 						{
 							telego.InlineKeyboardButton{
-								Text: "Continue",
+								Text: i18n.T(ctx, "button.continue"),
 								URL:  session.URL,
 							},
 						},
@@ -278,7 +311,7 @@ func upgradeCommandHandler(ctx context.Context, bot *Bot, message *telego.Messag
 		return
 	}
 	if lib.IsUserBasic(ctx) {
-		bot.SendMessage(context.Background(), tu.Message(chatID, "You are already a basic paid plan user! Premium upgrade plans are not available yet. Stay tuned for updates!").WithMessageThreadID(message.MessageThreadID))
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "upgrade.already_basic")).WithMessageThreadID(message.MessageThreadID))
 		return
 	}
 
@@ -290,7 +323,7 @@ func cancelSubscriptionCommandHandler(ctx context.Context, bot *Bot, message *te
 	chatID := util.GetChatID(message)
 	topicString := util.GetTopicID(message)
 	if lib.IsUserFree(ctx) {
-		bot.SendMessage(context.Background(), tu.Message(chatID, "You are already a free user!").WithMessageThreadID(message.MessageThreadID))
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "downgrade.already_free")).WithMessageThreadID(message.MessageThreadID))
 		return
 	}
 
@@ -298,12 +331,12 @@ func cancelSubscriptionCommandHandler(ctx context.Context, bot *Bot, message *te
 	callbackData := ""
 	if lib.IsUserFreePlus(ctx) {
 		// send confirmation message with yes/no buttons
-		confirmationMessage = "Are you sure you want to cancel your free+ plan?\n\nYou will be downgraded to the free plan immediately."
+		confirmationMessage = i18n.T(ctx, "downgrade.confirm_free_plus")
 		callbackData = "downgradefromfreeplus:" + topicString
 	}
 
 	if lib.IsUserBasic(ctx) {
-		confirmationMessage = "Are you sure you want to cancel your subscription to the basic plan?\n\nYou will be downgraded to the free+ plan immediately, loosing access to GPT-4 model and increased usage limits. Unused limits will NOT be refunded."
+		confirmationMessage = i18n.T(ctx, "downgrade.confirm_basic")
 		callbackData = "downgradefrombasic:" + topicString
 	}
 
@@ -319,11 +352,11 @@ func cancelSubscriptionCommandHandler(ctx context.Context, bot *Bot, message *te
 				{
 					telego.InlineKeyboardButton{
 						// whitecheckmark
-						Text:         "Yes",
+						Text:         i18n.T(ctx, "button.yes"),
 						CallbackData: callbackData,
 					},
 					telego.InlineKeyboardButton{
-						Text:         "No",
+						Text:         i18n.T(ctx, "button.no"),
 						CallbackData: "cancel:" + topicString,
 					},
 				},
@@ -332,7 +365,7 @@ func cancelSubscriptionCommandHandler(ctx context.Context, bot *Bot, message *te
 }
 
 func emptyCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
-	_, err := bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), "There is no message provided to correct or comment on. If you have a message you would like me to review, please provide it.").WithMessageThreadID(message.MessageThreadID))
+	_, err := bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), i18n.T(ctx, "empty_message")).WithMessageThreadID(message.MessageThreadID))
 	if err != nil {
 		log.Errorf("Failed to send EmptyCommand message: %v", err)
 	}
@@ -342,9 +375,9 @@ func supportCommandHandler(ctx context.Context, bot *Bot, message *telego.Messag
 	log.Infof("Support command received from userID: %s", util.GetChatIDString(message))
 	supportMessage := tu.MessageWithEntities(
 		util.GetChatID(message),
-		tu.Entity("If you have any questions, please contact us at "),
+		tu.Entity(i18n.T(ctx, "support.contact")),
 		tu.Entity("free+support@radiant.space").Email(),
-		tu.Entityf(", explaining the problem and mentioning userID: %s.", util.GetChatIDString(message)),
+		tu.Entity(i18n.T(ctx, "support.mention_user", i18n.Args{"user": util.GetChatIDString(message)})),
 	).WithMessageThreadID(message.MessageThreadID)
 	_, err := bot.SendMessage(context.Background(), supportMessage)
 	if err != nil {
@@ -364,11 +397,15 @@ func clearThreadCommandHandler(ctx context.Context, bot *Bot, message *telego.Me
 	chatIDString := util.GetChatIDString(message)
 	topicIDString := util.GetTopicID(message)
 
-	_, err := bot.SendMessage(context.Background(), tu.Message(chatID, "All memory cleared!").WithMessageThreadID(message.MessageThreadID))
+	_, err := bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "memory_cleared")).WithMessageThreadID(message.MessageThreadID))
 	if err != nil {
 		log.Errorf("Failed to send ClearThreadCommand message: %v", err)
 	}
 
+	// forget the last interaction, so follow-ups don't build on a drawn image, and the /dictate draft
+	redis.RedisClient.Del(ctx, lib.UserLastInteractionKey(chatIDString, fmt.Sprintf("%d", message.MessageThreadID)))
+	redis.RedisClient.Del(ctx, lib.UserDictationDraftKey(chatIDString, topicIDString))
+
 	// clear local thread
 	go func() {
 		err := mongo.MongoDBClient.DeleteUserThread(context.WithValue(context.Background(), models.UserContext{}, chatIDString))
@@ -399,7 +436,7 @@ func validateParams(mode lib.ModeName, params string) string {
 		return ""
 	}
 
-	if mode == lib.VoiceGPT || mode == lib.Transcribe || mode == lib.Grammar || mode == lib.ChatGPT || mode == lib.Summarize || mode == lib.Translate {
+	if mode == lib.VoiceGPT || mode == lib.Transcribe || mode == lib.Grammar || mode == lib.ChatGPT || mode == lib.Summarize || mode == lib.Translate || mode == lib.Interpret {
 		// params expected to be language code for now
 		params = strings.ToLower(params)
 
@@ -817,11 +854,9 @@ func getLanguageName(languageCode string) string {
 	}
 }
 
-func IsCreateImageCommand(prompt string) bool {
-	// should return true if the prompt is a create image command, i.e. first couple of words are "create/draw/picture/imagine/image"
-	// and false otherwise
-
-	// Normalize the prompt by converting to lowercase and removing punctuation
+// IsImageEditIntent should return true if a reply to a photo asks to change it, i.e. "make it a watercolor" or "remove the background",
+// and false for questions about the photo
+func IsImageEditIntent(prompt string) bool {
 	cleanPrompt := strings.Map(func(r rune) rune {
 		if unicode.IsPunct(r) {
 			return -1
@@ -829,30 +864,23 @@ func IsCreateImageCommand(prompt string) bool {
 		return r
 	}, strings.ToLower(prompt))
 
-	log.Debugf("Cleaned prompt: %s", cleanPrompt)
-
-	triggerWords := []string{"draw", "drawing", "picture", "imagine", "image", "paint", "painting", "sketch", "sketching", "illustration", "illustrate", "art", "design"}
-	stopWords := []string{"write", "article", "dont", "work", "working", "job", "jobs", "task", "tasks", "assignment", "assignments", "homework", "homeworks", "essay", "essays", "report", "reports", "paper", "papers", "document", "documents", "text", "message", "letter", "email", "conversation", "speak", "speech"}
+	triggerWords := []string{"make", "turn", "change", "remove", "replace", "add", "convert", "edit", "erase", "recolor", "colorize", "restyle", "redraw", "transform", "swap", "stylize", "retouch"}
+	stopWords := []string{"what", "whats", "why", "who", "how", "which", "where", "when", "describe", "explain", "tell", "read", "translate", "list", "text", "caption", "summary", "summarize", "count", "identify", "does", "is", "are"}
 
-	// Split the prompt into words
 	words := strings.Fields(cleanPrompt)
 	foundTrigger := false
-	foundStop := false
 	for i, word := range words {
 		if i > 5 {
-			return foundTrigger && !foundStop
+			break
+		}
+		if contains(stopWords, word) {
+			return false
 		}
-
 		if contains(triggerWords, word) {
 			foundTrigger = true
 		}
-
-		if contains(stopWords, word) {
-			foundStop = true
-		}
 	}
-
-	return foundTrigger && !foundStop
+	return foundTrigger
 }
 
 func contains(arr []string, word string) bool {
@@ -873,7 +901,7 @@ func startCommandHandler(ctx context.Context, bot *Bot, message *telego.Message)
 			params = ""
 			// ignore params longer than 64 characters
 			// https://core.telegram.org/api/links#bot-links
-		} else {
+		} else if !strings.HasPrefix(params, MEMBER_MEMORY_START_PREFIX) {
 			// base64 decode params
 			decoded := util.Base64Decode(params)
 			if decoded != "" {
@@ -881,6 +909,13 @@ func startCommandHandler(ctx context.Context, bot *Bot, message *telego.Message)
 			}
 		}
 	}
+	// links from /mymemory in groups open private notes of the group
+	if groupIDString, ok := strings.CutPrefix(params, MEMBER_MEMORY_START_PREFIX); ok && message.Chat.Type == telego.ChatTypePrivate && message.From != nil {
+		config.CONFIG.DataDogClient.Incr("start_command", []string{"source:mymemory"}, 1)
+		openMemberMemory(ctx, bot, message, groupIDString)
+		return
+	}
+
 	ddParams := params
 	if ddParams == "" {
 		ddParams = "empty"
@@ -930,7 +965,7 @@ func startCommandHandler(ctx context.Context, bot *Bot, message *telego.Message)
 		sendGeneralOnboardingVideo(ctx, bot, message)
 	}
 
-	notification := lib.AddBotSuffixToGroupCommands(ctx, ONBOARDING_TEXT)
+	notification := lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "onboarding"))
 	chatId := util.GetChatID(message)
 	_, err := bot.SendMessage(context.Background(), tu.Message(chatId, notification).WithMessageThreadID(message.MessageThreadID).WithReplyMarkup(GetStatusKeyboard(ctx)))
 	if err != nil {
@@ -4,47 +4,29 @@ import (
 	"testing"
 )
 
-// test func IsCreateImageCommand(prompt string) bool {
-// prompts := []string{
-// 	"Can you create an image of a sunset?",
-// 	"Draw, please, an image of a cat",
-// 	"I'd like a picture! Of a mountain",
-// 	"Imagine this image: a futuristic city",
-// 	"Cam you creete an imege of a sunset?",
-// }
-
-func TestIsCreateImageCommandTrue(t *testing.T) {
-	prompts := []string{
-		"Can you create a drawing of a sunset?",
-		"Can you create an image of a sunset?",
-		"Draw, please, an image of a cat",
-		"I'd like a picture! Of a mountain",
-		"Imagine this image: a futuristic city",
-		"Cam you creete an image of a sunset?",
-		"Sketch a picture of a cat",
+func TestIsImageEditIntent(t *testing.T) {
+	edits := []string{
+		"Make it look like a watercolor",
+		"remove the background, please",
+		"can you turn this into a cartoon?",
+		"Add a hat to the cat",
 	}
-
-	for _, prompt := range prompts {
-		if !IsCreateImageCommand(prompt) {
-			t.Errorf("IsCreateImageCommand(%s) = false; want true", prompt)
+	for _, prompt := range edits {
+		if !IsImageEditIntent(prompt) {
+			t.Errorf("IsImageEditIntent(%s) = false; want true", prompt)
 		}
 	}
-}
 
-func TestIsCreateImageCommandFalse(t *testing.T) {
-	prompts := []string{
-		"Imagene, please, an article about a cat",
-		"I'd like a video! Of a mountain",
-		"Don't you think this image is beautiful?",
-		"Can we talk about what you think about this image?",
-		`🗣:
-		Перечисли источники, из которых ты взял информацию.
-		`,
+	questions := []string{
+		"What's in this photo?",
+		"how to remove the background",
+		"make a list of the items here",
+		"does it make sense?",
+		"nice photo",
 	}
-
-	for _, prompt := range prompts {
-		if IsCreateImageCommand(prompt) {
-			t.Errorf("IsCreateImageCommand(%s) = true; want false", prompt)
+	for _, prompt := range questions {
+		if IsImageEditIntent(prompt) {
+			t.Errorf("IsImageEditIntent(%s) = true; want false", prompt)
 		}
 	}
 }
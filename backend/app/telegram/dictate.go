@@ -0,0 +1,219 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// DICTATE_CALLBACK_PREFIX marks draft keyboard callbacks: dict.new starts over with a new draft
+const DICTATE_CALLBACK_PREFIX = "dict."
+
+const (
+	DICTATE_MODEL          = models.ChatGpt4oMini
+	MAX_DICTATE_TOKENS     = 3000
+	DICTATE_DRAFT_TTL      = 7 * 24 * time.Hour
+	DEFAULT_DICTATE_FORMAT = "note"
+)
+
+// dictateFormats describe what a draft of each format looks like
+var dictateFormats = map[string]string{
+	"email": "an email: a subject line, a greeting, short paragraphs and a sign-off",
+	"note":  "a note: a short title, short paragraphs and bullet points where they help, decisions and action items at the end if there are any",
+	"todo":  "a to-do list: short actionable items as a Markdown checklist (- [ ] item), grouped under short headings if there are several topics, with dates and owners when mentioned",
+	"post":  "a social media post: a hook in the first line, short paragraphs and a few hashtags at most",
+}
+
+const DICTATE_INSTRUCTIONS = `You turn dictated voice notes into clean drafts. The transcript is spoken language: drop filler words, false starts and repetitions, fix grammar, keep every fact, name, number and date.
+Write %s.
+Write in the language the speaker uses, whatever the language of these instructions. Reply with the draft only, without comments.`
+
+const DICTATE_EDIT_INSTRUCTIONS = `You edit a draft the user dictated earlier, it is %s.
+The user's message is either a change request (e.g. "make it more formal", "remove the second point") or more dictated content to add. Apply it and reply with the whole updated draft only, without comments.
+Keep the language of the draft unless the user asks to translate it.`
+
+type dictationDraft struct {
+	Format string `json:"format"`
+	Text   string `json:"text"`
+	Edits  int    `json:"edits"`
+}
+
+// dictateCommandHandler switches to dictation: /dictate email turns voice notes into an email draft, a new draft is started
+func dictateCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	chatIDString := util.GetChatIDString(message)
+	reply := func(text string) {
+		text = lib.AddBotSuffixToGroupCommands(ctx, text)
+		bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), text).WithMessageThreadID(message.MessageThreadID))
+	}
+	if message.Chat.Type != telego.ChatTypePrivate && !lib.IsGroupModeAllowed(lib.GetGroupSettings(chatIDString), lib.Dictate) {
+		reply(i18n.T(ctx, "mode.disabled_in_group", i18n.Args{"mode": lib.Dictate}))
+		return
+	}
+
+	format := DEFAULT_DICTATE_FORMAT
+	messageArray := strings.Fields(message.Text)
+	if len(messageArray) > 1 {
+		format = strings.ToLower(messageArray[1])
+	}
+	if _, ok := dictateFormats[format]; !ok || len(messageArray) > 2 {
+		reply(i18n.T(ctx, "dictate.usage"))
+		return
+	}
+
+	redis.RedisClient.Del(ctx, lib.UserDictationDraftKey(chatIDString, util.GetTopicID(message)))
+	lib.SaveMode(chatIDString, util.GetTopicID(message), lib.Dictate, format)
+	reply(i18n.T(ctx, "mode.dictate", i18n.Args{"format": format}))
+}
+
+// dictateMessage writes a draft from a transcript (or a text), while a draft of the format is kept, messages edit it
+func dictateMessage(ctx context.Context, bot *telego.Bot, message *telego.Message, format string) {
+	chatIDString := util.GetChatIDString(message)
+	topicID := util.GetTopicID(message)
+	text := strings.TrimSpace(message.Text)
+	if text == "" {
+		return
+	}
+	if _, ok := dictateFormats[format]; !ok {
+		format = DEFAULT_DICTATE_FORMAT
+	}
+	sendTypingAction(bot, message)
+
+	draft := getDictationDraft(chatIDString, topicID)
+	action := "edit"
+	if draft == nil || draft.Format != format {
+		draft = &dictationDraft{Format: format}
+		action = "new"
+	}
+	updated, err := getDictationText(ctx, draft, text)
+	if err != nil {
+		log.Errorf("Failed to write dictation draft in chat %s: %v", chatIDString, err)
+		bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
+		return
+	}
+	if action == "edit" {
+		draft.Edits++
+	}
+	draft.Text = updated
+	saveDictationDraft(chatIDString, topicID, draft)
+	config.CONFIG.DataDogClient.Incr("telegram.dictation", []string{"format:" + format, "action:" + action, "channel_type:" + message.Chat.Type}, 1)
+
+	sendDictationDraft(ctx, bot, message, draft)
+}
+
+func getDictationText(ctx context.Context, draft *dictationDraft, text string) (string, error) {
+	messages := []models.Message{
+		{Role: "system", Content: fmt.Sprintf(DICTATE_INSTRUCTIONS, dictateFormats[draft.Format])},
+		{Role: "user", Content: text},
+	}
+	if draft.Text != "" {
+		messages = []models.Message{
+			{Role: "system", Content: fmt.Sprintf(DICTATE_EDIT_INSTRUCTIONS, dictateFormats[draft.Format])},
+			{Role: "assistant", Content: draft.Text},
+			{Role: "user", Content: text},
+		}
+	}
+	updated, err := BOT.API.ChatComplete(ctx, models.ChatCompletion{
+		Model:     string(DICTATE_MODEL),
+		Messages:  messages,
+		MaxTokens: MAX_DICTATE_TOKENS,
+	})
+	if err != nil {
+		return "", err
+	}
+	updated = strings.TrimSpace(updated)
+	if updated == "" {
+		return "", fmt.Errorf("empty draft")
+	}
+	return updated, nil
+}
+
+// sendDictationDraft sends the draft in chunks up to Telegram limit, the last one has the new draft button
+func sendDictationDraft(ctx context.Context, bot *telego.Bot, message *telego.Message, draft *dictationDraft) {
+	chatID := util.GetChatID(message)
+	keyboard := tu.InlineKeyboard(tu.InlineKeyboardRow(
+		tu.InlineKeyboardButton(i18n.T(ctx, "dictate.button_new")).WithCallbackData(DICTATE_CALLBACK_PREFIX + "new:" + strconv.Itoa(message.MessageThreadID)),
+	))
+	chunks := util.ChunkMarkdownToTelegramHTML(draft.Text, util.TELEGRAM_MESSAGE_LIMIT)
+	for i, chunk := range chunks {
+		params := tu.Message(chatID, chunk.HTML).WithParseMode("HTML").WithMessageThreadID(message.MessageThreadID)
+		if i == len(chunks)-1 {
+			params = params.WithReplyMarkup(keyboard)
+		}
+		sentMessage, err := bot.SendMessage(context.Background(), params)
+		if err != nil && strings.Contains(err.Error(), "can't parse entities") {
+			params.Text = chunk.Markdown
+			params.ParseMode = ""
+			sentMessage, err = bot.SendMessage(context.Background(), params)
+		}
+		if err != nil {
+			log.Errorf("Failed to send dictation draft in chat %s: %v", util.GetChatIDString(message), err)
+			return
+		}
+		cacheMessageText(message, sentMessage.MessageID, chunk.Markdown)
+	}
+}
+
+func handleDictateCallbackQuery(callbackQuery telego.CallbackQuery, topicString string) {
+	chat := callbackQuery.Message.GetChat()
+	chatIDString := fmt.Sprint(chat.ID)
+	_, ctx, cancelContext, err := lib.SetupUserAndContext(chatIDString, lib.TelegramClientName, chatIDString, topicString)
+	if err != nil {
+		log.Errorf("handleDictateCallbackQuery: failed to setup user %s: %v", chatIDString, err)
+		return
+	}
+	defer cancelContext()
+	if strings.TrimPrefix(callbackQuery.Data, DICTATE_CALLBACK_PREFIX) != "new" {
+		log.Errorf("handleDictateCallbackQuery: invalid callback %s in chat %s", callbackQuery.Data, chatIDString)
+		return
+	}
+
+	redis.RedisClient.Del(ctx, lib.UserDictationDraftKey(chatIDString, topicString))
+	config.CONFIG.DataDogClient.Incr("telegram.dictation", []string{"action:reset"}, 1)
+	BOT.EditMessageReplyMarkup(ctx, &telego.EditMessageReplyMarkupParams{
+		ChatID:    chat.ChatID(),
+		MessageID: callbackQuery.Message.GetMessageID(),
+	})
+	BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
+		CallbackQueryID: callbackQuery.ID,
+		Text:            i18n.T(ctx, "dictate.new_draft"),
+	})
+}
+
+func getDictationDraft(chatIDString string, topicID string) *dictationDraft {
+	draftJson, err := redis.RedisClient.Get(context.Background(), lib.UserDictationDraftKey(chatIDString, topicID)).Result()
+	if err != nil || draftJson == "" {
+		return nil
+	}
+	var draft dictationDraft
+	err = json.Unmarshal([]byte(draftJson), &draft)
+	if err != nil {
+		log.Errorf("[dictate] failed to unmarshal draft in chat %s: %v", chatIDString, err)
+		return nil
+	}
+	return &draft
+}
+
+func saveDictationDraft(chatIDString string, topicID string, draft *dictationDraft) {
+	draftBytes, err := json.Marshal(draft)
+	if err != nil {
+		log.Errorf("[dictate] failed to marshal draft in chat %s: %v", chatIDString, err)
+		return
+	}
+	err = redis.RedisClient.Set(context.Background(), lib.UserDictationDraftKey(chatIDString, topicID), string(draftBytes), DICTATE_DRAFT_TTL).Err()
+	if err != nil {
+		log.Errorf("[dictate] failed to save draft in chat %s: %v", chatIDString, err)
+	}
+}
@@ -0,0 +1,34 @@
+package telegram
+
+import (
+	"talk2robots/m/v2/app/lib"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDictationDraft(t *testing.T) {
+	assert.Nil(t, getDictationDraft("4901", "0"))
+
+	saveDictationDraft("4901", "0", &dictationDraft{Format: "todo", Text: "- [ ] buy milk"})
+	draft := getDictationDraft("4901", "0")
+	assert.NotNil(t, draft)
+	assert.Equal(t, "todo", draft.Format)
+	assert.Equal(t, "- [ ] buy milk", draft.Text)
+	assert.Nil(t, getDictationDraft("4901", "7"), "topics keep their own drafts")
+}
+
+func TestDictateFormats(t *testing.T) {
+	for _, format := range []string{"email", "note", "todo", "post"} {
+		assert.NotEmpty(t, dictateFormats[format], format)
+	}
+	assert.NotEmpty(t, dictateFormats[DEFAULT_DICTATE_FORMAT])
+}
+
+func TestDictateTranscriptionLanguage(t *testing.T) {
+	// dictate params are a format, not the language of the speech
+	for format := range dictateFormats {
+		request := transcriptionRequest(t, lib.Dictate, format, lib.TranscriptOptions{})
+		assert.NotContains(t, request.MultipartForm.Value, "language", format)
+	}
+}
@@ -0,0 +1,96 @@
+package telegram
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/documents"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/google/uuid"
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// DOCUMENT_PROMPT is used when a document is sent without a question
+const DOCUMENT_PROMPT = "Briefly summarize this document and offer to answer questions about it."
+
+// DOCUMENT_INSTRUCTIONS are added to every document turn, so citations are kept in follow-ups too
+const DOCUMENT_INSTRUCTIONS = "The user attached the document below. Answer from it and cite pages like (p. 3) when page markers are present."
+
+// DOCUMENT_MODES read document text, the rest (grammar, teacher etc) ask to switch the mode instead
+var DOCUMENT_MODES = map[lib.ModeName]bool{
+	lib.ChatGPT:    true,
+	lib.VoiceGPT:   true,
+	lib.Summarize:  true,
+	lib.Translate:  true,
+	lib.Search:     true,
+	lib.Transcribe: true,
+}
+
+// getDocument downloads and extracts text of a PDF, DOCX, text or source file, failures are reported to the user
+func getDocument(ctx context.Context, bot *telego.Bot, message *telego.Message) (documents.Document, bool) {
+	chatID := util.GetChatID(message)
+	chatIDString := util.GetChatIDString(message)
+	reply := func(text string) {
+		bot.SendMessage(context.Background(), tu.Message(chatID, text).WithMessageThreadID(message.MessageThreadID))
+	}
+
+	name := message.Document.FileName
+	kind := documents.DetectKind(message.Document.MimeType, name)
+	config.CONFIG.DataDogClient.Incr("telegram.document_message_received", []string{"kind:" + string(kind), "channel_type:" + message.Chat.Type}, 1)
+	if kind == documents.KindUnsupported {
+		log.Infof("Unsupported document %s (%s) in chat %s", name, message.Document.MimeType, chatIDString)
+		reply(i18n.T(ctx, "documents.unsupported"))
+		return documents.Document{}, false
+	}
+
+	fileData, err := bot.GetFile(context.Background(), &telego.GetFileParams{FileID: message.Document.FileID})
+	if err != nil {
+		log.Errorf("Failed to get document %s in chat %s: %v", name, chatIDString, err)
+		if strings.Contains(err.Error(), "file is too big") {
+			reply(i18n.T(ctx, "documents.too_big"))
+			return documents.Document{}, false
+		}
+		reply(i18n.T(ctx, "documents.download_failed"))
+		return documents.Document{}, false
+	}
+
+	fileName := "/data/" + uuid.New().String() + strings.ToLower(filepath.Ext(name))
+	defer util.SafeOsDelete(fileName)
+	err = downloadTelegramFile(bot, fileData.FilePath, fileName)
+	if err != nil {
+		log.Errorf("Failed to download document %s in chat %s: %v", name, chatIDString, err)
+		reply(i18n.T(ctx, "documents.download_failed"))
+		return documents.Document{}, false
+	}
+
+	document, err := documents.Extract(fileName, name, kind)
+	if err != nil {
+		log.Warnf("Failed to extract text of %s (%s) in chat %s: %v", name, kind, chatIDString, err)
+		config.CONFIG.DataDogClient.Incr("telegram.document_extract_failed", []string{"kind:" + string(kind)}, 1)
+		reply(i18n.T(ctx, "documents.no_text"))
+		return documents.Document{}, false
+	}
+	log.Infof("Extracted %d pages of %s (%s) in chat %s", len(document.Pages), name, kind, chatIDString)
+	return document, true
+}
+
+// withDocumentText puts the chunked document after the user's question, so it's kept in the thread for follow-ups
+func withDocumentText(ctx context.Context, bot *telego.Bot, message *telego.Message, document documents.Document) {
+	chunks, truncated := documents.Chunks(document, documents.CHUNK_SIZE, documents.MAX_DOCUMENT_SIZE)
+	if truncated {
+		bot.SendMessage(context.Background(), tu.Message(message.Chat.ChatID(), i18n.T(ctx, "documents.truncated", i18n.Args{"parts": len(chunks)})).WithMessageThreadID(message.MessageThreadID))
+	}
+	config.CONFIG.DataDogClient.Count("telegram.document_chunks", int64(len(chunks)), []string{"kind:" + string(document.Kind)}, 1)
+
+	prompt := strings.TrimSpace(message.Text + "\n" + message.Caption)
+	if prompt == "" {
+		prompt = DOCUMENT_PROMPT
+	}
+	message.Text = prompt + "\n\n" + DOCUMENT_INSTRUCTIONS + "\n" + documents.Render(document.Name, chunks, truncated)
+}
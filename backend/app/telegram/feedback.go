@@ -0,0 +1,220 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FEEDBACK_INTERACTION_TTL = 24 * time.Hour
+	FEEDBACK_COMMENT_TTL     = 30 * time.Minute
+)
+
+// lastInteraction is the latest prompt and answer in a chat/topic, kept in redis to give feedback some context,
+// once answered it's also kept under each answer message, so a rating of any of them finds its own prompt
+type lastInteraction struct {
+	Prompt          string `json:"prompt"`
+	PromptMessageId int    `json:"prompt_message_id,omitempty"`
+	Answer          string `json:"answer"`
+	Mode            string `json:"mode"`
+	Engine          string `json:"engine"`
+
+	// RequestId ties the interaction to the usage billed for it
+	RequestId string `json:"request_id,omitempty"`
+
+	// ImagePrompt is set when the interaction drew an image, follow-ups like "now make it blue" build on it
+	ImagePrompt string `json:"image_prompt,omitempty"`
+}
+
+func getLastInteraction(chatIDString string, topicID string) *lastInteraction {
+	return getInteraction(chatIDString, lib.UserLastInteractionKey(chatIDString, topicID))
+}
+
+func saveLastInteraction(chatIDString string, topicID string, interaction *lastInteraction) {
+	saveInteraction(chatIDString, lib.UserLastInteractionKey(chatIDString, topicID), interaction)
+}
+
+func getInteraction(chatIDString string, key string) *lastInteraction {
+	interactionJson, err := redis.RedisClient.Get(context.Background(), key).Result()
+	if err != nil || interactionJson == "" {
+		return nil
+	}
+	var interaction lastInteraction
+	err = json.Unmarshal([]byte(interactionJson), &interaction)
+	if err != nil {
+		log.Errorf("[feedback] failed to unmarshal interaction in chat %s: %v", chatIDString, err)
+		return nil
+	}
+	return &interaction
+}
+
+func saveInteraction(chatIDString string, key string, interaction *lastInteraction) {
+	interactionBytes, err := json.Marshal(interaction)
+	if err != nil {
+		log.Errorf("[feedback] failed to marshal interaction in chat %s: %v", chatIDString, err)
+		return
+	}
+	err = redis.RedisClient.Set(context.Background(), key, string(interactionBytes), FEEDBACK_INTERACTION_TTL).Err()
+	if err != nil {
+		log.Errorf("[feedback] failed to save interaction in chat %s: %v", chatIDString, err)
+	}
+}
+
+// rememberPrompt starts a new interaction, the answer is filled in by rememberAnswer once it's sent
+func rememberPrompt(ctx context.Context, message *telego.Message, mode lib.ModeName, engineModel models.Engine) {
+	requestId, _ := ctx.Value(models.RequestContext{}).(string)
+	saveLastInteraction(util.GetChatIDString(message), fmt.Sprintf("%d", message.MessageThreadID), &lastInteraction{
+		Prompt:          message.Text,
+		PromptMessageId: message.MessageID,
+		Mode:            string(mode),
+		Engine:          string(engineModel),
+		RequestId:       requestId,
+	})
+}
+
+// rememberAnswer completes the interaction started by the user message and keeps it under every message the answer was sent in
+func rememberAnswer(message *telego.Message, answerMessageIds []int, answer string) {
+	chatIDString := util.GetChatIDString(message)
+	topicID := fmt.Sprintf("%d", message.MessageThreadID)
+	interaction := getLastInteraction(chatIDString, topicID)
+	if interaction == nil || interaction.PromptMessageId != message.MessageID {
+		// another prompt in the same topic took over, don't mix them up
+		interaction = &lastInteraction{Prompt: message.Text, PromptMessageId: message.MessageID}
+	}
+	interaction.Answer = answer
+	saveLastInteraction(chatIDString, topicID, interaction)
+	for _, answerMessageId := range answerMessageIds {
+		saveInteraction(chatIDString, lib.AnswerInteractionKey(chatIDString, answerMessageId), interaction)
+	}
+}
+
+func newFeedback(chatIDString string, topicID string, messageId int, answer string) *models.MongoFeedback {
+	feedback := &models.MongoFeedback{
+		UserId:    chatIDString,
+		Client:    string(lib.TelegramClientName),
+		TopicId:   topicID,
+		MessageId: messageId,
+		Answer:    answer,
+	}
+
+	// ratings may land on any message, only answers remembered under that message get their prompt
+	interaction := getInteraction(chatIDString, lib.AnswerInteractionKey(chatIDString, messageId))
+	if interaction != nil {
+		feedback.Prompt = interaction.Prompt
+		feedback.Mode = interaction.Mode
+		feedback.Engine = interaction.Engine
+		if feedback.Answer == "" {
+			feedback.Answer = interaction.Answer
+		}
+		if interaction.RequestId != "" {
+			cost, err := redis.RedisClient.Get(context.Background(), lib.RequestCostKey(interaction.RequestId)).Float64()
+			if err == nil {
+				feedback.Cost = cost
+			}
+		}
+	}
+	return feedback
+}
+
+func saveFeedback(chatIDString string, feedback *models.MongoFeedback) {
+	ctx := context.WithValue(context.Background(), models.UserContext{}, chatIDString)
+	err := mongo.MongoDBClient.SaveFeedback(ctx, feedback)
+	if err != nil {
+		log.Errorf("[feedback] failed to save %s feedback in chat %s: %v", feedback.Kind, chatIDString, err)
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.feedback_saved", []string{"kind:" + feedback.Kind, "mood:" + feedback.Mood, "channel_type:" + feedback.ChatType}, 1)
+}
+
+// saveCallbackFeedback stores like/dislike button presses
+func saveCallbackFeedback(callbackQuery telego.CallbackQuery, topicString string) {
+	chat := callbackQuery.Message.GetChat()
+	chatIDString := fmt.Sprintf("%d", chat.ID)
+	answer := ""
+	if message, ok := callbackQuery.Message.(*telego.Message); ok {
+		answer = message.Text
+		if answer == "" {
+			answer = message.Caption
+		}
+	}
+
+	feedback := newFeedback(chatIDString, topicString, callbackQuery.Message.GetMessageID(), answer)
+	feedback.FromId = callbackQuery.From.ID
+	feedback.ChatType = chat.Type
+	feedback.Kind = callbackQuery.Data
+	feedback.Mood = "positive"
+	if callbackQuery.Data == "dislike" {
+		feedback.Mood = "negative"
+	}
+	saveFeedback(chatIDString, feedback)
+}
+
+// saveReactionFeedback stores emoji reactions on messages
+func saveReactionFeedback(reaction *telego.MessageReactionUpdated, reactionString string, mood string) {
+	chatIDString := fmt.Sprintf("%d", reaction.Chat.ID)
+	feedback := newFeedback(chatIDString, "", reaction.MessageID, "")
+	if reaction.User != nil {
+		feedback.FromId = reaction.User.ID
+	}
+	feedback.ChatType = reaction.Chat.Type
+	feedback.Kind = "reaction"
+	feedback.Reaction = reactionString
+	feedback.Mood = mood
+	saveFeedback(chatIDString, feedback)
+}
+
+// askWhatWasWrong sends an optional follow up question, the reply is stored as a comment to the dislike
+func askWhatWasWrong(bot *telego.Bot, chat telego.Chat, topicID int, dislikedMessageId int, language string) {
+	chatIDString := fmt.Sprintf("%d", chat.ID)
+	question, err := bot.SendMessage(context.Background(), tu.Message(chat.ChatID(), i18n.Translate(language, "feedback.question")).
+		WithMessageThreadID(topicID).
+		WithReplyMarkup(tu.ForceReply().WithSelective().WithInputFieldPlaceholder(i18n.Translate(language, "feedback.placeholder"))))
+	if err != nil {
+		log.Errorf("[feedback] failed to ask what was wrong in chat %s: %v", chatIDString, err)
+		return
+	}
+	err = redis.RedisClient.Set(context.Background(), lib.FeedbackCommentKey(chatIDString, question.MessageID), dislikedMessageId, FEEDBACK_COMMENT_TTL).Err()
+	if err != nil {
+		log.Errorf("[feedback] failed to save feedback question in chat %s: %v", chatIDString, err)
+	}
+}
+
+// handleFeedbackComment returns true if the message was a reply to the "what was wrong?" question
+func handleFeedbackComment(ctx context.Context, bot *telego.Bot, message *telego.Message) bool {
+	if message.ReplyToMessage == nil || message.Text == "" || strings.HasPrefix(message.Text, "/") {
+		return false
+	}
+	chatIDString := util.GetChatIDString(message)
+	commentKey := lib.FeedbackCommentKey(chatIDString, message.ReplyToMessage.MessageID)
+	dislikedMessageId, err := redis.RedisClient.Get(ctx, commentKey).Int()
+	if err != nil {
+		return false
+	}
+
+	err = mongo.MongoDBClient.UpdateFeedbackComment(ctx, dislikedMessageId, message.Text)
+	if err != nil {
+		log.Errorf("[feedback] failed to save feedback comment in chat %s: %v", chatIDString, err)
+	}
+	redis.RedisClient.Del(ctx, commentKey)
+	config.CONFIG.DataDogClient.Incr("telegram.feedback_comment", []string{"channel_type:" + message.Chat.Type}, 1)
+
+	_, err = bot.SendMessage(context.Background(), tu.Message(message.Chat.ChatID(), i18n.T(ctx, "feedback.comment_saved")).WithMessageThreadID(message.MessageThreadID))
+	if err != nil {
+		log.Errorf("[feedback] failed to thank for feedback comment in chat %s: %v", chatIDString, err)
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package telegram
+
+import (
+	"context"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/mymmrac/telego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedbackRatesOlderAnswer(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	chat := telego.Chat{ID: 4701, Type: telego.ChatTypeSupergroup}
+
+	first := &telego.Message{MessageID: 10, MessageThreadID: 7, Chat: chat, Text: "what is a cat?"}
+	rememberPrompt(context.WithValue(context.Background(), models.RequestContext{}, "request-1"), first, lib.ChatGPT, models.ChatGpt4oMini)
+	rememberAnswer(first, []int{11, 12}, "A cat is...")
+	redis.RedisClient.Set(context.Background(), lib.RequestCostKey("request-1"), 0.25, 0)
+
+	second := &telego.Message{MessageID: 13, MessageThreadID: 7, Chat: chat, Text: "and a dog?"}
+	rememberPrompt(context.WithValue(context.Background(), models.RequestContext{}, "request-2"), second, lib.ChatGPT, models.ChatGpt4oMini)
+	rememberAnswer(second, []int{14}, "A dog is...")
+
+	// the second part of the first answer is rated after the second answer was sent
+	feedback := newFeedback("4701", "7", 12, "")
+	assert.Equal(t, "what is a cat?", feedback.Prompt)
+	assert.Equal(t, "A cat is...", feedback.Answer)
+	assert.Equal(t, 0.25, feedback.Cost)
+
+	// reactions don't know the topic, the answer message is enough
+	feedback = newFeedback("4701", "", 14, "")
+	assert.Equal(t, "and a dog?", feedback.Prompt)
+
+	// messages that aren't answers get no prompt
+	feedback = newFeedback("4701", "7", 13, "")
+	assert.Empty(t, feedback.Prompt)
+	assert.Empty(t, feedback.Answer)
+}
@@ -0,0 +1,271 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/ai/images"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/storage"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/google/uuid"
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// GALLERY_CALLBACK_PREFIX marks gallery keyboard callbacks: gal.p=<page> opens a page,
+// gal.s=<id> sends an image again and gal.r=<id> draws a new one by the same prompt
+const GALLERY_CALLBACK_PREFIX = "gal."
+
+const (
+	GALLERY_PAGE_SIZE     = 5
+	GALLERY_PROMPT_LENGTH = 80
+	GALLERY_DATE_FORMAT   = "02 Jan 2006"
+)
+
+// sendAndSaveImages sends generated images, keeps them in the chat's gallery and in the conversation,
+// images are downloaded first as engine URLs expire
+func sendAndSaveImages(ctx context.Context, bot *telego.Bot, message *telego.Message, engine models.Engine, price float64, request models.ImageRequest, generated []models.GeneratedImage) {
+	generated = images.Download(ctx, generated)
+	sendGeneratedImages(bot, message, engine, request, generated)
+	go func() {
+		saved := saveGeneratedImages(ctx, message, engine, price, request, generated)
+		rememberImageTurn(ctx, message, engine, request, generated, saved)
+	}()
+}
+
+// saveGeneratedImages puts images into the blob store and their prompts, engine and cost into mongo, returns saved ones
+func saveGeneratedImages(ctx context.Context, message *telego.Message, engine models.Engine, price float64, request models.ImageRequest, generated []models.GeneratedImage) []*models.MongoImage {
+	if !storage.Enabled() {
+		return nil
+	}
+	chatIDString := util.GetChatIDString(message)
+	saved := []*models.MongoImage{}
+	for i := range generated {
+		if len(generated[i].Data) == 0 {
+			log.Warnf("Generated image in chat %s wasn't downloaded, not saving it", chatIDString)
+			config.CONFIG.DataDogClient.Incr("telegram.image_save_failed", []string{"reason:not_downloaded", "model:" + string(engine)}, 1)
+			continue
+		}
+		contentType := http.DetectContentType(generated[i].Data)
+		extension := "jpg"
+		if contentType == "image/png" {
+			extension = "png"
+		}
+		options := request.ImageOptions
+		options.N = 1
+		if options.Seed > 0 {
+			options.Seed += int64(i)
+		}
+		image := &models.MongoImage{
+			ID:            uuid.New().String(),
+			Client:        string(lib.TelegramClientName),
+			TopicId:       util.GetTopicID(message),
+			Prompt:        request.Prompt,
+			RevisedPrompt: generated[i].RevisedPrompt,
+			Engine:        string(engine),
+			Options:       options,
+			Edit:          len(request.Image) > 0,
+			Cost:          price,
+			ContentType:   contentType,
+		}
+		image.BlobKey = fmt.Sprintf("images/%s/%s.%s", chatIDString, image.ID, extension)
+
+		err := storage.BlobStoreClient.Put(ctx, image.BlobKey, generated[i].Data, contentType)
+		if err == nil {
+			err = mongo.MongoDBClient.SaveImage(ctx, image)
+		}
+		if err != nil {
+			log.Errorf("Failed to save image %s in chat %s: %v", image.ID, chatIDString, err)
+			config.CONFIG.DataDogClient.Incr("telegram.image_save_failed", []string{"reason:store", "model:" + string(engine)}, 1)
+			continue
+		}
+		config.CONFIG.DataDogClient.Incr("telegram.image_saved", []string{"model:" + string(engine), "store:" + storage.BlobStoreClient.Name()}, 1)
+		saved = append(saved, image)
+	}
+	return saved
+}
+
+// galleryCommandHandler lists images drawn in the chat, the newest first
+func galleryCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	if !storage.Enabled() {
+		bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), i18n.T(ctx, "gallery.disabled")).WithMessageThreadID(message.MessageThreadID))
+		return
+	}
+	text, keyboard, err := getGalleryPage(ctx, util.GetTopicID(message), 0)
+	if err != nil {
+		log.Errorf("Failed to get gallery in chat %s: %v", util.GetChatIDString(message), err)
+		text = i18n.T(ctx, "oopsie")
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.gallery_opened", []string{"channel_type:" + message.Chat.Type}, 1)
+	params := tu.Message(util.GetChatID(message), text).WithMessageThreadID(message.MessageThreadID)
+	if keyboard != nil {
+		params = params.WithReplyMarkup(keyboard)
+	}
+	bot.SendMessage(context.Background(), params)
+}
+
+// getGalleryPage lists a page of images with buttons to send each again or redraw it, edits can't be redrawn as originals aren't kept
+func getGalleryPage(ctx context.Context, topicString string, page int) (string, *telego.InlineKeyboardMarkup, error) {
+	saved, total, err := mongo.MongoDBClient.GetImages(ctx, page, GALLERY_PAGE_SIZE)
+	if err != nil {
+		return "", nil, err
+	}
+	if total == 0 {
+		return i18n.T(ctx, "gallery.empty"), nil, nil
+	}
+	pages := int((total + GALLERY_PAGE_SIZE - 1) / GALLERY_PAGE_SIZE)
+	button := func(text string, data string) telego.InlineKeyboardButton {
+		return telego.InlineKeyboardButton{Text: text, CallbackData: GALLERY_CALLBACK_PREFIX + data + ":" + topicString}
+	}
+
+	lines := []string{i18n.T(ctx, "gallery.title", i18n.Args{"page": page + 1, "pages": pages, "total": total})}
+	rows := [][]telego.InlineKeyboardButton{}
+	for i, image := range saved {
+		number := page*GALLERY_PAGE_SIZE + i + 1
+		lines = append(lines, fmt.Sprintf("%d. %s · %s\n%s", number, image.CreatedAt.Format(GALLERY_DATE_FORMAT), images.EngineName(models.Engine(image.Engine)), galleryPrompt(image.Prompt)))
+		row := []telego.InlineKeyboardButton{button(fmt.Sprintf("🖼 %d", number), "s="+image.ID)}
+		if !image.Edit {
+			row = append(row, button(fmt.Sprintf("🔁 %d", number), "r="+image.ID))
+		}
+		rows = append(rows, row)
+	}
+	navigation := []telego.InlineKeyboardButton{}
+	if page > 0 {
+		navigation = append(navigation, button("⬅️", "p="+strconv.Itoa(page-1)))
+	}
+	if page < pages-1 {
+		navigation = append(navigation, button("➡️", "p="+strconv.Itoa(page+1)))
+	}
+	if len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+	lines = append(lines, i18n.T(ctx, "gallery.hint"))
+	return strings.Join(lines, "\n\n"), &telego.InlineKeyboardMarkup{InlineKeyboard: rows}, nil
+}
+
+func galleryPrompt(prompt string) string {
+	runes := []rune(strings.Join(strings.Fields(prompt), " "))
+	if len(runes) > GALLERY_PROMPT_LENGTH {
+		return string(runes[:GALLERY_PROMPT_LENGTH-3]) + "..."
+	}
+	return string(runes)
+}
+
+func handleGalleryCallbackQuery(callbackQuery telego.CallbackQuery, topicString string) {
+	chat := callbackQuery.Message.GetChat()
+	chatIDString := fmt.Sprint(chat.ID)
+	_, ctx, cancelContext, err := lib.SetupUserAndContext(chatIDString, lib.TelegramClientName, chatIDString, topicString)
+	if err != nil {
+		log.Errorf("handleGalleryCallbackQuery: failed to setup user %s: %v", chatIDString, err)
+		return
+	}
+	defer cancelContext()
+
+	action := strings.SplitN(strings.TrimPrefix(callbackQuery.Data, GALLERY_CALLBACK_PREFIX), "=", 2)
+	if len(action) != 2 {
+		log.Errorf("handleGalleryCallbackQuery: invalid callback %s in chat %s", callbackQuery.Data, chatIDString)
+		return
+	}
+	// keyboards sent before the store was turned off
+	if !storage.Enabled() {
+		BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{CallbackQueryID: callbackQuery.ID, Text: i18n.T(ctx, "gallery.disabled")})
+		return
+	}
+	threadID, _ := strconv.Atoi(topicString)
+	message := &telego.Message{Chat: chat, MessageThreadID: threadID}
+	BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{CallbackQueryID: callbackQuery.ID})
+	config.CONFIG.DataDogClient.Incr("telegram.gallery_action", []string{"action:" + action[0]}, 1)
+
+	if action[0] == "p" {
+		page, _ := strconv.Atoi(action[1])
+		text, keyboard, err := getGalleryPage(ctx, topicString, max(page, 0))
+		if err != nil {
+			log.Errorf("handleGalleryCallbackQuery: failed to get gallery page %d in chat %s: %v", page, chatIDString, err)
+			return
+		}
+		BOT.EditMessageText(ctx, &telego.EditMessageTextParams{
+			ChatID:      chat.ChatID(),
+			MessageID:   callbackQuery.Message.GetMessageID(),
+			Text:        text,
+			ReplyMarkup: keyboard,
+		})
+		return
+	}
+
+	image, err := mongo.MongoDBClient.GetImage(ctx, action[1])
+	if err != nil {
+		log.Errorf("handleGalleryCallbackQuery: failed to get image %s in chat %s: %v", action[1], chatIDString, err)
+		BOT.SendMessage(context.Background(), tu.Message(chat.ChatID(), i18n.T(ctx, "gallery.not_found")).WithMessageThreadID(threadID))
+		return
+	}
+	switch action[0] {
+	case "s":
+		resendImage(ctx, BOT.Bot, message, image)
+	case "r":
+		regenerateImage(ctx, BOT.Bot, message, image)
+	}
+}
+
+// resendImage sends a saved image from the blob store
+func resendImage(ctx context.Context, bot *telego.Bot, message *telego.Message, image *models.MongoImage) {
+	chatIDString := util.GetChatIDString(message)
+	data, err := storage.BlobStoreClient.Get(ctx, image.BlobKey)
+	if err != nil {
+		log.Errorf("Failed to get image %s in chat %s: %v", image.ID, chatIDString, err)
+		text := i18n.T(ctx, "oopsie")
+		if errors.Is(err, storage.ErrNotFound) {
+			text = i18n.T(ctx, "gallery.not_found")
+		}
+		bot.SendMessage(context.Background(), tu.Message(message.Chat.ChatID(), text).WithMessageThreadID(message.MessageThreadID))
+		return
+	}
+	request := models.ImageRequest{Prompt: image.Prompt, ImageOptions: image.Options}
+	sendGeneratedImages(bot, message, models.Engine(image.Engine), request, []models.GeneratedImage{{Data: data, RevisedPrompt: image.RevisedPrompt}})
+}
+
+// regenerateImage draws a new image by a saved prompt and options, with the same engine and a new seed
+func regenerateImage(ctx context.Context, bot *telego.Bot, message *telego.Message, image *models.MongoImage) {
+	chatIDString := util.GetChatIDString(message)
+	reply := func(text string) {
+		text = lib.AddBotSuffixToGroupCommands(ctx, text)
+		bot.SendMessage(context.Background(), tu.Message(message.Chat.ChatID(), text).WithMessageThreadID(message.MessageThreadID))
+	}
+	if message.Chat.Type != telego.ChatTypePrivate && !lib.GetGroupSettings(chatIDString).ImagesAllowed {
+		reply(i18n.T(ctx, "group.image_generation_disabled"))
+		return
+	}
+	ok, subscription := lib.ValidateUserUsage(ctx)
+	if !ok {
+		config.CONFIG.DataDogClient.Incr("telegram.usage_exceeded", []string{"client:telegram", "channel_type:gallery", "subscription:" + string(subscription)}, 1)
+		reply(i18n.T(ctx, "usage.exceeded"))
+		return
+	}
+	options := image.Options
+	options.N = 1
+	options.Seed = 0
+	if err := lib.ValidateImageOptions(ctx, options); err != nil {
+		reply(i18n.T(ctx, "image.option_upgrade", i18n.Args{"max": lib.MaxImagesPerRequest(ctx)}))
+		return
+	}
+
+	provider := images.ProviderFor(models.Engine(image.Engine))
+	log.Infof("Regenerating image %s with %s in a chat %s..", image.ID, provider.Engine(), chatIDString)
+	sendImageAction(bot, message)
+	request := models.ImageRequest{Prompt: image.Prompt, ImageOptions: options}
+	generated, err := images.Generate(ctx, provider, request)
+	if err != nil {
+		sendImageError(ctx, bot, message, provider.Engine(), err)
+		return
+	}
+	sendAndSaveImages(ctx, bot, message, provider.Engine(), provider.PricePerImage(request), request, generated)
+}
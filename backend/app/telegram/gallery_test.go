@@ -0,0 +1,66 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/storage"
+	"testing"
+
+	"github.com/mymmrac/telego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveGeneratedImagesAndGallery(t *testing.T) {
+	previous := mongo.MongoDBClient
+	defer func() { mongo.MongoDBClient = previous }()
+	mongoClient := mongo.NewMockMongoDBClient(models.MongoUser{ID: "123"})
+	mongo.MongoDBClient = mongoClient
+	storage.BlobStoreClient = storage.NewLocalStore(t.TempDir())
+
+	ctx := context.WithValue(context.Background(), models.UserContext{}, "123")
+	message := &telego.Message{Chat: telego.Chat{ID: 123, Type: telego.ChatTypePrivate}}
+	png := []byte("\x89PNG\r\n\x1a\n")
+	request := models.ImageRequest{Prompt: "a fox in the snow", ImageOptions: models.ImageOptions{AspectRatio: "16:9", N: 2, Seed: 42}}
+	saveGeneratedImages(ctx, message, models.StableDiffusion3, 0.03, request, []models.GeneratedImage{{Data: png}, {URL: "https://expired"}, {Data: png}})
+
+	// images that weren't downloaded are skipped, seeds follow the image order
+	assert.Len(t, mongoClient.Images, 2)
+	saved := mongoClient.Images[1]
+	assert.Equal(t, "123", saved.UserId)
+	assert.Equal(t, string(models.StableDiffusion3), saved.Engine)
+	assert.Equal(t, 0.03, saved.Cost)
+	assert.Equal(t, models.ImageOptions{AspectRatio: "16:9", N: 1, Seed: 44}, saved.Options)
+	assert.Equal(t, "image/png", saved.ContentType)
+	assert.Equal(t, "images/123/"+saved.ID+".png", saved.BlobKey)
+	data, err := storage.BlobStoreClient.Get(ctx, saved.BlobKey)
+	assert.NoError(t, err)
+	assert.Equal(t, png, data)
+
+	for i := 0; i < GALLERY_PAGE_SIZE; i++ {
+		mongoClient.SaveImage(ctx, &models.MongoImage{ID: "edit", Prompt: "make it a watercolor", Engine: string(models.GptImage1), Edit: true})
+	}
+	text, keyboard, err := getGalleryPage(ctx, "", 0)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(text, "🖼 Your images, page 1 of 2 (7 in total):"), text)
+	assert.Contains(t, text, "1. ")
+	assert.Len(t, keyboard.InlineKeyboard, GALLERY_PAGE_SIZE+1)
+	assert.Len(t, keyboard.InlineKeyboard[0], 1, "edits can't be redrawn")
+	assert.Equal(t, []telego.InlineKeyboardButton{{Text: "➡️", CallbackData: "gal.p=1:"}}, keyboard.InlineKeyboard[GALLERY_PAGE_SIZE])
+
+	text, keyboard, err = getGalleryPage(ctx, "", 1)
+	assert.NoError(t, err)
+	assert.Contains(t, text, "7. ")
+	assert.Contains(t, text, "a fox in the snow")
+	assert.Equal(t, []telego.InlineKeyboardButton{
+		{Text: "🖼 6", CallbackData: "gal.s=" + mongoClient.Images[1].ID + ":"},
+		{Text: "🔁 6", CallbackData: "gal.r=" + mongoClient.Images[1].ID + ":"},
+	}, keyboard.InlineKeyboard[0])
+	assert.Equal(t, []telego.InlineKeyboardButton{{Text: "⬅️", CallbackData: "gal.p=0:"}}, keyboard.InlineKeyboard[2])
+}
+
+func TestGalleryPrompt(t *testing.T) {
+	assert.Equal(t, "a fox in the snow", galleryPrompt(" a fox\nin  the snow "))
+	assert.Len(t, []rune(galleryPrompt(strings.Repeat("я", 200))), GALLERY_PROMPT_LENGTH)
+}
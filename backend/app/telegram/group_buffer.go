@@ -0,0 +1,273 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	GROUP_DIGEST_MAX_CHARS = 100000
+
+	// MAX_DIGEST_TRANSCRIPTS caps voice messages transcribed for a single digest, the rest are only mentioned
+	MAX_DIGEST_TRANSCRIPTS = 20
+	GROUP_DIGEST_PROMPT    = `You will write a digest of a group chat conversation provided as a transcript, each line is "[time] Name: message".
+Structure the digest as:
+- Participants, who took part and their role in the discussion
+- Main topics and points, a few short paragraphs
+- Decisions made, if any
+- Action items as a bullet list with owners, if any
+Respond in the language most of the conversation is in.`
+)
+
+// summarizeCommandHandler either switches to summarize mode, or with a range param (/summarize 200, /summarize 6h) sends a group digest
+func summarizeCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	messageArray := strings.Fields(message.Text)
+	if len(messageArray) > 1 {
+		if count, since, ok := lib.ParseBufferRange(messageArray[1]); ok {
+			sendGroupDigest(ctx, bot, message, count, since)
+			return
+		}
+	}
+	getModeHandlerFunction(lib.Summarize, "mode.summarize")(ctx, bot, message)
+}
+
+// groupBufferCommandHandler enables, disables or purges group messages buffer used for digests
+func groupBufferCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	chatID := util.GetChatID(message)
+	chatIDString := util.GetChatIDString(message)
+	if message.Chat.Type == telego.ChatTypePrivate {
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "group.only")).WithMessageThreadID(message.MessageThreadID))
+		return
+	}
+
+	param := ""
+	messageArray := strings.Fields(message.Text)
+	if len(messageArray) > 1 {
+		param = strings.ToLower(messageArray[1])
+	}
+
+	response := ""
+	switch param {
+	case "on":
+		err := lib.SetGroupBufferEnabled(chatIDString, true)
+		if err != nil {
+			log.Errorf("Failed to enable group buffer in chat %s: %v", chatIDString, err)
+			response = i18n.T(ctx, "group_buffer.enable_failed")
+			break
+		}
+		response = i18n.T(ctx, "group_buffer.enabled", i18n.Args{"count": lib.GROUP_BUFFER_SIZE, "hours": lib.GROUP_BUFFER_TTL.Hours()})
+	case "off":
+		err := lib.SetGroupBufferEnabled(chatIDString, false)
+		if err == nil {
+			err = lib.PurgeGroupBuffer(chatIDString)
+		}
+		if err != nil {
+			log.Errorf("Failed to disable group buffer in chat %s: %v", chatIDString, err)
+			response = i18n.T(ctx, "group_buffer.disable_failed")
+			break
+		}
+		response = i18n.T(ctx, "group_buffer.disabled")
+	case "purge":
+		err := lib.PurgeGroupBuffer(chatIDString)
+		if err != nil {
+			log.Errorf("Failed to purge group buffer in chat %s: %v", chatIDString, err)
+			response = i18n.T(ctx, "group_buffer.purge_failed")
+			break
+		}
+		response = i18n.T(ctx, "group_buffer.purged")
+	default:
+		response = i18n.T(ctx, "group_buffer.status", i18n.Args{"status": onOff(ctx, lib.IsGroupBufferEnabled(chatIDString))})
+	}
+
+	config.CONFIG.DataDogClient.Incr("telegram.group_buffer_command", []string{"param:" + param}, 1)
+	response = lib.AddBotSuffixToGroupCommands(ctx, response)
+	bot.SendMessage(context.Background(), tu.Message(chatID, response).WithMessageThreadID(message.MessageThreadID))
+}
+
+// bufferGroupMessage stores a group message in the rolling buffer if the group opted in
+func bufferGroupMessage(message *telego.Message, text string, transcript string) {
+	if message.Chat.Type == telego.ChatTypePrivate {
+		return
+	}
+	chatIDString := util.GetChatIDString(message)
+	if !lib.IsGroupBufferEnabled(chatIDString) {
+		return
+	}
+	bufferedMessage := models.GroupBufferMessage{
+		From:       getSenderName(message.From),
+		Text:       text,
+		Transcript: transcript,
+		Timestamp:  message.Date,
+	}
+	if ok, mediaType := util.IsAudioMessage(message); ok {
+		bufferedMessage.MediaFileID = getMediaFileID(message)
+		bufferedMessage.MediaType = mediaType
+	}
+	lib.AppendToGroupBuffer(chatIDString, bufferedMessage)
+}
+
+// saveBufferedTranscript keeps a transcript of a buffered voice message, that was transcribed to answer it
+func saveBufferedTranscript(message *telego.Message, transcript string) {
+	chatIDString := util.GetChatIDString(message)
+	if message.Chat.Type == telego.ChatTypePrivate || !lib.IsGroupBufferEnabled(chatIDString) {
+		return
+	}
+	lib.SaveGroupBufferTranscript(chatIDString, getMediaFileID(message), transcript)
+}
+
+// transcribeBufferedMessage transcribes buffered voice messages, tests replace it to skip downloading
+var transcribeBufferedMessage = getVoiceTranscript
+
+// withBufferedTranscripts fills in transcripts of buffered voice messages, the ones not transcribed yet are transcribed now,
+// unless voice is disabled in the group
+func withBufferedTranscripts(ctx context.Context, bot *telego.Bot, message *telego.Message, bufferedMessages []models.GroupBufferMessage) {
+	// digests are requested by a command, before mode params are set, buffered voice is in any language anyway
+	ctx = context.WithValue(ctx, models.ParamsContext{}, "")
+	chatIDString := util.GetChatIDString(message)
+	voiceAllowed := lib.GetGroupSettings(chatIDString).VoiceAllowed
+	transcribed := 0
+	for i := range bufferedMessages {
+		bufferedMessage := &bufferedMessages[i]
+		if bufferedMessage.MediaFileID == "" || bufferedMessage.Transcript != "" {
+			continue
+		}
+		bufferedMessage.Transcript = lib.GetGroupBufferTranscript(chatIDString, bufferedMessage.MediaFileID)
+		if bufferedMessage.Transcript != "" || !voiceAllowed || transcribed >= MAX_DIGEST_TRANSCRIPTS {
+			continue
+		}
+		transcribed++
+		mediaMessage := getBufferedMediaMessage(message, bufferedMessage)
+		bufferedMessage.Transcript = transcribeBufferedMessage(ctx, bot, mediaMessage, lib.TranscriptOptions{})
+		if bufferedMessage.Transcript == "" {
+			// failed transcriptions are retried by the next digest
+			continue
+		}
+		lib.SaveGroupBufferTranscript(chatIDString, bufferedMessage.MediaFileID, bufferedMessage.Transcript)
+	}
+	if transcribed > 0 {
+		config.CONFIG.DataDogClient.Count("telegram.group_digest_transcripts", int64(transcribed), nil, 1)
+	}
+}
+
+// getBufferedMediaMessage rebuilds a voice/audio/video message from the buffer, replies go to the digest request topic
+func getBufferedMediaMessage(message *telego.Message, bufferedMessage *models.GroupBufferMessage) telego.Message {
+	mediaMessage := telego.Message{Chat: message.Chat, MessageThreadID: message.MessageThreadID}
+	switch bufferedMessage.MediaType {
+	case "audio":
+		mediaMessage.Audio = &telego.Audio{FileID: bufferedMessage.MediaFileID}
+	case "video":
+		mediaMessage.Video = &telego.Video{FileID: bufferedMessage.MediaFileID}
+	case "note":
+		mediaMessage.VideoNote = &telego.VideoNote{FileID: bufferedMessage.MediaFileID}
+	case "document":
+		mediaMessage.Document = &telego.Document{FileID: bufferedMessage.MediaFileID}
+	default:
+		mediaMessage.Voice = &telego.Voice{FileID: bufferedMessage.MediaFileID}
+	}
+	return mediaMessage
+}
+
+func getSenderName(from *telego.User) string {
+	if from == nil {
+		return "Anonymous"
+	}
+	name := strings.TrimSpace(from.FirstName + " " + from.LastName)
+	if from.Username != "" {
+		if name == "" {
+			return "@" + from.Username
+		}
+		return name + " (@" + from.Username + ")"
+	}
+	if name == "" {
+		return fmt.Sprintf("User %d", from.ID)
+	}
+	return name
+}
+
+func formatGroupBuffer(messages []models.GroupBufferMessage) string {
+	var transcript strings.Builder
+	for _, message := range messages {
+		text := message.Text
+		if message.Transcript != "" {
+			text = strings.TrimSpace(text + " 🗣 " + message.Transcript)
+		} else if message.MediaFileID != "" {
+			text = strings.TrimSpace(text + " 🗣 [" + message.MediaType + " message]")
+		}
+		transcript.WriteString(fmt.Sprintf("[%s] %s: %s\n", time.Unix(message.Timestamp, 0).UTC().Format("2006-01-02 15:04"), message.From, text))
+	}
+
+	// keep the latest part of the conversation if it's too long
+	result := transcript.String()
+	if len(result) > GROUP_DIGEST_MAX_CHARS {
+		result = result[len(result)-GROUP_DIGEST_MAX_CHARS:]
+		if newline := strings.Index(result, "\n"); newline >= 0 {
+			result = result[newline+1:]
+		}
+	}
+	return result
+}
+
+func sendGroupDigest(ctx context.Context, bot *Bot, message *telego.Message, count int, since time.Duration) {
+	chatID := util.GetChatID(message)
+	chatIDString := util.GetChatIDString(message)
+	if message.Chat.Type == telego.ChatTypePrivate {
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "group_buffer.summarize_only_groups")).WithMessageThreadID(message.MessageThreadID))
+		return
+	}
+	if !lib.IsGroupBufferEnabled(chatIDString) {
+		notification := lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "group_buffer.summarize_disabled"))
+		bot.SendMessage(context.Background(), tu.Message(chatID, notification).WithMessageThreadID(message.MessageThreadID))
+		return
+	}
+
+	ok, subscription := lib.ValidateUserUsage(ctx)
+	if !ok {
+		config.CONFIG.DataDogClient.Incr("telegram.usage_exceeded", []string{"client:telegram", "channel_type:" + message.Chat.Type, "subscription:" + string(subscription)}, 1)
+		message.Text = lib.AddBotSuffixToGroupCommands(ctx, string(UpgradeCommand))
+		AllCommandHandlers.handleCommand(ctx, bot, message)
+		return
+	}
+
+	// group member exceeded the monthly cap set by admins
+	groupSettings := lib.GetGroupSettings(chatIDString)
+	if !lib.ValidateGroupMemberUsage(ctx, groupSettings) {
+		config.CONFIG.DataDogClient.Incr("telegram.member_usage_exceeded", []string{"channel_type:" + message.Chat.Type}, 1)
+		sendGroupPolicyNotice(bot.Bot, message, i18n.T(ctx, "group.member_cap_reached", i18n.Args{"cap": fmt.Sprintf("%.2f", groupSettings.MemberMonthlyCap)}))
+		return
+	}
+
+	bufferedMessages, err := lib.GetGroupBuffer(chatIDString, count, since)
+	if err != nil {
+		log.Errorf("Failed to get group buffer in chat %s: %v", chatIDString, err)
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
+		return
+	}
+	if len(bufferedMessages) == 0 {
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "group_buffer.empty")).WithMessageThreadID(message.MessageThreadID))
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.group_digest", nil, 1)
+	log.Infof("Summarizing %d buffered messages in chat %s", len(bufferedMessages), chatIDString)
+
+	sendTypingAction(bot.Bot, message)
+	go func() {
+		withBufferedTranscripts(ctx, bot.Bot, message, bufferedMessages)
+		digestMessage := *message
+		digestMessage.Text = formatGroupBuffer(bufferedMessages)
+		seedData := []models.Message{{Role: "system", Content: GROUP_DIGEST_PROMPT}}
+		digestCtx, cancelContext := context.WithTimeout(context.WithValue(ctx, models.ParamsContext{}, ""), lib.TIMEOUT)
+		ProcessChatCompleteStreamingMessage(digestCtx, bot.Bot, &digestMessage, seedData, "", lib.Summarize, redis.GetModel(chatIDString), cancelContext)
+	}()
+}
@@ -0,0 +1,82 @@
+package telegram
+
+import (
+	"context"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatGroupBuffer(t *testing.T) {
+	transcript := formatGroupBuffer([]models.GroupBufferMessage{
+		{From: "Alice", Text: "hi", Timestamp: 1700000000},
+		{From: "Bob", Transcript: "on my way", MediaFileID: "voice-1", MediaType: "voice", Timestamp: 1700000060},
+		{From: "Carol", MediaFileID: "video-1", MediaType: "note", Timestamp: 1700000120},
+	})
+	assert.Equal(t, "[2023-11-14 22:13] Alice: hi\n[2023-11-14 22:14] Bob: 🗣 on my way\n[2023-11-14 22:15] Carol: 🗣 [note message]\n", transcript)
+}
+
+func TestBufferGroupVoiceMessage(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	lib.SetGroupBufferEnabled("-100", true)
+	message := &telego.Message{
+		Chat:  telego.Chat{ID: -100, Type: telego.ChatTypeGroup},
+		From:  &telego.User{ID: 1, FirstName: "Bob"},
+		Voice: &telego.Voice{FileID: "voice-1"},
+		Date:  time.Now().Unix(),
+	}
+
+	// untriggered voice messages are kept as references, transcripts made to answer them are kept aside
+	bufferGroupMessage(message, "", "")
+	saveBufferedTranscript(message, "on my way")
+
+	buffered, err := lib.GetGroupBuffer("-100", 0, 0)
+	assert.NoError(t, err)
+	if !assert.Len(t, buffered, 1) {
+		return
+	}
+	assert.Equal(t, "voice-1", buffered[0].MediaFileID)
+	assert.Equal(t, "voice", buffered[0].MediaType)
+	assert.Equal(t, "on my way", lib.GetGroupBufferTranscript("-100", "voice-1"))
+
+	mediaMessage := getBufferedMediaMessage(&telego.Message{Chat: message.Chat, MessageThreadID: 3}, &buffered[0])
+	assert.Equal(t, "voice-1", getMediaFileID(&mediaMessage))
+	assert.Equal(t, 3, mediaMessage.MessageThreadID)
+}
+
+func TestWithBufferedTranscripts(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	previous := transcribeBufferedMessage
+	defer func() { transcribeBufferedMessage = previous }()
+	transcribed := []string{}
+	transcribeBufferedMessage = func(ctx context.Context, bot *telego.Bot, message telego.Message, options lib.TranscriptOptions) string {
+		// whisper reads the transcription language from params
+		assert.Equal(t, "", ctx.Value(models.ParamsContext{}).(string))
+		fileID := getMediaFileID(&message)
+		transcribed = append(transcribed, fileID)
+		if fileID == "voice-2" {
+			return ""
+		}
+		return "on my way"
+	}
+
+	// a command context, mode params aren't set yet
+	ctx := context.WithValue(context.Background(), models.UserContext{}, "-100")
+	message := &telego.Message{Chat: telego.Chat{ID: -100, Type: telego.ChatTypeGroup}}
+	buffered := []models.GroupBufferMessage{
+		{From: "Alice", Text: "hi"},
+		{From: "Bob", MediaFileID: "voice-1", MediaType: "voice"},
+		{From: "Carol", MediaFileID: "voice-2", MediaType: "voice"},
+	}
+	withBufferedTranscripts(ctx, nil, message, buffered)
+
+	assert.Equal(t, []string{"voice-1", "voice-2"}, transcribed)
+	assert.Equal(t, "on my way", buffered[1].Transcript)
+	assert.Equal(t, "on my way", lib.GetGroupBufferTranscript("-100", "voice-1"))
+	assert.Empty(t, lib.GetGroupBufferTranscript("-100", "voice-2"), "failed transcriptions aren't cached")
+}
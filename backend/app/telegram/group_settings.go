@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// groupSettingsCommandHandler shows or updates the group policy, admin only like any other group command
+func groupSettingsCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	chatID := util.GetChatID(message)
+	chatIDString := util.GetChatIDString(message)
+	if message.Chat.Type == telego.ChatTypePrivate {
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "group.only")).WithMessageThreadID(message.MessageThreadID))
+		return
+	}
+
+	messageArray := strings.Fields(message.Text)
+	response := ""
+	switch {
+	case len(messageArray) == 1:
+		response = formatGroupSettings(ctx, lib.GetGroupSettings(chatIDString)) + "\n\n" + i18n.T(ctx, "group_settings.usage")
+	case strings.ToLower(messageArray[1]) == "reset":
+		err := lib.ResetGroupSettings(chatIDString)
+		if err != nil {
+			log.Errorf("Failed to reset group settings in chat %s: %v", chatIDString, err)
+			response = i18n.T(ctx, "oopsie")
+			break
+		}
+		response = i18n.T(ctx, "group_settings.reset") + "\n\n" + formatGroupSettings(ctx, lib.DefaultGroupSettings())
+	default:
+		settings := lib.GetGroupSettings(chatIDString)
+		subscription := ctx.Value(models.SubscriptionContext{}).(models.MongoSubscriptionName)
+		err := lib.ApplyGroupSetting(&settings, messageArray[1], strings.Join(messageArray[2:], " "), subscription)
+		if err != nil {
+			response = i18n.T(ctx, "group_settings.update_failed", i18n.Args{"error": err}) + "\n\n" + i18n.T(ctx, "group_settings.usage")
+			break
+		}
+		err = lib.SaveGroupSettings(chatIDString, settings)
+		if err != nil {
+			log.Errorf("Failed to save group settings in chat %s: %v", chatIDString, err)
+			response = i18n.T(ctx, "oopsie")
+			break
+		}
+		config.CONFIG.DataDogClient.Incr("telegram.group_settings_updated", []string{"setting:" + strings.ToLower(messageArray[1])}, 1)
+		response = i18n.T(ctx, "group_settings.updated") + "\n\n" + formatGroupSettings(ctx, settings)
+	}
+
+	response = lib.AddBotSuffixToGroupCommands(ctx, response)
+	bot.SendMessage(context.Background(), tu.Message(chatID, response).WithMessageThreadID(message.MessageThreadID))
+}
+
+func formatGroupSettings(ctx context.Context, settings models.GroupSettings) string {
+	modes := i18n.T(ctx, "group_settings.all")
+	if len(settings.AllowedModes) > 0 {
+		modes = strings.Join(settings.AllowedModes, ", ")
+	}
+	engines := i18n.T(ctx, "group_settings.all")
+	if len(settings.AllowedEngines) > 0 {
+		aliases := []string{}
+		for alias, engine := range lib.GroupEngineAliases {
+			for _, allowedEngine := range settings.AllowedEngines {
+				if string(engine) == allowedEngine {
+					aliases = append(aliases, alias)
+				}
+			}
+		}
+		sort.Strings(aliases)
+		engines = strings.Join(aliases, ", ")
+	}
+	memberCap := i18n.T(ctx, "group_settings.no_cap")
+	if settings.MemberMonthlyCap > 0 {
+		memberCap = i18n.T(ctx, "group_settings.cap", i18n.Args{"cap": fmt.Sprintf("%.2f", settings.MemberMonthlyCap)})
+	}
+	triggers := []string{}
+	for _, trigger := range settings.Triggers {
+		triggers = append(triggers, string(trigger))
+	}
+	keywords := i18n.T(ctx, "group_settings.none")
+	if len(settings.Keywords) > 0 {
+		keywords = strings.Join(settings.Keywords, ", ")
+	}
+	return i18n.T(ctx, "group_settings.summary", i18n.Args{
+		"modes":    modes,
+		"engines":  engines,
+		"images":   onOff(ctx, settings.ImagesAllowed),
+		"voice":    onOff(ctx, settings.VoiceAllowed),
+		"cap":      memberCap,
+		"triggers": strings.Join(triggers, ", "),
+		"keywords": keywords,
+		"memory":   onOff(ctx, settings.MemberMemory),
+	})
+}
+
+func onOff(ctx context.Context, value bool) string {
+	if value {
+		return i18n.T(ctx, "on")
+	}
+	return i18n.T(ctx, "off")
+}
+
+// isGroupMessageTriggered checks if a group message should be answered according to the group trigger policy
+func isGroupMessageTriggered(message *telego.Message, settings models.GroupSettings) bool {
+	text := message.Text + message.Caption
+	if lib.HasGroupTrigger(settings, models.GroupTriggerMention) && strings.Contains(text, "@"+BOT.Name) {
+		return true
+	}
+	if lib.HasGroupTrigger(settings, models.GroupTriggerReply) && message.ReplyToMessage != nil && message.ReplyToMessage.From != nil && message.ReplyToMessage.From.Username == BOT.Name {
+		return true
+	}
+	if lib.HasGroupTrigger(settings, models.GroupTriggerKeyword) && lib.HasGroupKeyword(settings, text) {
+		return true
+	}
+	return false
+}
+
+// sendGroupPolicyNotice lets a member know the message was not processed due to the group policy
+func sendGroupPolicyNotice(bot *telego.Bot, message *telego.Message, notice string) {
+	config.CONFIG.DataDogClient.Incr("telegram.group_policy_rejected", nil, 1)
+	bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), notice).WithMessageThreadID(message.MessageThreadID).WithReplyParameters(&telego.ReplyParameters{MessageID: message.MessageID}))
+}
@@ -0,0 +1,273 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+const GROUP_THREAD_INSTRUCTIONS = `This is a Telegram group chat%s with several participants. Each user message starts with the sender name followed by a colon.
+Keep track of who said what, address people by their first name when replying and don't mix up participants. Don't prefix your own replies with a name.`
+
+// MEMBER_MEMORY_START_PREFIX is a /start link param, that opens private notes of a member for the group in the private chat
+const MEMBER_MEMORY_START_PREFIX = "mymemory_"
+
+// memberCommands can be used by any group member, not only admins
+var memberCommands = []Command{MyMemoryCommand}
+
+func isMemberCommand(text string) bool {
+	for _, command := range memberCommands {
+		if isCommand(text, command) {
+			return true
+		}
+	}
+
+	// any member can catch up with /summarize 200 or /summarize 6h, switching to summarize mode is left to admins
+	if isCommand(text, SummarizeCommand) {
+		messageArray := strings.Fields(text)
+		if len(messageArray) > 1 {
+			_, _, ok := lib.ParseBufferRange(messageArray[1])
+			return ok
+		}
+	}
+	return false
+}
+
+func isCommand(text string, command Command) bool {
+	return text == string(command) || strings.HasPrefix(text, string(command)+" ") || strings.HasPrefix(text, string(command)+"@")
+}
+
+func getGroupInfo(message *telego.Message) string {
+	title := ""
+	if message.Chat.Title != "" {
+		title = fmt.Sprintf(" \"%s\"", message.Chat.Title)
+	}
+	return fmt.Sprintf(GROUP_THREAD_INSTRUCTIONS, title)
+}
+
+// getThreadAuthor returns a name of the user turn author in group chats, empty in private ones
+func getThreadAuthor(message *telego.Message) string {
+	if message.Chat.Type == telego.ChatTypePrivate {
+		return ""
+	}
+	return getSenderName(message.From)
+}
+
+// attributeText prefixes a group message with the sender name, the way models see group turns
+func attributeText(author string, text string) string {
+	if author == "" {
+		return text
+	}
+	return author + ": " + text
+}
+
+// attributeGroupTurns returns a copy of thread messages with authors moved into user turns content
+func attributeGroupTurns(messages []models.MultimodalMessage) []models.MultimodalMessage {
+	attributed := make([]models.MultimodalMessage, len(messages))
+	for i, message := range messages {
+		attributed[i] = message
+		if message.Name == "" {
+			continue
+		}
+		attributed[i].Name = ""
+		attributed[i].Content = make([]models.MultimodalContent, len(message.Content))
+		copy(attributed[i].Content, message.Content)
+		textFound := false
+		for j, content := range attributed[i].Content {
+			if content.Type == "text" {
+				attributed[i].Content[j].Text = attributeText(message.Name, content.Text)
+				textFound = true
+				break
+			}
+		}
+		if !textFound {
+			attributed[i].Content = append(attributed[i].Content, models.MultimodalContent{Type: "text", Text: attributeText(message.Name, "")})
+		}
+	}
+	return attributed
+}
+
+// getMemberMemoryInstructions returns private notes of the sender, if the group has member memory enabled
+func getMemberMemoryInstructions(message *telego.Message) string {
+	if message.Chat.Type == telego.ChatTypePrivate || message.From == nil {
+		return ""
+	}
+	chatIDString := util.GetChatIDString(message)
+	if !lib.GetGroupSettings(chatIDString).MemberMemory {
+		return ""
+	}
+	notes, err := lib.GetMemberMemory(chatIDString, fmt.Sprint(message.From.ID))
+	if err != nil {
+		log.Errorf("Failed to get member %d memory in chat %s: %v", message.From.ID, chatIDString, err)
+		return ""
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	name := getSenderName(message.From)
+	return fmt.Sprintf("Private notes %s shared with you, use them only when replying to %s and never reveal them to other participants:\n- %s", name, name, strings.Join(notes, "\n- "))
+}
+
+// withMemberMemory adds private member notes right before the last message, so they never end up in the shared thread
+func withMemberMemory(message *telego.Message, messages []models.MultimodalMessage) []models.MultimodalMessage {
+	instructions := getMemberMemoryInstructions(message)
+	if instructions == "" || len(messages) == 0 {
+		return messages
+	}
+	result := make([]models.MultimodalMessage, 0, len(messages)+1)
+	result = append(result, messages[:len(messages)-1]...)
+	result = append(result, models.MultimodalMessage{
+		Role:    "system",
+		Content: []models.MultimodalContent{{Type: "text", Text: instructions}},
+	})
+	return append(result, messages[len(messages)-1])
+}
+
+// myMemoryCommandHandler manages private notes of a group member, notes are only shown and taken in the private chat,
+// in the group members get a link to it
+func myMemoryCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	if message.From == nil {
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.member_memory_command", []string{"channel_type:" + message.Chat.Type}, 1)
+	if message.Chat.Type != telego.ChatTypePrivate {
+		sendMemberMemoryLink(ctx, bot, message)
+		return
+	}
+
+	member := fmt.Sprint(message.From.ID)
+	note := ""
+	if parts := strings.SplitN(message.Text, " ", 2); len(parts) > 1 {
+		note = strings.TrimSpace(parts[1])
+	}
+
+	// /mymemory <group id> picks the group, the same as the link sent in the group
+	if groupID, err := strconv.ParseInt(note, 10, 64); err == nil && groupID < 0 {
+		openMemberMemory(ctx, bot, message, note)
+		return
+	}
+
+	groupIDString := lib.GetMemberMemoryGroup(member)
+	if groupIDString == "" {
+		sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "member_memory.choose_group"))
+		return
+	}
+	groupTitle, ok := validateMemberMemoryGroup(ctx, bot, message, groupIDString)
+	if !ok {
+		return
+	}
+
+	switch strings.ToLower(note) {
+	case "":
+		sendMemberMemoryNotes(ctx, bot, message, groupIDString, groupTitle)
+	case "clear":
+		err := lib.ClearMemberMemory(groupIDString, member)
+		if err != nil {
+			log.Errorf("Failed to clear member %s memory in chat %s: %v", member, groupIDString, err)
+			sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "oopsie"))
+			return
+		}
+		sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "member_memory.cleared", i18n.Args{"group": groupTitle}))
+	default:
+		err := lib.AddMemberMemory(groupIDString, member, note)
+		if err != nil {
+			log.Errorf("Failed to add member %s memory in chat %s: %v", member, groupIDString, err)
+			sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "oopsie"))
+			return
+		}
+		sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "member_memory.noted", i18n.Args{"group": groupTitle, "count": lib.MEMBER_MEMORY_SIZE}))
+	}
+}
+
+// sendMemberMemoryLink points a member to the private chat, a note sent in the group is deleted if the bot can, and never kept
+func sendMemberMemoryLink(ctx context.Context, bot *Bot, message *telego.Message) {
+	chatIDString := util.GetChatIDString(message)
+	reply := func(text string, replyMarkup telego.ReplyMarkup) {
+		params := tu.Message(util.GetChatID(message), lib.AddBotSuffixToGroupCommands(ctx, text)).WithMessageThreadID(message.MessageThreadID)
+		if replyMarkup != nil {
+			params = params.WithReplyMarkup(replyMarkup)
+		}
+		bot.SendMessage(context.Background(), params)
+	}
+	if !lib.GetGroupSettings(chatIDString).MemberMemory {
+		reply(i18n.T(ctx, "member_memory.disabled"), nil)
+		return
+	}
+	if parts := strings.Fields(message.Text); len(parts) > 1 {
+		err := bot.DeleteMessage(context.Background(), &telego.DeleteMessageParams{ChatID: message.Chat.ChatID(), MessageID: message.MessageID})
+		if err != nil {
+			log.Infof("Failed to delete member memory note in chat %s: %v", chatIDString, err)
+		}
+	}
+	link := fmt.Sprintf("https://t.me/%s?start=%s%s", BOT.Name, MEMBER_MEMORY_START_PREFIX, chatIDString)
+	keyboard := tu.InlineKeyboard(tu.InlineKeyboardRow(tu.InlineKeyboardButton(i18n.T(ctx, "member_memory.button_open")).WithURL(link)))
+	reply(i18n.T(ctx, "member_memory.open_private", i18n.Args{"name": getSenderName(message.From)}), keyboard)
+}
+
+// openMemberMemory picks the group a member manages notes of in the private chat, by /start link or /mymemory <group id>
+func openMemberMemory(ctx context.Context, bot *Bot, message *telego.Message, groupIDString string) {
+	groupTitle, ok := validateMemberMemoryGroup(ctx, bot, message, groupIDString)
+	if !ok {
+		return
+	}
+	err := lib.SetMemberMemoryGroup(fmt.Sprint(message.From.ID), groupIDString)
+	if err != nil {
+		log.Errorf("Failed to save member %d memory group %s: %v", message.From.ID, groupIDString, err)
+		sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "oopsie"))
+		return
+	}
+	sendMemberMemoryNotes(ctx, bot, message, groupIDString, groupTitle)
+}
+
+// validateMemberMemoryGroup checks the member is still in the group and the group keeps member memory, returns the group title
+func validateMemberMemoryGroup(ctx context.Context, bot *Bot, message *telego.Message, groupIDString string) (string, bool) {
+	groupID, err := strconv.ParseInt(groupIDString, 10, 64)
+	if err != nil {
+		sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "member_memory.choose_group"))
+		return "", false
+	}
+	chatMember, err := bot.GetChatMember(context.Background(), &telego.GetChatMemberParams{ChatID: tu.ID(groupID), UserID: message.From.ID})
+	if err != nil || chatMember.MemberStatus() == telego.MemberStatusLeft || chatMember.MemberStatus() == telego.MemberStatusBanned {
+		log.Infof("Member %d is not in group %s: %v", message.From.ID, groupIDString, err)
+		sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "member_memory.not_member"))
+		return "", false
+	}
+	if !lib.GetGroupSettings(groupIDString).MemberMemory {
+		sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "member_memory.disabled"))
+		return "", false
+	}
+	groupTitle := groupIDString
+	if chat, err := bot.GetChat(context.Background(), &telego.GetChatParams{ChatID: tu.ID(groupID)}); err == nil && chat.Title != "" {
+		groupTitle = chat.Title
+	}
+	return groupTitle, true
+}
+
+func sendMemberMemoryNotes(ctx context.Context, bot *Bot, message *telego.Message, groupIDString string, groupTitle string) {
+	member := fmt.Sprint(message.From.ID)
+	notes, err := lib.GetMemberMemory(groupIDString, member)
+	if err != nil {
+		log.Errorf("Failed to get member %s memory in chat %s: %v", member, groupIDString, err)
+		sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "oopsie"))
+		return
+	}
+	if len(notes) == 0 {
+		sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "member_memory.empty", i18n.Args{"group": groupTitle}))
+		return
+	}
+	sendMemberMemoryReply(ctx, bot, message, i18n.T(ctx, "member_memory.list", i18n.Args{"group": groupTitle, "notes": strings.Join(notes, "\n- ")}))
+}
+
+func sendMemberMemoryReply(ctx context.Context, bot *Bot, message *telego.Message, text string) {
+	bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), text))
+}
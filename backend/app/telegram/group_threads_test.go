@@ -0,0 +1,39 @@
+package telegram
+
+import (
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributeGroupTurns(t *testing.T) {
+	messages := []models.MultimodalMessage{
+		{Role: "system", Content: []models.MultimodalContent{{Type: "text", Text: "instructions"}}},
+		{Role: "user", Name: "Alice", Content: []models.MultimodalContent{{Type: "text", Text: "hi there"}}},
+		{Role: "assistant", Content: []models.MultimodalContent{{Type: "text", Text: "Hi Alice!"}}},
+		{Role: "user", Name: "Bob (@bob)", Content: []models.MultimodalContent{{Type: "image_url"}, {Type: "text", Text: "what is it?"}}},
+	}
+
+	attributed := attributeGroupTurns(messages)
+
+	assert.Equal(t, "instructions", attributed[0].Content[0].Text)
+	assert.Equal(t, "Alice: hi there", attributed[1].Content[0].Text)
+	assert.Equal(t, "", attributed[1].Name)
+	assert.Equal(t, "Hi Alice!", attributed[2].Content[0].Text)
+	assert.Equal(t, "Bob (@bob): what is it?", attributed[3].Content[1].Text)
+
+	// the thread itself is not changed, so it's stored with authors
+	assert.Equal(t, "Alice", messages[1].Name)
+	assert.Equal(t, "hi there", messages[1].Content[0].Text)
+}
+
+func TestIsMemberCommand(t *testing.T) {
+	assert.True(t, isMemberCommand("/mymemory"))
+	assert.True(t, isMemberCommand("/mymemory@gienjibot likes tea"))
+	assert.True(t, isMemberCommand("/summarize@gienjibot 200"))
+	assert.True(t, isMemberCommand("/summarize 6h"))
+	assert.False(t, isMemberCommand("/summarize@gienjibot"))
+	assert.False(t, isMemberCommand("/summarize@gienjibot please"))
+	assert.False(t, isMemberCommand("/groupsettings@gienjibot"))
+}
@@ -0,0 +1,141 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"talk2robots/m/v2/app/ai/images"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// MAX_EDIT_IMAGE_BYTES is the Telegram Bot API download limit
+const MAX_EDIT_IMAGE_BYTES = 20 << 20
+
+// imageEditModes take a reply to a photo with an edit intent as an edit, /edit works in any mode
+var imageEditModes = map[lib.ModeName]bool{
+	lib.ChatGPT:  true,
+	lib.VoiceGPT: true,
+}
+
+// imageEdit refers to Telegram files, image is empty when /edit isn't a reply to a photo
+type imageEdit struct {
+	image  string
+	mask   string
+	prompt string
+}
+
+// isEditCommand goes around command handlers, edits are billed like other messages
+func isEditCommand(text string) bool {
+	return text == string(EditCommand) || strings.HasPrefix(text, string(EditCommand)+" ") || strings.HasPrefix(text, string(EditCommand)+"@")
+}
+
+// getImageEdit finds the photo to edit: the one with the /edit caption, or the one replied to with /edit or an edit intent.
+// A PNG file sent as a reply to a photo is the mask, Telegram keeps transparency of files only
+func getImageEdit(message *telego.Message, mode lib.ModeName) (imageEdit, bool) {
+	text := strings.TrimSpace(message.Text + "\n" + message.Caption)
+	explicit := isEditCommand(text)
+	prompt := text
+	if explicit {
+		prompt = strings.TrimPrefix(text, strings.Fields(text)[0])
+	}
+	prompt = strings.TrimSpace(strings.ReplaceAll(prompt, "@"+BOT.Name, ""))
+
+	own := messageImageFileID(message)
+	replied := ""
+	if message.ReplyToMessage != nil {
+		replied = messageImageFileID(message.ReplyToMessage)
+	}
+	if !explicit && (replied == "" || !imageEditModes[mode] || !IsImageEditIntent(prompt)) {
+		return imageEdit{}, false
+	}
+
+	edit := imageEdit{image: replied, prompt: prompt}
+	switch {
+	case replied != "" && isImageEditMask(message):
+		edit.mask = own
+	case own != "":
+		edit.image = own
+	}
+	return edit, true
+}
+
+// isImageEditMask is a PNG file replying to a photo
+func isImageEditMask(message *telego.Message) bool {
+	return message.Document != nil && message.Document.MimeType == "image/png" &&
+		message.ReplyToMessage != nil && messageImageFileID(message.ReplyToMessage) != ""
+}
+
+// messageImageFileID is the largest size of a photo, the first photo of an album or an image file
+func messageImageFileID(message *telego.Message) string {
+	if photos := messagePhotos(message); len(photos) > 0 {
+		return photos[0].FileID
+	}
+	if message.Document != nil && strings.HasPrefix(message.Document.MimeType, "image/") {
+		return message.Document.FileID
+	}
+	return ""
+}
+
+// editImage redraws the photo with an edit capable engine, the selected image engine if it can edit
+func editImage(ctx context.Context, bot *telego.Bot, message *telego.Message, edit imageEdit) error {
+	chatID := message.Chat.ChatID()
+	chatIDString := util.GetChatIDString(message)
+	request, ok := getImageRequest(ctx, bot, message, edit.prompt)
+	if !ok {
+		return nil
+	}
+	if edit.image == "" || request.Prompt == "" {
+		bot.SendMessage(context.Background(), tu.Message(chatID, lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "image_edit.usage"))).WithMessageThreadID(message.MessageThreadID))
+		return nil
+	}
+
+	editor := images.EditorFor(redis.GetImageModel(chatIDString))
+	config.CONFIG.DataDogClient.Incr("telegram.edit_image_received", []string{"channel_type:" + message.Chat.Type, "model:" + string(editor.Engine()), fmt.Sprintf("mask:%t", edit.mask != "")}, 1)
+	log.Infof("Editing image with %s in a chat %s..", editor.Engine(), chatIDString)
+	sendImageAction(bot, message)
+
+	var err error
+	request.Image, err = getTelegramFileBytes(bot, edit.image)
+	if err == nil && edit.mask != "" {
+		request.Mask, err = getTelegramFileBytes(bot, edit.mask)
+	}
+	if err != nil {
+		log.Errorf("Failed to download image to edit in chat %s: %v", chatIDString, err)
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "image.failed")).WithMessageThreadID(message.MessageThreadID))
+		return err
+	}
+
+	generated, err := images.Edit(ctx, editor, request)
+	if err != nil {
+		return sendImageError(ctx, bot, message, editor.Engine(), err)
+	}
+	sendAndSaveImages(ctx, bot, message, editor.Engine(), editor.PricePerImage(request), request, generated)
+	return nil
+}
+
+// getTelegramFileBytes reads a photo or a file into memory, images are small enough
+func getTelegramFileBytes(bot *telego.Bot, fileID string) ([]byte, error) {
+	file, err := bot.GetFile(context.Background(), &telego.GetFileParams{FileID: fileID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	response, err := http.Get(fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", bot.Token(), file.FilePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file, status: %s", response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, MAX_EDIT_IMAGE_BYTES))
+}
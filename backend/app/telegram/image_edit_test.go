@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"strings"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/mymmrac/telego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetImageEdit(t *testing.T) {
+	setupTestBot()
+	photo := &telego.Message{MessageID: 1, Photo: []telego.PhotoSize{{FileID: "small"}, {FileID: "large"}}}
+
+	// a reply with an edit intent in chat modes only
+	edit, ok := getImageEdit(&telego.Message{Text: "make it a watercolor", ReplyToMessage: photo}, lib.ChatGPT)
+	assert.True(t, ok)
+	assert.Equal(t, imageEdit{image: "large", prompt: "make it a watercolor"}, edit)
+	_, ok = getImageEdit(&telego.Message{Text: "make it a watercolor", ReplyToMessage: photo}, lib.Grammar)
+	assert.False(t, ok)
+	_, ok = getImageEdit(&telego.Message{Text: "what is it?", ReplyToMessage: photo}, lib.ChatGPT)
+	assert.False(t, ok)
+	_, ok = getImageEdit(&telego.Message{Text: "make it shorter"}, lib.ChatGPT)
+	assert.False(t, ok)
+
+	// /edit in any mode, as a reply or a caption
+	edit, ok = getImageEdit(&telego.Message{Text: "/edit@testbot remove the background", ReplyToMessage: photo}, lib.Grammar)
+	assert.True(t, ok)
+	assert.Equal(t, imageEdit{image: "large", prompt: "remove the background"}, edit)
+	edit, ok = getImageEdit(&telego.Message{Caption: "/edit add a hat", Photo: []telego.PhotoSize{{FileID: "own"}}}, lib.ChatGPT)
+	assert.True(t, ok)
+	assert.Equal(t, imageEdit{image: "own", prompt: "add a hat"}, edit)
+	edit, ok = getImageEdit(&telego.Message{Text: "/edit"}, lib.ChatGPT)
+	assert.True(t, ok)
+	assert.Equal(t, imageEdit{}, edit)
+
+	// a PNG file replying to a photo is the mask
+	mask := &telego.Message{Caption: "/edit a red sofa", Document: &telego.Document{FileID: "mask", MimeType: "image/png"}, ReplyToMessage: photo}
+	assert.True(t, isImageEditMask(mask))
+	edit, ok = getImageEdit(mask, lib.Translate)
+	assert.True(t, ok)
+	assert.Equal(t, imageEdit{image: "large", mask: "mask", prompt: "a red sofa"}, edit)
+}
+
+func TestImageCaption(t *testing.T) {
+	caption := imageCaption(models.GptImage1, models.ImageRequest{Prompt: "make it a watercolor", Image: []byte{1}, Mask: []byte{1}}, models.GeneratedImage{})
+	assert.Equal(t, "🎨 GPT Image 1 · edit · mask\nmake it a watercolor", caption)
+
+	caption = imageCaption(models.DallE3, models.ImageRequest{Prompt: "a cat"}, models.GeneratedImage{RevisedPrompt: "A fluffy cat"})
+	assert.Equal(t, "🎨 DALL-E 3\nA fluffy cat", caption)
+
+	options := models.ImageOptions{AspectRatio: "16:9", HD: true, N: 2, Style: "natural", Seed: 42}
+	caption = imageCaption(models.StableDiffusion3, models.ImageRequest{Prompt: "a fox", ImageOptions: options}, models.GeneratedImage{})
+	assert.Equal(t, "🎨 Stable Diffusion 3 · 16:9 · HD · natural · seed 42\na fox", caption)
+
+	caption = imageCaption(models.DallE3, models.ImageRequest{Prompt: strings.Repeat("я", 2000)}, models.GeneratedImage{})
+	assert.Len(t, []rune(caption), MAX_IMAGE_CAPTION)
+}
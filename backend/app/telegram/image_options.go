@@ -0,0 +1,108 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+
+	"github.com/mymmrac/telego"
+	log "github.com/sirupsen/logrus"
+)
+
+// IMAGE_OPTIONS_CALLBACK_PREFIX marks image options keyboard callbacks, e.g. img.ar=16x9:<topic>
+const IMAGE_OPTIONS_CALLBACK_PREFIX = "img."
+
+// GetImageOptionsKeyboard sets the chat's default image options, options in a prompt override them
+func GetImageOptionsKeyboard(ctx context.Context) *telego.InlineKeyboardMarkup {
+	userIdString := ctx.Value(models.UserContext{}).(string)
+	topicString := ctx.Value(models.TopicContext{}).(string)
+	options := lib.GetImageOptions(userIdString)
+	button := func(text string, active bool, data string) telego.InlineKeyboardButton {
+		if active {
+			text = "✅ " + text
+		}
+		return telego.InlineKeyboardButton{Text: text, CallbackData: IMAGE_OPTIONS_CALLBACK_PREFIX + data + ":" + topicString}
+	}
+
+	keyboard := [][]telego.InlineKeyboardButton{}
+	row := []telego.InlineKeyboardButton{}
+	for _, ratio := range lib.AspectRatios {
+		row = append(row, button(ratio, options.AspectRatio == ratio, "ar="+strings.Replace(ratio, ":", "x", 1)))
+		if len(row) == 4 {
+			keyboard = append(keyboard, row)
+			row = []telego.InlineKeyboardButton{}
+		}
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+
+	keyboard = append(keyboard, []telego.InlineKeyboardButton{
+		button(i18n.T(ctx, "image_options.standard"), !options.HD, "hd=off"),
+		button(i18n.T(ctx, "image_options.hd"), options.HD, "hd=on"),
+	})
+	row = []telego.InlineKeyboardButton{}
+	for n := 1; n <= 4; n++ {
+		row = append(row, button(fmt.Sprintf("×%d", n), max(options.N, 1) == n, "n="+strconv.Itoa(n)))
+	}
+	keyboard = append(keyboard, row)
+	keyboard = append(keyboard, []telego.InlineKeyboardButton{
+		button(i18n.T(ctx, "image_options.vivid"), options.Style == "vivid", "style=vivid"),
+		button(i18n.T(ctx, "image_options.natural"), options.Style == "natural", "style=natural"),
+	})
+	keyboard = append(keyboard, []telego.InlineKeyboardButton{
+		{Text: i18n.T(ctx, "button.back"), CallbackData: "images:" + topicString},
+	})
+	return &telego.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
+
+// handleImageOptionsCallbackQuery applies an image options keyboard button, options outside the plan aren't saved
+func handleImageOptionsCallbackQuery(callbackQuery telego.CallbackQuery, topicString string) {
+	chat := callbackQuery.Message.GetChat()
+	chatIDString := fmt.Sprint(chat.ID)
+	_, ctx, cancelContext, err := lib.SetupUserAndContext(chatIDString, lib.TelegramClientName, chatIDString, topicString)
+	if err != nil {
+		log.Errorf("handleImageOptionsCallbackQuery: failed to setup user %s: %v", chatIDString, err)
+		return
+	}
+	defer cancelContext()
+
+	option := strings.SplitN(strings.TrimPrefix(callbackQuery.Data, IMAGE_OPTIONS_CALLBACK_PREFIX), "=", 2)
+	if len(option) != 2 {
+		log.Errorf("handleImageOptionsCallbackQuery: invalid callback %s in chat %s", callbackQuery.Data, chatIDString)
+		return
+	}
+	options := lib.GetImageOptions(chatIDString)
+	previous := options
+	err = lib.ApplyImageOption(&options, option[0], option[1])
+	if err == nil {
+		err = lib.ValidateImageOptions(ctx, options)
+	}
+	if err == nil && options != previous {
+		err = lib.SaveImageOptions(chatIDString, options)
+	}
+	text := i18n.T(ctx, "image_options.updated")
+	if errors.Is(err, lib.ErrImageOptionNotInPlan) {
+		text = i18n.T(ctx, "image.option_upgrade", i18n.Args{"max": lib.MaxImagesPerRequest(ctx)})
+	} else if err != nil {
+		log.Errorf("handleImageOptionsCallbackQuery: failed to apply %s in chat %s: %v", callbackQuery.Data, chatIDString, err)
+		text = i18n.T(ctx, "oopsie")
+	}
+	BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{CallbackQueryID: callbackQuery.ID, Text: text, ShowAlert: errors.Is(err, lib.ErrImageOptionNotInPlan)})
+	if err != nil || options == previous {
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.image_options_changed", []string{"option:" + option[0]}, 1)
+
+	BOT.EditMessageReplyMarkup(ctx, &telego.EditMessageReplyMarkupParams{
+		ChatID:      chat.ChatID(),
+		MessageID:   callbackQuery.Message.GetMessageID(),
+		ReplyMarkup: GetImageOptionsKeyboard(ctx),
+	})
+}
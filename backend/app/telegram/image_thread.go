@@ -0,0 +1,150 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"talk2robots/m/v2/app/ai"
+	"talk2robots/m/v2/app/ai/images"
+	"talk2robots/m/v2/app/ai/openai"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/mymmrac/telego"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	IMAGE_PROMPT_MODEL      = models.ChatGpt4oMini
+	MAX_IMAGE_PROMPT_TOKENS = 300
+)
+
+const IMAGE_PROMPT_INSTRUCTIONS = `You turn follow-up image requests into standalone prompts for an image generator that doesn't see the conversation.
+The previous image was drawn by the prompt the user gives first. If the request changes or continues that image, reply with one full prompt describing the whole new image, keeping everything the request doesn't change.
+If the request is about something unrelated, reply with the request rewritten as a prompt. Keep the language of the request.
+Reply with the prompt only, without quotes or explanations.`
+
+// isImageFollowUp is a change request to the image drawn right before, e.g. "now make it blue",
+// any other interaction in between starts over
+func isImageFollowUp(message *telego.Message, mode lib.ModeName) bool {
+	if !imageEditModes[mode] || message.Text == "" || len(message.Photo) > 0 || !IsImageEditIntent(message.Text) {
+		return false
+	}
+	interaction := getLastInteraction(util.GetChatIDString(message), fmt.Sprintf("%d", message.MessageThreadID))
+	return interaction != nil && interaction.ImagePrompt != ""
+}
+
+// getStandaloneImagePrompt rewrites a request into a full prompt when an image was drawn right before,
+// the request is used as is when there is nothing to build on or rewriting fails
+func getStandaloneImagePrompt(ctx context.Context, message *telego.Message, prompt string) string {
+	interaction := getLastInteraction(util.GetChatIDString(message), fmt.Sprintf("%d", message.MessageThreadID))
+	if interaction == nil || interaction.ImagePrompt == "" || strings.TrimSpace(prompt) == "" {
+		return prompt
+	}
+	rewritten, err := BOT.API.ChatComplete(ctx, models.ChatCompletion{
+		Model: string(IMAGE_PROMPT_MODEL),
+		Messages: []models.Message{
+			{Role: "system", Content: IMAGE_PROMPT_INSTRUCTIONS},
+			{Role: "user", Content: "Previous prompt: " + interaction.ImagePrompt + "\n\nRequest: " + prompt},
+		},
+		MaxTokens: MAX_IMAGE_PROMPT_TOKENS,
+	})
+	rewritten = strings.Trim(strings.TrimSpace(rewritten), "\"")
+	if err != nil || rewritten == "" {
+		log.Warnf("Failed to rewrite image prompt in chat %s, drawing the request: %v", util.GetChatIDString(message), err)
+		return prompt
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.image_prompt_rewritten", []string{"channel_type:" + message.Chat.Type}, 1)
+	log.Debugf("Rewrote image prompt in chat %s: %s", util.GetChatIDString(message), rewritten)
+	return rewritten
+}
+
+// rememberImageTurn keeps the drawn image as the last interaction for follow-ups and,
+// in modes with memory, adds the request and the image to the chat thread, so the model knows what it drew
+func rememberImageTurn(ctx context.Context, message *telego.Message, engine models.Engine, request models.ImageRequest, generated []models.GeneratedImage, saved []*models.MongoImage) {
+	chatIDString := util.GetChatIDString(message)
+	topicID := util.GetTopicID(message)
+	mode, _ := lib.GetMode(chatIDString, topicID)
+	userText := strings.TrimSpace(message.Text)
+	if userText == "" {
+		userText = request.Prompt
+	}
+	assistantText := imageTurnText(engine, request, generated, saved)
+
+	interaction := &lastInteraction{Prompt: userText, Answer: assistantText, Mode: string(mode), Engine: string(engine)}
+	interaction.RequestId, _ = ctx.Value(models.RequestContext{}).(string)
+	if len(request.Image) == 0 {
+		interaction.ImagePrompt = request.Prompt
+	}
+	saveLastInteraction(chatIDString, fmt.Sprintf("%d", message.MessageThreadID), interaction)
+
+	if mode != lib.ChatGPT && mode != lib.VoiceGPT {
+		return
+	}
+	author := getThreadAuthor(message)
+	chatEngine := redis.GetModel(chatIDString)
+	if ai.IsFireworksAI(chatEngine) || ai.IsClaudeAI(chatEngine) || ai.IsGrok(chatEngine) {
+		threadInfo := getUserInfo(message)
+		if author != "" {
+			threadInfo = getGroupInfo(message)
+		}
+		for _, turn := range []*models.MultimodalMessage{
+			{Role: "user", Content: []models.MultimodalContent{{Type: "text", Text: userText}}, Name: author},
+			{Role: "assistant", Content: []models.MultimodalContent{{Type: "text", Text: assistantText}}},
+		} {
+			err := mongo.MongoDBClient.AddToUserThread(ctx, nil, turn, threadInfo)
+			if err != nil {
+				log.Errorf("Failed to add image turn to local thread in chat %s: %v", chatIDString, err)
+				return
+			}
+		}
+		return
+	}
+
+	turns := []models.Message{
+		{Role: "user", Content: attributeText(author, userText)},
+		{Role: "assistant", Content: assistantText},
+	}
+	threadId, _ := redis.RedisClient.Get(ctx, lib.UserCurrentThreadKey(chatIDString, topicID)).Result()
+	if threadId == "" {
+		thread, err := openai.CreateThread(ctx, &models.Thread{Messages: turns})
+		if err != nil {
+			log.Errorf("Failed to create thread with image turn in chat %s: %v", chatIDString, err)
+			return
+		}
+		redis.RedisClient.Set(ctx, lib.UserCurrentThreadKey(chatIDString, topicID), thread.ID, 0)
+		return
+	}
+	for _, turn := range turns {
+		_, err := openai.CreateThreadMessage(ctx, threadId, &turn)
+		if err != nil {
+			log.Errorf("Failed to add image turn to thread %s in chat %s: %v", threadId, chatIDString, err)
+			return
+		}
+	}
+}
+
+// imageTurnText describes drawn images for the chat model: the engine, the prompt they were drawn by and gallery ids
+func imageTurnText(engine models.Engine, request models.ImageRequest, generated []models.GeneratedImage, saved []*models.MongoImage) string {
+	action := "Drew"
+	if len(request.Image) > 0 {
+		action = "Edited the user's photo into"
+	}
+	text := fmt.Sprintf("[%s %d image(s) with %s", action, len(generated), images.EngineName(engine))
+	if len(saved) > 0 {
+		ids := []string{}
+		for _, image := range saved {
+			ids = append(ids, image.ID)
+		}
+		text += ", saved in /images as " + strings.Join(ids, ", ")
+	}
+	prompt := request.Prompt
+	if len(generated) > 0 && generated[0].RevisedPrompt != "" {
+		prompt = generated[0].RevisedPrompt
+	}
+	return text + "]\n" + prompt
+}
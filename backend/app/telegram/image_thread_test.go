@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/mymmrac/telego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsImageFollowUp(t *testing.T) {
+	message := &telego.Message{Chat: telego.Chat{ID: 4601, Type: telego.ChatTypePrivate}, Text: "now make it blue"}
+	assert.False(t, isImageFollowUp(message, lib.ChatGPT), "nothing was drawn")
+
+	saveLastInteraction("4601", "0", &lastInteraction{Prompt: "draw a cat on a bike", ImagePrompt: "a cat on a bike"})
+	assert.True(t, isImageFollowUp(message, lib.ChatGPT))
+	assert.True(t, isImageFollowUp(message, lib.VoiceGPT))
+	assert.False(t, isImageFollowUp(message, lib.Grammar), "modes without memory don't follow up")
+	assert.False(t, isImageFollowUp(&telego.Message{Chat: message.Chat, Text: "what is it riding?"}, lib.ChatGPT))
+
+	// any other answer in between starts over
+	saveLastInteraction("4601", "0", &lastInteraction{Prompt: "tell me about cats", Answer: "Cats are..."})
+	assert.False(t, isImageFollowUp(message, lib.ChatGPT))
+}
+
+func TestImageTurnText(t *testing.T) {
+	request := models.ImageRequest{Prompt: "a cat on a bike"}
+	text := imageTurnText(models.DallE3, request, []models.GeneratedImage{{RevisedPrompt: "A ginger cat riding a red bicycle"}}, []*models.MongoImage{{ID: "abc"}})
+	assert.Equal(t, "[Drew 1 image(s) with DALL-E 3, saved in /images as abc]\nA ginger cat riding a red bicycle", text)
+
+	request = models.ImageRequest{Prompt: "make it a watercolor", Image: []byte{1}}
+	text = imageTurnText(models.GptImage1, request, []models.GeneratedImage{{}, {}}, nil)
+	assert.Equal(t, "[Edited the user's photo into 2 image(s) with GPT Image 1]\nmake it a watercolor", text)
+}
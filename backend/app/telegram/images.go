@@ -0,0 +1,154 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"talk2robots/m/v2/app/ai/images"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// MAX_IMAGE_CAPTION is below Telegram's 1024 characters caption limit
+const MAX_IMAGE_CAPTION = 1000
+
+// generateImage draws the message text with the image engine selected in the chat
+func generateImage(ctx context.Context, bot *telego.Bot, message *telego.Message) error {
+	chatIDString := util.GetChatIDString(message)
+	provider := images.ProviderFor(redis.GetImageModel(chatIDString))
+	config.CONFIG.DataDogClient.Incr("telegram.create_image_received", []string{"channel_type:" + message.Chat.Type, "model:" + string(provider.Engine())}, 1)
+	log.Infof("Generating image with %s in a chat %s..", provider.Engine(), chatIDString)
+	sendImageAction(bot, message)
+
+	request, ok := getImageRequest(ctx, bot, message, message.Text)
+	if !ok {
+		return nil
+	}
+	request.Prompt = getStandaloneImagePrompt(ctx, message, request.Prompt)
+	generated, err := images.Generate(ctx, provider, request)
+	if err != nil {
+		return sendImageError(ctx, bot, message, provider.Engine(), err)
+	}
+	sendAndSaveImages(ctx, bot, message, provider.Engine(), provider.PricePerImage(request), request, generated)
+	return nil
+}
+
+// getImageRequest takes options out of the prompt on top of the chat's image options and checks them against the plan
+func getImageRequest(ctx context.Context, bot *telego.Bot, message *telego.Message, text string) (models.ImageRequest, bool) {
+	reply := func(text string) {
+		bot.SendMessage(context.Background(), tu.Message(message.Chat.ChatID(), text).WithMessageThreadID(message.MessageThreadID))
+	}
+	prompt, options, err := lib.ParseImageOptions(text, lib.GetImageOptions(util.GetChatIDString(message)))
+	if err != nil {
+		reply(i18n.T(ctx, "image.invalid_option", i18n.Args{"error": err.Error()}))
+		return models.ImageRequest{}, false
+	}
+	if err := lib.ValidateImageOptions(ctx, options); err != nil {
+		config.CONFIG.DataDogClient.Incr("telegram.image.option_not_in_plan", []string{"channel_type:" + message.Chat.Type}, 1)
+		reply(lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "image.option_upgrade", i18n.Args{"max": lib.MaxImagesPerRequest(ctx)})))
+		return models.ImageRequest{}, false
+	}
+	if options.N > 1 {
+		config.CONFIG.DataDogClient.Count("telegram.image.count", int64(options.N), []string{"channel_type:" + message.Chat.Type}, 1)
+	}
+	return models.ImageRequest{Prompt: prompt, ImageOptions: options}, true
+}
+
+func sendImageError(ctx context.Context, bot *telego.Bot, message *telego.Message, engine models.Engine, err error) error {
+	chatIDString := util.GetChatIDString(message)
+	if errors.Is(err, models.ErrImageContentPolicy) {
+		log.Warnf("Content policy violation in chat %s", chatIDString)
+		config.CONFIG.DataDogClient.Incr("telegram.image.content_policy_violation", []string{"client:telegram", "channel_type:" + message.Chat.Type, "model:" + string(engine)}, 1)
+		bot.SendMessage(context.Background(), tu.Message(message.Chat.ChatID(), i18n.T(ctx, "image.content_policy")).WithMessageThreadID(message.MessageThreadID))
+		return err
+	}
+	log.Errorf("Error creating image with %s in chat %s: %v", engine, chatIDString, err)
+	bot.SendMessage(context.Background(), tu.Message(message.Chat.ChatID(), i18n.T(ctx, "image.failed")).WithMessageThreadID(message.MessageThreadID))
+	return err
+}
+
+// sendGeneratedImages sends several images as an album, captioned once
+func sendGeneratedImages(bot *telego.Bot, message *telego.Message, engine models.Engine, request models.ImageRequest, generated []models.GeneratedImage) {
+	chatIDString := util.GetChatIDString(message)
+	log.Infof("Sending %d images to chat %s", len(generated), chatIDString)
+	if len(generated) == 1 {
+		_, err := bot.SendPhoto(context.Background(), &telego.SendPhotoParams{
+			ChatID:          message.Chat.ChatID(),
+			Photo:           imageInputFile(generated[0]),
+			Caption:         imageCaption(engine, request, generated[0]),
+			MessageThreadID: message.MessageThreadID,
+		})
+		if err != nil {
+			log.Errorf("Error sending image to chat %s: %v", chatIDString, err)
+		}
+		return
+	}
+
+	media := []telego.InputMedia{}
+	for i, image := range generated {
+		photo := tu.MediaPhoto(imageInputFile(image))
+		if i == 0 {
+			photo = photo.WithCaption(imageCaption(engine, request, image))
+		}
+		media = append(media, photo)
+	}
+	_, err := bot.SendMediaGroup(context.Background(), tu.MediaGroup(message.Chat.ChatID(), media...).WithMessageThreadID(message.MessageThreadID))
+	if err != nil {
+		log.Errorf("Error sending %d images to chat %s: %v", len(generated), chatIDString, err)
+	}
+}
+
+// imageCaption lists the generation parameters, then the prompt the image was drawn by
+func imageCaption(engine models.Engine, request models.ImageRequest, image models.GeneratedImage) string {
+	params := []string{images.EngineName(engine)}
+	if request.AspectRatio != "" && request.AspectRatio != lib.DEFAULT_ASPECT_RATIO {
+		params = append(params, request.AspectRatio)
+	}
+	if request.HD {
+		params = append(params, "HD")
+	}
+	if request.Style != "" {
+		params = append(params, request.Style)
+	}
+	if request.Seed > 0 {
+		params = append(params, fmt.Sprintf("seed %d", request.Seed))
+	}
+	if len(request.Image) > 0 {
+		params = append(params, "edit")
+	}
+	if len(request.Mask) > 0 {
+		params = append(params, "mask")
+	}
+	prompt := image.RevisedPrompt
+	if prompt == "" {
+		prompt = request.Prompt
+	}
+	caption := []rune("🎨 " + strings.Join(params, " · ") + "\n" + strings.TrimSpace(prompt))
+	if len(caption) > MAX_IMAGE_CAPTION {
+		return string(caption[:MAX_IMAGE_CAPTION-3]) + "..."
+	}
+	return string(caption)
+}
+
+// imageInputFile uploads downloaded and inline images and sends the rest by URL
+func imageInputFile(image models.GeneratedImage) telego.InputFile {
+	if len(image.Data) == 0 {
+		return telego.InputFile{URL: image.URL}
+	}
+	name := "image.jpg"
+	if http.DetectContentType(image.Data) == "image/png" {
+		name = "image.png"
+	}
+	return telego.InputFile{File: NamedReader{Reader: bytes.NewReader(image.Data), name: name}}
+}
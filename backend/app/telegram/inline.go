@@ -0,0 +1,291 @@
+package telegram
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+	"time"
+
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	INLINE_DEBOUNCE       = 800 * time.Millisecond
+	INLINE_CACHE_TTL      = 24 * time.Hour
+	INLINE_PENDING_TTL    = time.Minute
+	INLINE_PENDING_WAIT   = 30 * time.Second
+	INLINE_THUMBNAIL_URL  = "https://gienji.me/assets/images/image01.jpg"
+	INLINE_NO_TRANSLATION = "[no translation required]"
+)
+
+// inlineVariant is one of the results offered for an inline query
+type inlineVariant struct {
+	ID        string
+	Title     string // message id in the i18n catalog
+	Mode      lib.ModeName
+	System    string
+	MaxTokens int
+}
+
+var inlineVariants = []inlineVariant{
+	{
+		ID:        "short",
+		Title:     "inline.short",
+		Mode:      lib.ChatGPT,
+		System:    config.AI_INSTRUCTIONS + "\n\nAnswer in one or two sentences, no follow up questions.",
+		MaxTokens: 256,
+	},
+	{
+		ID:        "detailed",
+		Title:     "inline.detailed",
+		Mode:      lib.ChatGPT,
+		System:    config.AI_INSTRUCTIONS,
+		MaxTokens: 1024,
+	},
+	{
+		ID:        "translate",
+		Title:     "inline.translate",
+		Mode:      lib.Translate,
+		MaxTokens: 1024,
+	},
+	{
+		ID:        "grammar",
+		Title:     "inline.grammar",
+		Mode:      lib.Grammar,
+		MaxTokens: 1024,
+	},
+}
+
+func handleInlineQuery(bhctx *th.Context, inlineQuery telego.InlineQuery) error {
+	bot := bhctx.Bot()
+	chatID := inlineQuery.From.ID
+	chatIDString := fmt.Sprint(chatID)
+	user, ctx, cancelContext, err := lib.SetupUserAndContext(chatIDString, "telegram", chatIDString, "")
+	if err != nil {
+		log.Errorf("Failed to setup user and context for inline query %d: %v", chatID, err)
+		return err
+	}
+	defer cancelContext()
+	ctx = withUserLanguage(ctx, user, &inlineQuery.From)
+	if inlineQuery.Query == "" {
+		inlineQuery.Query = "What can you do?"
+	}
+	log.Infof("Inline query from ID: %d, query size: %d", inlineQuery.From.ID, len(inlineQuery.Query))
+
+	ok, subscription := lib.ValidateUserUsage(ctx)
+	if !ok {
+		config.CONFIG.DataDogClient.Incr("telegram.usage_exceeded", []string{"client:telegram", "channel_type:inline", "subscription:" + string(subscription)}, 1)
+		return answerInlineQuery(bot, inlineQuery.ID, []telego.InlineQueryResult{getInlineUpgradeArticle(ctx)})
+	}
+
+	// telegram sends a query on every keystroke, so only answer the latest one
+	if isInlineQueryDebounced(chatIDString, inlineQuery.ID) {
+		config.CONFIG.DataDogClient.Incr("telegram.inline_message_debounced", []string{"channel_type:" + inlineQuery.ChatType}, 1)
+		return nil
+	}
+
+	config.CONFIG.DataDogClient.Incr("telegram.inline_message_received", []string{"channel_type:" + inlineQuery.ChatType}, 1)
+
+	engineModel := redis.GetModel(chatIDString)
+	responses, err := getInlineResponses(ctx, chatIDString, engineModel, inlineQuery.Query)
+	if err != nil {
+		log.Errorf("Failed to get completion for inline query: %v", err)
+		return err
+	}
+
+	results := []telego.InlineQueryResult{}
+	for _, variant := range inlineVariants {
+		response, exists := responses[variant.ID]
+		if !exists || response == "" {
+			continue
+		}
+		results = append(results, &telego.InlineQueryResultArticle{
+			Type:         "article",
+			ID:           variant.ID,
+			Title:        i18n.T(ctx, variant.Title),
+			URL:          config.CONFIG.BotUrl,
+			ThumbnailURL: INLINE_THUMBNAIL_URL,
+			Description:  response,
+			InputMessageContent: &telego.InputTextMessageContent{
+				MessageText: util.MarkdownToTelegramHTML(response),
+				ParseMode:   "HTML",
+			},
+		})
+	}
+	if len(results) == 0 {
+		log.Warnf("No inline results for %d", chatID)
+		return nil
+	}
+
+	err = answerInlineQuery(bot, inlineQuery.ID, results)
+	if err != nil {
+		log.Errorf("Failed to answer %d inline query: %v", chatID, err)
+	}
+	return err
+}
+
+func answerInlineQuery(bot *telego.Bot, inlineQueryID string, results []telego.InlineQueryResult) error {
+	params := &telego.AnswerInlineQueryParams{
+		InlineQueryID: inlineQueryID,
+		CacheTime:     60 * 5, // 5 minutes, answers are cached per user in redis
+		IsPersonal:    true,
+		Results:       results,
+	}
+	err := bot.AnswerInlineQuery(context.Background(), params)
+
+	// retry w/o parse mode if failed
+	if err != nil && strings.Contains(err.Error(), "can't parse entities") {
+		for _, result := range params.Results {
+			if article, ok := result.(*telego.InlineQueryResultArticle); ok {
+				if content, ok := article.InputMessageContent.(*telego.InputTextMessageContent); ok {
+					content.ParseMode = ""
+				}
+			}
+		}
+		err = bot.AnswerInlineQuery(context.Background(), params)
+	}
+	return err
+}
+
+func getInlineUpgradeArticle(ctx context.Context) *telego.InlineQueryResultArticle {
+	return &telego.InlineQueryResultArticle{
+		Type:         "article",
+		ID:           "upgrade",
+		Title:        i18n.T(ctx, "inline.limit_title"),
+		URL:          config.CONFIG.BotUrl,
+		ThumbnailURL: INLINE_THUMBNAIL_URL,
+		Description:  i18n.T(ctx, "inline.limit_description"),
+		InputMessageContent: &telego.InputTextMessageContent{
+			MessageText: i18n.T(ctx, "inline.limit_message", i18n.Args{"bot": config.CONFIG.BotName}),
+		},
+	}
+}
+
+// isInlineQueryDebounced waits a bit and returns true if a newer query has arrived from the same user
+func isInlineQueryDebounced(chatIDString string, inlineQueryID string) bool {
+	latestKey := lib.InlineQueryLatestKey(chatIDString)
+	redis.RedisClient.Set(context.Background(), latestKey, inlineQueryID, INLINE_PENDING_TTL)
+	time.Sleep(INLINE_DEBOUNCE)
+	latest, err := redis.RedisClient.Get(context.Background(), latestKey).Result()
+	return err == nil && latest != inlineQueryID
+}
+
+// getInlineResponses returns variants from the per user cache, waits for in-progress identical query or generates new ones
+func getInlineResponses(ctx context.Context, chatIDString string, engineModel models.Engine, query string) (map[string]string, error) {
+	queryHashBytes := sha1.Sum([]byte(query))
+	queryHash := hex.EncodeToString(queryHashBytes[:])
+	cacheKey := lib.InlineQueryCacheKey(chatIDString, string(engineModel), queryHash)
+	pendingKey := lib.InlineQueryPendingKey(chatIDString, queryHash)
+
+	if responses := getCachedInlineResponses(cacheKey); responses != nil {
+		config.CONFIG.DataDogClient.Incr("telegram.inline_cache_hit", nil, 1)
+		return responses, nil
+	}
+
+	// the first query claims the generation, identical ones wait for its results
+	claimed, err := redis.RedisClient.SetNX(context.Background(), pendingKey, "true", INLINE_PENDING_TTL).Result()
+	if err != nil {
+		log.Errorf("Failed to claim inline query in chat %s: %v", chatIDString, err)
+		claimed = true
+	}
+	if !claimed {
+		deadline := time.Now().Add(INLINE_PENDING_WAIT)
+		for time.Now().Before(deadline) {
+			time.Sleep(500 * time.Millisecond)
+			if responses := getCachedInlineResponses(cacheKey); responses != nil {
+				return responses, nil
+			}
+		}
+		return nil, fmt.Errorf("timed out waiting for in-progress inline query in chat %s", chatIDString)
+	}
+	defer redis.RedisClient.Del(context.Background(), pendingKey)
+
+	responses := map[string]string{}
+	var responsesMutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, variant := range inlineVariants {
+		wg.Add(1)
+		go func(variant inlineVariant) {
+			defer wg.Done()
+			response, err := getInlineVariantResponse(ctx, engineModel, variant, query)
+			if err != nil {
+				log.Errorf("Failed to get %s inline response in chat %s: %v", variant.ID, chatIDString, err)
+				return
+			}
+			responsesMutex.Lock()
+			responses[variant.ID] = response
+			responsesMutex.Unlock()
+		}(variant)
+	}
+	wg.Wait()
+
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("no inline responses in chat %s", chatIDString)
+	}
+
+	responsesBytes, err := json.Marshal(responses)
+	if err == nil {
+		redis.RedisClient.Set(context.Background(), cacheKey, string(responsesBytes), INLINE_CACHE_TTL)
+	}
+	return responses, nil
+}
+
+func getCachedInlineResponses(cacheKey string) map[string]string {
+	cached, err := redis.RedisClient.Get(context.Background(), cacheKey).Result()
+	if err != nil || cached == "" {
+		return nil
+	}
+	var responses map[string]string
+	err = json.Unmarshal([]byte(cached), &responses)
+	if err != nil {
+		return nil
+	}
+	return responses
+}
+
+func getInlineVariantResponse(ctx context.Context, engineModel models.Engine, variant inlineVariant, query string) (string, error) {
+	messages := []models.Message{}
+	userMessagePrimer := ""
+	if variant.System != "" {
+		messages = append(messages, models.Message{Role: "system", Content: variant.System})
+	} else {
+		var seedData []models.Message
+		seedData, userMessagePrimer = lib.GetSeedDataAndPrimer(variant.Mode)
+		messages = append(messages, seedData...)
+	}
+	messages = append(messages, models.Message{Role: "user", Content: userMessagePrimer + query})
+
+	response, err := BOT.API.ChatComplete(ctx, models.ChatCompletion{
+		Model:     string(engineModel),
+		Messages:  messages,
+		MaxTokens: variant.MaxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch variant.Mode {
+	case lib.Translate:
+		if strings.Contains(response, INLINE_NO_TRANSLATION) {
+			return "", nil
+		}
+	case lib.Grammar:
+		response = postprocessMessage(response, variant.Mode, userMessagePrimer)
+		if strings.TrimSpace(response) == "✅" {
+			response = query + " ✅"
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,96 @@
+package telegram
+
+import (
+	"context"
+	"talk2robots/m/v2/app/ai/intent"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+
+	"github.com/mymmrac/telego"
+	log "github.com/sirupsen/logrus"
+)
+
+// SourceFollowUp marks changes to the image drawn right before, e.g. "now make it blue"
+const SourceFollowUp intent.Source = "follow_up"
+
+var INTENT_ROUTER = intent.NewRouter(func(ctx context.Context, completion models.ChatCompletion) (string, error) {
+	return BOT.API.ChatComplete(ctx, completion)
+})
+
+// routedIntents leave a mode for a single message: /chatgpt routes to any intent, /voicegpt keeps voice replies
+// for everything but images and searches, other modes only draw. Intents that aren't routed come back as a chat
+var routedIntents = map[lib.ModeName][]intent.Intent{
+	lib.ChatGPT:  {intent.Image, intent.Search, intent.Translate, intent.Summarize},
+	lib.VoiceGPT: {intent.Image, intent.Search},
+}
+
+// intentModes are the modes routed intents switch to for a single message
+var intentModes = map[intent.Intent]lib.ModeName{
+	intent.Search:    lib.Search,
+	intent.Translate: lib.Translate,
+	intent.Summarize: lib.Summarize,
+}
+
+// groupIntents drops intents switching to modes the group doesn't allow, images follow the group image policy instead
+func groupIntents(settings models.GroupSettings, intents []intent.Intent) []intent.Intent {
+	allowed := []intent.Intent{}
+	for _, candidate := range intents {
+		if mode, ok := intentModes[candidate]; ok && !lib.IsGroupModeAllowed(settings, mode) {
+			continue
+		}
+		allowed = append(allowed, candidate)
+	}
+	return allowed
+}
+
+// routeIntent classifies text messages, chat modes ask the intent router, photos, documents and other modes
+// rely on the heuristic only so that they don't pay for a model call. Image follow-ups skip classification,
+// group settings limit where a message can be routed, private chats pass the defaults
+func routeIntent(ctx context.Context, message *telego.Message, mode lib.ModeName, settings models.GroupSettings) intent.Result {
+	chat := intent.Result{Intent: intent.Chat, Confidence: 1}
+	if message.Text == "" {
+		return chat
+	}
+	if isImageFollowUp(message, mode) {
+		return intent.Result{Intent: intent.Image, Confidence: 1, Source: SourceFollowUp}
+	}
+	allowed, chatMode := routedIntents[mode]
+	if !chatMode {
+		allowed = []intent.Intent{intent.Image}
+	}
+	allowed = groupIntents(settings, allowed)
+
+	var result intent.Result
+	if chatMode && len(message.Photo) == 0 && message.Document == nil {
+		result = INTENT_ROUTER.Route(ctx, message.Text)
+	} else {
+		result = intent.Heuristic(message.Text)
+	}
+	if result.Intent == intent.Chat {
+		return chat
+	}
+
+	tags := []string{"intent:" + string(result.Intent), "source:" + string(result.Source), "mode:" + string(mode), "channel_type:" + message.Chat.Type}
+	if !isIntentAllowed(allowed, result.Intent) {
+		return chat
+	}
+	if !result.Routed() {
+		config.CONFIG.DataDogClient.Incr("telegram.intent_below_threshold", tags, 1)
+		log.Debugf("Intent %s with confidence %.2f is below threshold in mode %s", result.Intent, result.Confidence, mode)
+		return chat
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.intent_routed", tags, 1)
+	config.CONFIG.DataDogClient.Distribution("telegram.intent_confidence", result.Confidence, tags, 1)
+	log.Infof("Routing a message in mode %s to %s (%s, confidence %.2f)", mode, result.Intent, result.Source, result.Confidence)
+	return result
+}
+
+func isIntentAllowed(allowed []intent.Intent, target intent.Intent) bool {
+	for _, candidate := range allowed {
+		if candidate == target {
+			return true
+		}
+	}
+	return false
+}
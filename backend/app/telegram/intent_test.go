@@ -0,0 +1,24 @@
+package telegram
+
+import (
+	"talk2robots/m/v2/app/ai/intent"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupIntents(t *testing.T) {
+	intents := routedIntents[lib.ChatGPT]
+	assert.Equal(t, intents, groupIntents(lib.DefaultGroupSettings(), intents))
+
+	settings := lib.DefaultGroupSettings()
+	settings.AllowedModes = []string{string(lib.ChatGPT), string(lib.Summarize)}
+	assert.Equal(t, []intent.Intent{intent.Image, intent.Summarize}, groupIntents(settings, intents))
+
+	// images are up to the image policy, not allowed modes
+	settings.ImagesAllowed = false
+	assert.Equal(t, []intent.Intent{intent.Image}, groupIntents(settings, []intent.Intent{intent.Image, intent.Search}))
+	assert.Empty(t, groupIntents(models.GroupSettings{AllowedModes: []string{string(lib.Grammar)}}, []intent.Intent{intent.Translate}))
+}
@@ -0,0 +1,163 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	INTERPRET_MODEL          = models.ChatGpt4oMini
+	MAX_INTERPRET_TOKENS     = 2000
+	DEFAULT_INTERPRET_TARGET = "en"
+
+	// INTERPRET_PAIR_SEPARATOR joins two-party languages in mode params, e.g. "en-es"
+	INTERPRET_PAIR_SEPARATOR = "-"
+
+	// MAX_CAPTION is Telegram's limit for media captions, longer interpretations come as a text and a voice message
+	MAX_CAPTION = 1024
+)
+
+const INTERPRET_INSTRUCTIONS = `You are an interpreter. Translate the user's speech transcript into %s.
+Keep the meaning, tone and register of the speaker, the translation is read aloud, so write numbers, units and abbreviations the way they are spoken.
+Reply with the translation only, without quotes, notes or the original text.`
+
+const INTERPRET_TWO_PARTY_INSTRUCTIONS = `You are an interpreter between two people, one speaks %s and the other speaks %s.
+Detect which of the two languages the user's speech transcript is in and translate it into the other one. If the transcript is in neither, translate it into %s.
+Keep the meaning, tone and register of the speaker, the translation is read aloud, so write numbers, units and abbreviations the way they are spoken.
+Reply with the translation only, without quotes, notes or the original text.`
+
+// INTERPRET_TRANSCRIPTION_PROMPT tells whisper which languages to expect in a two-party conversation
+const INTERPRET_TRANSCRIPTION_PROMPT = "A conversation in %s and %s."
+
+// interpretCommandHandler switches to speech-to-speech translation:
+// /interpret es speaks everything in Spanish, /interpret en es translates between English and Spanish both ways
+func interpretCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	chatID := util.GetChatID(message)
+	reply := func(text string) {
+		text = lib.AddBotSuffixToGroupCommands(ctx, text)
+		bot.SendMessage(context.Background(), tu.Message(chatID, text).WithMessageThreadID(message.MessageThreadID))
+	}
+	if message.Chat.Type != telego.ChatTypePrivate && !lib.IsGroupModeAllowed(lib.GetGroupSettings(util.GetChatIDString(message)), lib.Interpret) {
+		reply(i18n.T(ctx, "mode.disabled_in_group", i18n.Args{"mode": lib.Interpret}))
+		return
+	}
+
+	languages := []string{}
+	for _, param := range strings.Fields(message.Text)[1:] {
+		language := validateParams(lib.Interpret, param)
+		if language == "" {
+			reply(i18n.T(ctx, "interpret.usage"))
+			return
+		}
+		if len(languages) == 0 || languages[0] != language {
+			languages = append(languages, language)
+		}
+	}
+	if len(languages) > 2 {
+		reply(i18n.T(ctx, "interpret.usage"))
+		return
+	}
+	if len(languages) == 0 {
+		languages = append(languages, DEFAULT_INTERPRET_TARGET)
+	}
+
+	if len(languages) == 2 {
+		reply(i18n.T(ctx, "mode.interpret_two_party", i18n.Args{
+			"first":  getLanguageDisplayName(ctx, languages[0]),
+			"second": getLanguageDisplayName(ctx, languages[1]),
+		}))
+	} else {
+		reply(i18n.T(ctx, "mode.interpret", i18n.Args{"language": getLanguageDisplayName(ctx, languages[0])}))
+	}
+	lib.SaveMode(util.GetChatIDString(message), util.GetTopicID(message), lib.Interpret, strings.Join(languages, INTERPRET_PAIR_SEPARATOR))
+}
+
+// parseInterpretLanguages reads mode params, first is empty unless two parties talk
+func parseInterpretLanguages(params string) (first string, second string) {
+	first, second, found := strings.Cut(params, INTERPRET_PAIR_SEPARATOR)
+	if !found {
+		second = first
+		first = ""
+	}
+	if second == "" {
+		second = DEFAULT_INTERPRET_TARGET
+	}
+	return first, second
+}
+
+// interpretTranscriptionPrompt hints whisper with the two languages people talk in, single target interpretation takes any language
+func interpretTranscriptionPrompt(params string) string {
+	first, second := parseInterpretLanguages(params)
+	if first == "" {
+		return ""
+	}
+	return fmt.Sprintf(INTERPRET_TRANSCRIPTION_PROMPT, getLanguageName(first), getLanguageName(second))
+}
+
+// interpretMessage translates a transcript (or a text) and speaks the translation, both texts go to the caption
+func interpretMessage(ctx context.Context, bot *telego.Bot, message *telego.Message, params string) {
+	chatIDString := util.GetChatIDString(message)
+	text := strings.TrimSpace(message.Text)
+	if text == "" {
+		return
+	}
+	sendAudioAction(bot, message)
+	first, second := parseInterpretLanguages(params)
+	translation, err := getInterpretation(ctx, text, first, second)
+	if err != nil {
+		log.Errorf("Failed to interpret message in chat %s: %v", chatIDString, err)
+		bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.interpreted", []string{"channel_type:" + message.Chat.Type, fmt.Sprintf("two_party:%t", first != "")}, 1)
+
+	// captions quote what people said, so they are escaped rather than rendered as Markdown
+	caption := interpretCaption(text, translation)
+	if len([]rune(caption)) > MAX_CAPTION {
+		ChunkSendText(bot, message, caption)
+		ChunkSendVoice(ctx, bot, message, translation, false)
+		return
+	}
+	_, err = sendSpeech(ctx, bot, message, translation, util.MarkdownChunk{Markdown: caption, HTML: util.EscapeHTML(caption)})
+	if err != nil {
+		log.Errorf("Failed to send interpretation in chat %s: %v", chatIDString, err)
+		ChunkSendText(bot, message, caption)
+	}
+}
+
+func getInterpretation(ctx context.Context, text string, first string, second string) (string, error) {
+	instructions := fmt.Sprintf(INTERPRET_INSTRUCTIONS, getLanguageName(second))
+	if first != "" {
+		instructions = fmt.Sprintf(INTERPRET_TWO_PARTY_INSTRUCTIONS, getLanguageName(first), getLanguageName(second), getLanguageName(second))
+	}
+	translation, err := BOT.API.ChatComplete(ctx, models.ChatCompletion{
+		Model: string(INTERPRET_MODEL),
+		Messages: []models.Message{
+			{Role: "system", Content: instructions},
+			{Role: "user", Content: text},
+		},
+		MaxTokens: MAX_INTERPRET_TOKENS,
+	})
+	if err != nil {
+		return "", err
+	}
+	translation = strings.Trim(strings.TrimSpace(translation), "\"")
+	if translation == "" {
+		return "", fmt.Errorf("empty translation")
+	}
+	return translation, nil
+}
+
+func interpretCaption(original string, translation string) string {
+	return "🗣:\n" + original + "\n\n🌍:\n" + translation
+}
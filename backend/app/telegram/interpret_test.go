@@ -0,0 +1,58 @@
+package telegram
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"talk2robots/m/v2/app/ai/openai"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInterpretLanguages(t *testing.T) {
+	first, second := parseInterpretLanguages("es")
+	assert.Equal(t, "", first)
+	assert.Equal(t, "es", second)
+
+	first, second = parseInterpretLanguages("en-es")
+	assert.Equal(t, "en", first)
+	assert.Equal(t, "es", second)
+
+	first, second = parseInterpretLanguages("")
+	assert.Equal(t, "", first)
+	assert.Equal(t, DEFAULT_INTERPRET_TARGET, second)
+}
+
+func TestInterpretCaption(t *testing.T) {
+	assert.Equal(t, "🗣:\nгде вокзал?\n\n🌍:\n¿Dónde está la estación?", interpretCaption("где вокзал?", "¿Dónde está la estación?"))
+}
+
+// transcriptionRequest builds the whisper request for a voice message in the mode, the way getVoiceTranscript does
+func transcriptionRequest(t *testing.T, mode lib.ModeName, params string, options lib.TranscriptOptions) *http.Request {
+	ctx := context.WithValue(context.Background(), models.ParamsContext{}, params)
+	config := openai.WhisperConfig{WhisperAPIEndpoint: "https://api.openai.com/v1/audio/", Prompt: options.Prompt}
+	request, err := openai.NewTranscriptionRequest(withTranscriptionLanguage(ctx, mode), config, models.Whisper, strings.NewReader("voice"), "voice.ogg")
+	assert.NoError(t, err)
+	assert.NoError(t, request.ParseMultipartForm(1024))
+	return request
+}
+
+func TestInterpretTranscriptionLanguage(t *testing.T) {
+	// single target, the speech is in any language
+	request := transcriptionRequest(t, lib.Interpret, "es", lib.TranscriptOptions{Prompt: interpretTranscriptionPrompt("es")})
+	assert.NotContains(t, request.MultipartForm.Value, "language")
+	assert.NotContains(t, request.MultipartForm.Value, "prompt")
+
+	// two parties, whisper is hinted with both languages
+	request = transcriptionRequest(t, lib.Interpret, "en-es", lib.TranscriptOptions{Prompt: interpretTranscriptionPrompt("en-es")})
+	assert.NotContains(t, request.MultipartForm.Value, "language")
+	assert.Equal(t, "A conversation in English and Spanish.", request.FormValue("prompt"))
+
+	// language params of other modes are still passed on
+	request = transcriptionRequest(t, lib.Transcribe, "es", lib.TranscriptOptions{})
+	assert.Equal(t, "es", request.FormValue("language"))
+	assert.Equal(t, string(models.Whisper), request.FormValue("model"))
+}
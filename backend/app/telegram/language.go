@@ -0,0 +1,82 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// withUserLanguage picks up the telegram app language for chats without a language yet,
+// in groups the first member talking to the bot sets it, admins can change it with /language
+func withUserLanguage(ctx context.Context, user *models.MongoUser, from *telego.User) context.Context {
+	if (user != nil && user.Language != "") || from == nil {
+		return ctx
+	}
+	language := i18n.Language(from.LanguageCode)
+	if language == "" {
+		return ctx
+	}
+	err := mongo.MongoDBClient.UpdateUserLanguage(ctx, language)
+	if err != nil {
+		log.Errorf("Failed to save language %s for chat %s: %v", language, ctx.Value(models.UserContext{}).(string), err)
+	}
+	if user != nil {
+		user.Language = language
+	}
+	return context.WithValue(ctx, models.LanguageContext{}, language)
+}
+
+func getLanguageDisplayName(ctx context.Context, language string) string {
+	if !i18n.IsSupported(language) {
+		return getLanguageName(language)
+	}
+	return i18n.T(ctx, "language.name."+language)
+}
+
+func languageCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	chatID := util.GetChatID(message)
+	chatIDString := util.GetChatIDString(message)
+	available := strings.Join(i18n.Languages(), ", ")
+	reply := func(text string) {
+		text = lib.AddBotSuffixToGroupCommands(ctx, text)
+		bot.SendMessage(context.Background(), tu.Message(chatID, text).WithMessageThreadID(message.MessageThreadID))
+	}
+
+	messageArray := strings.Fields(message.Text)
+	if len(messageArray) < 2 {
+		current, _ := ctx.Value(models.LanguageContext{}).(string)
+		if current == "" {
+			current = i18n.DEFAULT_LANGUAGE
+		}
+		reply(i18n.T(ctx, "language.current", i18n.Args{"language": getLanguageDisplayName(ctx, current), "languages": available}))
+		return
+	}
+
+	language := i18n.Language(messageArray[1])
+	if !i18n.IsSupported(language) {
+		language = languageToCode(strings.ToLower(messageArray[1]))
+	}
+	if !i18n.IsSupported(language) {
+		reply(i18n.T(ctx, "language.unsupported", i18n.Args{"language": messageArray[1], "languages": available}))
+		return
+	}
+
+	err := mongo.MongoDBClient.UpdateUserLanguage(ctx, language)
+	if err != nil {
+		log.Errorf("Failed to update language for chat %s: %v", chatIDString, err)
+		reply(i18n.T(ctx, "oopsie"))
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.language_changed", []string{"language:" + language}, 1)
+	ctx = context.WithValue(ctx, models.LanguageContext{}, language)
+	reply(i18n.T(ctx, "language.updated", i18n.Args{"language": getLanguageDisplayName(ctx, language)}))
+}
@@ -0,0 +1,164 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"talk2robots/m/v2/app/ai/openai"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/converters"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MAX_PARALLEL_SEGMENTS limits concurrent transcription requests of a single long recording
+	MAX_PARALLEL_SEGMENTS = 4
+
+	// FAILED_SEGMENT_MARKER replaces the text of segments that couldn't be transcribed
+	FAILED_SEGMENT_MARKER = "[…]"
+)
+
+// transcribeLongAudio splits a converted recording into overlapping segments at silences,
+// transcribes them in parallel and stitches the transcripts, reporting progress in a status message.
+// Each segment is billed for its own duration, so overlaps are billed twice, same as the API charges them.
+func transcribeLongAudio(ctx context.Context, bot *telego.Bot, message telego.Message, whisperConfig openai.WhisperConfig, audioFile string, duration time.Duration, silences []converters.Silence, maxSegment time.Duration) (string, []models.TranscriptSegment) {
+	startTime := time.Now()
+	chatIDString := util.GetChatIDString(&message)
+	segments := converters.PlanSegments(duration, silences, maxSegment, converters.SEGMENT_OVERLAP)
+	log.Infof("Transcribing %s long audio in chat %s in %d segments", duration, chatIDString, len(segments))
+	config.CONFIG.DataDogClient.Count("transcribe.segments", int64(len(segments)), nil, 1)
+
+	progress := newTranscriptionProgress(ctx, bot, &message, duration, len(segments))
+	texts := make([]string, len(segments))
+	timed := make([][]models.TranscriptSegment, len(segments))
+	failed := 0
+	var failedMutex sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, MAX_PARALLEL_SEGMENTS)
+	for i, segment := range segments {
+		wg.Add(1)
+		go func(i int, segment converters.Segment) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			texts[i], timed[i] = transcribeSegment(ctx, whisperConfig, audioFile, i, segment)
+			if texts[i] == "" {
+				log.Warnf("Failed to transcribe segment %d (%s-%s) in chat %s", i, segment.Start, segment.End, chatIDString)
+				config.CONFIG.DataDogClient.Incr("transcribe.segment_failed", nil, 1)
+				texts[i] = FAILED_SEGMENT_MARKER
+				failedMutex.Lock()
+				failed++
+				failedMutex.Unlock()
+			}
+			progress.segmentDone()
+		}(i, segment)
+	}
+	wg.Wait()
+	progress.delete()
+
+	config.CONFIG.DataDogClient.Timing("transcribe.segmented", time.Since(startTime), nil, 1)
+	if failed == len(segments) {
+		return "", nil
+	}
+	if failed > 0 {
+		bot.SendMessage(context.Background(), tu.Message(message.Chat.ChatID(), i18n.T(ctx, "audio.segments_failed", i18n.Args{"count": failed, "marker": FAILED_SEGMENT_MARKER})).WithMessageThreadID(message.MessageThreadID))
+	}
+	return converters.StitchTranscripts(texts), converters.MergeTimedSegments(segments, timed)
+}
+
+// transcribeSegment cuts a segment into its own file and transcribes it, retrying once.
+// Timings of the returned transcript segments are relative to the start of the segment.
+func transcribeSegment(ctx context.Context, whisperConfig openai.WhisperConfig, audioFile string, index int, segment converters.Segment) (string, []models.TranscriptSegment) {
+	segmentFileName := fmt.Sprintf("%s-%d.ogg", strings.TrimSuffix(audioFile, ".ogg"), index)
+	defer util.SafeOsDelete(segmentFileName)
+	err := converters.CutSegment(audioFile, segment, segmentFileName)
+	if err != nil {
+		log.Errorf("Error cutting segment %d of %s: %v", index, audioFile, err)
+		return "", nil
+	}
+	segmentBuffer, err := os.ReadFile(segmentFileName)
+	if err != nil {
+		log.Errorf("Error reading segment %d of %s: %v", index, audioFile, err)
+		return "", nil
+	}
+
+	segmentCtx := context.WithValue(ctx, models.WhisperDurationContext{}, segment.Duration())
+	for attempt := 0; attempt < 2; attempt++ {
+		whisper := openai.NewWhisper()
+		whisper.Whisper(segmentCtx, whisperConfig, io.NopCloser(bytes.NewReader(segmentBuffer)), segmentFileName)
+		if text := strings.TrimSpace(whisper.Transcript().Text); text != "" {
+			return text, whisper.Transcript().Segments
+		}
+	}
+	return "", nil
+}
+
+// transcriptionProgress edits a status message as segments of a long recording are transcribed
+type transcriptionProgress struct {
+	ctx       context.Context
+	bot       *telego.Bot
+	chatID    telego.ChatID
+	messageID int
+	duration  time.Duration
+	total     int
+	done      int
+	mutex     sync.Mutex
+}
+
+func newTranscriptionProgress(ctx context.Context, bot *telego.Bot, message *telego.Message, duration time.Duration, total int) *transcriptionProgress {
+	progress := &transcriptionProgress{ctx: ctx, bot: bot, chatID: message.Chat.ChatID(), duration: duration, total: total}
+	status, err := bot.SendMessage(context.Background(), tu.Message(progress.chatID, progress.text()).WithMessageThreadID(message.MessageThreadID))
+	if err != nil {
+		log.Errorf("Failed to send transcription progress in chat %d: %v", progress.chatID.ID, err)
+		return progress
+	}
+	progress.messageID = status.MessageID
+	return progress
+}
+
+func (p *transcriptionProgress) text() string {
+	return i18n.T(p.ctx, "audio.transcribing_long", i18n.Args{
+		"duration": p.duration.Round(time.Second).String(),
+		"done":     p.done,
+		"count":    p.total,
+	})
+}
+
+func (p *transcriptionProgress) segmentDone() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.done++
+	if p.messageID == 0 || p.done == p.total {
+		return
+	}
+	_, err := p.bot.EditMessageText(context.Background(), &telego.EditMessageTextParams{
+		ChatID:    p.chatID,
+		MessageID: p.messageID,
+		Text:      p.text(),
+	})
+	if err != nil {
+		log.Warnf("Failed to update transcription progress in chat %d: %v", p.chatID.ID, err)
+	}
+}
+
+func (p *transcriptionProgress) delete() {
+	if p.messageID == 0 {
+		return
+	}
+	err := p.bot.DeleteMessage(context.Background(), &telego.DeleteMessageParams{ChatID: p.chatID, MessageID: p.messageID})
+	if err != nil {
+		log.Warnf("Failed to delete transcription progress in chat %d: %v", p.chatID.ID, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"talk2robots/m/v2/app/config"
 	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/models"
 	"talk2robots/m/v2/app/util"
@@ -39,14 +40,14 @@ func ProcessStreamingMessageWithLocalThreads(
 		ctx,
 		models.ChatMultimodalCompletion{
 			Model:    string(engineModel),
-			Messages: messages,
+			Messages: withMemberMemory(message, attributeGroupTurns(messages)),
 		},
 		cancelContext,
 	)
 
 	if err != nil {
 		log.Errorf("[ProcessThreadedStreamingMessage] Failed get streaming response from AI in chat: %s, %v", chatIDString, err)
-		_, err = bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+		_, err = bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 		if err != nil {
 			log.Errorf("[ProcessThreadedStreamingMessage] Failed to send error message in chat: %s, %v", chatIDString, err)
 		}
@@ -72,11 +73,14 @@ func processMessageChannelWithLocalThread(
 	))
 	if err != nil {
 		log.Errorf("[processMessageChannel] Failed to send primer message in chat: %s, %v", chatIDString, err)
-		bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 		return
 	}
 	isVoice, _ := util.IsAudioMessage(message)
 
+	// the answer may overflow into more messages, each of them can be rated
+	answerMessageIds := []int{responseMessage.MessageID}
+
 	// only update message every 3 seconds to prevent rate limiting from telegram
 	ticker := time.NewTicker(3 * time.Second)
 	previousMessageLength := len(responseText)
@@ -85,10 +89,15 @@ func processMessageChannelWithLocalThread(
 		ticker.Stop()
 		finalMessageString := strings.TrimPrefix(responseText, "...")
 
-		_, err = ChunkEditSendMessage(ctx, bot, responseMessage, finalMessageString, isVoice, true)
+		var lastMessage *telego.Message
+		lastMessage, err = ChunkEditSendMessage(ctx, bot, responseMessage, finalMessageString, isVoice, true)
+		if lastMessage != nil {
+			answerMessageIds = append(answerMessageIds, lastMessage.MessageID)
+		}
 		if err != nil {
 			log.Errorf("[processMessageChannel] Failed to ChunkEditSendMessage message in chat: %s, %v", chatIDString, err)
 		}
+		rememberAnswer(message, answerMessageIds, finalMessageString)
 
 		go func() {
 			if len(messages) == 0 || len(messages[len(messages)-1].Content) == 0 {
@@ -146,6 +155,7 @@ func processMessageChannelWithLocalThread(
 				log.Errorf("[processMessageChannel] Failed to ChunkEditSendMessage message in chat: %s, %v", chatIDString, err)
 			}
 			if nextMessageObject != nil {
+				answerMessageIds = append(answerMessageIds, nextMessageObject.MessageID)
 				responseMessage = nextMessageObject
 				responseText = nextMessageObject.Text
 				nextMessageObject = nil
@@ -171,6 +181,11 @@ func prepareMessagesForLocalThread(
 ) (messages []models.MultimodalMessage, isNewThread bool, err error) {
 	messages = make([]models.MultimodalMessage, 0)
 	chatIDString := util.GetChatIDString(message)
+	author := getThreadAuthor(message)
+	threadInfo := getUserInfo(message)
+	if author != "" {
+		threadInfo = getGroupInfo(message)
+	}
 	thread, err := mongo.MongoDBClient.GetUserThread(ctx)
 	if err != nil {
 		if strings.Contains(err.Error(), "failed to find user thread") {
@@ -179,7 +194,7 @@ func prepareMessagesForLocalThread(
 				Role: "system",
 				Content: []models.MultimodalContent{
 					{Type: "text", Text: config.AI_INSTRUCTIONS},
-					{Type: "text", Text: getUserInfo(message)},
+					{Type: "text", Text: threadInfo},
 				},
 			})
 			isNewThread = true
@@ -208,6 +223,7 @@ func prepareMessagesForLocalThread(
 		messages = append(messages, models.MultimodalMessage{
 			Role:    "user",
 			Content: []models.MultimodalContent{{Type: "text", Text: message.Text}},
+			Name:    author,
 		})
 		return messages, isNewThread, nil
 	}
@@ -224,6 +240,7 @@ func prepareMessagesForLocalThread(
 	messages = append(messages, models.MultimodalMessage{
 		Role:    "user",
 		Content: photoMultiModelContent,
+		Name:    author,
 	})
 	return messages, isNewThread, nil
 }
@@ -10,6 +10,7 @@ import (
 	"talk2robots/m/v2/app/ai"
 	"talk2robots/m/v2/app/ai/openai"
 	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/models"
 	"talk2robots/m/v2/app/payments"
@@ -22,8 +23,6 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-const OOPSIE = "Oopsie, it looks like my AI brain isn't working 🧠🔥. Please try again later."
-
 func ProcessChatCompleteStreamingMessage(
 	ctx context.Context,
 	bot *telego.Bot,
@@ -38,6 +37,8 @@ func ProcessChatCompleteStreamingMessage(
 	chatIDString := util.GetChatIDString(message)
 	langParams := ctx.Value(models.ParamsContext{}).(string)
 	if mode == lib.Translate && langParams != "" {
+		// copy seed data to keep the shared translate seed intact
+		seedData = append([]models.Message{}, seedData...)
 		seedData[0].Content = strings.ReplaceAll(seedData[0].Content, "English", getLanguageName(langParams))
 	}
 	messages, engineModel, err := prepareMessages(ctx, bot, message, seedData, userMessagePrimer, mode, engineModel)
@@ -57,7 +58,7 @@ func ProcessChatCompleteStreamingMessage(
 
 	if err != nil {
 		log.Errorf("Failed get streaming response from AI: %s", err)
-		_, err = bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+		_, err = bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 		if err != nil {
 			log.Errorf("Failed to send error message in chat: %s, %v", chatIDString, err)
 		}
@@ -86,6 +87,13 @@ func ProcessThreadedStreamingMessage(
 
 	var messages chan string
 
+	// group turns are attributed to their senders, member notes are passed to a run only, not to the shared thread
+	threadText := attributeText(getThreadAuthor(message), message.Text)
+	additionalInstructions := ""
+	if message.Chat.Type != telego.ChatTypePrivate {
+		additionalInstructions = strings.TrimSpace(getGroupInfo(message) + "\n\n" + getMemberMemoryInstructions(message))
+	}
+
 	threadRunId := ""
 	threadId, err := redis.RedisClient.Get(ctx, lib.UserCurrentThreadKey(chatIDString, topicID)).Result()
 	if err != nil {
@@ -97,31 +105,31 @@ func ProcessThreadedStreamingMessage(
 		messages, err = openai.CreateThreadAndRunStreaming(ctx, models.AssistantIdForModel(engineModel), engineModel, &models.Thread{
 			Messages: []models.Message{
 				{
-					Content: message.Text,
+					Content: threadText,
 					Role:    "user",
 				},
 			},
-		}, cancelContext)
+		}, additionalInstructions, cancelContext)
 
 		if err != nil {
 			log.Errorf("Failed to create and run thread streaming for user id: %s, error: %v", chatIDString, err)
-			bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+			bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 			return
 		}
 	} else {
 		log.Infof("Found thread %s for chat %s, adding a message..", threadId, chatIDString)
 
-		err = createThreadMessageWithRetries(ctx, threadId, threadRunId, message.Text, chatIDString)
+		err = createThreadMessageWithRetries(ctx, threadId, threadRunId, threadText, chatIDString)
 		if err != nil {
 			log.Errorf("Failed to add message to thread in chat %s: %s", chatID, err)
-			bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+			bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 			return
 		}
 
-		messages, err = openai.CreateRunStreaming(ctx, models.AssistantIdForModel(engineModel), engineModel, threadId, cancelContext)
+		messages, err = openai.CreateRunStreaming(ctx, models.AssistantIdForModel(engineModel), engineModel, threadId, additionalInstructions, cancelContext)
 		if err != nil {
 			log.Errorf("Failed to create and run streaming for user id: %s, error: %v", chatIDString, err)
-			bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+			bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 			return
 		}
 	}
@@ -173,7 +181,7 @@ func ProcessThreadedNonStreamingMessage(
 		})
 		if err != nil {
 			log.Errorf("Failed to create thread: %s", err)
-			bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+			bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 			return
 		}
 		threadId = threadRun.ThreadID
@@ -185,14 +193,14 @@ func ProcessThreadedNonStreamingMessage(
 		err := createThreadMessageWithRetries(ctx, threadId, threadRunId, message.Text, chatIDString)
 		if err != nil {
 			log.Errorf("Failed to add message to thread in chat %s: %s", chatID, err)
-			bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+			bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 			return
 		}
 
 		threadRun, err = openai.CreateRun(ctx, models.AssistantIdForModel(engineModel), threadId)
 		if err != nil {
 			log.Errorf("Failed to create run in chat %s: %s", chatIDString, err)
-			bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+			bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 			return
 		}
 		threadRunId = threadRun.ID
@@ -201,7 +209,7 @@ func ProcessThreadedNonStreamingMessage(
 	_, err := pollThreadRun(ctx, threadId, chatIDString, threadRunId)
 	if err != nil {
 		log.Errorf("Failed to final poll thread run in chat %s: %s", chatIDString, err)
-		bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID).WithMessageThreadID(message.MessageThreadID))
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID).WithMessageThreadID(message.MessageThreadID))
 		return
 	}
 
@@ -209,7 +217,7 @@ func ProcessThreadedNonStreamingMessage(
 	threadMessage, err := openai.ListThreadMessagesForARun(ctx, threadId, threadRunId)
 	if err != nil {
 		log.Errorf("Failed to get messages from thread in chat %s: %s", chatIDString, err)
-		bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 		return
 	}
 
@@ -231,13 +239,15 @@ func ProcessThreadedNonStreamingMessage(
 	}
 
 	usage.Usage.TotalTokens = usage.Usage.PromptTokens + usage.Usage.CompletionTokens
-	go payments.Bill(ctx, usage)
+	payments.BillAsync(ctx, usage)
 
+	var answerMessageIds []int
 	if mode != lib.VoiceGPT {
-		ChunkSendMessage(bot, message, totalContent)
+		answerMessageIds = ChunkSendMessage(bot, message, totalContent)
 	} else {
-		ChunkSendVoice(ctx, bot, message, totalContent, true)
+		answerMessageIds = ChunkSendVoice(ctx, bot, message, totalContent, true)
 	}
+	rememberAnswer(message, answerMessageIds, totalContent)
 }
 
 func ProcessChatCompleteNonStreamingMessage(ctx context.Context, bot *telego.Bot, message *telego.Message, seedData []models.Message, userMessagePrimer string, mode lib.ModeName, engineModel models.Engine) {
@@ -257,7 +267,7 @@ func ProcessChatCompleteNonStreamingMessage(ctx context.Context, bot *telego.Bot
 		log.Errorf("Failed get response from Open AI in chat %s: %s", chatID, err)
 
 		if isPrivate {
-			bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+			bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 		}
 		return
 	}
@@ -280,11 +290,13 @@ func ProcessChatCompleteNonStreamingMessage(ctx context.Context, bot *telego.Bot
 		// split response into two parts: corrected message and explanation, using Explanation: as a separator
 		separator := "Explanation:"
 		parts := strings.Split(response, separator)
+		var answerMessageIds []int
 		for _, part := range parts {
-			ChunkSendMessage(bot, message, part)
+			answerMessageIds = append(answerMessageIds, ChunkSendMessage(bot, message, part)...)
 		}
+		rememberAnswer(message, answerMessageIds, response)
 	} else {
-		ChunkSendMessage(bot, message, response)
+		rememberAnswer(message, ChunkSendMessage(bot, message, response), response)
 	}
 }
 
@@ -329,13 +341,13 @@ func prepareMessages(
 		if strings.Contains(err.Error(), "free plan") {
 			bot.SendMessage(context.Background(), &telego.SendMessageParams{
 				ChatID:          chatID,
-				Text:            "Image vision is not currently available on free plans. Check /upgrade options to use this feature.",
+				Text:            i18n.T(ctx, "image.vision_upgrade"),
 				MessageThreadID: message.MessageThreadID,
 			})
 		} else {
 			bot.SendMessage(context.Background(), &telego.SendMessageParams{
 				ChatID:          chatID,
-				Text:            "😔 can't accept image messages at the moment",
+				Text:            i18n.T(ctx, "image.not_accepted"),
 				MessageThreadID: message.MessageThreadID,
 			})
 		}
@@ -358,12 +370,7 @@ func prepareMessages(
 func getPhotoBase64(message *telego.Message, ctx context.Context, bot *telego.Bot) (photoMultiModelContent []models.MultimodalContent, err error) {
 	chatIDString := util.GetChatIDString(message)
 	photoMultiModelContent = make([]models.MultimodalContent, 0)
-	for i, photoSize := range message.Photo {
-		// skip all photos but last for now
-		if i != len(message.Photo)-1 {
-			continue
-		}
-
+	for _, photoSize := range messagePhotos(message) {
 		photoWidth := photoSize.Width
 		log.Infof("Got photo from chat %s, width: %d, height: %d, kbytes: %.1fk", chatIDString, photoWidth, photoSize.Height, float64(photoSize.FileSize/1024))
 		var photoFile *telego.File
@@ -471,30 +478,50 @@ func pollThreadRun(ctx context.Context, threadId string, chatIDString string, ru
 	}
 }
 
-// sends a message in up to 4000 chars chunks
-func ChunkSendMessage(bot *telego.Bot, message *telego.Message, text string) {
+// sends a message in chunks up to Telegram limit, rendering Markdown into Telegram HTML, returns ids of the sent messages
+func ChunkSendMessage(bot *telego.Bot, message *telego.Message, text string) []int {
+	if text == "" {
+		return nil
+	}
+	return sendChunks(bot, message, util.ChunkMarkdownToTelegramHTML(text, util.TELEGRAM_MESSAGE_LIMIT))
+}
+
+// sends plain text (transcripts, documents, user messages) in chunks up to Telegram limit, escaped instead of rendered as Markdown
+func ChunkSendText(bot *telego.Bot, message *telego.Message, text string) {
 	if text == "" {
 		return
 	}
+	sendChunks(bot, message, util.ChunkTextToTelegramHTML(text, util.TELEGRAM_MESSAGE_LIMIT))
+}
+
+func sendChunks(bot *telego.Bot, message *telego.Message, chunks []util.MarkdownChunk) (sentMessageIds []int) {
 	chatID := message.Chat.ChatID()
 	ctx := context.Background()
-	for _, chunk := range util.ChunkString(text, 4000) {
-		_, err := bot.SendMessage(ctx, tu.Message(chatID, chunk).WithMessageThreadID(message.MessageThreadID).WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
+	for _, chunk := range chunks {
+		sentMessage, err := bot.SendMessage(ctx, tu.Message(chatID, chunk.HTML).WithParseMode("HTML").WithMessageThreadID(message.MessageThreadID).WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
+		if err != nil && strings.Contains(err.Error(), "can't parse entities") {
+			sentMessage, err = bot.SendMessage(ctx, tu.Message(chatID, chunk.Markdown).WithMessageThreadID(message.MessageThreadID).WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
+		}
 		if err != nil {
 			if strings.Contains(err.Error(), "message thread not found") {
 				// retry without message thread id
-				_, err = bot.SendMessage(ctx, tu.Message(chatID, chunk).WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
+				sentMessage, err = bot.SendMessage(ctx, tu.Message(chatID, chunk.HTML).WithParseMode("HTML").WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
 			}
 
 			if err != nil {
 				log.Errorf("Failed to send message to telegram: %v, chatID: %s, threadID: %d", err, chatID, message.MessageThreadID)
 			}
 		}
+		if err == nil && sentMessage != nil {
+			cacheMessageText(message, sentMessage.MessageID, chunk.Markdown)
+			sentMessageIds = append(sentMessageIds, sentMessage.MessageID)
+		}
 		time.Sleep(1 * time.Second)
 	}
+	return sentMessageIds
 }
 
-// update current message and sends a new message in up to 4000 chars chunks
+// update current message and sends new messages in chunks up to Telegram limit, rendering Markdown into Telegram HTML
 func ChunkEditSendMessage(
 	ctx context.Context,
 	bot *telego.Bot,
@@ -508,7 +535,7 @@ func ChunkEditSendMessage(
 	}
 	chatID := message.Chat.ChatID()
 	messageID := message.MessageID
-	chunks := util.ChunkString(text, 4000)
+	chunks := util.ChunkMarkdownToTelegramHTML(text, util.TELEGRAM_MESSAGE_LIMIT)
 	for i, chunk := range chunks {
 		last := false
 		markup := getLikeDislikeReplyMarkup(message.MessageThreadID)
@@ -517,43 +544,47 @@ func ChunkEditSendMessage(
 			last = true
 		}
 		if i == 0 {
-			log.Debugf("[ChunkEditSendMessage] chunk %d (size %d) - editing message %d in chat %s", i, len(chunk), messageID, chatID)
+			log.Debugf("[ChunkEditSendMessage] chunk %d (size %d) - editing message %d in chat %s", i, len(chunk.HTML), messageID, chatID)
 			params := &telego.EditMessageTextParams{
 				ChatID:      chatID,
 				MessageID:   messageID,
-				Text:        chunk,
+				Text:        chunk.HTML,
 				ReplyMarkup: markup,
-				ParseMode:   "MarkdownV2",
+				ParseMode:   "HTML",
 			}
 			_, err = bot.EditMessageText(context.Background(), params)
 
-			if err != nil && strings.Contains(err.Error(), "can't parse entities") {
-				params.ParseMode = "HTML"
-				_, err = bot.EditMessageText(context.Background(), params)
-			}
-
 			if err != nil && strings.Contains(err.Error(), "can't parse entities") {
 				params.ParseMode = ""
+				params.Text = chunk.Markdown
 				_, err = bot.EditMessageText(context.Background(), params)
 			}
 
 			time.Sleep(1 * time.Second) // sleep to prevent rate limiting
 		} else {
-			log.Debugf("[ChunkEditSendMessage] chunk %d (size %d) - sending new message in chat %s", i, len(chunk), chatID)
-			lastMessage, err = bot.SendMessage(context.Background(), tu.Message(chatID, chunk).WithParseMode("MarkdownV2").WithMessageThreadID(message.MessageThreadID).WithReplyMarkup(markup))
+			log.Debugf("[ChunkEditSendMessage] chunk %d (size %d) - sending new message in chat %s", i, len(chunk.HTML), chatID)
+			lastMessage, err = bot.SendMessage(context.Background(), tu.Message(chatID, chunk.HTML).WithParseMode("HTML").WithMessageThreadID(message.MessageThreadID).WithReplyMarkup(markup))
 
 			if err != nil && strings.Contains(err.Error(), "can't parse entities") {
-				lastMessage, err = bot.SendMessage(context.Background(), tu.Message(chatID, chunk).WithParseMode("HTML").WithMessageThreadID(message.MessageThreadID).WithReplyMarkup(markup))
+				lastMessage, err = bot.SendMessage(context.Background(), tu.Message(chatID, chunk.Markdown).WithMessageThreadID(message.MessageThreadID).WithReplyMarkup(markup))
 			}
 
-			if err != nil && strings.Contains(err.Error(), "can't parse entities") {
-				lastMessage, err = bot.SendMessage(context.Background(), tu.Message(chatID, chunk).WithMessageThreadID(message.MessageThreadID).WithReplyMarkup(markup))
+			// keep Markdown source of the last message, so a streaming response can continue it
+			if lastMessage != nil {
+				lastMessage.Text = chunk.Markdown
 			}
 
 			time.Sleep(1 * time.Second) // sleep to prevent rate limiting
 		}
+		if finalize && err == nil {
+			if i == 0 {
+				cacheMessageText(message, messageID, chunk.Markdown)
+			} else if lastMessage != nil {
+				cacheMessageText(message, lastMessage.MessageID, chunk.Markdown)
+			}
+		}
 		if !last && voice {
-			ChunkSendVoice(ctx, bot, message, chunk, false)
+			ChunkSendVoice(ctx, bot, message, chunk.Markdown, false)
 		}
 	}
 	return lastMessage, err
@@ -568,52 +599,72 @@ func (nr NamedReader) Name() string {
 	return nr.name
 }
 
-func ChunkSendVoice(ctx context.Context, bot *telego.Bot, message *telego.Message, text string, caption bool) {
-	chatID := message.Chat.ChatID()
+// voices the text in chunks, returns ids of the sent messages
+func ChunkSendVoice(ctx context.Context, bot *telego.Bot, message *telego.Message, text string, caption bool) (sentMessageIds []int) {
 	for _, chunk := range util.ChunkString(text, 1000) {
-		sendAudioAction(bot, message)
-		voiceReader, err := openai.CreateSpeech(ctx, &models.TTSRequest{
-			Model: models.TTS,
-			Input: chunk,
-		})
+		chunkCaption := util.MarkdownChunk{}
+		if caption {
+			chunkCaption.Markdown = chunk
+			if len(chunk) > 1000 {
+				chunkCaption.Markdown = chunk[:1000] + "..."
+			}
+			chunkCaption.HTML = util.MarkdownToTelegramHTML(chunkCaption.Markdown)
+		}
+		sentMessageId, err := sendSpeech(ctx, bot, message, chunk, chunkCaption)
 		if err != nil {
-			log.Errorf("Failed to get voice message: %v for chatID: %d", err, chatID.ID)
+			log.Errorf("Failed to send voice message: %v in chatID: %d", err, message.Chat.ID)
 			continue
 		}
-		defer voiceReader.Close()
+		sentMessageIds = append(sentMessageIds, sentMessageId)
+	}
+	return sentMessageIds
+}
 
-		temporaryFileName := uuid.New().String()
-		voiceFile := telego.InputFile{
-			File: NamedReader{
-				Reader: voiceReader,
-				name:   temporaryFileName + ".ogg",
-			},
-		}
+// sendSpeech voices the text as a voice message or an mp3 audio, depending on voice settings,
+// caption is optional and already rendered, its source is sent as is if Telegram can't parse the HTML, returns the sent message id
+func sendSpeech(ctx context.Context, bot *telego.Bot, message *telego.Message, text string, caption util.MarkdownChunk) (int, error) {
+	chatID := message.Chat.ChatID()
+	sendAudioAction(bot, message)
+	tts := lib.TTSRequestForChat(util.GetChatIDString(message), text)
+	voiceReader, err := openai.CreateSpeech(ctx, tts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get voice message: %w", err)
+	}
+	defer voiceReader.Close()
 
-		trimmedChunk := chunk
-		if len(chunk) > 1000 {
-			trimmedChunk = chunk[:1000] + "..."
-		}
-		voiceParams := &telego.SendVoiceParams{
-			ChatID:          chatID,
-			Voice:           voiceFile,
-			MessageThreadID: message.MessageThreadID,
-			ParseMode:       "HTML",
-		}
-		if caption {
-			voiceParams.Caption = trimmedChunk
-		}
-		_, err = bot.SendVoice(context.Background(), voiceParams.WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
-		if err != nil && strings.Contains(err.Error(), "can't parse entities") {
-			voiceParams.ParseMode = ""
-			_, err = bot.SendVoice(context.Background(), voiceParams.WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
-		}
+	if tts.Format == models.TTSFormatMp3 {
+		sentMessageId, err := sendSpeechAudio(bot, message, voiceReader, text, caption)
 		time.Sleep(1 * time.Second) // sleep to prevent rate limiting
-		if err != nil {
-			log.Errorf("Failed to send voice message: %v in chatID: %d", err, chatID.ID)
-			continue
-		}
+		return sentMessageId, err
+	}
+
+	temporaryFileName := uuid.New().String()
+	voiceFile := telego.InputFile{
+		File: NamedReader{
+			Reader: voiceReader,
+			name:   temporaryFileName + ".ogg",
+		},
+	}
+	voiceParams := &telego.SendVoiceParams{
+		ChatID:          chatID,
+		Voice:           voiceFile,
+		MessageThreadID: message.MessageThreadID,
+		ParseMode:       "HTML",
 	}
+	if caption.HTML != "" {
+		voiceParams.Caption = caption.HTML
+	}
+	sentMessage, err := bot.SendVoice(context.Background(), voiceParams.WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
+	if err != nil && strings.Contains(err.Error(), "can't parse entities") {
+		voiceParams.ParseMode = ""
+		voiceParams.Caption = caption.Markdown
+		sentMessage, err = bot.SendVoice(context.Background(), voiceParams.WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
+	}
+	time.Sleep(1 * time.Second) // sleep to prevent rate limiting
+	if err != nil {
+		return 0, err
+	}
+	return sentMessage.MessageID, nil
 }
 
 func postprocessMessage(message string, mode lib.ModeName, userMessagePrimer string) string {
@@ -644,9 +695,12 @@ func processMessageChannel(
 	))
 	if err != nil {
 		log.Errorf("Failed to send primer message in chat: %s, %v", chatIDString, err)
-		bot.SendMessage(context.Background(), tu.Message(chatID, OOPSIE).WithMessageThreadID(message.MessageThreadID))
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "oopsie")).WithMessageThreadID(message.MessageThreadID))
 		return
 	}
+	// the answer may overflow into more messages, each of them can be rated
+	answerMessageIds := []int{responseMessage.MessageID}
+
 	// only update message every 3 seconds to prevent rate limiting from telegram
 	ticker := time.NewTicker(3 * time.Second)
 	previousMessageLength := len(responseText)
@@ -673,10 +727,15 @@ func processMessageChannel(
 				log.Errorf("Failed to add reaction to message in chat: %s, %v", chatIDString, err)
 			}
 		} else {
-			_, err = ChunkEditSendMessage(ctx, bot, responseMessage, finalMessageString, mode == lib.VoiceGPT, true)
+			var lastMessage *telego.Message
+			lastMessage, err = ChunkEditSendMessage(ctx, bot, responseMessage, finalMessageString, mode == lib.VoiceGPT, true)
+			if lastMessage != nil {
+				answerMessageIds = append(answerMessageIds, lastMessage.MessageID)
+			}
 			if err != nil {
 				log.Errorf("Failed to ChunkEditSendMessage message in chat: %s, %v", chatIDString, err)
 			}
+			rememberAnswer(message, answerMessageIds, finalMessageString)
 		}
 		if err != nil {
 			log.Errorf("Failed to add reply markup to message in chat: %s, %v", chatIDString, err)
@@ -700,6 +759,7 @@ func processMessageChannel(
 				log.Errorf("Failed to ChunkEditSendMessage message in chat: %s, %v", chatIDString, err)
 			}
 			if nextMessageObject != nil {
+				answerMessageIds = append(answerMessageIds, nextMessageObject.MessageID)
 				responseMessage = nextMessageObject
 				responseText = nextMessageObject.Text
 				nextMessageObject = nil
@@ -29,7 +29,7 @@ func TestProcessThreadedStreamingMessage(t *testing.T) {
 	// OpenAI API patch
 	openAIPatch, err := mpatch.PatchMethod(
 		openai.CreateThreadAndRunStreaming,
-		func(ctx context.Context, assistantId string, model models.Engine, thread *models.Thread, cancelContext context.CancelFunc) (chan string, error) {
+		func(ctx context.Context, assistantId string, model models.Engine, thread *models.Thread, additionalInstructions string, cancelContext context.CancelFunc) (chan string, error) {
 			messages := make(chan string)
 			go func() {
 				defer close(messages)
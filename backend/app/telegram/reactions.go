@@ -0,0 +1,273 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+type reactionAction string
+
+const (
+	readAloudReaction reactionAction = "read_aloud"
+	translateReaction reactionAction = "translate"
+	grammarReaction   reactionAction = "grammar"
+	summarizeReaction reactionAction = "summarize"
+
+	MESSAGE_TEXT_TTL = 48 * time.Hour
+)
+
+// cachedMessage is a recent message text with its topic, telegram passes neither along with reactions
+type cachedMessage struct {
+	Text    string `json:"text"`
+	TopicID int    `json:"topic_id,omitempty"`
+}
+
+// cacheMessageText remembers texts of recent messages in the chat and topic of the message, so reactions can be acted upon
+func cacheMessageText(message *telego.Message, messageId int, text string) {
+	if text == "" || messageId == 0 {
+		return
+	}
+	chatIDString := util.GetChatIDString(message)
+	cachedBytes, _ := json.Marshal(cachedMessage{Text: text, TopicID: message.MessageThreadID})
+	err := redis.RedisClient.Set(context.Background(), lib.MessageTextKey(chatIDString, messageId), string(cachedBytes), MESSAGE_TEXT_TTL).Err()
+	if err != nil {
+		log.Errorf("Failed to cache message %d text in chat %s: %v", messageId, chatIDString, err)
+	}
+}
+
+func getCachedMessage(chatIDString string, messageId int) *cachedMessage {
+	cached, err := redis.RedisClient.Get(context.Background(), lib.MessageTextKey(chatIDString, messageId)).Result()
+	if err != nil || cached == "" {
+		return nil
+	}
+	message := &cachedMessage{}
+	if json.Unmarshal([]byte(cached), message) != nil || message.Text == "" {
+		// cached before topics were kept
+		return &cachedMessage{Text: cached}
+	}
+	return message
+}
+
+// getNewReactionActions returns actions for emoji reactions, that were just added to a message, by the chat emoji to action map
+func getNewReactionActions(reaction *telego.MessageReactionUpdated, reactionActions map[string]string) []reactionAction {
+	oldEmojis := map[string]bool{}
+	for _, oldReaction := range reaction.OldReaction {
+		if emoji, ok := oldReaction.(*telego.ReactionTypeEmoji); ok {
+			oldEmojis[lib.NormalizeReactionEmoji(emoji.Emoji)] = true
+		}
+	}
+
+	actions := []reactionAction{}
+	for _, newReaction := range reaction.NewReaction {
+		emoji, ok := newReaction.(*telego.ReactionTypeEmoji)
+		if !ok || oldEmojis[lib.NormalizeReactionEmoji(emoji.Emoji)] {
+			continue
+		}
+		if action, exists := reactionActions[lib.NormalizeReactionEmoji(emoji.Emoji)]; exists {
+			actions = append(actions, reactionAction(action))
+		}
+	}
+	return actions
+}
+
+// getReactionPolicyNotice checks the action against the group policy set by admins, the same way messages are checked
+func getReactionPolicyNotice(ctx context.Context, settings models.GroupSettings, action reactionAction) string {
+	switch action {
+	case readAloudReaction:
+		if !settings.VoiceAllowed {
+			return i18n.T(ctx, "group.voice_disabled")
+		}
+		return ""
+	case translateReaction:
+		if !lib.IsGroupModeAllowed(settings, lib.Translate) {
+			return lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "group.mode_disabled"))
+		}
+	case grammarReaction:
+		if !lib.IsGroupModeAllowed(settings, lib.Grammar) {
+			return lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "group.mode_disabled"))
+		}
+	case summarizeReaction:
+		if !lib.IsGroupModeAllowed(settings, lib.Summarize) {
+			return lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "group.mode_disabled"))
+		}
+	}
+	return ""
+}
+
+// handleReactionAction performs an action (read aloud, translate, grammar, summarize) on a reacted message
+func handleReactionAction(bot *telego.Bot, reaction *telego.MessageReactionUpdated, action reactionAction) {
+	chatIDString := fmt.Sprintf("%d", reaction.Chat.ID)
+	cached := getCachedMessage(chatIDString, reaction.MessageID)
+	if cached == nil {
+		log.Infof("No cached text for message %d in chat %s, skipping %s reaction", reaction.MessageID, chatIDString, action)
+		config.CONFIG.DataDogClient.Incr("telegram.reaction_action_skipped", []string{"action:" + string(action), "channel_type:" + reaction.Chat.Type}, 1)
+		return
+	}
+	text := cached.Text
+
+	message := &telego.Message{
+		Chat:            reaction.Chat,
+		MessageID:       reaction.MessageID,
+		MessageThreadID: cached.TopicID,
+		From:            reaction.User,
+		Text:            text,
+	}
+	isPrivate := reaction.Chat.Type == telego.ChatTypePrivate
+	user, ctx, cancelContext, err := lib.SetupUserAndContext(chatIDString, lib.TelegramClientName, chatIDString, util.GetTopicID(message))
+	if err != nil {
+		log.Errorf("Failed to setup user and context for %s reaction in chat %s: %v", action, chatIDString, err)
+		return
+	}
+
+	// enforce group policy set by admins
+	groupSettings := lib.DefaultGroupSettings()
+	if !isPrivate {
+		groupSettings = lib.GetGroupSettings(chatIDString)
+		if reaction.User != nil {
+			ctx = context.WithValue(ctx, models.MemberContext{}, fmt.Sprint(reaction.User.ID))
+		}
+		if notice := getReactionPolicyNotice(ctx, groupSettings, action); notice != "" {
+			log.Infof("Group policy rejected %s reaction in chat %s", action, chatIDString)
+			sendGroupPolicyNotice(bot, message, notice)
+			cancelContext()
+			return
+		}
+	}
+
+	ok, subscription := lib.ValidateUserUsage(ctx)
+	if !ok {
+		config.CONFIG.DataDogClient.Incr("telegram.usage_exceeded", []string{"client:telegram", "channel_type:reaction", "subscription:" + string(subscription)}, 1)
+		notification := lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "usage.exceeded"))
+		bot.SendMessage(context.Background(), tu.Message(reaction.Chat.ChatID(), notification).WithMessageThreadID(message.MessageThreadID))
+		cancelContext()
+		return
+	}
+
+	// group member exceeded the monthly cap set by admins
+	if !isPrivate && !lib.ValidateGroupMemberUsage(ctx, groupSettings) {
+		config.CONFIG.DataDogClient.Incr("telegram.member_usage_exceeded", []string{"channel_type:reaction"}, 1)
+		sendGroupPolicyNotice(bot, message, i18n.T(ctx, "group.member_cap_reached", i18n.Args{"cap": fmt.Sprintf("%.2f", groupSettings.MemberMonthlyCap)}))
+		cancelContext()
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.reaction_action", []string{"action:" + string(action), "channel_type:" + reaction.Chat.Type}, 1)
+
+	engineModel := redis.GetModel(chatIDString)
+	if !isPrivate && !lib.IsGroupEngineAllowed(groupSettings, engineModel) {
+		groupEngine := lib.GetGroupEngine(groupSettings, ctx.Value(models.SubscriptionContext{}).(models.MongoSubscriptionName), engineModel)
+		log.Infof("Engine %s is not allowed in chat %s, using %s", engineModel, chatIDString, groupEngine)
+		engineModel = groupEngine
+	}
+	ctx = context.WithValue(ctx, models.ParamsContext{}, "")
+
+	switch action {
+	case readAloudReaction:
+		defer cancelContext()
+		ChunkSendVoice(ctx, bot, message, text, false)
+	case translateReaction:
+		ctx = context.WithValue(ctx, models.ParamsContext{}, getReactionLanguage(reaction.User, user))
+		sendTypingAction(bot, message)
+		seedData, userMessagePrimer := lib.GetSeedDataAndPrimer(lib.Translate)
+		ProcessChatCompleteStreamingMessage(ctx, bot, message, seedData, userMessagePrimer, lib.Translate, engineModel, cancelContext)
+	case grammarReaction:
+		sendTypingAction(bot, message)
+		seedData, userMessagePrimer := lib.GetSeedDataAndPrimer(lib.Grammar)
+		ProcessChatCompleteStreamingMessage(ctx, bot, message, seedData, userMessagePrimer, lib.Grammar, engineModel, cancelContext)
+	case summarizeReaction:
+		sendTypingAction(bot, message)
+		seedData, userMessagePrimer := lib.GetSeedDataAndPrimer(lib.Summarize)
+		ProcessChatCompleteStreamingMessage(ctx, bot, message, seedData, userMessagePrimer, lib.Summarize, engineModel, cancelContext)
+	default:
+		defer cancelContext()
+		log.Errorf("Unknown reaction action %s in chat %s", action, chatIDString)
+	}
+}
+
+// getReactionLanguage picks a language to translate to, defaults to English
+func getReactionLanguage(from *telego.User, user *models.MongoUser) string {
+	if from != nil && from.LanguageCode != "" {
+		return from.LanguageCode
+	}
+	if user != nil && user.Language != "" {
+		return user.Language
+	}
+	return "en"
+}
+
+// sendReactionActions runs reaction actions for a message reaction update
+func sendReactionActions(bot *telego.Bot, reaction *telego.MessageReactionUpdated) {
+	reactionActions := lib.GetReactionActions(fmt.Sprintf("%d", reaction.Chat.ID))
+	for _, action := range getNewReactionActions(reaction, reactionActions) {
+		go handleReactionAction(bot, reaction, action)
+	}
+}
+
+// cacheIncomingMessageText is used for users' own messages
+func cacheIncomingMessageText(message *telego.Message) {
+	text := message.Text
+	if text == "" {
+		text = message.Caption
+	}
+	cacheMessageText(message, message.MessageID, text)
+}
+
+// reactionsCommandHandler shows or changes which emoji reactions run which actions in the chat
+func reactionsCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	chatIDString := util.GetChatIDString(message)
+	messageArray := strings.Fields(message.Text)
+	param := ""
+	response := ""
+	switch {
+	case len(messageArray) == 1:
+		response = formatReactionActions(ctx, lib.GetReactionActions(chatIDString)) + "\n\n" + i18n.T(ctx, "reactions.usage")
+	case len(messageArray) == 2 && strings.ToLower(messageArray[1]) == "reset":
+		param = "reset"
+		err := lib.ResetReactionActions(chatIDString)
+		if err != nil {
+			log.Errorf("Failed to reset reaction actions in chat %s: %v", chatIDString, err)
+			response = i18n.T(ctx, "reactions.failed")
+			break
+		}
+		response = i18n.T(ctx, "reactions.updated") + "\n\n" + formatReactionActions(ctx, lib.DefaultReactionActions)
+	case len(messageArray) == 3:
+		param = strings.ToLower(messageArray[2])
+		err := lib.SetReactionAction(chatIDString, messageArray[1], messageArray[2])
+		if err != nil {
+			response = i18n.T(ctx, "reactions.update_failed", i18n.Args{"error": err}) + "\n\n" + i18n.T(ctx, "reactions.usage")
+			break
+		}
+		response = i18n.T(ctx, "reactions.updated") + "\n\n" + formatReactionActions(ctx, lib.GetReactionActions(chatIDString))
+	default:
+		response = i18n.T(ctx, "reactions.usage")
+	}
+
+	config.CONFIG.DataDogClient.Incr("telegram.reactions_command", []string{"param:" + param}, 1)
+	response = lib.AddBotSuffixToGroupCommands(ctx, response)
+	bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), response).WithMessageThreadID(message.MessageThreadID))
+}
+
+func formatReactionActions(ctx context.Context, reactionActions map[string]string) string {
+	if len(reactionActions) == 0 {
+		return i18n.T(ctx, "reactions.none")
+	}
+	lines := []string{}
+	for emoji, action := range reactionActions {
+		lines = append(lines, emoji+" "+i18n.T(ctx, "reactions.action."+action))
+	}
+	sort.Strings(lines)
+	return i18n.T(ctx, "reactions.list", i18n.Args{"actions": strings.Join(lines, "\n")})
+}
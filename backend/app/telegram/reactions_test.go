@@ -0,0 +1,101 @@
+package telegram
+
+import (
+	"context"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"testing"
+
+	"github.com/mymmrac/telego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNewReactionActions(t *testing.T) {
+	tests := []struct {
+		name        string
+		actions     map[string]string
+		oldReaction []telego.ReactionType
+		newReaction []telego.ReactionType
+		expected    []reactionAction
+	}{
+		{
+			name:        "new read aloud reaction",
+			newReaction: []telego.ReactionType{&telego.ReactionTypeEmoji{Type: "emoji", Emoji: "🔊"}},
+			expected:    []reactionAction{readAloudReaction},
+		},
+		{
+			name:        "already processed reaction",
+			oldReaction: []telego.ReactionType{&telego.ReactionTypeEmoji{Type: "emoji", Emoji: "✍"}},
+			newReaction: []telego.ReactionType{&telego.ReactionTypeEmoji{Type: "emoji", Emoji: "✍"}, &telego.ReactionTypeEmoji{Type: "emoji", Emoji: "📝"}},
+			expected:    []reactionAction{summarizeReaction},
+		},
+		{
+			name:        "emoji with variation selector",
+			newReaction: []telego.ReactionType{&telego.ReactionTypeEmoji{Type: "emoji", Emoji: "✍️"}},
+			expected:    []reactionAction{grammarReaction},
+		},
+		{
+			name:        "chat reaction actions",
+			actions:     map[string]string{"🔥": "summarize"},
+			newReaction: []telego.ReactionType{&telego.ReactionTypeEmoji{Type: "emoji", Emoji: "🔥"}, &telego.ReactionTypeEmoji{Type: "emoji", Emoji: "🔊"}},
+			expected:    []reactionAction{summarizeReaction},
+		},
+		{
+			name:        "feedback only reaction",
+			newReaction: []telego.ReactionType{&telego.ReactionTypeEmoji{Type: "emoji", Emoji: "👍"}},
+			expected:    []reactionAction{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reactionActions := test.actions
+			if reactionActions == nil {
+				reactionActions = lib.DefaultReactionActions
+			}
+			actions := getNewReactionActions(&telego.MessageReactionUpdated{
+				OldReaction: test.oldReaction,
+				NewReaction: test.newReaction,
+			}, reactionActions)
+			if len(actions) != len(test.expected) {
+				t.Fatalf("expected %v actions, got %v", test.expected, actions)
+			}
+			for i := range actions {
+				if actions[i] != test.expected[i] {
+					t.Errorf("expected action %s, got %s", test.expected[i], actions[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetReactionPolicyNotice(t *testing.T) {
+	ctx := context.WithValue(context.Background(), models.UserContext{}, "-100")
+	ctx = context.WithValue(ctx, models.ClientContext{}, string(lib.TelegramClientName))
+	settings := lib.DefaultGroupSettings()
+	for _, action := range []reactionAction{readAloudReaction, translateReaction, grammarReaction, summarizeReaction} {
+		assert.Empty(t, getReactionPolicyNotice(ctx, settings, action), action)
+	}
+
+	settings.AllowedModes = []string{string(lib.ChatGPT), string(lib.Translate)}
+	settings.VoiceAllowed = false
+	assert.NotEmpty(t, getReactionPolicyNotice(ctx, settings, readAloudReaction))
+	assert.Empty(t, getReactionPolicyNotice(ctx, settings, translateReaction))
+	assert.NotEmpty(t, getReactionPolicyNotice(ctx, settings, grammarReaction))
+	assert.NotEmpty(t, getReactionPolicyNotice(ctx, settings, summarizeReaction))
+}
+
+func TestCachedMessageKeepsTopic(t *testing.T) {
+	redis.RedisClient = redis.NewMockRedisClient()
+	message := &telego.Message{Chat: telego.Chat{ID: -100, Type: telego.ChatTypeSupergroup}, MessageThreadID: 42}
+
+	cacheMessageText(message, 7, "hello")
+	cached := getCachedMessage("-100", 7)
+	assert.Equal(t, &cachedMessage{Text: "hello", TopicID: 42}, cached)
+
+	// texts cached before topics were kept
+	redis.RedisClient.Set(context.Background(), lib.MessageTextKey("-100", 8), "plain text", 0)
+	assert.Equal(t, &cachedMessage{Text: "plain text"}, getCachedMessage("-100", 8))
+	assert.Nil(t, getCachedMessage("-100", 9))
+}
@@ -0,0 +1,265 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+const REMINDER_PROMPT = `You are running a prompt the user scheduled earlier, it's not a live conversation, so don't ask follow-up questions.
+Current time of the user is %s (%s). Reply in the language of the prompt.`
+
+const REMINDER_TIME_FORMAT = "Mon, 02 Jan 2006 15:04"
+
+func remindCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	createReminder(ctx, bot, message, false)
+}
+
+func scheduleCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	createReminder(ctx, bot, message, true)
+}
+
+// createReminder saves a reminder, prompt reminders are run through the AI when due instead of being sent as is
+func createReminder(ctx context.Context, bot *Bot, message *telego.Message, prompt bool) {
+	chatID := util.GetChatID(message)
+	chatIDString := util.GetChatIDString(message)
+	reply := func(text string) {
+		text = lib.AddBotSuffixToGroupCommands(ctx, text)
+		bot.SendMessage(context.Background(), tu.Message(chatID, text).WithMessageThreadID(message.MessageThreadID))
+	}
+
+	text := ""
+	if parts := strings.SplitN(message.Text, " ", 2); len(parts) > 1 {
+		text = strings.TrimSpace(parts[1])
+	}
+	if text == "" {
+		reply(i18n.T(ctx, "reminders.usage"))
+		return
+	}
+
+	location, timeZone := getUserLocation(ctx)
+	schedule, reminderText, err := lib.ParseReminder(text, time.Now().In(location))
+	if err != nil {
+		reply(i18n.T(ctx, "reminders.invalid", i18n.Args{"error": err.Error()}))
+		return
+	}
+
+	reminders, err := mongo.MongoDBClient.GetReminders(ctx)
+	if err != nil {
+		log.Errorf("Failed to get reminders in chat %s: %v", chatIDString, err)
+		reply(i18n.T(ctx, "oopsie"))
+		return
+	}
+	if len(reminders) >= lib.MAX_REMINDERS_PER_CHAT {
+		reply(i18n.T(ctx, "reminders.limit", i18n.Args{"count": lib.MAX_REMINDERS_PER_CHAT}))
+		return
+	}
+
+	reminder := &models.MongoReminder{
+		ID:        uuid.New().String(),
+		Client:    string(lib.TelegramClientName),
+		TopicId:   util.GetTopicID(message),
+		Text:      reminderText,
+		Prompt:    prompt,
+		Cron:      schedule.Cron,
+		TimeZone:  timeZone,
+		NextRunAt: schedule.Next(time.Now().In(location)),
+	}
+	err = mongo.MongoDBClient.SaveReminder(ctx, reminder)
+	if err != nil {
+		log.Errorf("Failed to save reminder in chat %s: %v", chatIDString, err)
+		reply(i18n.T(ctx, "oopsie"))
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.reminder_created", []string{"prompt:" + strconv.FormatBool(prompt), "recurring:" + strconv.FormatBool(schedule.Cron != "")}, 1)
+	log.Infof("Created reminder %s in chat %s, next run at %s", reminder.ID, chatIDString, reminder.NextRunAt)
+
+	args := i18n.Args{"when": reminder.NextRunAt.In(location).Format(REMINDER_TIME_FORMAT), "timezone": timeZone, "schedule": schedule.Cron}
+	confirmation := i18n.T(ctx, "reminders.created", args)
+	if schedule.Cron != "" {
+		confirmation = i18n.T(ctx, "reminders.created_recurring", args)
+	}
+	if timeZone == "UTC" {
+		confirmation += "\n\n" + i18n.T(ctx, "reminders.timezone_hint")
+	}
+	reply(confirmation)
+}
+
+// remindersCommandHandler lists reminders, cancels them with /reminders cancel <number|all> and sets the time zone with /reminders timezone <zone>
+func remindersCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	chatID := util.GetChatID(message)
+	chatIDString := util.GetChatIDString(message)
+	reply := func(text string) {
+		text = lib.AddBotSuffixToGroupCommands(ctx, text)
+		bot.SendMessage(context.Background(), tu.Message(chatID, text).WithMessageThreadID(message.MessageThreadID))
+	}
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) > 0 && (strings.EqualFold(args[0], "timezone") || strings.EqualFold(args[0], "tz")) {
+		if len(args) == 1 {
+			_, timeZone := getUserLocation(ctx)
+			reply(i18n.T(ctx, "reminders.timezone_current", i18n.Args{"timezone": timeZone}))
+			return
+		}
+		zone := strings.Join(args[1:], "")
+		_, timeZone, err := lib.ParseTimeZone(zone)
+		if err != nil {
+			reply(i18n.T(ctx, "reminders.timezone_invalid", i18n.Args{"timezone": zone}))
+			return
+		}
+		err = mongo.MongoDBClient.UpdateUserTimeZone(ctx, timeZone)
+		if err != nil {
+			log.Errorf("Failed to update time zone in chat %s: %v", chatIDString, err)
+			reply(i18n.T(ctx, "oopsie"))
+			return
+		}
+		config.CONFIG.DataDogClient.Incr("telegram.reminders_timezone_changed", nil, 1)
+		reply(i18n.T(ctx, "reminders.timezone_updated", i18n.Args{"timezone": timeZone}))
+		return
+	}
+
+	reminders, err := mongo.MongoDBClient.GetReminders(ctx)
+	if err != nil {
+		log.Errorf("Failed to get reminders in chat %s: %v", chatIDString, err)
+		reply(i18n.T(ctx, "oopsie"))
+		return
+	}
+
+	if len(args) > 0 && (strings.EqualFold(args[0], "cancel") || strings.EqualFold(args[0], "delete")) {
+		if len(args) < 2 {
+			reply(i18n.T(ctx, "reminders.usage"))
+			return
+		}
+		cancelled := []models.MongoReminder{}
+		if strings.EqualFold(args[1], "all") {
+			cancelled = reminders
+		} else {
+			number, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+			if err != nil || number < 1 || number > len(reminders) {
+				reply(i18n.T(ctx, "reminders.not_found"))
+				return
+			}
+			cancelled = append(cancelled, reminders[number-1])
+		}
+		for _, reminder := range cancelled {
+			err = mongo.MongoDBClient.DeleteReminder(ctx, reminder.ID)
+			if err != nil {
+				log.Errorf("Failed to delete reminder %s in chat %s: %v", reminder.ID, chatIDString, err)
+				reply(i18n.T(ctx, "oopsie"))
+				return
+			}
+		}
+		config.CONFIG.DataDogClient.Count("telegram.reminders_cancelled", int64(len(cancelled)), nil, 1)
+		reply(i18n.T(ctx, "reminders.cancelled", i18n.Args{"count": len(cancelled)}))
+		return
+	}
+
+	if len(reminders) == 0 {
+		reply(i18n.T(ctx, "reminders.empty"))
+		return
+	}
+	location, timeZone := getUserLocation(ctx)
+	reply(i18n.T(ctx, "reminders.list", i18n.Args{"reminders": formatReminders(reminders, location), "timezone": timeZone}))
+}
+
+func formatReminders(reminders []models.MongoReminder, location *time.Location) string {
+	var result strings.Builder
+	for i, reminder := range reminders {
+		icon := "⏰"
+		if reminder.Prompt {
+			icon = "🤖"
+		}
+		schedule := ""
+		if reminder.Cron != "" {
+			schedule = " 🔁 " + reminder.Cron
+		}
+		fmt.Fprintf(&result, "%d. %s %s%s — %s\n", i+1, icon, reminder.NextRunAt.In(location).Format(REMINDER_TIME_FORMAT), schedule, reminder.Text)
+	}
+	return strings.TrimSpace(result.String())
+}
+
+// getUserLocation returns the chat time zone, UTC if it's not set
+func getUserLocation(ctx context.Context) (*time.Location, string) {
+	user, err := mongo.MongoDBClient.GetUser(ctx)
+	if err != nil || user == nil || user.TimeZone == "" {
+		return time.UTC, "UTC"
+	}
+	location, timeZone, err := lib.ParseTimeZone(user.TimeZone)
+	if err != nil {
+		log.Errorf("Invalid time zone %s for chat %s: %v", user.TimeZone, user.ID, err)
+		return time.UTC, "UTC"
+	}
+	return location, timeZone
+}
+
+// FireReminder sends a due reminder to its chat/topic, prompt reminders are answered by the chat's AI engine and billed as usual
+func FireReminder(reminder models.MongoReminder) error {
+	_, ctx, cancelContext, err := lib.SetupUserAndContext(reminder.UserId, lib.TelegramClientName, reminder.UserId, reminder.TopicId)
+	if err != nil {
+		if cancelContext != nil {
+			cancelContext()
+		}
+		return fmt.Errorf("FireReminder: failed to setup user %s: %w", reminder.UserId, err)
+	}
+	defer cancelContext()
+
+	chatIDInt64, err := strconv.ParseInt(reminder.UserId, 10, 64)
+	if err != nil {
+		return fmt.Errorf("FireReminder: invalid chat id %s: %w", reminder.UserId, err)
+	}
+	chatID := tu.ID(chatIDInt64)
+	threadID, _ := strconv.Atoi(reminder.TopicId)
+
+	if !reminder.Prompt {
+		_, err = BOT.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "reminders.fire", i18n.Args{"text": reminder.Text})).WithMessageThreadID(threadID))
+		return err
+	}
+
+	ok, subscription := lib.ValidateUserUsage(ctx)
+	if !ok {
+		config.CONFIG.DataDogClient.Incr("telegram.usage_exceeded", []string{"client:telegram", "channel_type:reminder", "subscription:" + string(subscription)}, 1)
+		notification := lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "usage.exceeded"))
+		_, err = BOT.SendMessage(context.Background(), tu.Message(chatID, notification).WithMessageThreadID(threadID))
+		return err
+	}
+
+	chatType := telego.ChatTypePrivate
+	if strings.HasPrefix(reminder.UserId, "-") {
+		chatType = telego.ChatTypeGroup
+		if reminder.TopicId != "" {
+			chatType = telego.ChatTypeSupergroup
+		}
+	}
+	promptMessage := telego.Message{
+		Chat:            telego.Chat{ID: chatIDInt64, Type: chatType},
+		MessageThreadID: threadID,
+		Text:            reminder.Text,
+	}
+
+	location, timeZone := time.UTC, "UTC"
+	if reminder.TimeZone != "" {
+		if zone, name, err := lib.ParseTimeZone(reminder.TimeZone); err == nil {
+			location, timeZone = zone, name
+		}
+	}
+	seedData := []models.Message{{Role: "system", Content: fmt.Sprintf(REMINDER_PROMPT, time.Now().In(location).Format(REMINDER_TIME_FORMAT), timeZone)}}
+	ctx = context.WithValue(ctx, models.ParamsContext{}, "")
+	promptCtx, cancelPrompt := context.WithTimeout(ctx, lib.TIMEOUT)
+	sendTypingAction(BOT.Bot, &promptMessage)
+	ProcessChatCompleteStreamingMessage(promptCtx, BOT.Bot, &promptMessage, seedData, "", lib.ChatGPT, redis.GetModel(reminder.UserId), cancelPrompt)
+	return nil
+}
@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/converters"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/google/uuid"
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// MAX_VIDEO_UPLOAD_SIZE is the Telegram Bot API limit for sending files
+const MAX_VIDEO_UPLOAD_SIZE = 50 * 1024 * 1024
+
+// transcribeCommandHandler switches to transcribe mode, /transcribe [srt|vtt|timestamps] [burn] [language]
+func transcribeCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	chatIDString := util.GetChatIDString(message)
+	if message.Chat.Type != telego.ChatTypePrivate && !lib.IsGroupModeAllowed(lib.GetGroupSettings(chatIDString), lib.Transcribe) {
+		getModeHandlerFunction(lib.Transcribe, "mode.transcribe")(ctx, bot, message)
+		return
+	}
+
+	options, rest := lib.ParseTranscriptOptions(strings.Fields(message.Text)[1:])
+	modeMessage := *message
+	modeMessage.Text = strings.TrimSpace(string(TranscribeCommand) + " " + strings.Join(rest, " "))
+	getModeHandlerFunction(lib.Transcribe, "mode.transcribe")(ctx, bot, &modeMessage)
+
+	err := lib.SaveTranscriptOptions(chatIDString, util.GetTopicID(message), options)
+	if err != nil {
+		log.Errorf("Failed to save transcript options in chat %s: %v", chatIDString, err)
+		return
+	}
+	if !options.Timed() {
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.transcript_format_set", []string{"format:" + string(options.Format), "burn:" + strconv.FormatBool(options.BurnSubtitles)}, 1)
+	notification := i18n.T(ctx, "transcribe.format_enabled", i18n.Args{"format": string(options.Format)})
+	if options.BurnSubtitles {
+		notification += "\n" + i18n.T(ctx, "transcribe.burn_enabled")
+	}
+	bot.SendMessage(context.Background(), tu.Message(util.GetChatID(message), notification).WithMessageThreadID(message.MessageThreadID))
+}
+
+// sendSubtitles sends the timed transcript as a subtitle/text document, videos are also sent back with burned-in subtitles if asked
+func sendSubtitles(ctx context.Context, bot *telego.Bot, message *telego.Message, options lib.TranscriptOptions, segments []models.TranscriptSegment, sourceFile string) {
+	chatIDString := util.GetChatIDString(message)
+	if len(segments) == 0 {
+		log.Warnf("No transcript timings to render %s subtitles in chat %s", options.Format, chatIDString)
+		return
+	}
+
+	content, fileName := converters.RenderSRT(segments), "transcript.srt"
+	switch options.Format {
+	case lib.TranscriptFormatVTT:
+		content, fileName = converters.RenderVTT(segments), "transcript.vtt"
+	case lib.TranscriptFormatTimestamps:
+		content, fileName = converters.RenderTimestamps(segments), "transcript.txt"
+	}
+	_, err := bot.SendDocument(context.Background(), &telego.SendDocumentParams{
+		ChatID:          message.Chat.ChatID(),
+		MessageThreadID: message.MessageThreadID,
+		Document:        telego.InputFile{File: NamedReader{Reader: strings.NewReader(content), name: fileName}},
+	})
+	if err != nil {
+		log.Errorf("Failed to send %s subtitles in chat %s: %v", options.Format, chatIDString, err)
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.subtitles_sent", []string{"format:" + string(options.Format)}, 1)
+
+	if options.BurnSubtitles && (message.Video != nil || message.VideoNote != nil) {
+		sendVideoWithSubtitles(ctx, bot, message, segments, sourceFile)
+	}
+}
+
+func sendVideoWithSubtitles(ctx context.Context, bot *telego.Bot, message *telego.Message, segments []models.TranscriptSegment, sourceFile string) {
+	chatIDString := util.GetChatIDString(message)
+	sendFailure := func() {
+		bot.SendMessage(context.Background(), tu.Message(message.Chat.ChatID(), i18n.T(ctx, "transcribe.burn_failed")).WithMessageThreadID(message.MessageThreadID))
+	}
+	bot.SendChatAction(context.Background(), &telego.SendChatActionParams{ChatID: message.Chat.ChatID(), Action: telego.ChatActionUploadVideo, MessageThreadID: message.MessageThreadID})
+
+	baseName := "/data/" + uuid.New().String()
+	subtitlesFile, videoFile := baseName+".srt", baseName+".mp4"
+	defer util.SafeOsDelete(subtitlesFile)
+	defer util.SafeOsDelete(videoFile)
+	err := os.WriteFile(subtitlesFile, []byte(converters.RenderSRT(segments)), 0644)
+	if err != nil {
+		log.Errorf("Failed to write subtitles %s in chat %s: %v", subtitlesFile, chatIDString, err)
+		sendFailure()
+		return
+	}
+	err = converters.BurnSubtitles(sourceFile, subtitlesFile, videoFile)
+	if err != nil {
+		log.Errorf("Failed to burn subtitles in chat %s: %v", chatIDString, err)
+		sendFailure()
+		return
+	}
+
+	videoInfo, err := os.Stat(videoFile)
+	if err != nil || videoInfo.Size() > MAX_VIDEO_UPLOAD_SIZE {
+		log.Warnf("Video with subtitles in chat %s is too big to send: %v", chatIDString, err)
+		sendFailure()
+		return
+	}
+	video, err := os.Open(videoFile)
+	if err != nil {
+		log.Errorf("Failed to open video with subtitles in chat %s: %v", chatIDString, err)
+		sendFailure()
+		return
+	}
+	defer video.Close()
+	_, err = bot.SendVideo(context.Background(), &telego.SendVideoParams{
+		ChatID:            message.Chat.ChatID(),
+		MessageThreadID:   message.MessageThreadID,
+		Video:             telego.InputFile{File: NamedReader{Reader: video, name: "subtitled.mp4"}},
+		SupportsStreaming: true,
+	})
+	if err != nil {
+		log.Errorf("Failed to send video with subtitles in chat %s: %v", chatIDString, err)
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.subtitled_video_sent", nil, 1)
+}
@@ -12,14 +12,17 @@ import (
 	"strconv"
 	"strings"
 	"talk2robots/m/v2/app/ai"
+	"talk2robots/m/v2/app/ai/intent"
 	"talk2robots/m/v2/app/ai/openai"
 	"talk2robots/m/v2/app/config"
 	"talk2robots/m/v2/app/converters"
 	"talk2robots/m/v2/app/db/mongo"
 	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/models"
 	"talk2robots/m/v2/app/payments"
+	"talk2robots/m/v2/app/search"
 	"talk2robots/m/v2/app/util"
 	"time"
 
@@ -41,6 +44,7 @@ type Bot struct {
 	Dummy   bool
 	telego.ChatID
 	WhisperConfig openai.WhisperConfig
+	Search        search.SearchProvider
 }
 
 var AllCommandHandlers CommandHandlers = CommandHandlers{}
@@ -88,9 +92,11 @@ func NewBot(cfg *config.Config) (*Bot, error) {
 			StopTimeout:        5 * time.Second,
 			OnTranscribe:       nil,
 		},
+		Search:  search.NewProvider(cfg),
 		Server:  server,
 		Handler: server.Handler,
 	}
+	log.Infof("Web search provider: %s", BOT.Search.Name())
 
 	return BOT, nil
 }
@@ -128,6 +134,11 @@ func signBotForUpdates(bot *telego.Bot, system bool) (<-chan telego.Update, *fas
 func handleMessage(bhctx *th.Context, message telego.Message) error {
 	bot := bhctx.Bot()
 
+	// album parts arrive as separate messages, they are answered once all parts are in
+	if message.MediaGroupID != "" {
+		albums.add(bot, message)
+		return nil
+	}
 	return handleMessageWithBot(bot, message)
 }
 
@@ -136,7 +147,7 @@ func handleMessageWithBot(bot *telego.Bot, message telego.Message) error {
 	chatIDString := util.GetChatIDString(&message)
 	topicID := util.GetTopicID(&message)
 	isPrivate := message.Chat.Type == "private"
-	_, ctx, cancelContext, err := lib.SetupUserAndContext(chatIDString, "telegram", chatIDString, topicID)
+	user, ctx, cancelContext, err := lib.SetupUserAndContext(chatIDString, "telegram", chatIDString, topicID)
 	if err != nil {
 		if err == lib.ErrUserBanned {
 			log.Infof("User %s is banned", chatIDString)
@@ -146,15 +157,25 @@ func handleMessageWithBot(bot *telego.Bot, message telego.Message) error {
 		log.Errorf("Error setting up user and context: %v", err)
 		return err
 	}
+	ctx = withUserLanguage(ctx, user, message.From)
+	if !isPrivate && message.From != nil {
+		ctx = context.WithValue(ctx, models.MemberContext{}, fmt.Sprint(message.From.ID))
+	}
 
 	// process commands
-	if message.Voice == nil && message.Audio == nil && message.Video == nil && message.VideoNote == nil && message.Document == nil && message.Photo == nil && (message.Text == string(EmptyCommand) || strings.HasPrefix(message.Text, "/")) {
+	if message.Voice == nil && message.Audio == nil && message.Video == nil && message.VideoNote == nil && message.Document == nil && message.Photo == nil && (message.Text == string(EmptyCommand) || strings.HasPrefix(message.Text, "/")) && !isEditCommand(message.Text) {
 		if !isPrivate {
 			if !strings.Contains(message.Text, "@"+BOT.Name) {
 				log.Infof("Ignoring public command w/o @mention in channel: %s", chatIDString)
 				return err
 			}
 
+			// member commands are available to everyone, the rest only to admins
+			if isMemberCommand(message.Text) {
+				AllCommandHandlers.handleCommand(ctx, BOT, &message)
+				return nil
+			}
+
 			// only allow admins to use commands in channels
 			chatMember, err := bot.GetChatMember(context.Background(), &telego.GetChatMemberParams{
 				ChatID: chatID,
@@ -175,18 +196,57 @@ func handleMessageWithBot(bot *telego.Bot, message telego.Message) error {
 		return nil
 	}
 
+	// a reply to the "what was wrong?" question after a dislike
+	if handleFeedbackComment(ctx, bot, &message) {
+		return nil
+	}
+
+	// opted-in groups keep recent messages for /summarize digests, voice is transcribed when a digest is asked for
+	isAudioMessage, _ := util.IsAudioMessage(&message)
+	if !isPrivate {
+		bufferGroupMessage(&message, message.Text+message.Caption, "")
+	}
+
 	mode, params := lib.GetMode(chatIDString, topicID)
 	log.Infof("chat %s, mode: %s, params: %s", chatIDString, mode, params)
 	ctx = context.WithValue(ctx, models.ParamsContext{}, params)
 	// while in channels, only react to
-	// 1. @mentions
+	// 1. @mentions, replies or keywords, according to /groupsettings triggers
 	// 2. audio messages in /transcribe mode
 	// 3. /grammar fixes
-	if !isPrivate && mode != lib.Transcribe && mode != lib.Grammar && !strings.Contains(message.Text, "@"+BOT.Name) {
-		log.Infof("Ignoring public message w/o @mention and not in transcribe or grammar mode in channel: %s", chatIDString)
+	groupSettings := lib.DefaultGroupSettings()
+	isTriggered := isPrivate
+	if !isPrivate {
+		groupSettings = lib.GetGroupSettings(chatIDString)
+		isTriggered = isGroupMessageTriggered(&message, groupSettings)
+	}
+	if !isPrivate && mode != lib.Transcribe && mode != lib.Grammar && !isTriggered {
+		log.Infof("Ignoring public message w/o trigger and not in transcribe or grammar mode in channel: %s", chatIDString)
 		return nil
 	}
 
+	// enforce group policy set by admins
+	if !isPrivate {
+		notice := ""
+		if !lib.IsGroupModeAllowed(groupSettings, mode) {
+			notice = lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "group.mode_disabled"))
+		} else if isAudioMessage && !groupSettings.VoiceAllowed {
+			notice = i18n.T(ctx, "group.voice_disabled")
+		} else if message.Photo != nil && !groupSettings.ImagesAllowed {
+			notice = i18n.T(ctx, "group.images_disabled")
+		}
+		if notice != "" {
+			log.Infof("Group policy rejected message in chat %s, mode: %s", chatIDString, mode)
+			if isTriggered {
+				sendGroupPolicyNotice(bot, &message, notice)
+			}
+			return nil
+		}
+	}
+
+	// remember users' own messages, so reactions can be acted upon
+	cacheIncomingMessageText(&message)
+
 	if message.Video != nil && strings.HasPrefix(message.Caption, string(SYSTEMSetOnboardingVideoCommand)) {
 		log.Infof("System command received: %+v", message) // audit
 		message.Text = string(SYSTEMSetOnboardingVideoCommand)
@@ -208,18 +268,37 @@ func handleMessageWithBot(bot *telego.Bot, message telego.Message) error {
 		return nil
 	}
 
+	// group member exceeded the monthly cap set by admins
+	if !isPrivate && !lib.ValidateGroupMemberUsage(ctx, groupSettings) {
+		config.CONFIG.DataDogClient.Incr("telegram.member_usage_exceeded", []string{"channel_type:" + message.Chat.Type}, 1)
+		if isTriggered {
+			sendGroupPolicyNotice(bot, &message, i18n.T(ctx, "group.member_cap_reached", i18n.Args{"cap": fmt.Sprintf("%.2f", groupSettings.MemberMonthlyCap)}))
+		}
+		return nil
+	}
+
 	voiceTranscriptionText := ""
 	// if the message is voice/audio/video message, process it to upload to WhisperAI API and get the transcription
 	if ok, voice_type := util.IsAudioMessage(&message); ok {
 		config.CONFIG.DataDogClient.Incr("telegram.voice_message_received", []string{"type:" + voice_type, "channel_type:" + message.Chat.Type}, 1)
 
 		// send typing action to show that bot is working
-		if mode != lib.VoiceGPT {
+		if mode != lib.VoiceGPT && mode != lib.Interpret {
 			sendTypingAction(bot, &message)
 		} else {
 			sendAudioAction(bot, &message)
 		}
-		voiceTranscriptionText = getVoiceTranscript(ctx, bot, message)
+		transcriptOptions := lib.TranscriptOptions{}
+		if mode == lib.Transcribe {
+			transcriptOptions = lib.GetTranscriptOptions(chatIDString, topicID)
+		}
+		if mode == lib.Interpret {
+			transcriptOptions.Prompt = interpretTranscriptionPrompt(params)
+		}
+		voiceTranscriptionText = getVoiceTranscript(withTranscriptionLanguage(ctx, mode), bot, message, transcriptOptions)
+		if !isPrivate {
+			saveBufferedTranscript(&message, voiceTranscriptionText)
+		}
 
 		if mode != lib.Transcribe {
 			// combine message text with transcription
@@ -227,72 +306,108 @@ func handleMessageWithBot(bot *telego.Bot, message telego.Message) error {
 				message.Text = message.Text + "\n" + voiceTranscriptionText
 			}
 
+			cacheIncomingMessageText(&message)
+
 			// process commands again if it was a voice command
 			if message.Text == string(EmptyCommand) || strings.HasPrefix(message.Text, "/") {
 				AllCommandHandlers.handleCommand(ctx, BOT, &message)
 				return nil
 			}
 		}
+	} else if util.IsDocumentMessage(&message) && !isImageEditMask(&message) {
+		// groups in transcribe or grammar modes only read documents when asked
+		if !isTriggered {
+			return nil
+		}
+		if !DOCUMENT_MODES[mode] {
+			notice := lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "documents.switch_mode"))
+			bot.SendMessage(context.Background(), tu.Message(chatID, notice).WithMessageThreadID(message.MessageThreadID))
+			return nil
+		}
+		sendTypingAction(bot, &message)
+		document, ok := getDocument(ctx, bot, &message)
+		if !ok {
+			return nil
+		}
+		if mode == lib.Transcribe {
+			ChunkSendText(bot, &message, document.Text())
+			return nil
+		}
+		withDocumentText(ctx, bot, &message, document)
 	} else if message.Text != "" {
 		config.CONFIG.DataDogClient.Incr("telegram.text_message_received", []string{"channel_type:" + message.Chat.Type}, 1)
 	}
 
+	if edit, ok := getImageEdit(&message, mode); ok && isTriggered {
+		if !isPrivate && !groupSettings.ImagesAllowed {
+			sendGroupPolicyNotice(bot, &message, i18n.T(ctx, "group.image_generation_disabled"))
+			return nil
+		}
+		return editImage(ctx, bot, &message, edit)
+	}
+
+	// interpreter speaks the translation back instead of chatting, drawing or searching
+	if mode == lib.Interpret {
+		go interpretMessage(ctx, bot, &message, params)
+		return nil
+	}
+
+	// dictation writes or edits the draft, transcripts are not echoed as the draft follows
+	if mode == lib.Dictate {
+		go dictateMessage(ctx, bot, &message, params)
+		return nil
+	}
+
 	if mode == lib.Transcribe {
-		ChunkSendMessage(bot, &message, voiceTranscriptionText)
+		ChunkSendText(bot, &message, voiceTranscriptionText)
 		if isPrivate && message.Text != "" {
-			bot.SendMessage(context.Background(), tu.Message(chatID, "The bot is in /transcribe mode. Please send a voice/audio/video message to transcribe or change to another mode (/status)."))
+			bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "mode.transcribe_hint")))
 		}
 		return nil
 	}
 
 	if mode != lib.VoiceGPT && !(mode == lib.Grammar && !isPrivate) && voiceTranscriptionText != "" {
-		ChunkSendMessage(bot, &message, "🗣:\n"+voiceTranscriptionText)
+		ChunkSendText(bot, &message, "🗣:\n"+voiceTranscriptionText)
 	}
 
-	if IsCreateImageCommand(message.Text) {
-		config.CONFIG.DataDogClient.Incr("telegram.create_image_received", []string{"channel_type:" + message.Chat.Type}, 1)
-		log.Infof("Generating image in a chat %s..", chatIDString)
-		sendImageAction(bot, &message)
-		url, revisedPrompt, err := openai.CreateImage(ctx, message.Text)
-		if err != nil {
-			if strings.Contains(err.Error(), "content_policy_violation") {
-				log.Warnf("Content policy violation in chat %s", chatIDString)
-				config.CONFIG.DataDogClient.Incr("telegram.image.content_policy_violation", []string{"client:telegram", "channel_type:" + message.Chat.Type}, 1)
-				bot.SendMessage(context.Background(), tu.Message(chatID, "Sorry, I can't create an image with that content. Please try again with a different prompt.").WithMessageThreadID(message.MessageThreadID))
-				return err
-			}
-			log.Errorf("Error creating image in chat %s: %v", chatIDString, err)
-			return err
-		}
-		log.Infof("Sending image to chat %s", chatIDString)
-		if len(revisedPrompt) > 1000 {
-			revisedPrompt = revisedPrompt[:997] + "..."
-		}
-		if url != "" {
-			_, err := bot.SendPhoto(context.Background(), &telego.SendPhotoParams{
-				ChatID:          chatID,
-				Photo:           telego.InputFile{URL: url},
-				Caption:         revisedPrompt,
-				MessageThreadID: message.MessageThreadID,
-			})
-
-			if err != nil {
-				log.Errorf("Error sending image to chat %s: %v", chatIDString, err)
-			}
+	route := routeIntent(ctx, &message, mode, groupSettings)
+	if route.Intent == intent.Image {
+		if !isPrivate && !groupSettings.ImagesAllowed {
+			sendGroupPolicyNotice(bot, &message, i18n.T(ctx, "group.image_generation_disabled"))
+			return nil
 		}
-		return nil
+		return generateImage(ctx, bot, &message)
 	}
 
 	if message.Photo != nil {
 		config.CONFIG.DataDogClient.Incr("telegram.photo_message_received", []string{"channel_type:" + message.Chat.Type}, 1)
 	}
 
+	switch route.Intent {
+	case intent.Translate:
+		mode = lib.Translate
+		ctx = context.WithValue(ctx, models.ParamsContext{}, route.Language)
+	case intent.Summarize:
+		mode = lib.Summarize
+	}
+	if mode == lib.Search || route.Intent == intent.Search {
+		withSearchResults(ctx, bot, &message)
+	} else {
+		withLinkPages(ctx, bot, &message, mode)
+	}
+
 	var seedData []models.Message
 	var userMessagePrimer string
 	seedData, userMessagePrimer = lib.GetSeedDataAndPrimer(mode)
 
 	log.Debugf("Received message: %d, in chat: %d, initiating request to AI", message.MessageID, chatID.ID)
 	engineModel := redis.GetModel(chatIDString)
+	if !isPrivate && !lib.IsGroupEngineAllowed(groupSettings, engineModel) {
+		groupEngine := lib.GetGroupEngine(groupSettings, ctx.Value(models.SubscriptionContext{}).(models.MongoSubscriptionName), engineModel)
+		log.Infof("Engine %s is not allowed in chat %s, using %s", engineModel, chatIDString, groupEngine)
+		engineModel = groupEngine
+	}
+	rememberPrompt(ctx, &message, mode, engineModel)
 
 	// send action to show that bot is working
 	if mode != lib.VoiceGPT {
@@ -300,7 +415,7 @@ func handleMessageWithBot(bot *telego.Bot, message telego.Message) error {
 	} else {
 		sendAudioAction(bot, &message)
 	}
-	if mode == lib.ChatGPT || mode == lib.VoiceGPT {
+	if mode == lib.ChatGPT || mode == lib.VoiceGPT || mode == lib.Search {
 		go ProcessThreadedStreamingMessage(ctx, bot, &message, mode, engineModel, cancelContext)
 	} else if mode == lib.Summarize || mode == lib.Translate || (mode == lib.Grammar && isPrivate) {
 		go ProcessChatCompleteStreamingMessage(ctx, bot, &message, seedData, userMessagePrimer, mode, engineModel, cancelContext)
@@ -338,6 +453,22 @@ func handleCallbackQuery(bhctx *th.Context, callbackQuery telego.CallbackQuery)
 
 	log.Infof("Callback query %s for user: %d in chat %d, topic %s, messageId %d", callbackQuery.Data, userId, chatId, topicString, messageId)
 	config.CONFIG.DataDogClient.Incr("telegram.callback_query", []string{"data:" + callbackQuery.Data, "channel_type:" + chatType}, 1)
+	if strings.HasPrefix(callbackQuery.Data, VOICE_CALLBACK_PREFIX) {
+		handleVoiceCallbackQuery(callbackQuery, topicString)
+		return nil
+	}
+	if strings.HasPrefix(callbackQuery.Data, IMAGE_OPTIONS_CALLBACK_PREFIX) {
+		handleImageOptionsCallbackQuery(callbackQuery, topicString)
+		return nil
+	}
+	if strings.HasPrefix(callbackQuery.Data, GALLERY_CALLBACK_PREFIX) {
+		handleGalleryCallbackQuery(callbackQuery, topicString)
+		return nil
+	}
+	if strings.HasPrefix(callbackQuery.Data, DICTATE_CALLBACK_PREFIX) {
+		handleDictateCallbackQuery(callbackQuery, topicString)
+		return nil
+	}
 	switch callbackQuery.Data {
 	case "like":
 		log.Infof("User %d liked a message in chat %d.", userId, chatId)
@@ -350,9 +481,10 @@ func handleCallbackQuery(bhctx *th.Context, callbackQuery telego.CallbackQuery)
 			log.Errorf("[like] Failed to edit message reply markup in chat %d: %v", chatId, err)
 		}
 		config.CONFIG.DataDogClient.Incr("telegram.like", []string{"channel_type:" + chatType}, 1)
+		go saveCallbackFeedback(callbackQuery, topicString)
 		bot.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "Thanks for your feedback! 👍",
+			Text:            i18n.Translate(callbackQuery.From.LanguageCode, "feedback.liked"),
 		})
 	case "dislike":
 		log.Infof("User %d disliked a message in chat %d.", userId, chatId)
@@ -365,11 +497,13 @@ func handleCallbackQuery(bhctx *th.Context, callbackQuery telego.CallbackQuery)
 			log.Errorf("[like] Failed to edit message reply markup in chat %d: %v", chatId, err)
 		}
 		config.CONFIG.DataDogClient.Incr("telegram.dislike", []string{"channel_type:" + chatType}, 1)
+		go saveCallbackFeedback(callbackQuery, topicString)
 		bot.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "Thanks for your feedback!",
+			Text:            i18n.Translate(callbackQuery.From.LanguageCode, "feedback.thanks"),
 		})
-	case string(lib.ChatGPT), string(lib.VoiceGPT), string(lib.Grammar), string(lib.Teacher), string(lib.Summarize), string(lib.Transcribe), string(lib.Translate):
+		askWhatWasWrong(bot, chat, topicId, messageId, callbackQuery.From.LanguageCode)
+	case string(lib.ChatGPT), string(lib.VoiceGPT), string(lib.Grammar), string(lib.Teacher), string(lib.Summarize), string(lib.Transcribe), string(lib.Translate), string(lib.Search):
 		handleCommandsInCallbackQuery(callbackQuery, topicString)
 	case "models":
 		bot.EditMessageReplyMarkup(ctx, &telego.EditMessageReplyMarkupParams{
@@ -377,12 +511,24 @@ func handleCallbackQuery(bhctx *th.Context, callbackQuery telego.CallbackQuery)
 			MessageID:   messageId,
 			ReplyMarkup: GetModelsKeyboard(ctx),
 		})
+	case "voicesettings":
+		bot.EditMessageReplyMarkup(ctx, &telego.EditMessageReplyMarkupParams{
+			ChatID:      chat.ChatID(),
+			MessageID:   messageId,
+			ReplyMarkup: GetVoiceKeyboard(ctx),
+		})
 	case "images":
 		bot.EditMessageReplyMarkup(ctx, &telego.EditMessageReplyMarkupParams{
 			ChatID:      chat.ChatID(),
 			MessageID:   messageId,
 			ReplyMarkup: GetImageModelsKeyboard(ctx),
 		})
+	case "imageoptions":
+		bot.EditMessageReplyMarkup(ctx, &telego.EditMessageReplyMarkupParams{
+			ChatID:      chat.ChatID(),
+			MessageID:   messageId,
+			ReplyMarkup: GetImageOptionsKeyboard(ctx),
+		})
 	case "status":
 		bot.EditMessageReplyMarkup(ctx, &telego.EditMessageReplyMarkupParams{
 			ChatID:      chat.ChatID(),
@@ -401,10 +547,10 @@ func handleCallbackQuery(bhctx *th.Context, callbackQuery telego.CallbackQuery)
 		err := mongo.MongoDBClient.UpdateUserSubscription(ctx, models.Subscriptions[models.FreeSubscriptionName])
 		if err != nil {
 			log.Errorf("Failed to downgrade user %s subscription: to free %v", chatString, err)
-			bot.SendMessage(context.Background(), tu.Message(tu.ID(chatId), "Failed to downgrade your account to free plan. Please try again later.").WithMessageThreadID(topicId))
+			bot.SendMessage(context.Background(), tu.Message(tu.ID(chatId), i18n.T(ctx, "downgrade.free_failed")).WithMessageThreadID(topicId))
 			return err
 		}
-		bot.SendMessage(context.Background(), tu.Message(tu.ID(chatId), "You are now a free user!").WithMessageThreadID(topicId))
+		bot.SendMessage(context.Background(), tu.Message(tu.ID(chatId), i18n.T(ctx, "downgrade.free_done")).WithMessageThreadID(topicId))
 	case "downgradefrombasic":
 		user, ctx, _, err := lib.SetupUserAndContext(chatString, "telegram", chatString, topicString)
 		if err != nil {
@@ -421,9 +567,9 @@ func handleCallbackQuery(bhctx *th.Context, callbackQuery telego.CallbackQuery)
 			err := mongo.MongoDBClient.UpdateUserSubscription(ctx, models.Subscriptions[models.FreePlusSubscriptionName])
 			if err != nil {
 				log.Errorf("Failed to downgrade user %s subscription: to free+ %v", chatString, err)
-				bot.SendMessage(context.Background(), tu.Message(tu.ID(chatId), "Failed to downgrade your account to free+ plan. Please try again later.").WithMessageThreadID(topicId))
+				bot.SendMessage(context.Background(), tu.Message(tu.ID(chatId), i18n.T(ctx, "downgrade.free_plus_failed")).WithMessageThreadID(topicId))
 			}
-			bot.SendMessage(context.Background(), tu.Message(tu.ID(chatId), "You are now a free+ user!").WithMessageThreadID(topicId))
+			bot.SendMessage(context.Background(), tu.Message(tu.ID(chatId), i18n.T(ctx, "downgrade.free_plus_done")).WithMessageThreadID(topicId))
 			return err
 		}
 
@@ -477,7 +623,7 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 	if callbackQuery.Data == string(currentEngine) {
 		err := BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "You are already using " + callbackQuery.Data + " engine!",
+			Text:            i18n.T(ctx, "engine.already_using", i18n.Args{"engine": callbackQuery.Data}),
 		})
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to answer callback query, already using: %v", err)
@@ -495,13 +641,13 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 	}()
 	if callbackQuery.Data == string(models.LlamaV3_8b) {
 		go redis.SaveModel(chatIDString, models.LlamaV3_8b)
-		_, err := BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), "Switched to small Llama3 model, fast and cheap!").WithMessageThreadID(topicID))
+		_, err := BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), i18n.T(ctx, "engine.llama_small")).WithMessageThreadID(topicID))
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to send Llama3 small message: %v", err)
 		}
 		err = BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "Switched to small Llama3 model!",
+			Text:            i18n.T(ctx, "engine.llama_small_short"),
 		})
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to answer callback query: %v", err)
@@ -510,13 +656,13 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 	}
 	if callbackQuery.Data == string(models.ChatGpt35Turbo) {
 		go redis.SaveModel(chatIDString, models.ChatGpt35Turbo)
-		_, err := BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), "Switched to GPT-3.5 Turbo model, fast and cheap!").WithMessageThreadID(topicID))
+		_, err := BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), i18n.T(ctx, "engine.gpt35")).WithMessageThreadID(topicID))
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to send GPT-3.5 message: %v", err)
 		}
 		err = BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "Switched to " + callbackQuery.Data + " engine!",
+			Text:            i18n.T(ctx, "engine.switched", i18n.Args{"engine": callbackQuery.Data}),
 		})
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to answer callback query: %v", err)
@@ -525,13 +671,13 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 	}
 	if callbackQuery.Data == string(models.ChatGpt4oMini) {
 		go redis.SaveModel(chatIDString, models.ChatGpt4oMini)
-		_, err := BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), "Switched to GPT-4o Mini model, fast and cheap!").WithMessageThreadID(topicID))
+		_, err := BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), i18n.T(ctx, "engine.gpt4o_mini")).WithMessageThreadID(topicID))
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to send GPT-4o Mini message: %v", err)
 		}
 		err = BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "Switched to " + callbackQuery.Data + " engine!",
+			Text:            i18n.T(ctx, "engine.switched", i18n.Args{"engine": callbackQuery.Data}),
 		})
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to answer callback query: %v", err)
@@ -543,17 +689,17 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 		user, err := mongo.MongoDBClient.GetUser(ctx)
 		if err != nil {
 			log.Errorf("Failed to get user: %v", err)
-			BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), fmt.Sprintf("Failed to switch to %s model, please try again later", callbackQuery.Data)).WithMessageThreadID(topicID))
+			BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), i18n.T(ctx, "engine.switch_failed", i18n.Args{"engine": callbackQuery.Data})).WithMessageThreadID(topicID))
 			return
 		}
 		if user.SubscriptionType.Name == models.FreeSubscriptionName || user.SubscriptionType.Name == models.FreePlusSubscriptionName {
-			notification := fmt.Sprintf("To use %s model check available /upgrade options! Meanwhile, you can still use GPT-3.5 Turbo, it's fast, cheap and quite smart.", callbackQuery.Data)
+			notification := i18n.T(ctx, "engine.upgrade_required", i18n.Args{"engine": callbackQuery.Data})
 			notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
 			BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), notification).WithMessageThreadID(topicID))
 			return
 		}
 		go redis.SaveModel(chatIDString, models.Engine(callbackQuery.Data))
-		notification := fmt.Sprintf("Switched to %s model, very intelligent, but slower and expensive! Don't forget to check /status regularly to avoid hitting the usage cap.", callbackQuery.Data)
+		notification := i18n.T(ctx, "engine.gpt4", i18n.Args{"engine": callbackQuery.Data})
 		notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
 		_, err = BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), notification).WithMessageThreadID(topicID))
 		if err != nil {
@@ -561,7 +707,7 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 		}
 		err = BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "Switched to " + callbackQuery.Data + " engine!",
+			Text:            i18n.T(ctx, "engine.switched", i18n.Args{"engine": callbackQuery.Data}),
 		})
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to answer callback query: %v", err)
@@ -573,17 +719,17 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 		user, err := mongo.MongoDBClient.GetUser(ctx)
 		if err != nil {
 			log.Errorf("Failed to get user: %v", err)
-			BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), fmt.Sprintf("Failed to switch to %s model, please try again later", callbackQuery.Data)).WithMessageThreadID(topicID))
+			BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), i18n.T(ctx, "engine.switch_failed", i18n.Args{"engine": callbackQuery.Data})).WithMessageThreadID(topicID))
 			return
 		}
 		if user.SubscriptionType.Name == models.FreeSubscriptionName || user.SubscriptionType.Name == models.FreePlusSubscriptionName {
-			notification := fmt.Sprintf("To use %s models check available /upgrade options! Meanwhile, you can still use GPT-3.5 Turbo, it's fast, cheap and quite smart.", "Claude AI")
+			notification := i18n.T(ctx, "engine.upgrade_required", i18n.Args{"engine": "Claude AI"})
 			notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
 			BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), notification).WithMessageThreadID(topicID))
 			return
 		}
 		go redis.SaveModel(chatIDString, models.Engine(callbackQuery.Data))
-		notification := fmt.Sprintf("Switched to %s model! Don't forget to check /status regularly to avoid hitting the usage cap.", callbackQuery.Data)
+		notification := i18n.T(ctx, "engine.claude", i18n.Args{"engine": callbackQuery.Data})
 		notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
 		_, err = BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), notification).WithMessageThreadID(topicID))
 		if err != nil {
@@ -591,7 +737,7 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 		}
 		err = BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "Switched to " + callbackQuery.Data + " engine!",
+			Text:            i18n.T(ctx, "engine.switched", i18n.Args{"engine": callbackQuery.Data}),
 		})
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to answer callback query: %v", err)
@@ -600,7 +746,7 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 	}
 	if callbackQuery.Data == string(models.LlamaV3_70b) {
 		go redis.SaveModel(chatIDString, models.LlamaV3_70b)
-		notification := "Switched to big Llama3 model, intelligent, but slower and expensive! Don't forget to check /status regularly to avoid hitting the usage cap."
+		notification := i18n.T(ctx, "engine.llama_big")
 		notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
 		_, err := BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), notification).WithMessageThreadID(topicID))
 		if err != nil {
@@ -608,7 +754,7 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 		}
 		err = BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "Switched to big Llama3 engine!",
+			Text:            i18n.T(ctx, "engine.llama_big_short"),
 		})
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to answer callback query: %v", err)
@@ -617,7 +763,7 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 	}
 	if callbackQuery.Data == string(models.Grok) {
 		go redis.SaveModel(chatIDString, models.Grok)
-		notification := "Switched to Grok model, intelligent and fun!"
+		notification := i18n.T(ctx, "engine.grok")
 		notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
 		_, err := BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), notification).WithMessageThreadID(topicID))
 		if err != nil {
@@ -625,7 +771,7 @@ func handleEngineSwitchCallbackQuery(callbackQuery telego.CallbackQuery, topicSt
 		}
 		err = BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "Switched to Grok engine!",
+			Text:            i18n.T(ctx, "engine.switched", i18n.Args{"engine": "Grok"}),
 		})
 		if err != nil {
 			log.Errorf("handleEngineSwitchCallbackQuery failed to answer callback query: %v", err)
@@ -650,7 +796,7 @@ func handleImageModelSwitchCallbackQuery(callbackQuery telego.CallbackQuery, top
 	if callbackQuery.Data == string(currentModel) {
 		err := BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 			CallbackQueryID: callbackQuery.ID,
-			Text:            "You are already using " + callbackQuery.Data + " model!",
+			Text:            i18n.T(ctx, "image_model.already_using", i18n.Args{"model": callbackQuery.Data}),
 		})
 		if err != nil {
 			log.Errorf("handleImageModelSwitchCallbackQuery failed to answer callback query, already using: %v", err)
@@ -658,7 +804,7 @@ func handleImageModelSwitchCallbackQuery(callbackQuery telego.CallbackQuery, top
 		return
 	}
 	go redis.SaveImageModel(chatIDString, models.Engine(callbackQuery.Data))
-	notification := fmt.Sprintf("Switched to %s image model, enjoy!", callbackQuery.Data)
+	notification := i18n.T(ctx, "image_model.switched", i18n.Args{"model": callbackQuery.Data})
 	notification = lib.AddBotSuffixToGroupCommands(ctx, notification)
 	_, err := BOT.SendMessage(ctx, tu.Message(tu.ID(chatID), notification).WithMessageThreadID(topicID))
 	if err != nil {
@@ -666,80 +812,13 @@ func handleImageModelSwitchCallbackQuery(callbackQuery telego.CallbackQuery, top
 	}
 	err = BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
 		CallbackQueryID: callbackQuery.ID,
-		Text:            "Switched to " + callbackQuery.Data + " image model!",
+		Text:            i18n.T(ctx, "image_model.switched_short", i18n.Args{"model": callbackQuery.Data}),
 	})
 	if err != nil {
 		log.Errorf("handleImageModelSwitchCallbackQuery failed to answer callback query: %v", err)
 	}
 }
 
-func handleInlineQuery(bhctx *th.Context, inlineQuery telego.InlineQuery) error {
-	bot := bhctx.Bot()
-	chatID := inlineQuery.From.ID
-	chatIDString := fmt.Sprint(chatID)
-	_, ctx, _, _ := lib.SetupUserAndContext(chatIDString, "telegram", chatIDString, "")
-	if inlineQuery.Query == "" {
-		inlineQuery.Query = "What can you do?"
-	}
-	log.Infof("Inline query from ID: %d, query size: %d", inlineQuery.From.ID, len(inlineQuery.Query))
-
-	ok, subscription := lib.ValidateUserUsage(ctx)
-	if !ok {
-		config.CONFIG.DataDogClient.Incr("telegram.usage_exceeded", []string{"client:telegram", "channel_type:inline", "subscription:" + string(subscription)}, 1)
-	}
-
-	config.CONFIG.DataDogClient.Incr("telegram.inline_message_received", []string{"channel_type:" + inlineQuery.ChatType}, 1)
-
-	// get the response
-	response, err := BOT.API.ChatComplete(ctx, models.ChatCompletion{
-		Model: string(models.LlamaV3_8b),
-		Messages: []models.Message{
-			{
-				Role:    "system",
-				Content: config.AI_INSTRUCTIONS,
-			},
-			{
-				Role:    "user",
-				Content: inlineQuery.Query,
-			},
-		},
-		MaxTokens: 1024,
-	})
-	if err != nil {
-		log.Errorf("Failed to get completion for inline query: %v", err)
-		return err
-	}
-	params := &telego.AnswerInlineQueryParams{
-		InlineQueryID: inlineQuery.ID,
-		CacheTime:     60 * 60 * 24, // 24 hours
-	}
-	params.WithResults(&telego.InlineQueryResultArticle{
-		Type:         "article",
-		ID:           "0",
-		Title:        "FastGPT",
-		URL:          "https://t.me/gienjibot?start=s=home",
-		ThumbnailURL: "https://gienji.me/assets/images/image01.jpg",
-		Description:  response,
-		InputMessageContent: &telego.InputTextMessageContent{
-			MessageText: response,
-			ParseMode:   "HTML",
-		},
-	})
-	err = bot.AnswerInlineQuery(context.Background(), params)
-
-	// retry w/o parse mode if failed
-	if err != nil && strings.Contains(err.Error(), "can't parse entities") {
-		params.Results[0].(*telego.InlineQueryResultArticle).InputMessageContent.(*telego.InputTextMessageContent).ParseMode = ""
-		err = bot.AnswerInlineQuery(context.Background(), params)
-	}
-
-	if err != nil {
-		log.Errorf("Failed to answer %d inline query: %v", chatID, err)
-	}
-
-	return err
-}
-
 func handleChosenInlineResult(bhctx *th.Context, chosenInlineResult telego.ChosenInlineResult) error {
 	userID := chosenInlineResult.From.ID
 	log.Infof("Chosen inline result from ID: %d, result ID: %s", userID, chosenInlineResult.ResultID)
@@ -750,6 +829,7 @@ func handleGeneralUpdate(bhctx *th.Context, update telego.Update) error {
 	log.Debugf("handleGeneralUpdate: %v", update)
 
 	if update.MessageReaction != nil && update.MessageReaction.NewReaction != nil {
+		sendReactionActions(bhctx.Bot(), update.MessageReaction)
 		for _, reaction := range update.MessageReaction.NewReaction {
 			reactionType := reaction.ReactionType()
 			reactionString := "none"
@@ -772,6 +852,9 @@ func handleGeneralUpdate(bhctx *th.Context, update telego.Update) error {
 
 			log.Infof("Message reaction in chat %s: %s", fmt.Sprintf("%d", update.MessageReaction.Chat.ID), reactionString)
 			config.CONFIG.DataDogClient.Incr("telegram.message_reaction", []string{"channel_type:" + update.MessageReaction.Chat.Type, "reaction:" + reactionString, "mood:" + mood}, 1)
+			if reactionString != "none" {
+				go saveReactionFeedback(update.MessageReaction, reactionString, mood)
+			}
 		}
 	}
 
@@ -804,24 +887,42 @@ func handleGeneralUpdate(bhctx *th.Context, update telego.Update) error {
 	return nil
 }
 
-func getVoiceTranscript(ctx context.Context, bot *telego.Bot, message telego.Message) string {
-	startTime := time.Now()
-	chatID := util.GetChatID(&message)
-	chatIDString := util.GetChatIDString(&message)
-
-	var fileId string
+// getMediaFileID returns the file id of a voice/audio/video message, empty if there is no media
+func getMediaFileID(message *telego.Message) string {
 	switch {
 	case message.Voice != nil:
-		fileId = message.Voice.FileID
+		return message.Voice.FileID
 	case message.Audio != nil:
-		fileId = message.Audio.FileID
+		return message.Audio.FileID
 	case message.Video != nil:
-		fileId = message.Video.FileID
+		return message.Video.FileID
 	case message.VideoNote != nil:
-		fileId = message.VideoNote.FileID
+		return message.VideoNote.FileID
 	case message.Document != nil:
-		fileId = message.Document.FileID
-	default:
+		return message.Document.FileID
+	}
+	return ""
+}
+
+// withTranscriptionLanguage keeps mode params as the language to transcribe in, unless the mode uses them for something else,
+// interpret params are languages to translate into and dictate params a format, the speech is in any language and whisper detects it
+func withTranscriptionLanguage(ctx context.Context, mode lib.ModeName) context.Context {
+	switch mode {
+	case lib.Interpret, lib.Dictate:
+		return context.WithValue(ctx, models.ParamsContext{}, "")
+	}
+	return ctx
+}
+
+// getVoiceTranscript downloads, converts and transcribes a voice/audio/video message,
+// timed transcript options also send subtitle files and optionally the video with burned-in subtitles
+func getVoiceTranscript(ctx context.Context, bot *telego.Bot, message telego.Message, options lib.TranscriptOptions) string {
+	startTime := time.Now()
+	chatID := util.GetChatID(&message)
+	chatIDString := util.GetChatIDString(&message)
+
+	fileId := getMediaFileID(&message)
+	if fileId == "" {
 		log.Errorf("No voice/audio/video message in chat %s", chatIDString)
 		return ""
 	}
@@ -829,10 +930,10 @@ func getVoiceTranscript(ctx context.Context, bot *telego.Bot, message telego.Mes
 	if err != nil {
 		log.Errorf("Failed to get voice/audio/video file data in chat %s: %v", chatIDString, err)
 		if strings.Contains(err.Error(), "file is too big") {
-			_, _ = bot.SendMessage(context.Background(), tu.Message(chatID, "Telegram API doesn't support downloading files bigger than 20Mb, try sending a shorter voice/audio/video message.").WithMessageThreadID(message.MessageThreadID))
+			_, _ = bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "audio.too_big")).WithMessageThreadID(message.MessageThreadID))
 			return ""
 		}
-		_, err = bot.SendMessage(context.Background(), tu.Message(chatID, "Something went wrong while getting voice/audio/video file, please try again.").WithMessageThreadID(message.MessageThreadID))
+		_, err = bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "audio.download_failed")).WithMessageThreadID(message.MessageThreadID))
 		if err != nil {
 			log.Errorf("Failed to send message in chat %s: %v", chatIDString, err)
 		}
@@ -840,14 +941,6 @@ func getVoiceTranscript(ctx context.Context, bot *telego.Bot, message telego.Mes
 	}
 	log.Debugf("Voice message file data: %+v", fileData)
 
-	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", bot.Token(), fileData.FilePath)
-	response, err := http.Get(fileURL)
-	if err != nil {
-		log.Errorf("Error downloading file in chat %s: %v", chatIDString, err)
-		return ""
-	}
-	defer response.Body.Close()
-
 	// create uuid for the file
 	temporaryFileName := uuid.New().String()
 	temporaryFileExtension := filepath.Ext(fileData.FilePath)
@@ -858,24 +951,16 @@ func getVoiceTranscript(ctx context.Context, bot *telego.Bot, message telego.Mes
 	whisperFileExtension := ".ogg"
 	whisperFile := "/data/" + temporaryFileName + whisperFileExtension
 
-	// save response.Body to a temporary file
-	f, err := os.Create(sourceFile)
-	if err != nil {
-		log.Errorf("Error creating file %s in chat %s: %v", sourceFile, chatIDString, err)
-		return ""
-	} else {
-		log.Infof("Created file %s for conversion in chat %s, size: %d", sourceFile, chatIDString, fileData.FileSize)
-	}
-	defer f.Close()
 	defer util.SafeOsDelete(sourceFile)
-	_, err = io.Copy(f, response.Body)
+	err = downloadTelegramFile(bot, fileData.FilePath, sourceFile)
 	if err != nil {
 		log.Errorf("Error saving voice message in chat %s: %v", chatIDString, err)
 		return ""
 	}
+	log.Infof("Created file %s for conversion in chat %s, size: %d", sourceFile, chatIDString, fileData.FileSize)
 
 	// convert .oga audio format into one of ['m4a', 'mp3', 'webm', 'mp4', 'mpga', 'wav', 'mpeg', 'ogg']
-	duration, err := converters.ConvertWithFFMPEG(sourceFile, whisperFile)
+	duration, silences, err := converters.ConvertWithSilences(sourceFile, whisperFile)
 	defer util.SafeOsDelete(whisperFile)
 	if err != nil {
 		log.Errorf("Error converting voice message in chat %s: %v", chatIDString, err)
@@ -885,6 +970,31 @@ func getVoiceTranscript(ctx context.Context, bot *telego.Bot, message telego.Mes
 	config.CONFIG.DataDogClient.Timing("transcribe.ffmpeg", time.Since(startTime), []string{"format:" + temporaryFileExtension}, 1)
 	config.CONFIG.DataDogClient.Timing("transcribe.ffmpeg.per_duration", time.Since(startTime), []string{"format:" + temporaryFileExtension}, duration.Seconds())
 
+	whisperConfig := BOT.WhisperConfig
+	if options.Timed() {
+		whisperConfig.ResponseFormat = openai.WHISPER_TIMESTAMPS_FORMAT
+	}
+	if options.Prompt != "" {
+		whisperConfig.Prompt = options.Prompt
+	}
+
+	// long recordings don't fit into a single transcription request
+	if whisperFileInfo, err := os.Stat(whisperFile); err == nil && duration > 0 {
+		maxSegment := converters.MaxSegmentDuration(duration, whisperFileInfo.Size())
+		if duration > maxSegment {
+			transcript, timedSegments := transcribeLongAudio(ctx, bot, message, whisperConfig, whisperFile, duration, silences, maxSegment)
+			config.CONFIG.DataDogClient.Timing("transcribe.total", time.Since(startTime), []string{"format:" + temporaryFileExtension}, 1)
+			config.CONFIG.DataDogClient.Timing("transcribe.total.per_duration", time.Since(startTime), []string{"format:" + temporaryFileExtension}, duration.Seconds())
+			if transcript == "" {
+				log.Warnf("Failed to transcribe long voice message in chat %s from %s, size %d", chatIDString, fileData.FilePath, fileData.FileSize)
+				bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "audio.transcription_failed")).WithMessageThreadID(message.MessageThreadID))
+			} else if options.Timed() {
+				sendSubtitles(ctx, bot, &message, options, timedSegments, sourceFile)
+			}
+			return transcript
+		}
+	}
+
 	// read the converted file
 	whisperBuffer, err := os.ReadFile(whisperFile)
 	if err != nil {
@@ -895,7 +1005,7 @@ func getVoiceTranscript(ctx context.Context, bot *telego.Bot, message telego.Mes
 	whisper := openai.NewWhisper()
 	whisper.Whisper(
 		context.WithValue(ctx, models.WhisperDurationContext{}, duration),
-		BOT.WhisperConfig,
+		whisperConfig,
 		io.NopCloser(bytes.NewReader(whisperBuffer)),
 		temporaryFileName+whisperFileExtension)
 
@@ -904,13 +1014,40 @@ func getVoiceTranscript(ctx context.Context, bot *telego.Bot, message telego.Mes
 
 	if whisper.Transcript().Text == "" {
 		log.Warnf("Failed to transcribe voice message in chat %s from %s, size %d", chatIDString, fileData.FilePath, fileData.FileSize)
-		bot.SendMessage(context.Background(), tu.Message(chatID, "Couldn't transcribe the voice/audio/video message, maybe next time?").WithMessageThreadID(message.MessageThreadID))
+		bot.SendMessage(context.Background(), tu.Message(chatID, i18n.T(ctx, "audio.transcription_failed")).WithMessageThreadID(message.MessageThreadID))
 		return ""
 	}
 
+	if options.Timed() {
+		sendSubtitles(ctx, bot, &message, options, whisper.Transcript().Segments, sourceFile)
+	}
 	return whisper.Transcript().Text
 }
 
+// downloadTelegramFile saves a file from Telegram servers, filePath comes from bot.GetFile
+func downloadTelegramFile(bot *telego.Bot, filePath string, destination string) error {
+	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", bot.Token(), filePath)
+	response, err := http.Get(fileURL)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download file, status: %s", response.Status)
+	}
+
+	f, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", destination, err)
+	}
+	defer f.Close()
+	_, err = io.Copy(f, response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to save file %s: %w", destination, err)
+	}
+	return nil
+}
+
 func sendTypingAction(bot *telego.Bot, message *telego.Message) {
 	ctx := context.Background()
 	chatID := message.Chat.ChatID()
@@ -31,11 +31,13 @@ const (
 	SYSTEMStatusCommand               Command = "/status"
 	SYSTEMStripeResetCommand          Command = "/stripereset"
 	SYSTEMUsageResetCommand           Command = "/usagereset"
+	SYSTEMUsageRebuildCommand         Command = "/usagerebuild"
 	SYSTEMUserCommand                 Command = "/user"
 	SYSTEMUsersCountCommand           Command = "/userscount"
 	SYSTEMUsersForSubscriptionCommand Command = "/usersforsubscription"
 	SYSTEMSendMessageToUsers          Command = "/sendmessagetousers"
 	SYSTEMSendMessageToAUser          Command = "/sendmessagetoauser"
+	SYSTEMFeedbackExportCommand       Command = "/feedbackexport"
 )
 
 var SystemCommandHandlers CommandHandlers = CommandHandlers{}
@@ -150,11 +152,13 @@ func setupSystemCommandHandlers() {
 		newCommandHandler(SYSTEMStripeResetCommand, handleStripeReset),
 		newCommandHandler(SYSTEMUsersCountCommand, handleUsersCount),
 		newCommandHandler(SYSTEMUsageResetCommand, handleUsageReset),
+		newCommandHandler(SYSTEMUsageRebuildCommand, handleUsageRebuild),
 		newCommandHandler(SYSTEMUsersForSubscriptionCommand, handleUsersForSubscription),
 		newCommandHandler(SYSTEMBanUserCommand, handleBanUser),
 		newCommandHandler(SYSTEMUnbanUserCommand, handleUnbanUser),
 		newCommandHandler(SYSTEMSendMessageToUsers, handleSendMessageToUsers),
 		newCommandHandler(SYSTEMSendMessageToAUser, handleSendMessageToAUser),
+		newCommandHandler(SYSTEMFeedbackExportCommand, handleFeedbackExport),
 	}
 }
 
@@ -247,6 +251,31 @@ func handleUsageReset(ctx context.Context, bot *Bot, message *telego.Message) {
 	bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, "Usage reset for user: "+userId))
 }
 
+// handleUsageRebuild sets usage counters from the usage ledger: of all users, of a user by id, or system totals with "totals"
+func handleUsageRebuild(ctx context.Context, bot *Bot, message *telego.Message) {
+	commandArray := strings.Fields(message.Text)
+	if len(commandArray) > 1 && commandArray[1] == "totals" {
+		err := payments.RebuildSystemTotals(ctx)
+		if err != nil {
+			bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, fmt.Sprintf("Failed to rebuild system totals: %v", err)))
+			return
+		}
+		bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, "System totals rebuilt from usage ledger"))
+		return
+	}
+
+	userId := ""
+	if len(commandArray) > 1 {
+		userId = commandArray[1]
+	}
+	rebuilt, err := payments.RebuildUsageCounters(ctx, userId)
+	if err != nil {
+		bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, fmt.Sprintf("Failed to rebuild usage: %v", err)))
+		return
+	}
+	bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, fmt.Sprintf("Usage rebuilt from usage ledger for %d users", rebuilt)))
+}
+
 func handleUsersForSubscription(ctx context.Context, bot *Bot, message *telego.Message) {
 	commandArray := strings.Split(message.Text, " ")
 	if len(commandArray) < 2 {
@@ -417,3 +446,67 @@ func handleSendMessageToAUser(ctx context.Context, bot *Bot, message *telego.Mes
 	log.Infof("[SYSTEM] Message sent to user %s", userId)
 	bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, fmt.Sprintf("Message sent to user %s", userId)))
 }
+
+func handleFeedbackExport(ctx context.Context, bot *Bot, message *telego.Message) {
+	commandUsage := fmt.Sprintf("Usage: %s <days> [like|dislike|reaction|positive|negative|neutral] [engine]", SYSTEMFeedbackExportCommand)
+	commandArray := strings.Fields(message.Text)
+	if len(commandArray) < 2 {
+		bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, commandUsage))
+		return
+	}
+	days, err := strconv.Atoi(commandArray[1])
+	if err != nil || days <= 0 {
+		bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, commandUsage))
+		return
+	}
+
+	filter := models.MongoFeedbackFilter{Since: time.Now().AddDate(0, 0, -1*days)}
+	for _, param := range commandArray[2:] {
+		switch param {
+		case "like", "dislike", "reaction":
+			filter.Kind = param
+		case "positive", "negative", "neutral":
+			filter.Mood = param
+		default:
+			filter.Engine = param
+		}
+	}
+
+	feedback, err := mongo.MongoDBClient.GetFeedback(context.Background(), filter)
+	if err != nil {
+		bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, fmt.Sprintf("Failed to get feedback: %s", err)))
+		return
+	}
+	if len(feedback) == 0 {
+		bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, "No feedback found"))
+		return
+	}
+
+	// one JSON object per line, ready for prompt tuning and evaluation tools
+	var dataset strings.Builder
+	for _, item := range feedback {
+		itemBytes, err := json.Marshal(item)
+		if err != nil {
+			log.Errorf("[SYSTEM] Failed to marshal feedback: %s", err)
+			continue
+		}
+		dataset.Write(itemBytes)
+		dataset.WriteString("\n")
+	}
+
+	fileName := fmt.Sprintf("feedback-%s.jsonl", time.Now().UTC().Format("2006-01-02"))
+	_, err = bot.SendDocument(context.Background(), &telego.SendDocumentParams{
+		ChatID: SystemBOT.ChatID,
+		Document: telego.InputFile{
+			File: NamedReader{
+				Reader: strings.NewReader(dataset.String()),
+				name:   fileName,
+			},
+		},
+		Caption: fmt.Sprintf("%d feedback records for the last %d days", len(feedback), days),
+	})
+	if err != nil {
+		log.Errorf("[SYSTEM] Failed to send feedback export: %s", err)
+		bot.SendMessage(context.Background(), tu.Message(SystemBOT.ChatID, fmt.Sprintf("Failed to send feedback export: %s", err)))
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"talk2robots/m/v2/app/ai/fireworks"
 	"talk2robots/m/v2/app/ai/midjourney"
 	"talk2robots/m/v2/app/ai/openai"
+	"talk2robots/m/v2/app/config"
 	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/models"
 
@@ -37,23 +39,24 @@ func GetUserStatus(ctx context.Context) string {
 		subscriptionToDisplay = string(subscription.Name) + " ($9.99/mo)"
 	}
 
-	entity := "User"
+	entity := i18n.T(ctx, "status.entity.user")
 	if strings.HasPrefix(userIdString, "-") {
-		entity = "Group"
+		entity = i18n.T(ctx, "status.entity.group")
 	}
 
-	return fmt.Sprintf(`⚙️ %s status:
-		Mode: %s%s
-		AI model: %s
-
-		Subscription: %s
-		AI credits: $%.2f/mo
-
-		Monthly usage (will reset on the 1st of the next month)
-		consumption: %.3f$ (%.1f%%)
-		tokens processed: %d
-		audio transcribed, minutes: %.2f
-		images created: %d`, entity, mode, paramsString, model, subscriptionToDisplay, subscription.MaximumUsage, usage, usagePercent, tokens, audioMinutes, imagesCount)
+	return i18n.T(ctx, "status.text", i18n.Args{
+		"entity":       entity,
+		"mode":         mode,
+		"params":       paramsString,
+		"model":        model,
+		"subscription": subscriptionToDisplay,
+		"credits":      fmt.Sprintf("%.2f", subscription.MaximumUsage),
+		"usage":        fmt.Sprintf("%.3f", usage),
+		"percent":      fmt.Sprintf("%.1f", usagePercent),
+		"tokens":       tokens,
+		"minutes":      fmt.Sprintf("%.2f", audioMinutes),
+		"images":       imagesCount,
+	})
 }
 
 func GetStatusKeyboard(ctx context.Context) *telego.InlineKeyboardMarkup {
@@ -67,6 +70,7 @@ func GetStatusKeyboard(ctx context.Context) *telego.InlineKeyboardMarkup {
 	transcribeActive := ""
 	summarizeActive := ""
 	translateActive := ""
+	searchActive := ""
 	switch mode {
 	case lib.ChatGPT:
 		chatGptActive = " ✅"
@@ -82,6 +86,8 @@ func GetStatusKeyboard(ctx context.Context) *telego.InlineKeyboardMarkup {
 		summarizeActive = " ✅"
 	case lib.Translate:
 		translateActive = " ✅"
+	case lib.Search:
+		searchActive = " ✅"
 	}
 
 	topicString := ctx.Value(models.TopicContext{}).(string)
@@ -122,19 +128,27 @@ func GetStatusKeyboard(ctx context.Context) *telego.InlineKeyboardMarkup {
 					Text:         "Translate" + translateActive,
 					CallbackData: string(lib.Translate) + ":" + topicString,
 				},
+				{
+					Text:         "Search" + searchActive,
+					CallbackData: string(lib.Search) + ":" + topicString,
+				},
 			},
 			{
 				{
-					Text:         "Choose AI 🧠",
+					Text:         i18n.T(ctx, "button.choose_ai"),
 					CallbackData: "models:" + topicString,
 				},
+				{
+					Text:         i18n.T(ctx, "button.voice"),
+					CallbackData: "voicesettings:" + topicString,
+				},
+			},
+			{
+				{
+					Text:         i18n.T(ctx, "button.choose_image_ai"),
+					CallbackData: "images:" + topicString,
+				},
 			},
-			// {
-			// 	{
-			// 		Text:         "Choose Image AI 🎨",
-			// 		CallbackData: "images:" + topicString,
-			// 	},
-			// },
 		},
 	}
 }
@@ -195,7 +209,7 @@ func GetModelsKeyboard(ctx context.Context) *telego.InlineKeyboardMarkup {
 			},
 			{
 				{
-					Text:         "Back ⬅️",
+					Text:         i18n.T(ctx, "button.back"),
 					CallbackData: "status:" + topicString,
 				},
 			},
@@ -205,20 +219,25 @@ func GetModelsKeyboard(ctx context.Context) *telego.InlineKeyboardMarkup {
 
 func GetImageModelsKeyboard(ctx context.Context) *telego.InlineKeyboardMarkup {
 	topicString := ctx.Value(models.TopicContext{}).(string)
-	return &telego.InlineKeyboardMarkup{
-		InlineKeyboard: [][]telego.InlineKeyboardButton{
+	keyboard := [][]telego.InlineKeyboardButton{
+		{
 			{
-				{
-					Text:         fmt.Sprintf("Dalle-3 (best) %.2f$/image\n🚀🚀🧠🧠🧠🧠🎨🎨🎨", openai.DALLE3_S),
-					CallbackData: string(models.DallE3) + ":" + topicString,
-				},
+				Text:         fmt.Sprintf("Dalle-3 (best) %.2f$/image\n🚀🚀🧠🧠🧠🧠🎨🎨🎨", openai.DALLE3_S),
+				CallbackData: string(models.DallE3) + ":" + topicString,
 			},
+		},
+	}
+	// Midjourney is only offered with a proxy to generate through
+	if midjourney.IsConfigured(config.CONFIG) {
+		keyboard = append(keyboard, []telego.InlineKeyboardButton{
 			{
-				{
-					Text:         fmt.Sprintf("Midjourney 6 %.2f$/image\n🚀🧠🧠🎨🎨🎨🎨", midjourney.MIDJOURNEY6),
-					CallbackData: string(models.Midjourney6) + ":" + topicString,
-				},
+				Text:         fmt.Sprintf("Midjourney 6 %.2f$/image\n🚀🧠🧠🎨🎨🎨🎨", midjourney.MIDJOURNEY6),
+				CallbackData: string(models.Midjourney6) + ":" + topicString,
 			},
+		})
+	}
+	return &telego.InlineKeyboardMarkup{
+		InlineKeyboard: append(keyboard, [][]telego.InlineKeyboardButton{
 			{
 				{
 					Text:         fmt.Sprintf("Stable Diffusion 3 %.3f$/image\n🚀🚀🚀🧠🧠🎨🎨🎨", fireworks.STABLEDIFFUSION3),
@@ -233,11 +252,17 @@ func GetImageModelsKeyboard(ctx context.Context) *telego.InlineKeyboardMarkup {
 			},
 			{
 				{
-					Text:         "Back ⬅️",
+					Text:         i18n.T(ctx, "button.image_options"),
+					CallbackData: "imageoptions:" + topicString,
+				},
+			},
+			{
+				{
+					Text:         i18n.T(ctx, "button.back"),
 					CallbackData: "status:" + topicString,
 				},
 			},
-		},
+		}...),
 	}
 }
 
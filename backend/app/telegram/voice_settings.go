@@ -0,0 +1,255 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"talk2robots/m/v2/app/ai/openai"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/i18n"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/util"
+
+	"github.com/google/uuid"
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// VOICE_CALLBACK_PREFIX marks voice settings keyboard callbacks, e.g. tts.voice=nova:<topic>
+const VOICE_CALLBACK_PREFIX = "tts."
+
+// voiceCommandHandler shows voice settings or changes one: /voice nova, /voice speed 1.25, /voice style calm, /voice format mp3
+func voiceCommandHandler(ctx context.Context, bot *Bot, message *telego.Message) {
+	chatID := util.GetChatID(message)
+	chatIDString := util.GetChatIDString(message)
+	reply := func(text string, keyboard *telego.InlineKeyboardMarkup) {
+		text = lib.AddBotSuffixToGroupCommands(ctx, text)
+		params := tu.Message(chatID, text).WithMessageThreadID(message.MessageThreadID)
+		if keyboard != nil {
+			params = params.WithReplyMarkup(keyboard)
+		}
+		bot.SendMessage(context.Background(), params)
+	}
+
+	settings := lib.GetVoiceSettings(chatIDString)
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 {
+		reply(formatVoiceSettings(ctx, settings), GetVoiceKeyboard(ctx))
+		return
+	}
+
+	name, value := strings.ToLower(args[0]), strings.Join(args[1:], " ")
+	if len(args) == 1 {
+		// /voice nova
+		name, value = "voice", args[0]
+	}
+	err := lib.ApplyVoiceSetting(&settings, name, value)
+	if err != nil {
+		reply(i18n.T(ctx, "voice.invalid", i18n.Args{"error": err.Error()}), nil)
+		return
+	}
+	err = lib.SaveVoiceSettings(chatIDString, settings)
+	if err != nil {
+		log.Errorf("Failed to save voice settings in chat %s: %v", chatIDString, err)
+		reply(i18n.T(ctx, "oopsie"), nil)
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.voice_settings_changed", []string{"setting:" + name}, 1)
+	reply(formatVoiceSettings(ctx, settings), GetVoiceKeyboard(ctx))
+	if name == "voice" || name == "style" || name == "instructions" {
+		sendVoicePreview(ctx, chatID, message.MessageThreadID, settings)
+	}
+}
+
+func formatVoiceSettings(ctx context.Context, settings models.VoiceSettings) string {
+	style := settings.Instructions
+	if style == "" {
+		style = i18n.T(ctx, "voice.no_style")
+	}
+	format := i18n.T(ctx, "voice.format_voice")
+	if settings.Format == models.TTSFormatMp3 {
+		format = i18n.T(ctx, "voice.format_mp3")
+	}
+	return i18n.T(ctx, "voice.settings", i18n.Args{
+		"voice":  settings.Voice,
+		"speed":  strconv.FormatFloat(settings.Speed, 'f', -1, 64),
+		"style":  style,
+		"format": format,
+	})
+}
+
+func GetVoiceKeyboard(ctx context.Context) *telego.InlineKeyboardMarkup {
+	userIdString := ctx.Value(models.UserContext{}).(string)
+	topicString := ctx.Value(models.TopicContext{}).(string)
+	settings := lib.GetVoiceSettings(userIdString)
+	button := func(text string, active bool, data string) telego.InlineKeyboardButton {
+		if active {
+			text = "✅ " + text
+		}
+		return telego.InlineKeyboardButton{Text: text, CallbackData: VOICE_CALLBACK_PREFIX + data + ":" + topicString}
+	}
+
+	keyboard := [][]telego.InlineKeyboardButton{}
+	row := []telego.InlineKeyboardButton{}
+	for _, voice := range lib.Voices {
+		row = append(row, button(voiceName(voice), settings.Voice == voice, "voice="+voice))
+		if len(row) == 3 {
+			keyboard = append(keyboard, row)
+			row = []telego.InlineKeyboardButton{}
+		}
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+
+	row = []telego.InlineKeyboardButton{}
+	for _, speed := range lib.VoiceSpeeds {
+		speedString := strconv.FormatFloat(speed, 'f', -1, 64)
+		row = append(row, button(speedString+"x", settings.Speed == speed, "speed="+speedString))
+	}
+	keyboard = append(keyboard, row)
+
+	keyboard = append(keyboard, []telego.InlineKeyboardButton{
+		button("😌", settings.Instructions == lib.VoiceStyles["calm"], "style=calm"),
+		button("🎉", settings.Instructions == lib.VoiceStyles["cheerful"], "style=cheerful"),
+		button("📖", settings.Instructions == lib.VoiceStyles["story"], "style=story"),
+		button("📰", settings.Instructions == lib.VoiceStyles["news"], "style=news"),
+		button("✖️", settings.Instructions == "", "style=none"),
+	})
+	keyboard = append(keyboard, []telego.InlineKeyboardButton{
+		button(i18n.T(ctx, "voice.format_voice"), settings.Format != models.TTSFormatMp3, "format=voice"),
+		button(i18n.T(ctx, "voice.format_mp3"), settings.Format == models.TTSFormatMp3, "format=mp3"),
+	})
+	keyboard = append(keyboard, []telego.InlineKeyboardButton{
+		{Text: i18n.T(ctx, "button.back"), CallbackData: "status:" + topicString},
+	})
+	return &telego.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
+
+// handleVoiceCallbackQuery applies a voice settings keyboard button and previews new voices and styles
+func handleVoiceCallbackQuery(callbackQuery telego.CallbackQuery, topicString string) {
+	chat := callbackQuery.Message.GetChat()
+	chatIDString := fmt.Sprint(chat.ID)
+	topicID, _ := strconv.Atoi(topicString)
+	_, ctx, cancelContext, err := lib.SetupUserAndContext(chatIDString, lib.TelegramClientName, chatIDString, topicString)
+	if err != nil {
+		log.Errorf("handleVoiceCallbackQuery: failed to setup user %s: %v", chatIDString, err)
+		return
+	}
+	defer cancelContext()
+
+	setting := strings.SplitN(strings.TrimPrefix(callbackQuery.Data, VOICE_CALLBACK_PREFIX), "=", 2)
+	if len(setting) != 2 {
+		log.Errorf("handleVoiceCallbackQuery: invalid callback %s in chat %s", callbackQuery.Data, chatIDString)
+		return
+	}
+	settings := lib.GetVoiceSettings(chatIDString)
+	previous := settings
+	err = lib.ApplyVoiceSetting(&settings, setting[0], setting[1])
+	if err == nil && settings != previous {
+		err = lib.SaveVoiceSettings(chatIDString, settings)
+	}
+	text := i18n.T(ctx, "voice.updated")
+	if err != nil {
+		log.Errorf("handleVoiceCallbackQuery: failed to apply %s in chat %s: %v", callbackQuery.Data, chatIDString, err)
+		text = i18n.T(ctx, "oopsie")
+	}
+	BOT.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{CallbackQueryID: callbackQuery.ID, Text: text})
+	if err != nil || settings == previous {
+		return
+	}
+	config.CONFIG.DataDogClient.Incr("telegram.voice_settings_changed", []string{"setting:" + setting[0]}, 1)
+
+	BOT.EditMessageReplyMarkup(ctx, &telego.EditMessageReplyMarkupParams{
+		ChatID:      chat.ChatID(),
+		MessageID:   callbackQuery.Message.GetMessageID(),
+		ReplyMarkup: GetVoiceKeyboard(ctx),
+	})
+	if setting[0] != "voice" && setting[0] != "style" {
+		return
+	}
+	// previews are billed as regular TTS, so they are skipped once the usage limit is reached
+	if ok, subscription := lib.ValidateUserUsage(ctx); !ok {
+		config.CONFIG.DataDogClient.Incr("telegram.usage_exceeded", []string{"client:telegram", "channel_type:voice_preview", "subscription:" + string(subscription)}, 1)
+		notification := lib.AddBotSuffixToGroupCommands(ctx, i18n.T(ctx, "usage.exceeded"))
+		BOT.SendMessage(context.Background(), tu.Message(chat.ChatID(), notification).WithMessageThreadID(topicID))
+		return
+	}
+	sendVoicePreview(ctx, chat.ChatID(), topicID, settings)
+}
+
+// sendVoicePreview sends a short sample with the chat's voice settings, billed as regular TTS
+func sendVoicePreview(ctx context.Context, chatID telego.ChatID, topicID int, settings models.VoiceSettings) {
+	message := &telego.Message{Chat: telego.Chat{ID: chatID.ID}, MessageThreadID: topicID}
+	sendAudioAction(BOT.Bot, message)
+	voiceReader, err := openai.CreateSpeech(ctx, &models.TTSRequest{
+		Model:        models.TTS,
+		Input:        i18n.T(ctx, "voice.preview", i18n.Args{"voice": voiceName(settings.Voice)}),
+		Voice:        settings.Voice,
+		Speed:        settings.Speed,
+		Instructions: settings.Instructions,
+	})
+	if err != nil {
+		log.Errorf("Failed to create voice preview in chat %d: %v", chatID.ID, err)
+		return
+	}
+	defer voiceReader.Close()
+
+	_, err = BOT.SendVoice(context.Background(), &telego.SendVoiceParams{
+		ChatID:          chatID,
+		MessageThreadID: topicID,
+		Voice:           telego.InputFile{File: NamedReader{Reader: voiceReader, name: uuid.New().String() + ".ogg"}},
+		Caption:         "🗣 " + voiceName(settings.Voice),
+	})
+	if err != nil {
+		log.Errorf("Failed to send voice preview in chat %d: %v", chatID.ID, err)
+	}
+}
+
+// sendSpeechAudio sends TTS as an mp3 audio file, which unlike voice messages can be seeked and played in background,
+// the title is taken from the spoken text, caption is optional, returns the sent message id
+func sendSpeechAudio(bot *telego.Bot, message *telego.Message, audio io.Reader, text string, caption util.MarkdownChunk) (int, error) {
+	audioParams := &telego.SendAudioParams{
+		ChatID:          message.Chat.ChatID(),
+		MessageThreadID: message.MessageThreadID,
+		Audio:           telego.InputFile{File: NamedReader{Reader: audio, name: uuid.New().String() + ".mp3"}},
+		Title:           audioTitle(text),
+		Performer:       config.CONFIG.BotName,
+	}
+	if caption.HTML != "" {
+		audioParams.Caption = caption.HTML
+		audioParams.ParseMode = "HTML"
+	}
+	sentMessage, err := bot.SendAudio(context.Background(), audioParams.WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
+	if err != nil && strings.Contains(err.Error(), "can't parse entities") {
+		audioParams.ParseMode = ""
+		audioParams.Caption = caption.Markdown
+		sentMessage, err = bot.SendAudio(context.Background(), audioParams.WithReplyMarkup(getLikeDislikeReplyMarkup(message.MessageThreadID)))
+	}
+	if err != nil {
+		return 0, err
+	}
+	return sentMessage.MessageID, nil
+}
+
+func voiceName(voice string) string {
+	if voice == "" {
+		return voice
+	}
+	return strings.ToUpper(voice[:1]) + voice[1:]
+}
+
+// audioTitle is the first line of the text, up to 64 characters
+func audioTitle(text string) string {
+	if index := strings.IndexByte(text, '\n'); index >= 0 {
+		text = text[:index]
+	}
+	if runes := []rune(text); len(runes) > 64 {
+		text = string(runes[:63]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,663 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TELEGRAM_MESSAGE_LIMIT is the maximum length of a Telegram message text
+const TELEGRAM_MESSAGE_LIMIT = 4096
+
+// MarkdownChunk is a part of a message small enough for Telegram, with its Markdown source and rendered HTML
+type MarkdownChunk struct {
+	Markdown string
+	HTML     string
+}
+
+type markdownBlockKind int
+
+const (
+	paragraphBlock markdownBlockKind = iota
+	codeBlock
+	tableBlock
+)
+
+type markdownBlock struct {
+	kind    markdownBlockKind
+	source  []string
+	content []string
+	lang    string
+	closed  bool
+}
+
+type inlineTag struct {
+	name   string
+	marker string
+	open   string
+}
+
+var (
+	fenceRegex          = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	headingRegex        = regexp.MustCompile(`^\s{0,3}#{1,6}\s+(.*?)\s*#*\s*$`)
+	bulletRegex         = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	numberedRegex       = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	ruleRegex           = regexp.MustCompile(`^\s{0,3}((-\s*){3,}|(\*\s*){3,}|(_\s*){3,})$`)
+	tableSeparatorRegex = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	htmlTagRegex        = regexp.MustCompile(`^<(/?)([a-zA-Z-]+)((?:\s+[^<>]*)?)\s*/?>`)
+	hrefRegex           = regexp.MustCompile(`href\s*=\s*["']([^"']+)["']`)
+	languageClassRegex  = regexp.MustCompile(`class\s*=\s*["']language-([\w+#.-]+)["']`)
+	entityRegex         = regexp.MustCompile(`^&(#\d+|#x[0-9a-fA-F]+|[a-zA-Z]+);`)
+	linkRegex           = regexp.MustCompile(`^\[([^\]]+)\]\(([^)\s]+)\)`)
+	rawPreOpenRegex     = regexp.MustCompile(`^\s*<pre[^>]*>(\s*<code[^>]*>)?`)
+	rawPreCloseRegex    = regexp.MustCompile(`(</code>\s*)?</pre>\s*$`)
+)
+
+// allowed raw HTML tags models might still produce, anything else is escaped
+var allowedInlineTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true,
+	"s": true, "strike": true, "del": true, "code": true, "a": true, "span": true, "tg-spoiler": true,
+}
+
+// MarkdownToTelegramHTML renders Markdown (or Telegram HTML) into Telegram-safe HTML with balanced tags
+func MarkdownToTelegramHTML(markdown string) string {
+	blocks := parseMarkdownBlocks(markdown)
+	rendered := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		rendered = append(rendered, renderMarkdownBlock(block))
+	}
+	return strings.Join(rendered, "\n\n")
+}
+
+// ChunkMarkdownToTelegramHTML splits Markdown on block boundaries into chunks, which render into at most `limit` characters,
+// formatting split in the middle of a block (code, tables, bold etc) is re-opened in the next chunk
+func ChunkMarkdownToTelegramHTML(markdown string, limit int) []MarkdownChunk {
+	pieces := []string{}
+	for _, block := range parseMarkdownBlocks(markdown) {
+		source := strings.Join(block.source, "\n")
+		if telegramLength(renderMarkdownBlock(block)) <= limit {
+			pieces = append(pieces, source)
+			continue
+		}
+		pieces = append(pieces, splitMarkdownBlock(block, limit)...)
+	}
+
+	chunks := []MarkdownChunk{}
+	current := ""
+	currentLength := 0
+	for _, piece := range pieces {
+		pieceLength := telegramLength(MarkdownToTelegramHTML(piece))
+		if current != "" && currentLength+2+pieceLength <= limit {
+			current += "\n\n" + piece
+			currentLength += 2 + pieceLength
+			continue
+		}
+		if current != "" {
+			chunks = append(chunks, MarkdownChunk{Markdown: current, HTML: MarkdownToTelegramHTML(current)})
+		}
+		current = piece
+		currentLength = pieceLength
+	}
+	if current != "" {
+		chunks = append(chunks, MarkdownChunk{Markdown: current, HTML: MarkdownToTelegramHTML(current)})
+	}
+	return chunks
+}
+
+// ChunkTextToTelegramHTML splits plain text on line and word boundaries into chunks, which escape into at most `limit` characters,
+// unlike ChunkMarkdownToTelegramHTML nothing is formatted, so transcripts and documents are shown as they are
+func ChunkTextToTelegramHTML(text string, limit int) []MarkdownChunk {
+	fits := func(s string) bool {
+		return telegramLength(EscapeHTML(s)) <= limit
+	}
+
+	chunks := []MarkdownChunk{}
+	current := ""
+	add := func(piece string, separator string) {
+		if current != "" && fits(current+separator+piece) {
+			current += separator + piece
+			return
+		}
+		if strings.TrimSpace(current) != "" {
+			chunks = append(chunks, MarkdownChunk{Markdown: current, HTML: EscapeHTML(current)})
+		}
+		current = piece
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if fits(line) {
+			add(line, "\n")
+			continue
+		}
+		for i, word := range strings.Fields(line) {
+			separator := " "
+			if i == 0 {
+				separator = "\n"
+			}
+			// escaping takes up to 5 characters per rune, so too long words are split well under the limit
+			for j, part := range splitByLength(word, limit/10) {
+				if j > 0 {
+					separator = ""
+				}
+				add(part, separator)
+			}
+		}
+	}
+	if strings.TrimSpace(current) != "" {
+		chunks = append(chunks, MarkdownChunk{Markdown: current, HTML: EscapeHTML(current)})
+	}
+	return chunks
+}
+
+// telegramLength counts UTF-16 code units, the way Telegram limits message length
+func telegramLength(s string) int {
+	length := 0
+	for _, r := range s {
+		if r > 0xFFFF {
+			length += 2
+		} else {
+			length++
+		}
+	}
+	return length
+}
+
+func parseMarkdownBlocks(markdown string) []markdownBlock {
+	blocks := []markdownBlock{}
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	paragraph := markdownBlock{kind: paragraphBlock}
+	flush := func() {
+		if len(paragraph.source) > 0 {
+			blocks = append(blocks, paragraph)
+		}
+		paragraph = markdownBlock{kind: paragraphBlock}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case fenceRegex.MatchString(line):
+			flush()
+			matches := fenceRegex.FindStringSubmatch(line)
+			fence := matches[1]
+			block := markdownBlock{kind: codeBlock, source: []string{line}, lang: matches[2]}
+			for i++; i < len(lines); i++ {
+				block.source = append(block.source, lines[i])
+				if strings.TrimSpace(lines[i]) == fence {
+					block.closed = true
+					break
+				}
+				block.content = append(block.content, lines[i])
+			}
+			blocks = append(blocks, block)
+		case rawPreOpenRegex.MatchString(line):
+			flush()
+			block := markdownBlock{kind: codeBlock}
+			if matches := languageClassRegex.FindStringSubmatch(line); matches != nil {
+				block.lang = matches[1]
+			}
+			content := rawPreOpenRegex.ReplaceAllString(line, "")
+			for {
+				block.source = append(block.source, lines[i])
+				if rawPreCloseRegex.MatchString(content) {
+					block.content = append(block.content, unescapeHTML(rawPreCloseRegex.ReplaceAllString(content, "")))
+					block.closed = true
+					break
+				}
+				block.content = append(block.content, unescapeHTML(content))
+				if i+1 >= len(lines) {
+					break
+				}
+				i++
+				content = lines[i]
+			}
+			blocks = append(blocks, block)
+		case strings.HasPrefix(strings.TrimSpace(line), "|") && i+1 < len(lines) && tableSeparatorRegex.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-"):
+			flush()
+			block := markdownBlock{kind: tableBlock}
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+				block.source = append(block.source, lines[i])
+			}
+			i--
+			blocks = append(blocks, block)
+		case strings.TrimSpace(line) == "":
+			flush()
+		default:
+			paragraph.source = append(paragraph.source, line)
+		}
+	}
+	flush()
+	return blocks
+}
+
+func renderMarkdownBlock(block markdownBlock) string {
+	switch block.kind {
+	case codeBlock:
+		code := EscapeHTML(strings.Join(block.content, "\n"))
+		if block.lang != "" {
+			return fmt.Sprintf("<pre><code class=\"language-%s\">%s</code></pre>", EscapeHTML(block.lang), code)
+		}
+		return "<pre>" + code + "</pre>"
+	case tableBlock:
+		return "<pre>" + EscapeHTML(renderTable(block.source)) + "</pre>"
+	}
+
+	rendered := []string{}
+	quote := []string{}
+	flushQuote := func() {
+		if len(quote) > 0 {
+			rendered = append(rendered, "<blockquote>"+strings.Join(quote, "\n")+"</blockquote>")
+			quote = []string{}
+		}
+	}
+	for _, line := range block.source {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") {
+			html, _ := renderInline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">")))
+			quote = append(quote, html)
+			continue
+		}
+		flushQuote()
+		rendered = append(rendered, renderMarkdownLine(line))
+	}
+	flushQuote()
+	return strings.Join(rendered, "\n")
+}
+
+func renderMarkdownLine(line string) string {
+	if ruleRegex.MatchString(line) {
+		return "——————"
+	}
+	if matches := headingRegex.FindStringSubmatch(line); matches != nil {
+		html, _ := renderInline(matches[1])
+		return "<b>" + html + "</b>"
+	}
+	if matches := bulletRegex.FindStringSubmatch(line); matches != nil {
+		html, _ := renderInline(matches[2])
+		return matches[1] + "• " + html
+	}
+	if matches := numberedRegex.FindStringSubmatch(line); matches != nil {
+		html, _ := renderInline(matches[3])
+		return matches[1] + matches[2] + ". " + html
+	}
+	html, _ := renderInline(line)
+	return html
+}
+
+// renderTable formats a Markdown table as aligned monospace text
+func renderTable(lines []string) string {
+	rows := [][]string{}
+	for i, line := range lines {
+		if i == 1 && tableSeparatorRegex.MatchString(line) {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		trimmed = strings.TrimPrefix(trimmed, "|")
+		trimmed = strings.TrimSuffix(trimmed, "|")
+		cells := strings.Split(trimmed, "|")
+		for j := range cells {
+			cells[j] = stripInlineMarkdown(strings.TrimSpace(cells[j]))
+		}
+		rows = append(rows, cells)
+	}
+
+	widths := []int{}
+	for _, row := range rows {
+		for j, cell := range row {
+			if j >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if width := utf8.RuneCountInString(cell); width > widths[j] {
+				widths[j] = width
+			}
+		}
+	}
+
+	formatRow := func(row []string) string {
+		cells := make([]string, len(widths))
+		for j := range widths {
+			cell := ""
+			if j < len(row) {
+				cell = row[j]
+			}
+			cells[j] = cell + strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+		}
+		return strings.TrimRight(strings.Join(cells, " | "), " ")
+	}
+
+	result := []string{}
+	for i, row := range rows {
+		result = append(result, formatRow(row))
+		if i == 0 && len(rows) > 1 {
+			separators := make([]string, len(widths))
+			for j, width := range widths {
+				separators[j] = strings.Repeat("-", width)
+			}
+			result = append(result, strings.Join(separators, "-+-"))
+		}
+	}
+	return strings.Join(result, "\n")
+}
+
+func stripInlineMarkdown(text string) string {
+	for _, marker := range []string{"**", "__", "~~", "`"} {
+		text = strings.ReplaceAll(text, marker, "")
+	}
+	return text
+}
+
+// renderInline renders a single line of inline Markdown, returns tags left open at the end of the line (closed in the output)
+func renderInline(line string) (string, []inlineTag) {
+	return renderInlineWithLookahead(line, "")
+}
+
+// renderInlineWithLookahead treats `lookahead` as a continuation of the line when deciding if a marker opens formatting
+func renderInlineWithLookahead(line string, lookahead string) (string, []inlineTag) {
+	var out strings.Builder
+	stack := []inlineTag{}
+	runes := []rune(line)
+
+	indexOf := func(name string, marker string) int {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if (marker != "" && stack[i].marker == marker) || (marker == "" && stack[i].marker == "" && stack[i].name == name) {
+				return i
+			}
+		}
+		return -1
+	}
+	closeTo := func(index int) {
+		reopen := stack[index+1:]
+		for i := len(stack) - 1; i >= index; i-- {
+			out.WriteString("</" + stack[i].name + ">")
+		}
+		stack = append(stack[:index], reopen...)
+		for _, tag := range reopen {
+			out.WriteString(tag.open)
+		}
+	}
+	isSpace := func(i int) bool {
+		return i < 0 || i >= len(runes) || unicode.IsSpace(runes[i])
+	}
+	isWord := func(i int) bool {
+		return i >= 0 && i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]))
+	}
+
+	markers := []struct {
+		marker string
+		name   string
+	}{{"**", "b"}, {"__", "b"}, {"~~", "s"}, {"||", "tg-spoiler"}, {"*", "i"}, {"_", "i"}}
+
+	for i := 0; i < len(runes); {
+		rest := string(runes[i:])
+		r := runes[i]
+
+		// escaped markdown characters
+		if r == '\\' && i+1 < len(runes) && strings.ContainsRune("\\`*_{}[]()#+-.!|~<>&", runes[i+1]) {
+			out.WriteString(EscapeHTML(string(runes[i+1])))
+			i += 2
+			continue
+		}
+
+		if r == '`' {
+			ticks := len(rest) - len(strings.TrimLeft(rest, "`"))
+			fence := strings.Repeat("`", ticks)
+			if end := strings.Index(rest[ticks:], fence); end >= 0 {
+				out.WriteString("<code>" + EscapeHTML(strings.TrimSpace(rest[ticks:ticks+end])) + "</code>")
+				i += utf8.RuneCountInString(rest[:ticks+end+ticks])
+				continue
+			}
+		}
+
+		if r == '[' {
+			if matches := linkRegex.FindStringSubmatch(rest); matches != nil {
+				text, _ := renderInline(matches[1])
+				out.WriteString("<a href=\"" + escapeAttribute(matches[2]) + "\">" + text + "</a>")
+				i += utf8.RuneCountInString(matches[0])
+				continue
+			}
+		}
+
+		if r == '<' {
+			if matches := htmlTagRegex.FindStringSubmatch(rest); matches != nil {
+				name := strings.ToLower(matches[2])
+				length := utf8.RuneCountInString(matches[0])
+				if name == "br" {
+					out.WriteString("\n")
+					i += length
+					continue
+				}
+				if allowedInlineTags[name] {
+					if matches[1] == "/" {
+						if index := indexOf(name, ""); index >= 0 {
+							closeTo(index)
+						}
+						i += length
+						continue
+					}
+					open := "<" + name + ">"
+					switch name {
+					case "a":
+						href := hrefRegex.FindStringSubmatch(matches[3])
+						if href == nil {
+							i += length
+							continue
+						}
+						open = "<a href=\"" + escapeAttribute(href[1]) + "\">"
+					case "span":
+						if !strings.Contains(matches[3], "tg-spoiler") {
+							i += length
+							continue
+						}
+						open = "<span class=\"tg-spoiler\">"
+					}
+					stack = append(stack, inlineTag{name: name, open: open})
+					out.WriteString(open)
+					i += length
+					continue
+				}
+			}
+			out.WriteString("&lt;")
+			i++
+			continue
+		}
+
+		if r == '&' {
+			if matches := entityRegex.FindString(rest); matches != "" {
+				out.WriteString(matches)
+				i += utf8.RuneCountInString(matches)
+				continue
+			}
+			out.WriteString("&amp;")
+			i++
+			continue
+		}
+
+		if r == '>' {
+			out.WriteString("&gt;")
+			i++
+			continue
+		}
+
+		handled := false
+		for _, m := range markers {
+			if !strings.HasPrefix(rest, m.marker) {
+				continue
+			}
+			length := utf8.RuneCountInString(m.marker)
+			isUnderscore := strings.HasPrefix(m.marker, "_")
+			if index := indexOf(m.name, m.marker); index >= 0 {
+				if !isSpace(i-1) && !(isUnderscore && isWord(i+length)) {
+					closeTo(index)
+					i += length
+					handled = true
+				}
+			} else if !isSpace(i+length) && !(isUnderscore && isWord(i-1)) && strings.Contains(string(runes[i+length:])+lookahead, m.marker) {
+				open := "<" + m.name + ">"
+				stack = append(stack, inlineTag{name: m.name, marker: m.marker, open: open})
+				out.WriteString(open)
+				i += length
+				handled = true
+			}
+			break
+		}
+		if handled {
+			continue
+		}
+
+		out.WriteRune(r)
+		i++
+	}
+
+	unclosed := append([]inlineTag{}, stack...)
+	for i := len(stack) - 1; i >= 0; i-- {
+		out.WriteString("</" + stack[i].name + ">")
+	}
+	return out.String(), unclosed
+}
+
+func splitMarkdownBlock(block markdownBlock, limit int) []string {
+	fits := func(source string) bool {
+		return telegramLength(MarkdownToTelegramHTML(source)) <= limit
+	}
+
+	switch block.kind {
+	case codeBlock:
+		open := "```" + block.lang
+		pieces := []string{}
+		current := []string{}
+		for _, line := range block.content {
+			for _, part := range splitByLength(line, limit/2) {
+				candidate := append(append([]string{}, current...), part)
+				if len(current) > 0 && !fits(open+"\n"+strings.Join(candidate, "\n")+"\n```") {
+					pieces = append(pieces, open+"\n"+strings.Join(current, "\n")+"\n```")
+					current = []string{}
+				}
+				current = append(current, part)
+			}
+		}
+		// an unfinished block is kept open, so a streaming response can continue it
+		last := open + "\n" + strings.Join(current, "\n")
+		if block.closed {
+			last += "\n```"
+		}
+		return append(pieces, last)
+	case tableBlock:
+		header := block.source[:2]
+		pieces := []string{}
+		current := append([]string{}, header...)
+		for _, row := range block.source[2:] {
+			candidate := append(append([]string{}, current...), row)
+			if len(current) > len(header) && !fits(strings.Join(candidate, "\n")) {
+				pieces = append(pieces, strings.Join(current, "\n"))
+				current = append([]string{}, header...)
+			}
+			current = append(current, row)
+		}
+		return append(pieces, strings.Join(current, "\n"))
+	}
+
+	pieces := []string{}
+	current := []string{}
+	for _, line := range block.source {
+		if !fits(line) {
+			if len(current) > 0 {
+				pieces = append(pieces, strings.Join(current, "\n"))
+				current = []string{}
+			}
+			pieces = append(pieces, splitLongLine(line, limit)...)
+			continue
+		}
+		candidate := append(append([]string{}, current...), line)
+		if len(current) > 0 && !fits(strings.Join(candidate, "\n")) {
+			pieces = append(pieces, strings.Join(current, "\n"))
+			current = []string{}
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, strings.Join(current, "\n"))
+	}
+	return pieces
+}
+
+// splitLongLine splits a line by words, closing formatting at the end of a piece and re-opening it in the next one
+func splitLongLine(line string, limit int) []string {
+	closeTags := func(source string, lookahead string) (closed string, reopen string) {
+		_, unclosed := renderInlineWithLookahead(source, lookahead)
+		closers := ""
+		openers := ""
+		for i := len(unclosed) - 1; i >= 0; i-- {
+			if unclosed[i].marker != "" {
+				closers += unclosed[i].marker
+			} else {
+				closers += "</" + unclosed[i].name + ">"
+			}
+		}
+		for _, tag := range unclosed {
+			if tag.marker != "" {
+				openers += tag.marker
+			} else {
+				openers += tag.open
+			}
+		}
+		return source + closers, openers
+	}
+	fits := func(source string, lookahead string) bool {
+		closed, _ := closeTags(source, lookahead)
+		html, _ := renderInline(closed)
+		return telegramLength(html) <= limit
+	}
+
+	parts := []string{}
+	for _, word := range strings.Fields(line) {
+		parts = append(parts, splitByLength(word, limit/2)...)
+	}
+
+	pieces := []string{}
+	current := ""
+	for i, part := range parts {
+		if current == "" {
+			current = part
+			continue
+		}
+		lookahead := " " + strings.Join(parts[i:], " ")
+		candidate := current + " " + part
+		if !fits(candidate, " "+strings.Join(parts[i+1:], " ")) {
+			closed, reopen := closeTags(current, lookahead)
+			pieces = append(pieces, closed)
+			candidate = reopen + part
+		}
+		current = candidate
+	}
+	if current != "" {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+func splitByLength(s string, size int) []string {
+	runes := []rune(s)
+	if size <= 0 || len(runes) <= size {
+		return []string{s}
+	}
+	parts := []string{}
+	for len(runes) > size {
+		parts = append(parts, string(runes[:size]))
+		runes = runes[size:]
+	}
+	return append(parts, string(runes))
+}
+
+// EscapeHTML escapes characters Telegram HTML parse mode treats specially
+func EscapeHTML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func escapeAttribute(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;").Replace(s)
+}
+
+func unescapeHTML(s string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", "\"", "&amp;", "&").Replace(s)
+}
@@ -0,0 +1,128 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownToTelegramHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "plain text",
+			markdown: "This is one message of ___ characters.",
+			want:     "This is one message of ___ characters.",
+		},
+		{
+			name:     "inline formatting",
+			markdown: "**bold** and *italic*, _also italic_, ~~strike~~ and `a < b`",
+			want:     "<b>bold</b> and <i>italic</i>, <i>also italic</i>, <s>strike</s> and <code>a &lt; b</code>",
+		},
+		{
+			name:     "stray characters",
+			markdown: "2 * 3 < 7 & snake_case_name > x &amp; y",
+			want:     "2 * 3 &lt; 7 &amp; snake_case_name &gt; x &amp; y",
+		},
+		{
+			name:     "unbalanced tags",
+			markdown: "<b>bold <i>both</b> italic?</i> </u> **never closed",
+			want:     "<b>bold <i>both</i></b><i> italic?</i>  **never closed",
+		},
+		{
+			name:     "links and headings",
+			markdown: "## Title\n[docs](https://example.com/?a=1&b=\"2\")",
+			want:     "<b>Title</b>\n<a href=\"https://example.com/?a=1&amp;b=&quot;2&quot;\">docs</a>",
+		},
+		{
+			name:     "lists and quotes",
+			markdown: "- one\n* two\n1. first\n> quoted **text**",
+			want:     "• one\n• two\n1. first\n<blockquote>quoted <b>text</b></blockquote>",
+		},
+		{
+			name:     "code block",
+			markdown: "Code:\n```go\nif a < b && c {\n}\n```\nDone",
+			want:     "Code:\n\n<pre><code class=\"language-go\">if a &lt; b &amp;&amp; c {\n}</code></pre>\n\nDone",
+		},
+		{
+			name:     "unfinished code block",
+			markdown: "```\nstreaming <code>",
+			want:     "<pre>streaming &lt;code&gt;</pre>",
+		},
+		{
+			name:     "raw pre block",
+			markdown: "<pre language=\"c++\">int a = 1 &lt; 2;\nreturn a;</pre>",
+			want:     "<pre>int a = 1 &lt; 2;\nreturn a;</pre>",
+		},
+		{
+			name:     "table",
+			markdown: "| Name | **Age** |\n|---|---:|\n| Alice | 30 |\n| Bob | 4 |",
+			want:     "<pre>Name  | Age\n------+----\nAlice | 30\nBob   | 4</pre>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MarkdownToTelegramHTML(tt.markdown))
+		})
+	}
+}
+
+func TestChunkMarkdownToTelegramHTML(t *testing.T) {
+	paragraph := strings.Repeat("word ", 30)
+	markdown := paragraph + "\n\n```python\n" + strings.Repeat("print('hello')\n", 20) + "```\n\n**" + strings.Repeat("bold ", 40) + "end**"
+
+	chunks := ChunkMarkdownToTelegramHTML(markdown, 100)
+	assert.Greater(t, len(chunks), 3)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, telegramLength(chunk.HTML), 100)
+	}
+
+	// code block is re-opened in every chunk it spans, bold is re-opened after a split
+	code := "<pre><code class=\"language-python\">" + strings.Repeat("print('hello')\n", 2) + "print('hello')</code></pre>"
+	want := []string{
+		strings.TrimSpace(strings.Repeat("word ", 20)),
+		strings.TrimSpace(strings.Repeat("word ", 10)),
+		code, code, code, code, code, code,
+		"<pre><code class=\"language-python\">print('hello')\nprint('hello')</code></pre>",
+		"<b>" + strings.TrimSpace(strings.Repeat("bold ", 18)) + "</b>",
+		"<b>" + strings.TrimSpace(strings.Repeat("bold ", 18)) + "</b>",
+		"<b>" + strings.Repeat("bold ", 4) + "end</b>",
+	}
+	html := []string{}
+	for _, chunk := range chunks {
+		html = append(html, chunk.HTML)
+	}
+	assert.Equal(t, want, html)
+
+	// short text is a single chunk
+	chunks = ChunkMarkdownToTelegramHTML("just *a* message", TELEGRAM_MESSAGE_LIMIT)
+	assert.Equal(t, []MarkdownChunk{{Markdown: "just *a* message", HTML: "just <i>a</i> message"}}, chunks)
+}
+
+func TestChunkTextToTelegramHTML(t *testing.T) {
+	// nothing is formatted, only escaped
+	chunks := ChunkTextToTelegramHTML("**not bold** <b>a & b</b>\n```go", TELEGRAM_MESSAGE_LIMIT)
+	assert.Equal(t, []MarkdownChunk{{Markdown: "**not bold** <b>a & b</b>\n```go", HTML: "**not bold** &lt;b&gt;a &amp; b&lt;/b&gt;\n```go"}}, chunks)
+
+	// lines are kept together, long lines are split by words
+	text := strings.Repeat("a<b ", 30) + "\nshort line\n" + strings.Repeat("x", 25)
+	chunks = ChunkTextToTelegramHTML(text, 50)
+	html := []string{}
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, telegramLength(chunk.HTML), 50)
+		html = append(html, chunk.HTML)
+	}
+	assert.Equal(t, []string{
+		strings.TrimSpace(strings.Repeat("a&lt;b ", 7)),
+		strings.TrimSpace(strings.Repeat("a&lt;b ", 7)),
+		strings.TrimSpace(strings.Repeat("a&lt;b ", 7)),
+		strings.TrimSpace(strings.Repeat("a&lt;b ", 7)),
+		"a&lt;b a&lt;b\nshort line\n" + strings.Repeat("x", 25),
+	}, html)
+
+	assert.Empty(t, ChunkTextToTelegramHTML("\n\n", TELEGRAM_MESSAGE_LIMIT))
+}
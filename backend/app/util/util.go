@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/documents"
 	"talk2robots/m/v2/app/models"
 
 	"github.com/mymmrac/telego"
@@ -127,9 +128,9 @@ func IsAudioMessage(message *telego.Message) (bool, string) {
 		case message.Document != nil:
 			voice_type = "document"
 
-			if !strings.HasPrefix(message.Document.MimeType, "audio/") && !strings.HasPrefix(message.Document.MimeType, "video/") {
+			if documents.DetectKind(message.Document.MimeType, message.Document.FileName) != documents.KindMedia {
 				chatIDString := GetChatIDString(message)
-				log.Warnf("Ignoring non-audio document message in chat %s, mimetype: %s", chatIDString, message.Document.MimeType)
+				log.Debugf("Non-media document message in chat %s is read as text, mimetype: %s", chatIDString, message.Document.MimeType)
 				return false, ""
 			}
 		}
@@ -138,6 +139,11 @@ func IsAudioMessage(message *telego.Message) (bool, string) {
 	return false, ""
 }
 
+// IsDocumentMessage is true for documents that are read as text, i.e. everything but media
+func IsDocumentMessage(message *telego.Message) bool {
+	return message.Document != nil && documents.DetectKind(message.Document.MimeType, message.Document.FileName) != documents.KindMedia
+}
+
 func SafeOsDelete(filename string) {
 	// test file does not exist
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
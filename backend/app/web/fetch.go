@@ -0,0 +1,304 @@
+// Package web fetches pages shared in chats safely and extracts their readable text
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"talk2robots/m/v2/app/documents"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	USER_AGENT_TOKEN = "talk2robots"
+	USER_AGENT       = "Mozilla/5.0 (compatible; talk2robots/1.0; +https://github.com/radiantspace/talk2robots)"
+
+	// FETCH_TIMEOUT covers the whole request including redirects and reading the body
+	FETCH_TIMEOUT = 15 * time.Second
+
+	// MAX_PAGE_BYTES caps downloaded bodies, the rest of a page is ignored
+	MAX_PAGE_BYTES = 3 * 1024 * 1024
+
+	// MAX_PAGE_TEXT caps extracted text in characters, about 5k tokens
+	MAX_PAGE_TEXT = 20000
+
+	MAX_REDIRECTS = 5
+)
+
+var (
+	ErrBlockedAddress     = errors.New("address is not public")
+	ErrUnsupportedScheme  = errors.New("only http and https links are supported")
+	ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+	ErrUnsupportedContent = errors.New("unsupported content type")
+	ErrNoText             = errors.New("no readable text")
+)
+
+// Page is the readable content of a fetched link
+type Page struct {
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	Text      string    `json:"text"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+type Fetcher struct {
+	client *http.Client
+	robots *robotsCache
+
+	// allowPrivate disables SSRF protection, for tests against local servers only
+	allowPrivate bool
+}
+
+func NewFetcher() *Fetcher {
+	fetcher := &Fetcher{robots: newRobotsCache()}
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		// checked on connect, after DNS resolution, so rebinding a public name to a private address doesn't help
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if fetcher.allowPrivate {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if IsBlockedIP(net.ParseIP(host)) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+	fetcher.client = &http.Client{
+		Timeout: FETCH_TIMEOUT,
+		Transport: &http.Transport{
+			// no proxy from the environment, it would connect to a private address on our behalf
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= MAX_REDIRECTS {
+				return fmt.Errorf("stopped after %d redirects", MAX_REDIRECTS)
+			}
+			if request.URL.Scheme != "http" && request.URL.Scheme != "https" {
+				return ErrUnsupportedScheme
+			}
+			return nil
+		},
+	}
+	return fetcher
+}
+
+var blockedNetworks = mustParseNetworks(
+	"0.0.0.0/8",     // this network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64, may map to private IPv4
+)
+
+func mustParseNetworks(cidrs ...string) []*net.IPNet {
+	networks := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// IsBlockedIP is true for loopback, private, link-local (incl. cloud metadata), multicast and other non-public addresses
+func IsBlockedIP(ip net.IP) bool {
+	if ip == nil {
+		return true
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var urlRegex = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'` + "`" + `]+`)
+
+// ExtractURLs finds up to max distinct http(s) links in the text, trailing punctuation is not part of a link
+func ExtractURLs(text string, max int) []string {
+	urls := []string{}
+	seen := map[string]bool{}
+	for _, match := range urlRegex.FindAllString(text, -1) {
+		match = trimURLPunctuation(match)
+		if seen[match] {
+			continue
+		}
+		if _, err := url.ParseRequestURI(match); err != nil {
+			continue
+		}
+		seen[match] = true
+		urls = append(urls, match)
+		if len(urls) == max {
+			break
+		}
+	}
+	return urls
+}
+
+func trimURLPunctuation(link string) string {
+	for {
+		trimmed := strings.TrimRight(link, ".,;:!?*_~")
+		// closing brackets are kept if they are balanced, e.g. wikipedia links
+		for _, pair := range []string{"()", "[]"} {
+			if strings.HasSuffix(trimmed, pair[1:]) && strings.Count(trimmed, pair[1:]) > strings.Count(trimmed, pair[:1]) {
+				trimmed = trimmed[:len(trimmed)-1]
+			}
+		}
+		if trimmed == link {
+			return link
+		}
+		link = trimmed
+	}
+}
+
+// Fetch downloads a public page, honouring robots.txt, and extracts its readable text.
+// HTML, plain text and PDF are supported.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	pageURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid link %s: %w", rawURL, err)
+	}
+	if pageURL.Scheme != "http" && pageURL.Scheme != "https" {
+		return nil, ErrUnsupportedScheme
+	}
+	if !f.robots.allowed(ctx, f, pageURL) {
+		return nil, ErrDisallowedByRobots
+	}
+
+	response, err := f.get(ctx, pageURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s, status: %s", rawURL, response.Status)
+	}
+
+	page := &Page{URL: response.Request.URL.String(), FetchedAt: time.Now().UTC()}
+	contentType := response.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	body := io.LimitReader(response.Body, MAX_PAGE_BYTES)
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml" || mediaType == "":
+		reader, err := charset.NewReader(body, contentType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", rawURL, err)
+		}
+		page.Title, page.Text, err = ExtractReadableText(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", rawURL, err)
+		}
+	case strings.HasPrefix(mediaType, "text/"):
+		reader, err := charset.NewReader(body, contentType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", rawURL, err)
+		}
+		text, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", rawURL, err)
+		}
+		page.Text = strings.TrimSpace(string(text))
+	case mediaType == "application/pdf":
+		page.Text, err = extractPDF(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pdf %s: %w", rawURL, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContent, mediaType)
+	}
+
+	page.Text = TruncateText(page.Text, MAX_PAGE_TEXT)
+	if page.Text == "" {
+		return nil, ErrNoText
+	}
+	return page, nil
+}
+
+func (f *Fetcher) get(ctx context.Context, link string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", USER_AGENT)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,application/pdf;q=0.8,*/*;q=0.5")
+	response, err := f.client.Do(request)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) {
+			return nil, ErrBlockedAddress
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+// extractPDF reuses document extraction, pdftotext needs a file
+func extractPDF(body io.Reader) (string, error) {
+	file, err := os.CreateTemp("", "link-*.pdf")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if _, err = io.Copy(file, body); err != nil {
+		return "", err
+	}
+	document, err := documents.Extract(file.Name(), file.Name(), documents.KindPDF)
+	if err != nil {
+		return "", err
+	}
+	chunks, _ := documents.Chunks(document, MAX_PAGE_TEXT, MAX_PAGE_TEXT)
+	texts := []string{}
+	for _, chunk := range chunks {
+		texts = append(texts, chunk.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// TruncateText cuts the text to max bytes at a line or word boundary
+func TruncateText(text string, max int) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= max {
+		return text
+	}
+	cut := max
+	for cut > 0 && text[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	if newline := strings.LastIndexByte(text[:cut], '\n'); newline > max/2 {
+		cut = newline
+	} else if space := strings.LastIndexByte(text[:cut], ' '); space > max/2 {
+		cut = space
+	}
+	return strings.TrimSpace(text[:cut]) + "\n…"
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractURLs(t *testing.T) {
+	text := "look at https://example.com/a?b=1, and (https://en.wikipedia.org/wiki/Go_(language)) plus http://example.com/b. Again https://example.com/a?b=1"
+	assert.Equal(t, []string{
+		"https://example.com/a?b=1",
+		"https://en.wikipedia.org/wiki/Go_(language)",
+		"http://example.com/b",
+	}, ExtractURLs(text, 5))
+
+	assert.Equal(t, []string{"https://example.com/a?b=1"}, ExtractURLs(text, 1))
+	assert.Empty(t, ExtractURLs("ftp://example.com and example.com", 3))
+}
+
+func TestIsBlockedIP(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "0.0.0.0", "100.64.0.1", "::1", "fd00::1", "fe80::1", "::ffff:127.0.0.1", "224.0.0.1"} {
+		assert.True(t, IsBlockedIP(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"8.8.8.8", "93.184.216.34", "2606:4700:4700::1111"} {
+		assert.False(t, IsBlockedIP(net.ParseIP(ip)), ip)
+	}
+	assert.True(t, IsBlockedIP(nil))
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	_, err := NewFetcher().Fetch(context.Background(), server.URL+"/metadata")
+	assert.True(t, errors.Is(err, ErrBlockedAddress), "unexpected error: %v", err)
+
+	_, err = NewFetcher().Fetch(context.Background(), "file:///etc/passwd")
+	assert.Equal(t, ErrUnsupportedScheme, err)
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><title>An article</title></head><body><nav>Menu</nav><article><h1>Heading</h1><p>First paragraph.</p></article></body></html>`))
+		case "/notes.txt":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("  plain notes  "))
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G'})
+		case "/moved":
+			http.Redirect(w, r, "/article", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	fetcher := NewFetcher()
+	fetcher.allowPrivate = true
+
+	page, err := fetcher.Fetch(context.Background(), server.URL+"/moved")
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/article", page.URL)
+	assert.Equal(t, "An article", page.Title)
+	assert.Equal(t, "Heading\nFirst paragraph.", page.Text)
+
+	page, err = fetcher.Fetch(context.Background(), server.URL+"/notes.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain notes", page.Text)
+
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/private/page")
+	assert.Equal(t, ErrDisallowedByRobots, err)
+
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/image.png")
+	assert.True(t, errors.Is(err, ErrUnsupportedContent))
+
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/missing")
+	assert.Error(t, err)
+}
+
+func TestTruncateText(t *testing.T) {
+	assert.Equal(t, "short", TruncateText(" short ", 10))
+	assert.Equal(t, "first line\n…", TruncateText("first line\nsecond line", 15))
+	truncated := TruncateText(strings.Repeat("яблоко ", 10), 15)
+	assert.Equal(t, "яблоко\n…", truncated)
+}
@@ -0,0 +1,163 @@
+package web
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skippedElements never contain the main text of a page
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Svg: true, atom.Iframe: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Form: true, atom.Button: true, atom.Select: true,
+}
+
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true, atom.Br: true, atom.Li: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Pre: true, atom.Blockquote: true,
+	atom.Tr: true, atom.Table: true, atom.Ul: true, atom.Ol: true, atom.Dd: true, atom.Dt: true, atom.Figcaption: true,
+}
+
+// ExtractReadableText parses HTML and returns the page title and the text of its main content:
+// <article> or <main> if present, otherwise the element holding most of the paragraph text
+func ExtractReadableText(reader io.Reader) (title string, text string, err error) {
+	document, err := html.Parse(reader)
+	if err != nil {
+		return "", "", err
+	}
+	title = findTitle(document)
+
+	content := findFirst(document, atom.Article)
+	if content == nil {
+		content = findFirst(document, atom.Main)
+	}
+	if content == nil {
+		content = bestParagraphParent(document)
+	}
+	if content == nil {
+		content = findFirst(document, atom.Body)
+	}
+	if content == nil {
+		content = document
+	}
+	return title, renderText(content), nil
+}
+
+func findTitle(document *html.Node) string {
+	ogTitle, title := "", ""
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			switch node.DataAtom {
+			case atom.Meta:
+				if getAttribute(node, "property") == "og:title" && ogTitle == "" {
+					ogTitle = strings.TrimSpace(getAttribute(node, "content"))
+				}
+			case atom.Title:
+				if title == "" && node.FirstChild != nil {
+					title = strings.TrimSpace(node.FirstChild.Data)
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(document)
+	if ogTitle != "" {
+		return ogTitle
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
+func findFirst(node *html.Node, element atom.Atom) *html.Node {
+	if node.Type == html.ElementNode && node.DataAtom == element {
+		return node
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if found := findFirst(child, element); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// bestParagraphParent scores parents by the length of their direct <p> children text
+func bestParagraphParent(document *html.Node) *html.Node {
+	scores := map[*html.Node]int{}
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode && skippedElements[node.DataAtom] {
+			return
+		}
+		if node.Type == html.ElementNode && node.DataAtom == atom.P && node.Parent != nil {
+			scores[node.Parent] += len(strings.TrimSpace(renderText(node)))
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(document)
+
+	var best *html.Node
+	for node, score := range scores {
+		if best == nil || score > scores[best] {
+			best = node
+		}
+	}
+	return best
+}
+
+// renderText flattens text of the node, block elements start new lines
+func renderText(node *html.Node) string {
+	var builder strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			builder.WriteString(node.Data)
+			return
+		case html.ElementNode:
+			if skippedElements[node.DataAtom] {
+				return
+			}
+			if node.DataAtom == atom.Img {
+				if alt := strings.TrimSpace(getAttribute(node, "alt")); alt != "" {
+					builder.WriteString(" [" + alt + "] ")
+				}
+				return
+			}
+		}
+		block := node.Type == html.ElementNode && blockElements[node.DataAtom]
+		if block {
+			builder.WriteString("\n")
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if block {
+			builder.WriteString("\n")
+		}
+	}
+	walk(node)
+
+	lines := []string{}
+	for _, line := range strings.Split(builder.String(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func getAttribute(node *html.Node, name string) string {
+	for _, attribute := range node.Attr {
+		if attribute.Key == name {
+			return attribute.Val
+		}
+	}
+	return ""
+}
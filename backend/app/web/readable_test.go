@@ -0,0 +1,35 @@
+package web
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractReadableText(t *testing.T) {
+	page := `<html><head><title>
+		Fallback title
+	</title><meta property="og:title" content="Real title"><script>var x = 1;</script></head>
+	<body>
+		<header>Site header</header>
+		<nav><a href="/">Home</a></nav>
+		<div class="sidebar"><p>Short ad</p></div>
+		<div class="content">
+			<p>This is the <b>first</b> paragraph of the story.</p>
+			<p>And the second one, which is a bit longer than the first.</p>
+			<img src="chart.png" alt="Chart of sales">
+		</div>
+		<footer>Copyright</footer>
+	</body></html>`
+	title, text, err := ExtractReadableText(strings.NewReader(page))
+	assert.NoError(t, err)
+	assert.Equal(t, "Real title", title)
+	assert.Equal(t, "This is the first paragraph of the story.\nAnd the second one, which is a bit longer than the first.\n[Chart of sales]", text)
+
+	page = `<html><head><title>Main page</title></head><body><main><h2>Title</h2><ul><li>one</li><li>two</li></ul></main><aside>Related</aside></body></html>`
+	title, text, err = ExtractReadableText(strings.NewReader(page))
+	assert.NoError(t, err)
+	assert.Equal(t, "Main page", title)
+	assert.Equal(t, "Title\none\ntwo", text)
+}
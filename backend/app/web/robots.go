@@ -0,0 +1,172 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ROBOTS_TTL      = 6 * time.Hour
+	MAX_ROBOTS_SIZE = 512 * 1024
+
+	// MAX_ROBOTS_HOSTS bounds the cache, links may point to any number of hosts
+	MAX_ROBOTS_HOSTS = 1000
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	regex   *regexp.Regexp
+}
+
+// Robots are the rules of a robots.txt group matching our user agent
+type Robots struct {
+	rules []robotsRule
+}
+
+// ParseRobots keeps rules of the group for the agent, or of the * group if there is no specific one
+func ParseRobots(body io.Reader, agent string) *Robots {
+	agent = strings.ToLower(agent)
+	specific, wildcard := []robotsRule{}, []robotsRule{}
+	hasSpecific := false
+	groupAgents := []string{}
+	inRules := false
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			// consecutive user-agent lines share a group
+			if inRules {
+				groupAgents = []string{}
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				// empty disallow allows everything
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value, regex: robotsPattern(value)}
+			for _, groupAgent := range groupAgents {
+				if groupAgent == "*" {
+					wildcard = append(wildcard, rule)
+				} else if strings.Contains(agent, groupAgent) {
+					specific = append(specific, rule)
+					hasSpecific = true
+				}
+			}
+		}
+	}
+	if hasSpecific {
+		return &Robots{rules: specific}
+	}
+	return &Robots{rules: wildcard}
+}
+
+// robotsPattern supports * wildcards and $ end anchors
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	expression := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expression += "$"
+	}
+	return regexp.MustCompile(expression)
+}
+
+// Allowed applies the longest matching rule, allow wins ties
+func (r *Robots) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !rule.regex.MatchString(path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			allowed, longest = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+type robotsEntry struct {
+	robots    *Robots
+	expiresAt time.Time
+}
+
+type robotsCache struct {
+	mutex   sync.Mutex
+	entries map[string]robotsEntry
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{entries: map[string]robotsEntry{}}
+}
+
+// allowed fetches robots.txt once per host, a missing or unreachable robots.txt allows everything
+func (c *robotsCache) allowed(ctx context.Context, fetcher *Fetcher, pageURL *url.URL) bool {
+	host := pageURL.Scheme + "://" + pageURL.Host
+	c.mutex.Lock()
+	entry, found := c.entries[host]
+	c.mutex.Unlock()
+	if !found || time.Now().After(entry.expiresAt) {
+		entry = robotsEntry{robots: fetchRobots(ctx, fetcher, host), expiresAt: time.Now().Add(ROBOTS_TTL)}
+		c.put(host, entry)
+	}
+	return entry.robots.Allowed(pageURL.EscapedPath())
+}
+
+// put drops expired entries once the cache is full, then the ones expiring first
+func (c *robotsCache) put(host string, entry robotsEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, found := c.entries[host]; !found && len(c.entries) >= MAX_ROBOTS_HOSTS {
+		now := time.Now()
+		for cachedHost, cachedEntry := range c.entries {
+			if now.After(cachedEntry.expiresAt) {
+				delete(c.entries, cachedHost)
+			}
+		}
+		for len(c.entries) >= MAX_ROBOTS_HOSTS {
+			oldestHost, oldestExpiresAt := "", time.Time{}
+			for cachedHost, cachedEntry := range c.entries {
+				if oldestHost == "" || cachedEntry.expiresAt.Before(oldestExpiresAt) {
+					oldestHost, oldestExpiresAt = cachedHost, cachedEntry.expiresAt
+				}
+			}
+			delete(c.entries, oldestHost)
+		}
+	}
+	c.entries[host] = entry
+}
+
+func fetchRobots(ctx context.Context, fetcher *Fetcher, host string) *Robots {
+	response, err := fetcher.get(ctx, host+"/robots.txt")
+	if err != nil {
+		return &Robots{}
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return &Robots{}
+	}
+	return ParseRobots(io.LimitReader(response.Body, MAX_ROBOTS_SIZE), USER_AGENT_TOKEN)
+}
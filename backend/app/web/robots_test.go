@@ -0,0 +1,69 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRobots(t *testing.T) {
+	body := `
+# comment
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+
+User-agent: otherbot
+Disallow: /
+`
+	robots := ParseRobots(strings.NewReader(body), USER_AGENT_TOKEN)
+	assert.True(t, robots.Allowed("/"))
+	assert.True(t, robots.Allowed("/article"))
+	assert.False(t, robots.Allowed("/private/page"))
+	assert.True(t, robots.Allowed("/private/public/page"))
+	assert.False(t, robots.Allowed("/files/doc.pdf"))
+	assert.True(t, robots.Allowed("/files/doc.pdf.html"))
+
+	// a group for our agent replaces the * group
+	body = `
+User-agent: Googlebot
+User-agent: talk2robots
+Disallow: /search
+Allow: /search/about
+
+User-agent: *
+Disallow: /
+`
+	robots = ParseRobots(strings.NewReader(body), USER_AGENT_TOKEN)
+	assert.True(t, robots.Allowed("/article"))
+	assert.False(t, robots.Allowed("/search?q=go"))
+	assert.True(t, robots.Allowed("/search/about"))
+
+	robots = ParseRobots(strings.NewReader("User-agent: *\nDisallow:\n"), USER_AGENT_TOKEN)
+	assert.True(t, robots.Allowed("/anything"))
+}
+
+func TestRobotsCacheIsBounded(t *testing.T) {
+	cache := newRobotsCache()
+	now := time.Now()
+	cache.put("https://expired.example", robotsEntry{robots: &Robots{}, expiresAt: now.Add(-time.Minute)})
+	for i := 1; i < MAX_ROBOTS_HOSTS; i++ {
+		cache.put(fmt.Sprintf("https://%d.example", i), robotsEntry{robots: &Robots{}, expiresAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+	assert.Len(t, cache.entries, MAX_ROBOTS_HOSTS)
+
+	// expired entries go first
+	cache.put("https://new.example", robotsEntry{robots: &Robots{}, expiresAt: now.Add(ROBOTS_TTL)})
+	assert.Len(t, cache.entries, MAX_ROBOTS_HOSTS)
+	assert.NotContains(t, cache.entries, "https://expired.example")
+
+	// then the ones expiring first
+	cache.put("https://newer.example", robotsEntry{robots: &Robots{}, expiresAt: now.Add(ROBOTS_TTL)})
+	assert.Len(t, cache.entries, MAX_ROBOTS_HOSTS)
+	assert.NotContains(t, cache.entries, "https://1.example")
+	assert.Contains(t, cache.entries, "https://new.example")
+}
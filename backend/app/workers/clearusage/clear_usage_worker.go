@@ -7,6 +7,7 @@ import (
 	"talk2robots/m/v2/app/db/redis"
 	"talk2robots/m/v2/app/lib"
 	"talk2robots/m/v2/app/workers"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -18,6 +19,13 @@ func Run() {
 	clearByWildcard(lib.UserTotalAudioMinutesKey("*"))
 	clearByWildcard(lib.UserTotalTokensKey("*"))
 	clearByWildcard(lib.UserTotalImagesKey("*"))
+	clearByWildcard(lib.UserTotalSearchesKey("*"))
+
+	// counters rebuilt from the usage ledger sum events since then
+	err := redis.RedisClient.Set(context.Background(), lib.UsagePeriodStartKey, time.Now().UTC().Format(time.RFC3339), 0).Err()
+	if err != nil {
+		log.Errorf("failed to save usage period start: %s", err)
+	}
 	log.Info("finished usage clearing")
 }
 
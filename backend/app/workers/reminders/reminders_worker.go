@@ -0,0 +1,101 @@
+// Run every 30 seconds to fire due reminders and scheduled prompts
+package reminders
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/mongo"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/models"
+	"talk2robots/m/v2/app/telegram"
+	"talk2robots/m/v2/app/workers"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// LEASE is how long a claimed reminder is hidden from other pods, it's fired again after that if the pod died
+	LEASE = 10 * time.Minute
+
+	// MAX_REMINDERS_PER_RUN caps a single run, the rest is picked up on the next tick
+	MAX_REMINDERS_PER_RUN = 100
+
+	// MAX_PARALLEL_REMINDERS limits concurrent AI prompts
+	MAX_PARALLEL_REMINDERS = 10
+)
+
+var WORKER *workers.Worker
+
+func Run() {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, MAX_PARALLEL_REMINDERS)
+	fired := 0
+	for ; fired < MAX_REMINDERS_PER_RUN; fired++ {
+		now := time.Now()
+		reminder, err := mongo.MongoDBClient.ClaimDueReminder(context.Background(), now, LEASE)
+		if err != nil {
+			log.Errorf("[reminders] failed to claim due reminder: %v", err)
+			break
+		}
+		if reminder == nil {
+			break
+		}
+
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(reminder models.MongoReminder) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			fire(reminder, now)
+		}(*reminder)
+	}
+	wg.Wait()
+	if fired > 0 {
+		log.Infof("[reminders] fired %d reminders", fired)
+	}
+}
+
+func fire(reminder models.MongoReminder, now time.Time) {
+	var err error
+	switch reminder.Client {
+	case string(lib.TelegramClientName):
+		err = telegram.FireReminder(reminder)
+	default:
+		log.Errorf("[reminders] unsupported client %s for reminder %s", reminder.Client, reminder.ID)
+	}
+	tags := []string{"client:" + reminder.Client, "recurring:" + strconv.FormatBool(reminder.Cron != "")}
+	if err != nil {
+		log.Errorf("[reminders] failed to fire reminder %s in chat %s: %v", reminder.ID, reminder.UserId, err)
+		config.CONFIG.DataDogClient.Incr("reminders_worker.failed", tags, 1)
+	} else {
+		config.CONFIG.DataDogClient.Incr("reminders_worker.fired", tags, 1)
+	}
+
+	ctx := context.WithValue(context.Background(), models.UserContext{}, reminder.UserId)
+	if reminder.Cron == "" {
+		err = mongo.MongoDBClient.DeleteReminder(ctx, reminder.ID)
+		if err != nil {
+			log.Errorf("[reminders] failed to delete fired reminder %s: %v", reminder.ID, err)
+		}
+		return
+	}
+
+	// missed runs, e.g. during downtime, are skipped rather than fired all at once
+	location, _, err := lib.ParseTimeZone(reminder.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	next := lib.ReminderSchedule{Cron: reminder.Cron}.Next(now.In(location))
+	if next.IsZero() {
+		log.Warnf("[reminders] reminder %s (%s) never runs again, deleting", reminder.ID, reminder.Cron)
+		mongo.MongoDBClient.DeleteReminder(ctx, reminder.ID)
+		return
+	}
+	err = mongo.MongoDBClient.RescheduleReminder(ctx, reminder.ID, next, now)
+	if err != nil {
+		log.Errorf("[reminders] failed to reschedule reminder %s: %v", reminder.ID, err)
+	}
+}
@@ -0,0 +1,29 @@
+// Run every 10 seconds to move billed usage events from the redis outbox to the usage ledger in mongo
+package usageledger
+
+import (
+	"context"
+	"talk2robots/m/v2/app/config"
+	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/lib"
+	"talk2robots/m/v2/app/payments"
+	"talk2robots/m/v2/app/workers"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var WORKER *workers.Worker
+
+func Run() {
+	flushed, err := payments.FlushUsageOutbox(context.Background())
+	if err != nil {
+		log.Errorf("[usageledger] failed to save usage events, %d saved: %v", flushed, err)
+		config.CONFIG.DataDogClient.Incr("usage_ledger_worker.failed", nil, 1)
+	}
+	if flushed > 0 {
+		log.Debugf("[usageledger] saved %d usage events", flushed)
+		config.CONFIG.DataDogClient.Count("usage_ledger_worker.events", int64(flushed), nil, 1)
+	}
+	pending, _ := redis.RedisClient.LLen(context.Background(), lib.UsageOutboxKey).Result()
+	config.CONFIG.DataDogClient.Gauge("usage_ledger_worker.outbox", float64(pending), nil, 1)
+}
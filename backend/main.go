@@ -10,14 +10,18 @@ import (
 	"talk2robots/m/v2/app/config"
 	"talk2robots/m/v2/app/db/mongo"
 	"talk2robots/m/v2/app/db/redis"
+	"talk2robots/m/v2/app/i18n"
 	"talk2robots/m/v2/app/payments"
 	"talk2robots/m/v2/app/slack"
+	"talk2robots/m/v2/app/storage"
 	"talk2robots/m/v2/app/telegram"
 	"talk2robots/m/v2/app/util"
 	"talk2robots/m/v2/app/workers"
 	"talk2robots/m/v2/app/workers/clearusage"
 	"talk2robots/m/v2/app/workers/onstart"
+	"talk2robots/m/v2/app/workers/reminders"
 	"talk2robots/m/v2/app/workers/status"
+	"talk2robots/m/v2/app/workers/usageledger"
 	"time"
 
 	"github.com/DataDog/datadog-go/v5/statsd"
@@ -47,6 +51,22 @@ func main() {
 		FireworksAPIKey: util.Env("FIREWORKS_API_KEY"),
 		ClaudeAPIKey:    util.Env("CLAUDE_API_KEY"),
 		GrokAPIKey:      util.Env("GROK_API_KEY"),
+		LocalesDir:      util.Env("LOCALES_DIR", ""),
+		// web search, the first provider with credentials is used unless SEARCH_PROVIDER is set
+		SearchProvider:    util.Env("SEARCH_PROVIDER", ""),
+		PerplexityAPIKey:  util.Env("PERPLEXITY_API_KEY", ""),
+		BraveSearchAPIKey: util.Env("BRAVE_SEARCH_API_KEY", ""),
+		SearXNGURL:        util.Env("SEARXNG_URL", ""),
+		// Midjourney has no public API, images are generated through a midjourney-proxy deployment when set
+		MidjourneyProxyURL:    util.Env("MIDJOURNEY_PROXY_URL", ""),
+		MidjourneyProxySecret: util.Env("MIDJOURNEY_PROXY_SECRET", ""),
+		// generated images are kept in an S3 compatible bucket when S3_BUCKET is set, on the data volume otherwise
+		BlobStoreDir:      util.Env("BLOB_STORE_DIR", ""),
+		S3Endpoint:        util.Env("S3_ENDPOINT", ""),
+		S3Region:          util.Env("S3_REGION", ""),
+		S3Bucket:          util.Env("S3_BUCKET", ""),
+		S3AccessKeyID:     util.Env("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: util.Env("S3_SECRET_ACCESS_KEY", ""),
 		Redis: config.Redis{
 			Host:     util.Env("REDIS_HOST"),
 			Port:     "6379",
@@ -82,8 +102,17 @@ func main() {
 		log.SetLevel(log.TraceLevel)
 	}
 
+	err = i18n.LoadDir(config.CONFIG.LocalesDir)
+	if err != nil {
+		log.Errorf("error loading translations: %v", err)
+	}
+
 	redis.RedisClient = redis.NewClient(config.CONFIG.Redis)
 	mongo.MongoDBClient = mongo.NewClient(config.CONFIG.MongoDBConnection)
+	storage.BlobStoreClient = storage.NewBlobStore(config.CONFIG)
+	if !storage.Enabled() {
+		log.Warn("S3_BUCKET and BLOB_STORE_DIR are not set, generated images won't be kept and /images is disabled")
+	}
 
 	// create and setup main telegram bot
 	var telegramBot *telegram.Bot
@@ -150,7 +179,15 @@ func main() {
 	clearusage.WORKER = workers.NewWorker(telegramBot.API, systemBot.Bot, config.CONFIG, time.Hour*23, clearusage.Run, true)
 	go clearusage.WORKER.Start()
 
-	go TearDown(sigs, done, slackBot, telegramBot, systemBot, status.WORKER, clearusage.WORKER)
+	// create reminders worker
+	reminders.WORKER = workers.NewWorker(telegramBot.API, systemBot.Bot, config.CONFIG, time.Second*30, reminders.Run, false)
+	go reminders.WORKER.Start()
+
+	// create usage ledger worker
+	usageledger.WORKER = workers.NewWorker(telegramBot.API, systemBot.Bot, config.CONFIG, time.Second*10, usageledger.Run, false)
+	go usageledger.WORKER.Start()
+
+	go TearDown(sigs, done, slackBot, telegramBot, systemBot, status.WORKER, clearusage.WORKER, reminders.WORKER, usageledger.WORKER)
 
 	telegramBot.Server.Handler = fasthttp.TimeoutHandler(func(ctx *fasthttp.RequestCtx) {
 		switch string(ctx.Path()) {
@@ -179,7 +216,7 @@ func main() {
 	log.Info("Done")
 }
 
-func TearDown(sigs chan os.Signal, done chan struct{}, slackBot *slack.Bot, telegramBot *telegram.Bot, systemBot *telegram.Bot, statusWorker *workers.Worker, clearUsageWorker *workers.Worker) {
+func TearDown(sigs chan os.Signal, done chan struct{}, slackBot *slack.Bot, telegramBot *telegram.Bot, systemBot *telegram.Bot, statusWorker *workers.Worker, clearUsageWorker *workers.Worker, remindersWorker *workers.Worker, usageLedgerWorker *workers.Worker) {
 	<-sigs
 	exitMessage := fmt.Sprintf("🤖 %s bids farewell ❌ inside %s", config.CONFIG.BotName, util.Env("POD_NAME", "unknown"))
 	log.Info(exitMessage)
@@ -187,6 +224,8 @@ func TearDown(sigs chan os.Signal, done chan struct{}, slackBot *slack.Bot, tele
 	systemBot.Bot.SendMessage(context.Background(), tu.Message(tu.ID(chatId), exitMessage))
 	statusWorker.StopWorker()
 	clearUsageWorker.StopWorker()
+	remindersWorker.StopWorker()
+	usageLedgerWorker.StopWorker()
 	err := telegramBot.BotHandler.Stop()
 	if err != nil {
 		log.Errorf("TearDown: BotHandler.Stop for bot: %v", err)
@@ -204,6 +243,9 @@ func TearDown(sigs chan os.Signal, done chan struct{}, slackBot *slack.Bot, tele
 		log.Errorf("TearDown: Stop for system bot: %v", err)
 	}
 
+	// save usage events billed while the bots were stopping
+	usageledger.Run()
+
 	err = mongo.MongoDBClient.Disconnect(context.Background())
 	if err != nil {
 		log.Errorf("TearDown: Disconnecting from MongoDB: %v", err)